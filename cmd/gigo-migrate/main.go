@@ -0,0 +1,194 @@
+// gigo-migrate manages the schema of a Gigo TiDB database using the
+// migrations embedded in the ti package.
+//
+//	gigo-migrate status
+//	gigo-migrate up [version]
+//	gigo-migrate down <version>
+//	gigo-migrate force <version>
+//	gigo-migrate lint [dir]
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/spf13/cobra"
+)
+
+type dbFlags struct {
+	host     string
+	port     string
+	user     string
+	password string
+	database string
+}
+
+func main() {
+	err := rootCmd().Execute()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func rootCmd() *cobra.Command {
+	flags := &dbFlags{}
+
+	root := &cobra.Command{
+		Use:          "gigo-migrate",
+		Short:        "Manage the migrations of a Gigo database",
+		SilenceUsage: true,
+	}
+
+	root.PersistentFlags().StringVar(&flags.host, "host", "gigo-dev-tidb", "database host")
+	root.PersistentFlags().StringVar(&flags.port, "port", "4000", "database port")
+	root.PersistentFlags().StringVar(&flags.user, "user", "gigo-dev", "database user")
+	root.PersistentFlags().StringVar(&flags.password, "password", os.Getenv("GIGO_DB_PASSWORD"), "database password (defaults to $GIGO_DB_PASSWORD)")
+	root.PersistentFlags().StringVar(&flags.database, "database", "gigo_dev", "database name")
+
+	root.AddCommand(
+		statusCmd(flags),
+		upCmd(flags),
+		downCmd(flags),
+		forceCmd(flags),
+		lintCmd(),
+	)
+
+	return root
+}
+
+// openMigrator
+//
+//	Connects to the configured database without applying migrations
+func openMigrator(flags *dbFlags) (*ti.Migrator, error) {
+	db, err := ti.OpenDatabase(flags.host, flags.port, "mysql", flags.user, flags.password, flags.database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	return ti.NewMigrator(db.DB)
+}
+
+// parseVersion
+//
+//	Parses a migration version argument
+func parseVersion(arg string) (uint, error) {
+	version, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid version %q: %v", arg, err)
+	}
+	return uint(version), nil
+}
+
+func statusCmd(flags *dbFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the current migration version and pending migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			migrator, err := openMigrator(flags)
+			if err != nil {
+				return err
+			}
+
+			status, err := migrator.Status()
+			if err != nil {
+				return err
+			}
+
+			pending := make([]string, len(status.Pending))
+			for i, v := range status.Pending {
+				pending[i] = strconv.FormatUint(uint64(v), 10)
+			}
+			if len(pending) == 0 {
+				pending = append(pending, "none")
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "version: %d\n", status.Version)
+			fmt.Fprintf(out, "dirty:   %t\n", status.Dirty)
+			fmt.Fprintf(out, "latest:  %d\n", status.Latest)
+			fmt.Fprintf(out, "pending: %s\n", strings.Join(pending, ", "))
+			return nil
+		},
+	}
+}
+
+func upCmd(flags *dbFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "up [version]",
+		Short: "Apply all pending migrations or the pending migrations up to a version",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			migrator, err := openMigrator(flags)
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 0 {
+				return migrator.Up()
+			}
+
+			version, err := parseVersion(args[0])
+			if err != nil {
+				return err
+			}
+			return migrator.UpTo(version)
+		},
+	}
+}
+
+func downCmd(flags *dbFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "down <version>",
+		Short: "Revert migrations until the version is the latest applied (0 reverts everything)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := parseVersion(args[0])
+			if err != nil {
+				return err
+			}
+
+			migrator, err := openMigrator(flags)
+			if err != nil {
+				return err
+			}
+			return migrator.DownTo(version)
+		},
+	}
+}
+
+func forceCmd(flags *dbFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "force <version>",
+		Short: "Set the migration version and clear the dirty flag without running migrations",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := parseVersion(args[0])
+			if err != nil {
+				return err
+			}
+
+			migrator, err := openMigrator(flags)
+			if err != nil {
+				return err
+			}
+			return migrator.Force(version)
+		},
+	}
+}
+
+func lintCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "lint [dir]",
+		Short: "Check the embedded migrations or the migrations in a directory",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return ti.LintEmbeddedMigrations()
+			}
+			return ti.LintMigrations(os.DirFS(args[0]), ".")
+		},
+	}
+}
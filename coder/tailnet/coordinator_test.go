@@ -4,10 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/gage-technologies/gigo-lib/cluster"
-	"github.com/gage-technologies/gigo-lib/config"
 	"github.com/gage-technologies/gigo-lib/logging"
-	"github.com/gage-technologies/gigo-lib/mq"
-	"github.com/gage-technologies/gigo-lib/mq/streams"
+	"github.com/gage-technologies/gigo-lib/mq/mqtest"
 	etcd "go.etcd.io/etcd/client/v3"
 	"golang.org/x/xerrors"
 	"net"
@@ -99,12 +97,7 @@ func TestCoordinator(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 		clusterNode1 := cluster.NewStandaloneNode(ctx, 69, "test", leaderRoutine, followerRoutine, time.Second, logger)
-		js, err := mq.NewJetstreamClient(config.JetstreamConfig{
-			Host:        "mq://gigo-dev-nats:4222",
-			MaxPubQueue: 256,
-		}, logger)
-		require.NoError(t, err)
-		defer js.DeleteStream(streams.StreamTailscale)
+		js := mqtest.NewJetstreamClient(t)
 		coordinator, err := tailnet.NewCoordinator(clusterNode1, js, logger)
 		assert.NoError(t, err)
 		client, server := net.Pipe()
@@ -133,12 +126,7 @@ func TestCoordinator(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 		clusterNode2 := cluster.NewStandaloneNode(ctx, 69, "test", leaderRoutine, followerRoutine, time.Second, logger)
-		js, err := mq.NewJetstreamClient(config.JetstreamConfig{
-			Host:        "mq://gigo-dev-nats:4222",
-			MaxPubQueue: 256,
-		}, logger)
-		require.NoError(t, err)
-		defer js.DeleteStream(streams.StreamTailscale)
+		js := mqtest.NewJetstreamClient(t)
 		coordinator, err := tailnet.NewCoordinator(clusterNode2, js, logger)
 		assert.NoError(t, err)
 		client, server := net.Pipe()
@@ -167,12 +155,7 @@ func TestCoordinator(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 		clusterNode3 := cluster.NewStandaloneNode(ctx, 69, "test", leaderRoutine, followerRoutine, time.Second, logger)
-		js, err := mq.NewJetstreamClient(config.JetstreamConfig{
-			Host:        "mq://gigo-dev-nats:4222",
-			MaxPubQueue: 256,
-		}, logger)
-		require.NoError(t, err)
-		defer js.DeleteStream(streams.StreamTailscale)
+		js := mqtest.NewJetstreamClient(t)
 		coordinator, err := tailnet.NewCoordinator(clusterNode3, js, logger)
 		agentWS, agentServerWS := net.Pipe()
 		defer agentWS.Close()
@@ -330,12 +313,7 @@ func TestCoordinator(t *testing.T) {
 			clusterNode2.Close()
 		}()
 		time.Sleep(time.Second * 2)
-		js, err := mq.NewJetstreamClient(config.JetstreamConfig{
-			Host:        "mq://gigo-dev-nats:4222",
-			MaxPubQueue: 256,
-		}, logger)
-		require.NoError(t, err)
-		defer js.DeleteStream(streams.StreamTailscale)
+		js := mqtest.NewJetstreamClient(t)
 		coordinator1, err := tailnet.NewCoordinator(clusterNode1, js, logger)
 		require.NoError(t, err)
 		coordinator2, err := tailnet.NewCoordinator(clusterNode2, js, logger)
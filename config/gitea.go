@@ -3,5 +3,5 @@ package config
 type GiteaConfig struct {
 	HostUrl  string `yaml:"host_url"`
 	Username string `yaml:"username"`
-	Password string `yaml:"password""`
+	Password string `yaml:"password"`
 }
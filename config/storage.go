@@ -3,8 +3,9 @@ package config
 type StorageEngine string
 
 const (
-	StorageEngineS3 StorageEngine = "s3"
-	StorageEngineFS StorageEngine = "fs"
+	StorageEngineS3        StorageEngine = "s3"
+	StorageEngineFS        StorageEngine = "fs"
+	StorageEngineJetstream StorageEngine = "jetstream"
 )
 
 type StorageS3Config struct {
@@ -20,8 +21,15 @@ type StorageFSConfig struct {
 	Root string `yaml:"root"`
 }
 
+type StorageJetstreamConfig struct {
+	Bucket   string `yaml:"bucket"`
+	MaxBytes int64  `yaml:"max_bytes"`
+	Replicas int    `yaml:"replicas"`
+}
+
 type StorageConfig struct {
-	Engine StorageEngine   `yaml:"engine"`
-	S3     StorageS3Config `yaml:"s3"`
-	FS     StorageFSConfig `yaml:"fs"`
+	Engine    StorageEngine          `yaml:"engine"`
+	S3        StorageS3Config        `yaml:"s3"`
+	FS        StorageFSConfig        `yaml:"fs"`
+	Jetstream StorageJetstreamConfig `yaml:"jetstream"`
 }
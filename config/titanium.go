@@ -1,5 +1,21 @@
 package config
 
+import "time"
+
+type TitaniumTLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	CAPath             string `yaml:"ca_path"`
+	CertPath           string `yaml:"cert_path"`
+	KeyPath            string `yaml:"key_path"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+type TitaniumReplicaConfig struct {
+	Host string `yaml:"host"`
+	Port string `yaml:"port"`
+}
+
 type TitaniumConfig struct {
 	TitaniumHost       string   `yaml:"db_host"`
 	TitaniumPort       string   `yaml:"db_port"`
@@ -8,4 +24,29 @@ type TitaniumConfig struct {
 	TitaniumUser       string   `yaml:"db_user"`
 	TitaniumPassword   string   `yaml:"db_password"`
 	TitaniumBackupPath string   `yaml:"db_backup_path"`
+	// DriverName is the database/sql driver used to open connections;
+	// defaults to mysql
+	DriverName string `yaml:"driver_name"`
+
+	// connection pool - zero values fall back to the ti package defaults
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+
+	// connection behaviour
+	DialTimeout  time.Duration     `yaml:"dial_timeout"`
+	ReadTimeout  time.Duration     `yaml:"read_timeout"`
+	WriteTimeout time.Duration     `yaml:"write_timeout"`
+	TLS          TitaniumTLSConfig `yaml:"tls"`
+	// Params are appended to the DSN of every connection
+	Params map[string]string `yaml:"params"`
+
+	// ReadReplicas receive all queries that do not modify data
+	ReadReplicas []TitaniumReplicaConfig `yaml:"read_replicas"`
+
+	// instrumentation - zero values fall back to the ti package defaults
+	DisableInstrumentation bool          `yaml:"disable_instrumentation"`
+	SlowQueryThreshold     time.Duration `yaml:"slow_query_threshold"`
+	MaxQueryFingerprints   int           `yaml:"max_query_fingerprints"`
 }
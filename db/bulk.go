@@ -0,0 +1,294 @@
+package ti
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// MaxPlaceholders is the maximum number of placeholders that MySQL
+	// accepts in a single prepared statement
+	MaxPlaceholders = 65535
+)
+
+// bulkInsertPattern matches the head of an insert statement up to the start of its first row
+var bulkInsertPattern = regexp.MustCompile(`(?is)^\s*(insert\s+(?:ignore\s+)?into\s+[\w.` + "`" + `]+\s*\([^)]*\)\s*values?)\s*\(`)
+
+// bulkUpsertPattern matches the optional clause that follows the rows of an insert statement
+var bulkUpsertPattern = regexp.MustCompile(`(?is)^\s*(on\s+duplicate\s+key\s+update\s+.+?)?\s*;?\s*$`)
+
+// BulkStatement
+//
+//	Statement produced by a BulkInsertBuilder
+type BulkStatement struct {
+	Statement string
+	Values    []interface{}
+}
+
+// BulkInsertOptions
+//
+//	Options for a BulkInsertBuilder
+type BulkInsertOptions struct {
+	// MaxPlaceholders caps the placeholders in a single statement;
+	// defaults to MaxPlaceholders
+	MaxPlaceholders int
+	// MaxRows caps the rows in a single statement; 0 only limits
+	// the rows by the number of placeholders
+	MaxRows int
+}
+
+// bulkGroup
+//
+//	Rows of compatible insert statements that are merged together
+type bulkGroup struct {
+	head         string
+	row          string
+	tail         string
+	placeholders int
+	rows         [][]interface{}
+	// statement is set for statements that cannot be merged
+	statement *BulkStatement
+}
+
+// BulkInsertBuilder
+//
+//	Merges single row insert statements into multi-row insert statements.
+//	Statements are compatible when they insert the same columns into the
+//	same table with the same row expression and the same on duplicate key
+//	update clause. The rows of compatible statements are merged into the
+//	position of the first statement of their kind and statements that cannot
+//	be merged keep their position. Inserts are never merged across a
+//	statement that cannot be merged so no insert is moved before an update
+//	or delete that was added ahead of it. Merged statements are split into
+//	chunks that respect the placeholder limit.
+type BulkInsertBuilder struct {
+	maxPlaceholders int
+	maxRows         int
+	groups          []*bulkGroup
+	index           map[string]*bulkGroup
+}
+
+// NewBulkInsertBuilder
+//
+//	Creates a new BulkInsertBuilder
+func NewBulkInsertBuilder(opts BulkInsertOptions) *BulkInsertBuilder {
+	if opts.MaxPlaceholders <= 0 || opts.MaxPlaceholders > MaxPlaceholders {
+		opts.MaxPlaceholders = MaxPlaceholders
+	}
+
+	return &BulkInsertBuilder{
+		maxPlaceholders: opts.MaxPlaceholders,
+		maxRows:         opts.MaxRows,
+		index:           make(map[string]*bulkGroup),
+	}
+}
+
+// OnDuplicateKeyUpdate
+//
+//	Returns an on duplicate key update clause that overwrites the passed
+//	columns with the values of the inserted row. The clause can be appended
+//	to an insert statement to turn it into an upsert.
+func OnDuplicateKeyUpdate(columns ...string) string {
+	assignments := make([]string, 0, len(columns))
+	for _, column := range columns {
+		assignments = append(assignments, fmt.Sprintf("%s = values(%s)", column, column))
+	}
+	return "on duplicate key update " + strings.Join(assignments, ", ")
+}
+
+// Add
+//
+//	Adds a statement to the builder. Single row insert statements are
+//	merged with compatible statements added since the last statement that
+//	cannot be merged; any other statement is executed unchanged in its
+//	position.
+func (b *BulkInsertBuilder) Add(statement string, values ...interface{}) error {
+	group, ok := parseBulkInsert(statement)
+	if !ok {
+		b.groups = append(b.groups, &bulkGroup{statement: &BulkStatement{Statement: statement, Values: values}})
+		// later inserts must execute after this statement
+		b.index = make(map[string]*bulkGroup)
+		return nil
+	}
+
+	if len(values) != group.placeholders {
+		return fmt.Errorf("statement expects %d values but received %d: %s", group.placeholders, len(values), statement)
+	}
+	if group.placeholders > b.maxPlaceholders {
+		return fmt.Errorf("statement has %d placeholders which exceeds the limit of %d", group.placeholders, b.maxPlaceholders)
+	}
+
+	key := group.head + "\x00" + group.row + "\x00" + group.tail
+	if existing, ok := b.index[key]; ok {
+		existing.rows = append(existing.rows, values)
+		return nil
+	}
+
+	group.rows = [][]interface{}{values}
+	b.index[key] = group
+	b.groups = append(b.groups, group)
+	return nil
+}
+
+// Len
+//
+//	Returns the number of statements that have been added
+func (b *BulkInsertBuilder) Len() int {
+	count := 0
+	for _, group := range b.groups {
+		if group.statement != nil {
+			count++
+			continue
+		}
+		count += len(group.rows)
+	}
+	return count
+}
+
+// Statements
+//
+//	Returns the merged statements in execution order
+func (b *BulkInsertBuilder) Statements() []BulkStatement {
+	statements := make([]BulkStatement, 0, len(b.groups))
+
+	for _, group := range b.groups {
+		if group.statement != nil {
+			statements = append(statements, *group.statement)
+			continue
+		}
+
+		// rows without placeholders are only limited by the row cap
+		chunkSize := len(group.rows)
+		if group.placeholders > 0 {
+			chunkSize = b.maxPlaceholders / group.placeholders
+		}
+		if b.maxRows > 0 && chunkSize > b.maxRows {
+			chunkSize = b.maxRows
+		}
+
+		for start := 0; start < len(group.rows); start += chunkSize {
+			end := start + chunkSize
+			if end > len(group.rows) {
+				end = len(group.rows)
+			}
+			statements = append(statements, group.build(group.rows[start:end]))
+		}
+	}
+
+	return statements
+}
+
+// ExecTx
+//
+//	Executes the merged statements inside the passed transaction
+func (b *BulkInsertBuilder) ExecTx(ctx context.Context, tx *Tx, callerName *string) error {
+	for _, statement := range b.Statements() {
+		_, err := tx.ExecContext(ctx, callerName, statement.Statement, statement.Values...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Exec
+//
+//	Executes the merged statements inside a single transaction that
+//	is retried on write conflicts
+func (b *BulkInsertBuilder) Exec(ctx context.Context, db *Database, span *trace.Span, callerName *string) error {
+	if len(b.groups) == 0 {
+		return nil
+	}
+
+	return db.RunInTx(ctx, span, callerName, nil, func(ctx context.Context, tx *Tx) error {
+		return b.ExecTx(ctx, tx, callerName)
+	})
+}
+
+// build
+//
+//	Creates a multi-row statement for the passed rows of the group
+func (g *bulkGroup) build(rows [][]interface{}) BulkStatement {
+	var statement strings.Builder
+	values := make([]interface{}, 0, len(rows)*g.placeholders)
+
+	statement.WriteString(g.head)
+	statement.WriteString(" ")
+	for i, row := range rows {
+		if i > 0 {
+			statement.WriteString(", ")
+		}
+		statement.WriteString(g.row)
+		values = append(values, row...)
+	}
+	if g.tail != "" {
+		statement.WriteString(" ")
+		statement.WriteString(g.tail)
+	}
+
+	return BulkStatement{Statement: statement.String(), Values: values}
+}
+
+// parseBulkInsert
+//
+//	Splits a single row insert statement into its head, row and tail.
+//	Statements with more than one row, a select instead of values or
+//	placeholders after the row cannot be merged.
+func parseBulkInsert(statement string) (*bulkGroup, bool) {
+	match := bulkInsertPattern.FindStringSubmatchIndex(statement)
+	if match == nil {
+		return nil, false
+	}
+
+	head := strings.Join(strings.Fields(statement[match[2]:match[3]]), " ")
+
+	// find the end of the row, skipping nested parentheses and string literals
+	rowStart := match[1] - 1
+	depth := 0
+	placeholders := 0
+	var quote byte
+	rowEnd := -1
+	for i := rowStart; i < len(statement) && rowEnd < 0; i++ {
+		c := statement[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"':
+			quote = c
+		case '?':
+			placeholders++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				rowEnd = i + 1
+			}
+		}
+	}
+	if rowEnd < 0 {
+		return nil, false
+	}
+
+	tail := bulkUpsertPattern.FindStringSubmatch(statement[rowEnd:])
+	if tail == nil || strings.Contains(tail[1], "?") {
+		return nil, false
+	}
+
+	return &bulkGroup{
+		head:         head,
+		row:          statement[rowStart:rowEnd],
+		tail:         strings.Join(strings.Fields(tail[1]), " "),
+		placeholders: placeholders,
+	}, true
+}
@@ -0,0 +1,91 @@
+package ti
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkInsertBuilder(t *testing.T) {
+	b := NewBulkInsertBuilder(BulkInsertOptions{})
+
+	assert.NoError(t, b.Add("insert ignore into post(_id, title, share_hash) values(?, ?, uuid_to_bin(?));", 1, "test", "hash"))
+	assert.NoError(t, b.Add("insert ignore into post_tags(post_id, tag_id) values(?, ?);", 1, 10))
+	assert.NoError(t, b.Add("insert ignore into post_langs(post_id, lang_id) values(?,?);", 1, 5))
+	assert.NoError(t, b.Add("insert ignore into post_tags(post_id, tag_id) values(?, ?);", 1, 11))
+	assert.NoError(t, b.Add("update tag set usage_count = usage_count + 1 where _id in (?, ?)", 10, 11))
+	assert.NoError(t, b.Add("insert ignore into post_tags(post_id, tag_id) values(?, ?);", 1, 12))
+
+	assert.Equal(t, 6, b.Len())
+	assert.Equal(t, []BulkStatement{
+		{
+			Statement: "insert ignore into post(_id, title, share_hash) values (?, ?, uuid_to_bin(?))",
+			Values:    []interface{}{1, "test", "hash"},
+		},
+		{
+			Statement: "insert ignore into post_tags(post_id, tag_id) values (?, ?), (?, ?)",
+			Values:    []interface{}{1, 10, 1, 11},
+		},
+		{
+			Statement: "insert ignore into post_langs(post_id, lang_id) values (?,?)",
+			Values:    []interface{}{1, 5},
+		},
+		{
+			Statement: "update tag set usage_count = usage_count + 1 where _id in (?, ?)",
+			Values:    []interface{}{10, 11},
+		},
+		// inserts added after the update are not moved before it
+		{
+			Statement: "insert ignore into post_tags(post_id, tag_id) values (?, ?)",
+			Values:    []interface{}{1, 12},
+		},
+	}, b.Statements())
+}
+
+func TestBulkInsertBuilder_Upsert(t *testing.T) {
+	b := NewBulkInsertBuilder(BulkInsertOptions{})
+
+	upsert := "insert into tag(_id, value) values (?, ?) " + OnDuplicateKeyUpdate("value")
+	assert.NoError(t, b.Add(upsert, 1, "go"))
+	assert.NoError(t, b.Add(upsert, 2, "rust"))
+
+	// placeholders in the update clause prevent the statement from being merged
+	increment := "insert into tag(_id, value) values (?, ?) on duplicate key update usage_count = usage_count + ?"
+	assert.NoError(t, b.Add(increment, 3, "c", 1))
+	assert.NoError(t, b.Add(increment, 4, "java", 1))
+
+	assert.Equal(t, []BulkStatement{
+		{
+			Statement: "insert into tag(_id, value) values (?, ?), (?, ?) on duplicate key update value = values(value)",
+			Values:    []interface{}{1, "go", 2, "rust"},
+		},
+		{Statement: increment, Values: []interface{}{3, "c", 1}},
+		{Statement: increment, Values: []interface{}{4, "java", 1}},
+	}, b.Statements())
+}
+
+func TestBulkInsertBuilder_Chunks(t *testing.T) {
+	b := NewBulkInsertBuilder(BulkInsertOptions{MaxPlaceholders: 5})
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, b.Add("insert into post_tags(post_id, tag_id) values (?, ?)", 1, i))
+	}
+
+	statements := b.Statements()
+	if !assert.Len(t, statements, 3) {
+		return
+	}
+	assert.Equal(t, "insert into post_tags(post_id, tag_id) values (?, ?), (?, ?)", statements[0].Statement)
+	assert.Equal(t, []interface{}{1, 4}, statements[2].Values)
+
+	b = NewBulkInsertBuilder(BulkInsertOptions{MaxRows: 4})
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, b.Add("insert into post_tags(post_id, tag_id) values (?, ?)", 1, i))
+	}
+	assert.Len(t, b.Statements(), 2)
+}
+
+func TestBulkInsertBuilder_InvalidValues(t *testing.T) {
+	b := NewBulkInsertBuilder(BulkInsertOptions{})
+	assert.Error(t, b.Add("insert into post_tags(post_id, tag_id) values (?, ?)", 1))
+}
@@ -1,8 +1,119 @@
-package gen
-
 -- name: InsertDatabaseVersion :exec
 insert
 ignore into database_versions (
     version,
     date
 ) values (?, utc_timestamp());
+
+-- name: GetUserByID :one
+select * from users where _id = ? limit 1;
+
+-- name: GetUserByUserName :one
+select * from users where user_name = ? limit 1;
+
+-- name: GetUserByEmail :one
+select * from users where email = ? limit 1;
+
+-- name: UpdateUserStatus :exec
+update users set user_status = ? where _id = ?;
+
+-- name: GetPostByID :one
+select * from post where _id = ? limit 1;
+
+-- name: ListPostsByAuthor :many
+-- Pages through the posts of an author from newest to oldest; pass the
+-- id of the last post in the previous page as the cursor.
+select * from post
+where author_id = ? and deleted = false and _id < ?
+order by _id desc
+limit ?;
+
+-- name: UpdatePostPublished :exec
+update post set published = ?, updated_at = ? where _id = ?;
+
+-- name: MarkPostDeleted :execrows
+update post set deleted = true, updated_at = ? where _id = ? and deleted = false;
+
+-- name: GetAttemptByID :one
+select * from attempt where _id = ? limit 1;
+
+-- name: ListAttemptsByAuthor :many
+-- Pages through the attempts of an author from newest to oldest
+select * from attempt
+where author_id = ? and _id < ?
+order by _id desc
+limit ?;
+
+-- name: ListAttemptsByPost :many
+-- Pages through the attempts of a post from newest to oldest
+select * from attempt
+where post_id = ? and _id < ?
+order by _id desc
+limit ?;
+
+-- name: CloseAttempt :execrows
+update attempt set closed = true, success = ?, closed_date = ?, updated_at = ? where _id = ? and closed = false;
+
+-- name: GetWorkspaceByID :one
+select * from workspaces where _id = ? limit 1;
+
+-- name: ListWorkspacesByOwner :many
+-- Pages through the workspaces of an owner from newest to oldest
+select * from workspaces
+where owner_id = ? and _id < ?
+order by _id desc
+limit ?;
+
+-- name: ListWorkspacesByState :many
+-- Pages through the workspaces in a state from oldest to newest
+select * from workspaces
+where state = ? and _id > ?
+order by _id
+limit ?;
+
+-- name: UpdateWorkspaceState :exec
+update workspaces set state = ?, last_state_update = ? where _id = ?;
+
+-- name: UpdateWorkspaceInitState :exec
+update workspaces set init_state = ?, last_state_update = ? where _id = ?;
+
+-- name: GetWorkspaceAgentByID :one
+select * from workspace_agent where _id = ? limit 1;
+
+-- name: GetLatestWorkspaceAgentByWorkspace :one
+select * from workspace_agent
+where workspace_id = ?
+order by created_at desc
+limit 1;
+
+-- name: ListWorkspaceAgentsByOwner :many
+-- Pages through the workspace agents of an owner from newest to oldest
+select * from workspace_agent
+where owner_id = ? and _id < ?
+order by _id desc
+limit ?;
+
+-- name: UpdateWorkspaceAgentState :exec
+update workspace_agent set state = ?, updated_at = ? where _id = ?;
+
+-- name: GetNotificationByID :one
+select * from notification where _id = ? limit 1;
+
+-- name: ListNotificationsByUser :many
+-- Pages through the notifications of a user from newest to oldest
+select * from notification
+where user_id = ? and _id < ?
+order by _id desc
+limit ?;
+
+-- name: ListUnacknowledgedNotificationsByUser :many
+select * from notification
+where user_id = ? and acknowledged = false
+order by _id desc
+limit ?;
+
+-- name: AcknowledgeNotification :execrows
+update notification set acknowledged = true where _id = ? and user_id = ?;
+
+-- name: AcknowledgeAllNotifications :execrows
+update notification set acknowledged = true where user_id = ? and acknowledged = false;
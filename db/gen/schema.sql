@@ -33,8 +33,9 @@ create table users (
     stripe_account varchar(280),
     exclusive_agreement boolean not null default false,
     reset_token varchar(500),
-    hasBroadcast boolean not null default false,
+    has_broadcast boolean not null default false,
     holiday_themes boolean not null default true,
+    tutorials json,
     is_ephemeral boolean not null default false
 );
 
@@ -153,7 +154,8 @@ create table discussion_up_vote (
 create table follower (
     follower bigint not null,
     following bigint not null,
-    primary key (follower, following)
+    primary key (follower, following),
+    index follower_following_idx (following, follower)
 );
 
 create table friend_requests (
@@ -164,7 +166,9 @@ create table friend_requests (
      friend_name varchar(50) not null,
      response boolean null,
      date datetime not null,
-     notification_id bigint not null
+     notification_id bigint not null,
+     index friend_requests_user_idx (user_id, friend),
+     index friend_requests_friend_idx (friend, user_id)
 );
 
 create table friends (
@@ -173,7 +177,9 @@ create table friends (
      user_name varchar(50) not null,
      friend bigint not null,
      friend_name varchar(50) not null,
-     date datetime not null
+     date datetime not null,
+     index friends_user_idx (user_id, friend),
+     index friends_friend_idx (friend, user_id)
 );
 
 create table implicit_rec (
@@ -183,7 +189,8 @@ create table implicit_rec (
       session_id binary(16) not null,
       implicit_action int not null,
       created_at timestamp not null,
-      user_tier_at_action int not null
+      user_tier_at_action int not null,
+      index implicit_rec_created_at_idx (created_at, user_id, post_id, implicit_action)
 );
 
 create table nemesis (
@@ -197,7 +204,10 @@ create table nemesis (
      time_of_villainy datetime not null,
      victor bigint,
      is_accepted boolean not null,
-     end_time datetime
+     end_time datetime,
+     index nemesis_antagonist_idx (antagonist_id, victor),
+     index nemesis_protagonist_idx (protagonist_id, victor),
+     index nemesis_state_idx (is_accepted, victor, end_time)
 );
 
 create table nemesis_history (
@@ -210,7 +220,8 @@ create table nemesis_history (
      protagonist_total_xp bigint not null,
      antagonist_total_xp bigint not null,
      is_alerted boolean not null,
-     created_at datetime not null
+     created_at datetime not null,
+     index nemesis_history_created_at_idx (created_at)
 );
 
 create table notification (
@@ -220,7 +231,9 @@ create table notification (
       notification_type int not null,
       created_at datetime not null,
       acknowledged boolean not null default false,
-      interacting_user_id bigint
+      interacting_user_id bigint,
+      collapsed_count int not null default 0,
+      index notification_unacknowledged_idx (user_id, acknowledged, notification_type, created_at)
 );
 
 create table post (
@@ -249,7 +262,8 @@ create table post (
     leads boolean not null default false,
     embedded boolean not null default false,
     deleted boolean not null default false,
-    exclusive_description varchar(500)
+    exclusive_description varchar(500),
+    share_hash binary(16)
 );
 
 create table post_awards (
@@ -281,7 +295,8 @@ create table recommended_post (
     expires_at timestamp not null,
     reference_tier int not null,
     accepted boolean not null default false,
-    views bigint not null default 0
+    views bigint not null default 0,
+    index recommended_post_user_idx (user_id, accepted)
 );
 
 create table rewards (
@@ -391,7 +406,8 @@ create table user_active_times (
     _id bigint primary key not null,
     user_id bigint not null,
     start_time datetime not null,
-    end_time datetime not null
+    end_time datetime not null,
+    index user_active_times_end_time_idx (end_time, user_id)
 );
 
 create table user_badges (
@@ -496,7 +512,8 @@ create table workspace_config_langs (
 create table if not exists xp_boosts (
     _id bigint not null primary key,
     user_id bigint not null,
-    end_date datetime
+    end_date datetime,
+    multiplier double not null default 2
 );
 
 create table if not exists zookies (
@@ -527,20 +544,25 @@ create table if not exists xp_reasons (
    user_id bigint not null,
    date timestamp not null,
    reason varchar(280) not null,
-   xp bigint not null
+   xp bigint not null,
+   idempotency_key varchar(280),
+   unique index xp_reasons_idempotency_idx (user_id, idempotency_key)
 );
 
 create table if not exists chat (
     _id bigint not null primary key,
     name varchar(280) not null,
     type int not null,
-    last_message_time datetime
+    last_message_time datetime,
+    last_message bigint
 );
 
 create table if not exists chat_users (
     chat_id bigint not null,
     user_id bigint not null,
     created_at datetime not null,
+    last_read_message bigint,
+    muted boolean default false,
     primary key (chat_id, user_id)
 );
 
@@ -549,7 +571,7 @@ create table if not exists chat_messages (
     chat_id bigint not null,
     author_id bigint not null,
     author varchar(280) not null,
-    message varchar(500) not null,
+    message text not null,
     created_at datetime(6) not null,
     revision bigint not null,
     type int not null
@@ -564,12 +586,94 @@ create table if not exists curated_post (
 create table if not exists curated_post_type (
     curated_id bigint not null,
     proficiency_type int not null,
-    primary key (curated_id, type)
+    primary key (curated_id, proficiency_type)
 );
 
 create table if not exists ephemeral_shared_workspaces (
    workspace_id BIGINT NOT NULL,
    ip BIGINT NOT NULL,
    date DATETIME NOT NULL,
-   primary key (workpace_id, ip)
-);
\ No newline at end of file
+   user_id BIGINT NOT NULL,
+   challenge_id BIGINT NOT NULL,
+   primary key (workspace_id, ip, challenge_id)
+);
+
+create table if not exists volpool_volume (
+    _id bigint not null primary key,
+    size int not null,
+    state int not null,
+    pvc_name varchar(500) not null,
+    workspace_id bigint,
+    storage_class varchar(255)
+);
+
+create table if not exists outbox_messages (
+    _id bigint not null primary key,
+    subject varchar(280) not null,
+    payload longblob not null,
+    created_at datetime(6) not null,
+    sent_at datetime(6),
+    attempts int not null default 0,
+    last_error text,
+    index outbox_messages_pending_idx (sent_at, _id)
+);
+create table if not exists account_deletions (
+    user_id bigint not null primary key,
+    user_name varchar(280) not null,
+    requested_at datetime not null,
+    completed_at datetime,
+    index account_deletions_pending_idx (completed_at, requested_at)
+);
+
+create table if not exists account_deletion_steps (
+    user_id bigint not null,
+    step varchar(64) not null,
+    status int not null,
+    attempts int not null default 0,
+    last_error text,
+    updated_at datetime not null,
+    primary key (user_id, step)
+);
+
+create table if not exists audit_log (
+    _id bigint not null primary key,
+    actor_id bigint,
+    action varchar(64) not null,
+    target_type varchar(64) not null,
+    target_id bigint not null,
+    diff json,
+    ip varchar(45),
+    request_id varchar(64),
+    created_at datetime not null,
+    index audit_log_actor_idx (actor_id, created_at),
+    index audit_log_target_idx (target_type, target_id, created_at),
+    index audit_log_created_at_idx (created_at)
+);
+
+create table if not exists user_blocks (
+    user_id bigint not null,
+    blocked_id bigint not null,
+    created_at datetime not null,
+    primary key (user_id, blocked_id),
+    index user_blocks_blocked_idx (blocked_id, user_id)
+);
+
+create table if not exists notification_preferences (
+    user_id bigint not null,
+    notification_type int not null,
+    in_app boolean not null default true,
+    email boolean not null default false,
+    muted boolean not null default false,
+    updated_at datetime not null,
+    primary key (user_id, notification_type)
+);
+
+create table if not exists notification_digests (
+    user_id bigint not null primary key,
+    last_sent_at datetime not null
+);
+
+create table if not exists database_versions (
+    version bigint not null primary key,
+    date datetime not null
+);
@@ -0,0 +1,373 @@
+package ti
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gage-technologies/gigo-lib/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const (
+	metricsNamespace = "gigo_db"
+
+	// DefaultSlowQueryThreshold is the duration above which a statement
+	// is logged as slow when the options do not set a threshold
+	DefaultSlowQueryThreshold = time.Millisecond * 500
+	// DefaultMaxFingerprints is the number of distinct fingerprints that are
+	// tracked when the options do not set a limit
+	DefaultMaxFingerprints = 1000
+
+	// overflowFingerprint is used for statements once the fingerprint limit has been reached
+	overflowFingerprint = "other"
+)
+
+var (
+	fingerprintCommentPattern    = regexp.MustCompile(`(?s)/\*.*?\*/|--[^\n]*`)
+	fingerprintStringPattern     = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.)*"`)
+	fingerprintNumberPattern     = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	fingerprintHexPattern        = regexp.MustCompile(`\b0x[0-9a-f]+\b`)
+	fingerprintWhitespacePattern = regexp.MustCompile(`\s+`)
+	fingerprintListPattern       = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	fingerprintValuesPattern     = regexp.MustCompile(`(\(\?\+\))(?:\s*,\s*\(\?\+\))+`)
+)
+
+// FingerprintQuery
+//
+//	Normalizes a statement so that every execution of the same statement
+//	shares a fingerprint. Literals are replaced with placeholders, lists of
+//	placeholders are collapsed and comments and whitespace are removed.
+func FingerprintQuery(query string) string {
+	fingerprint := fingerprintCommentPattern.ReplaceAllString(query, " ")
+	fingerprint = strings.ToLower(fingerprint)
+	fingerprint = fingerprintStringPattern.ReplaceAllString(fingerprint, "?")
+	fingerprint = fingerprintHexPattern.ReplaceAllString(fingerprint, "?")
+	fingerprint = fingerprintNumberPattern.ReplaceAllString(fingerprint, "?")
+	fingerprint = fingerprintWhitespacePattern.ReplaceAllString(fingerprint, " ")
+	fingerprint = fingerprintListPattern.ReplaceAllString(fingerprint, "(?+)")
+	fingerprint = fingerprintValuesPattern.ReplaceAllString(fingerprint, "$1")
+	return strings.TrimSuffix(strings.TrimSpace(fingerprint), ";")
+}
+
+// redactArgs
+//
+//	Describes the arguments of a statement by their type and size
+//	so that they can be logged without leaking their values
+func redactArgs(args []interface{}) string {
+	redacted := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+			redacted = append(redacted, "nil")
+		case string:
+			redacted = append(redacted, fmt.Sprintf("string(%d)", len(v)))
+		case []byte:
+			redacted = append(redacted, fmt.Sprintf("[]byte(%d)", len(v)))
+		default:
+			redacted = append(redacted, fmt.Sprintf("%T", arg))
+		}
+	}
+	return "[" + strings.Join(redacted, ", ") + "]"
+}
+
+// InstrumentationOptions
+//
+//	Options for the instrumentation of a Database
+type InstrumentationOptions struct {
+	// Logger receives the slow query logs; slow queries are not
+	// logged if Logger is nil
+	Logger logging.Logger
+	// Registerer is the prometheus registerer that the metrics
+	// are added to; defaults to prometheus.DefaultRegisterer
+	Registerer prometheus.Registerer
+	// SlowQueryThreshold is the duration above which a statement is
+	// logged as slow; defaults to DefaultSlowQueryThreshold
+	SlowQueryThreshold time.Duration
+	// MaxFingerprints caps the number of distinct fingerprints tracked
+	// in the metrics; defaults to DefaultMaxFingerprints
+	MaxFingerprints int
+}
+
+// queryStats
+//
+//	Records the latency of statements executed through a Database
+type queryStats struct {
+	logger          logging.Logger
+	slowThreshold   time.Duration
+	maxFingerprints int
+
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+	slow     *prometheus.CounterVec
+
+	// fingerprints caches the fingerprint of each raw statement
+	fingerprints map[string]string
+	// tracked is the set of fingerprints that have their own metrics
+	tracked map[string]bool
+	lock    *sync.RWMutex
+}
+
+// instrument
+//
+//	Enables the instrumentation of every statement executed through the
+//	database, its transactions and its generated queries. The latency of
+//	each statement is recorded in a histogram keyed by the statement's
+//	fingerprint, statements slower than the threshold are logged with
+//	their arguments redacted and the connection pool stats of the primary
+//	and each read replica are exported as metrics. Statements that return
+//	rows are timed until the rows are returned, not until they are read.
+//
+//	Databases are instrumented exactly once when they are opened, before
+//	they are shared between goroutines.
+func (db *Database) instrument(opts InstrumentationOptions) error {
+	if opts.Registerer == nil {
+		opts.Registerer = prometheus.DefaultRegisterer
+	}
+	if opts.SlowQueryThreshold <= 0 {
+		opts.SlowQueryThreshold = DefaultSlowQueryThreshold
+	}
+	if opts.MaxFingerprints <= 0 {
+		opts.MaxFingerprints = DefaultMaxFingerprints
+	}
+
+	stats := &queryStats{
+		logger:          opts.Logger,
+		slowThreshold:   opts.SlowQueryThreshold,
+		maxFingerprints: opts.MaxFingerprints,
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_duration_seconds",
+			Help:      "Latency of the statements executed against the database",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"fingerprint", "operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_errors_total",
+			Help:      "Number of statements that returned an error",
+		}, []string{"fingerprint", "operation"}),
+		slow: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "slow_queries_total",
+			Help:      "Number of statements that exceeded the slow query threshold",
+		}, []string{"fingerprint", "operation"}),
+		fingerprints: make(map[string]string),
+		tracked:      make(map[string]bool),
+		lock:         &sync.RWMutex{},
+	}
+
+	// databases opened by the same process share the statement metrics
+	duration, err := registerMetric(opts.Registerer, stats.duration)
+	if err != nil {
+		return err
+	}
+	stats.duration = duration.(*prometheus.HistogramVec)
+
+	errs, err := registerMetric(opts.Registerer, stats.errors)
+	if err != nil {
+		return err
+	}
+	stats.errors = errs.(*prometheus.CounterVec)
+
+	slow, err := registerMetric(opts.Registerer, stats.slow)
+	if err != nil {
+		return err
+	}
+	stats.slow = slow.(*prometheus.CounterVec)
+
+	// label the stats of each pool so that the primary and the replicas are reported separately
+	err = db.registerPool(opts.Registerer, db.DB, "primary", fmt.Sprintf("%s:%s", db.Host, db.Port))
+	if err != nil {
+		return err
+	}
+	for i, replica := range db.replicas {
+		addr := ""
+		if i < len(db.replicaAddrs) {
+			addr = db.replicaAddrs[i]
+		}
+		err = db.registerPool(opts.Registerer, replica, fmt.Sprintf("replica-%d", i), addr)
+		if err != nil {
+			return err
+		}
+	}
+
+	db.stats.Store(stats)
+	db.Queries = New(&instrumentedDBTX{db: db.DB, stats: stats})
+
+	return nil
+}
+
+// registerMetric
+//
+//	Registers the metric and returns the collector that is registered.
+//	A metric that has already been registered by another database returns
+//	the existing collector so that databases share the statement metrics.
+func registerMetric(registerer prometheus.Registerer, metric prometheus.Collector) (prometheus.Collector, error) {
+	err := registerer.Register(metric)
+	if err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			return registered.ExistingCollector, nil
+		}
+		return nil, fmt.Errorf("failed to register database metrics: %v", err)
+	}
+	return metric, nil
+}
+
+// registerPool
+//
+//	Exports the stats of a connection pool labelled by its role and
+//	address. A pool that is registered with the same labels as the pool
+//	of another open database replaces it since the registry can only
+//	report one of them. The collector is removed when the database is
+//	closed.
+func (db *Database) registerPool(registerer prometheus.Registerer, pool *sql.DB, role string, addr string) error {
+	registerer = prometheus.WrapRegistererWith(prometheus.Labels{"pool": role, "addr": addr}, registerer)
+	collector := collectors.NewDBStatsCollector(pool, db.DBName)
+
+	err := registerer.Register(collector)
+	if err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if !errors.As(err, &registered) {
+			return fmt.Errorf("failed to register database pool metrics: %v", err)
+		}
+		registerer.Unregister(registered.ExistingCollector)
+		err = registerer.Register(collector)
+		if err != nil {
+			return fmt.Errorf("failed to register database pool metrics: %v", err)
+		}
+	}
+
+	db.poolCollectors = append(db.poolCollectors, func() {
+		registerer.Unregister(collector)
+	})
+	return nil
+}
+
+// unregisterPools
+//
+//	Removes the stats of the database's connection pools from the metrics
+func (db *Database) unregisterPools() {
+	for _, unregister := range db.poolCollectors {
+		unregister()
+	}
+	db.poolCollectors = nil
+}
+
+// fingerprint
+//
+//	Returns the fingerprint that the statement is tracked under
+func (s *queryStats) fingerprint(query string) string {
+	s.lock.RLock()
+	fingerprint, ok := s.fingerprints[query]
+	s.lock.RUnlock()
+	if ok {
+		return fingerprint
+	}
+
+	fingerprint = FingerprintQuery(query)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// bound the cardinality of the metrics when statements are built dynamically
+	if !s.tracked[fingerprint] {
+		if len(s.tracked) >= s.maxFingerprints {
+			fingerprint = overflowFingerprint
+		} else {
+			s.tracked[fingerprint] = true
+		}
+	}
+	if len(s.fingerprints) < s.maxFingerprints*4 {
+		s.fingerprints[query] = fingerprint
+	}
+
+	return fingerprint
+}
+
+// observe
+//
+//	Records the execution of a statement that started at the passed time
+func (s *queryStats) observe(operation string, callerName *string, query string, args []interface{}, start time.Time, err error) {
+	elapsed := time.Since(start)
+	fingerprint := s.fingerprint(query)
+
+	s.duration.WithLabelValues(fingerprint, operation).Observe(elapsed.Seconds())
+	if err != nil && err != sql.ErrNoRows {
+		s.errors.WithLabelValues(fingerprint, operation).Inc()
+	}
+
+	if elapsed < s.slowThreshold {
+		return
+	}
+
+	s.slow.WithLabelValues(fingerprint, operation).Inc()
+	if s.logger == nil {
+		return
+	}
+
+	caller := "unknown"
+	if callerName != nil {
+		caller = *callerName
+	}
+	// log the normalized statement so that literals in the raw statement are not leaked
+	s.logger.Warnf("slow query: %s took %s (caller: %s)\n    statement: %s\n    args: %s",
+		operation, elapsed, caller, FingerprintQuery(query), redactArgs(args))
+}
+
+// observe
+//
+//	Records the execution of a statement if the database is instrumented
+func (db *Database) observe(operation string, callerName *string, query string, args []interface{}, start time.Time, err error) {
+	if stats := db.stats.Load(); stats != nil {
+		stats.observe(operation, callerName, query, args, start, err)
+	}
+}
+
+// observe
+//
+//	Records the execution of a statement if the database is instrumented
+func (tx *Tx) observe(operation string, callerName *string, query string, args []interface{}, start time.Time, err error) {
+	if tx.stats != nil {
+		tx.stats.observe(operation, callerName, query, args, start, err)
+	}
+}
+
+// instrumentedDBTX
+//
+//	DBTX that records the statements executed by the generated queries
+type instrumentedDBTX struct {
+	db    DBTX
+	stats *queryStats
+}
+
+func (d *instrumentedDBTX) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, err := d.db.ExecContext(ctx, query, args...)
+	d.stats.observe("exec", nil, query, args, start, err)
+	return res, err
+}
+
+func (d *instrumentedDBTX) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return d.db.PrepareContext(ctx, query)
+}
+
+func (d *instrumentedDBTX) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	d.stats.observe("query", nil, query, args, start, err)
+	return rows, err
+}
+
+func (d *instrumentedDBTX) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := d.db.QueryRowContext(ctx, query, args...)
+	d.stats.observe("queryrow", nil, query, args, start, row.Err())
+	return row
+}
@@ -0,0 +1,164 @@
+package ti
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gage-technologies/gigo-lib/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprintQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{
+			query: "select * from users where _id = ?",
+			want:  "select * from users where _id = ?",
+		},
+		{
+			query: "SELECT *\n  FROM users\n  WHERE user_name = 'gigo' AND xp > 420;",
+			want:  "select * from users where user_name = ? and xp > ?",
+		},
+		{
+			query: "select * from post where _id in (?, ?, ?) -- load the posts",
+			want:  "select * from post where _id in (?+)",
+		},
+		{
+			query: "insert into tag(_id, value) values (?, ?), (?, ?), (?, ?)",
+			want:  "insert into tag(_id, value) values (?+)",
+		},
+		{
+			query: "select /* hint */ bin_to_uuid(secret) from workspace_agent where secret = 0xdeadbeef and user_stats2 = 1",
+			want:  "select bin_to_uuid(secret) from workspace_agent where secret = ? and user_stats2 = ?",
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, FingerprintQuery(tt.query))
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	assert.Equal(t, "[int64, string(6), []byte(3), nil, time.Time]",
+		redactArgs([]interface{}{int64(42), "secret", []byte{1, 2, 3}, nil, time.Time{}}))
+}
+
+func TestDatabase_Instrument(t *testing.T) {
+	logger, err := logging.CreateBasicLogger(logging.NewDefaultBasicLoggerOptions("/tmp/gigo-lib-db-instrument-test.log"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	db := &Database{DB: &sql.DB{}, DBName: "gigo_dev_test"}
+	err = db.instrument(InstrumentationOptions{
+		Logger:             logger,
+		Registerer:         prometheus.NewRegistry(),
+		SlowQueryThreshold: time.Millisecond * 100,
+		MaxFingerprints:    2,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	stats := db.stats.Load()
+	callerName := "TestDatabase_Instrument"
+
+	db.observe("query", &callerName, "select * from users where _id = 1", nil, time.Now(), nil)
+	db.observe("query", &callerName, "select * from users where _id = 2", nil, time.Now().Add(-time.Second), nil)
+	db.observe("exec", nil, "delete from users where _id = ?", []interface{}{int64(1)}, time.Now(), errors.New("failed"))
+	db.observe("exec", nil, "delete from post where _id = ?", []interface{}{int64(1)}, time.Now(), nil)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(stats.slow.WithLabelValues("select * from users where _id = ?", "query")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(stats.errors.WithLabelValues("delete from users where _id = ?", "exec")))
+	// the third fingerprint exceeds the limit and is tracked as overflow
+	assert.Equal(t, overflowFingerprint, stats.fingerprint("delete from post where _id = ?"))
+	assert.Equal(t, 3, testutil.CollectAndCount(stats.duration))
+
+	// transactions inherit the instrumentation of the database
+	tx := &Tx{stats: stats}
+	tx.observe("exec", nil, "delete from users where _id = ?", nil, time.Now(), errors.New("failed"))
+	assert.Equal(t, 2.0, testutil.ToFloat64(stats.errors.WithLabelValues("delete from users where _id = ?", "exec")))
+}
+
+func TestDatabase_InstrumentShared(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	// databases with the same name share the registered metrics
+	first := &Database{DB: &sql.DB{}, DBName: "gigo_dev_test", Host: "gigo-dev-tidb", Port: "4000"}
+	second := &Database{DB: &sql.DB{}, DBName: "gigo_dev_test", Host: "gigo-dev-tidb", Port: "4000"}
+	for _, db := range []*Database{first, second} {
+		err := db.instrument(InstrumentationOptions{Registerer: registry})
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	first.observe("exec", nil, "delete from users where _id = ?", nil, time.Now(), errors.New("failed"))
+	second.observe("exec", nil, "delete from users where _id = ?", nil, time.Now(), errors.New("failed"))
+	assert.Same(t, first.stats.Load().errors, second.stats.Load().errors)
+	assert.Equal(t, 2.0, testutil.ToFloat64(second.stats.Load().errors.WithLabelValues("delete from users where _id = ?", "exec")))
+
+	// registration errors other than duplicates are returned
+	conflicting := prometheus.NewRegistry()
+	conflicting.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "query_duration_seconds",
+		Help:      "Latency of the statements executed against the database",
+	}))
+	third := &Database{DB: &sql.DB{}, DBName: "gigo_dev_test"}
+	assert.Error(t, third.instrument(InstrumentationOptions{Registerer: conflicting}))
+}
+
+func TestDatabase_InstrumentPools(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	db := &Database{
+		DB:           &sql.DB{},
+		DBName:       "gigo_dev_test",
+		Host:         "gigo-dev-tidb",
+		Port:         "4000",
+		replicas:     []*sql.DB{{}, {}},
+		replicaAddrs: []string{"gigo-dev-tidb-replica-0:4000", "gigo-dev-tidb-replica-1:4000"},
+	}
+	err := db.instrument(InstrumentationOptions{Registerer: registry})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// every pool reports its own stats
+	poolLabels := func() map[string]string {
+		pools := make(map[string]string)
+		families, err := registry.Gather()
+		if !assert.NoError(t, err) {
+			return pools
+		}
+		for _, family := range families {
+			if family.GetName() != "go_sql_open_connections" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				labels := make(map[string]string)
+				for _, label := range metric.GetLabel() {
+					labels[label.GetName()] = label.GetValue()
+				}
+				pools[labels["pool"]] = labels["addr"]
+			}
+		}
+		return pools
+	}
+	assert.Equal(t, map[string]string{
+		"primary":   "gigo-dev-tidb:4000",
+		"replica-0": "gigo-dev-tidb-replica-0:4000",
+		"replica-1": "gigo-dev-tidb-replica-1:4000",
+	}, poolLabels())
+
+	// the pools are removed from the metrics once the database is closed
+	db.unregisterPools()
+	assert.Empty(t, poolLabels())
+}
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN reset_token;
@@ -0,0 +1 @@
+ALTER TABLE follower DROP INDEX uk_follower_follower_following;
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN has_broadcast;
+ALTER TABLE users ADD COLUMN hasBroadcast boolean not null default false;
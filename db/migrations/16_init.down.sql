@@ -0,0 +1 @@
+-- Migration 16 is empty so there is nothing to reverse
@@ -0,0 +1 @@
+-- The unique constraints are restored by the down migration of 19
@@ -0,0 +1,2 @@
+ALTER TABLE recommended_post ADD CONSTRAINT uk_recommended_post_user_id UNIQUE (user_id);
+ALTER TABLE recommended_post ADD CONSTRAINT uk_recommended_post_post_id UNIQUE (post_id);
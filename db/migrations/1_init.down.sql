@@ -0,0 +1,49 @@
+-- Drop every table created by the initial schema
+drop table if exists zookies;
+drop table if exists xp_boosts;
+drop table if exists workspace_config_langs;
+drop table if exists workspace_config_tags;
+drop table if exists workspace_config;
+drop table if exists workspace_agent_stats;
+drop table if exists workspace_agent;
+drop table if exists workspaces;
+drop table if exists user_session_key;
+drop table if exists user_free_premium;
+drop table if exists user_saved_posts;
+drop table if exists user_badges;
+drop table if exists user_active_times;
+drop table if exists up_vote;
+drop table if exists thread_reply;
+drop table if exists thread_comment;
+drop table if exists tag;
+drop table if exists stats_xp;
+drop table if exists user_daily_usage;
+drop table if exists user_stats;
+drop table if exists search_rec_posts;
+drop table if exists search_rec;
+drop table if exists user_rewards_inventory;
+drop table if exists rewards;
+drop table if exists recommended_post;
+drop table if exists post_langs;
+drop table if exists post_tags;
+drop table if exists post_awards;
+drop table if exists post;
+drop table if exists notification;
+drop table if exists nemesis_history;
+drop table if exists nemesis;
+drop table if exists implicit_rec;
+drop table if exists friends;
+drop table if exists friend_requests;
+drop table if exists follower;
+drop table if exists discussion_up_vote;
+drop table if exists discussion_tags;
+drop table if exists discussion_awards;
+drop table if exists discussion;
+drop table if exists comment_awards;
+drop table if exists comment;
+drop table if exists coffee;
+drop table if exists broadcast_event;
+drop table if exists award;
+drop table if exists attempt_awards;
+drop table if exists attempt;
+drop table if exists users;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS xp_reasons;
+ALTER TABLE recommended_post DROP COLUMN accepted;
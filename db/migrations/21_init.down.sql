@@ -0,0 +1 @@
+ALTER TABLE recommended_post DROP COLUMN views;
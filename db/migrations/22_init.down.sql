@@ -0,0 +1,3 @@
+drop table if exists chat_messages;
+drop table if exists chat_users;
+drop table if exists chat;
@@ -0,0 +1 @@
+drop table if exists curated_post_type;
@@ -0,0 +1 @@
+ALTER TABLE curated_post ADD COLUMN proficiency_type int not null default 0;
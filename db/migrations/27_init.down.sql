@@ -0,0 +1,2 @@
+ALTER TABLE chat DROP COLUMN last_message;
+ALTER TABLE chat_users DROP COLUMN last_read_message;
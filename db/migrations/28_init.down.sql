@@ -0,0 +1 @@
+ALTER TABLE friend_requests DROP COLUMN notification_id;
@@ -0,0 +1 @@
+ALTER TABLE chat_users DROP COLUMN muted;
@@ -0,0 +1,2 @@
+ALTER TABLE user_stats DROP COLUMN closed;
+ALTER TABLE user_stats DROP COLUMN expiration;
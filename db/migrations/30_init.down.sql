@@ -0,0 +1,2 @@
+-- Messages longer than 500 characters will be truncated
+ALTER TABLE chat_messages MODIFY message varchar(500) NOT NULL;
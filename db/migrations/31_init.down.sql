@@ -0,0 +1,3 @@
+drop table if exists ephemeral_shared_workspaces;
+ALTER TABLE workspaces DROP COLUMN is_ephemeral;
+ALTER TABLE users DROP COLUMN is_ephemeral;
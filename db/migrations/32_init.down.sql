@@ -0,0 +1 @@
+drop table if exists volpool_volume;
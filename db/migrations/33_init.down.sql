@@ -0,0 +1,2 @@
+ALTER TABLE volpool_volume DROP COLUMN storage_class;
+ALTER TABLE post DROP COLUMN share_hash;
@@ -0,0 +1,12 @@
+-- Add outbox table so that jetstream messages can be written in the same
+-- transaction as the rows they describe and relayed once committed
+create table if not exists outbox_messages (
+    _id bigint not null primary key,
+    subject varchar(280) not null,
+    payload longblob not null,
+    created_at datetime(6) not null,
+    sent_at datetime(6),
+    attempts int not null default 0,
+    last_error text,
+    index outbox_messages_pending_idx (sent_at, _id)
+);
@@ -0,0 +1 @@
+drop table if exists database_versions;
@@ -0,0 +1,5 @@
+-- Add database_versions table to record every migration that has been applied
+create table if not exists database_versions (
+    version bigint not null primary key,
+    date datetime not null
+);
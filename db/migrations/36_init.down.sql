@@ -0,0 +1,3 @@
+ALTER TABLE xp_boosts DROP COLUMN multiplier;
+DROP INDEX xp_reasons_idempotency_idx ON xp_reasons;
+ALTER TABLE xp_reasons DROP COLUMN idempotency_key;
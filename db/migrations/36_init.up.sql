@@ -0,0 +1,6 @@
+-- Add idempotency key to xp_reasons so that retried XP grants are only applied once
+ALTER TABLE xp_reasons ADD COLUMN idempotency_key varchar(280);
+CREATE UNIQUE INDEX xp_reasons_idempotency_idx ON xp_reasons (user_id, idempotency_key);
+
+-- Add multiplier to xp_boosts; existing boosts double the XP that is granted
+ALTER TABLE xp_boosts ADD COLUMN multiplier double NOT NULL DEFAULT 2;
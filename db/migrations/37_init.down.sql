@@ -0,0 +1,2 @@
+drop table if exists account_deletion_steps;
+drop table if exists account_deletions;
@@ -0,0 +1,19 @@
+-- Add account deletion tables that persist the progress of each deletion
+-- so that a deletion that was interrupted can be resumed
+create table if not exists account_deletions (
+    user_id bigint not null primary key,
+    user_name varchar(280) not null,
+    requested_at datetime not null,
+    completed_at datetime,
+    index account_deletions_pending_idx (completed_at, requested_at)
+);
+
+create table if not exists account_deletion_steps (
+    user_id bigint not null,
+    step varchar(64) not null,
+    status int not null,
+    attempts int not null default 0,
+    last_error text,
+    updated_at datetime not null,
+    primary key (user_id, step)
+);
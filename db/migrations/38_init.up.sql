@@ -0,0 +1,15 @@
+-- Add the append-only audit log of privileged and sensitive changes
+create table if not exists audit_log (
+    _id bigint not null primary key,
+    actor_id bigint,
+    action varchar(64) not null,
+    target_type varchar(64) not null,
+    target_id bigint not null,
+    diff json,
+    ip varchar(45),
+    request_id varchar(64),
+    created_at datetime not null,
+    index audit_log_actor_idx (actor_id, created_at),
+    index audit_log_target_idx (target_type, target_id, created_at),
+    index audit_log_created_at_idx (created_at)
+);
@@ -0,0 +1,6 @@
+DROP INDEX follower_following_idx ON follower;
+DROP INDEX friend_requests_friend_idx ON friend_requests;
+DROP INDEX friend_requests_user_idx ON friend_requests;
+DROP INDEX friends_friend_idx ON friends;
+DROP INDEX friends_user_idx ON friends;
+drop table if exists user_blocks;
@@ -0,0 +1,14 @@
+-- Add block lists and the indexes used by the social graph queries
+create table if not exists user_blocks (
+    user_id bigint not null,
+    blocked_id bigint not null,
+    created_at datetime not null,
+    primary key (user_id, blocked_id),
+    index user_blocks_blocked_idx (blocked_id, user_id)
+);
+
+CREATE INDEX friends_user_idx ON friends (user_id, friend);
+CREATE INDEX friends_friend_idx ON friends (friend, user_id);
+CREATE INDEX friend_requests_user_idx ON friend_requests (user_id, friend);
+CREATE INDEX friend_requests_friend_idx ON friend_requests (friend, user_id);
+CREATE INDEX follower_following_idx ON follower (following, follower);
@@ -0,0 +1 @@
+-- Re-initializing user_stats is a data migration that cannot be reversed
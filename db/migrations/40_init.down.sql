@@ -0,0 +1,5 @@
+DROP INDEX user_active_times_end_time_idx ON user_active_times;
+DROP INDEX nemesis_history_created_at_idx ON nemesis_history;
+DROP INDEX nemesis_state_idx ON nemesis;
+DROP INDEX nemesis_protagonist_idx ON nemesis;
+DROP INDEX nemesis_antagonist_idx ON nemesis;
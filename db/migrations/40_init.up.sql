@@ -0,0 +1,6 @@
+-- Add the indexes used by nemesis matchmaking and resolution
+CREATE INDEX nemesis_antagonist_idx ON nemesis (antagonist_id, victor);
+CREATE INDEX nemesis_protagonist_idx ON nemesis (protagonist_id, victor);
+CREATE INDEX nemesis_state_idx ON nemesis (is_accepted, victor, end_time);
+CREATE INDEX nemesis_history_created_at_idx ON nemesis_history (created_at);
+CREATE INDEX user_active_times_end_time_idx ON user_active_times (end_time, user_id);
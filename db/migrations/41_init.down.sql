@@ -0,0 +1,4 @@
+DROP INDEX notification_unacknowledged_idx ON notification;
+ALTER TABLE notification DROP COLUMN collapsed_count;
+drop table if exists notification_digests;
+drop table if exists notification_preferences;
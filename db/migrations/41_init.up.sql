@@ -0,0 +1,19 @@
+-- Add per-user notification preferences, the number of notifications that
+-- were collapsed into a notification and the time of each user's last digest
+create table if not exists notification_preferences (
+    user_id bigint not null,
+    notification_type int not null,
+    in_app boolean not null default true,
+    email boolean not null default false,
+    muted boolean not null default false,
+    updated_at datetime not null,
+    primary key (user_id, notification_type)
+);
+
+create table if not exists notification_digests (
+    user_id bigint not null primary key,
+    last_sent_at datetime not null
+);
+
+ALTER TABLE notification ADD COLUMN collapsed_count int NOT NULL DEFAULT 0;
+CREATE INDEX notification_unacknowledged_idx ON notification (user_id, acknowledged, notification_type, created_at);
@@ -0,0 +1,2 @@
+DROP INDEX recommended_post_user_idx ON recommended_post;
+DROP INDEX implicit_rec_created_at_idx ON implicit_rec;
@@ -0,0 +1,3 @@
+-- Add the indexes used by the recommendation pipeline
+CREATE INDEX implicit_rec_created_at_idx ON implicit_rec (created_at, user_id, post_id, implicit_action);
+CREATE INDEX recommended_post_user_idx ON recommended_post (user_id, accepted);
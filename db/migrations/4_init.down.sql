@@ -0,0 +1 @@
+ALTER TABLE post DROP COLUMN deleted;
@@ -0,0 +1 @@
+ALTER TABLE attempt DROP COLUMN post_type;
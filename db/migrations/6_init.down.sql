@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN stripe_account;
@@ -0,0 +1 @@
+drop table if exists exclusive_content_purchases;
@@ -0,0 +1 @@
+ALTER TABLE recommended_post DROP INDEX uk_recommended_p_post_i_user_i;
@@ -0,0 +1,401 @@
+package ti
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/mysql"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// migrationFilePattern matches migration files in the form <version>_<name>.<up|down>.sql
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// MigrationStatus
+//
+//	Current state of the database schema
+type MigrationStatus struct {
+	// Version is the currently applied migration; 0 means no migrations have been applied
+	Version uint
+	// Dirty is true when a migration failed part way through and
+	// the version must be forced before migrating again
+	Dirty bool
+	// Latest is the newest migration available
+	Latest uint
+	// Pending lists the migrations that have not been applied
+	Pending []uint
+}
+
+// Migrator
+//
+//	Manages the schema of a database using the migrations embedded in
+//	this package. Migrations are read directly from the embedded files.
+//	Every operation keeps the database_versions table in sync with the
+//	applied migrations.
+type Migrator struct {
+	db       *sql.DB
+	migrate  *migrate.Migrate
+	versions []uint
+}
+
+// NewMigrator
+//
+//	Creates a new Migrator for the passed database
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	versions, err := migrationVersions(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	sourceDriver, err := iofs.New(migrations, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration source: %v", err)
+	}
+
+	migrationDriver, err := mysql.WithInstance(db, &mysql.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration driver: %v", err)
+	}
+
+	migrator, err := migrate.NewWithInstance("iofs", sourceDriver, "mysql", migrationDriver)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrator: %v", err)
+	}
+
+	return &Migrator{
+		db:       db,
+		migrate:  migrator,
+		versions: versions,
+	}, nil
+}
+
+// Status
+//
+//	Returns the current state of the database schema
+func (m *Migrator) Status() (*MigrationStatus, error) {
+	version, dirty, err := m.version()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{
+		Version: version,
+		Dirty:   dirty,
+		Pending: make([]uint, 0),
+	}
+
+	for _, v := range m.versions {
+		if v > version {
+			status.Pending = append(status.Pending, v)
+		}
+	}
+
+	if len(m.versions) > 0 {
+		status.Latest = m.versions[len(m.versions)-1]
+	}
+
+	return status, nil
+}
+
+// Up
+//
+//	Applies all pending migrations
+func (m *Migrator) Up() error {
+	err := m.migrate.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to run migrations: %v", err)
+	}
+	return m.syncVersions()
+}
+
+// UpTo
+//
+//	Applies pending migrations up to and including the passed version
+func (m *Migrator) UpTo(version uint) error {
+	current, _, err := m.version()
+	if err != nil {
+		return err
+	}
+
+	if version < current {
+		return fmt.Errorf("cannot migrate up to %d: database is already at %d", version, current)
+	}
+
+	return m.migrateTo(version)
+}
+
+// DownTo
+//
+//	Reverts applied migrations until the passed version is the
+//	latest applied migration. Passing 0 reverts every migration.
+func (m *Migrator) DownTo(version uint) error {
+	current, _, err := m.version()
+	if err != nil {
+		return err
+	}
+
+	if version > current {
+		return fmt.Errorf("cannot migrate down to %d: database is at %d", version, current)
+	}
+
+	if version == 0 {
+		err = m.migrate.Down()
+		if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			return fmt.Errorf("failed to revert migrations: %v", err)
+		}
+		return m.syncVersions()
+	}
+
+	return m.migrateTo(version)
+}
+
+// Force
+//
+//	Sets the migration version without running any migrations and
+//	clears the dirty flag. This is used to recover from a failed
+//	migration after the database has been repaired manually.
+func (m *Migrator) Force(version uint) error {
+	if version > 0 && !m.hasVersion(version) {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	forced := int(version)
+	if version == 0 {
+		// golang-migrate represents an empty database as -1
+		forced = -1
+	}
+
+	err := m.migrate.Force(forced)
+	if err != nil {
+		return fmt.Errorf("failed to force migration version: %v", err)
+	}
+
+	return m.syncVersions()
+}
+
+// migrateTo
+//
+//	Migrates up or down to the passed version
+func (m *Migrator) migrateTo(version uint) error {
+	if !m.hasVersion(version) {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	err := m.migrate.Migrate(version)
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to migrate to %d: %v", version, err)
+	}
+
+	return m.syncVersions()
+}
+
+// version
+//
+//	Returns the current version of the database and whether it is dirty
+func (m *Migrator) version() (uint, bool, error) {
+	version, dirty, err := m.migrate.Version()
+	if err != nil {
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to retrieve migration version: %v", err)
+	}
+	return version, dirty, nil
+}
+
+// hasVersion
+//
+//	Returns whether a migration exists for the passed version
+func (m *Migrator) hasVersion(version uint) bool {
+	i := sort.Search(len(m.versions), func(i int) bool { return m.versions[i] >= version })
+	return i < len(m.versions) && m.versions[i] == version
+}
+
+// syncVersions
+//
+//	Updates the database_versions table so that it contains exactly the
+//	migrations that have been applied. The table is created by a migration
+//	itself so the sync is skipped while the table does not exist.
+func (m *Migrator) syncVersions() error {
+	current, dirty, err := m.version()
+	if err != nil {
+		return err
+	}
+
+	// leave the table untouched until a dirty database is repaired
+	if dirty {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	var exists int
+	err = m.db.QueryRowContext(ctx,
+		"select count(*) from information_schema.tables where table_schema = database() and table_name = 'database_versions'",
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check for database_versions table: %v", err)
+	}
+	if exists == 0 {
+		return nil
+	}
+
+	_, err = m.db.ExecContext(ctx, "delete from database_versions where version > ?", current)
+	if err != nil {
+		return fmt.Errorf("failed to remove reverted database versions: %v", err)
+	}
+
+	// load the versions that have already been recorded
+	res, err := m.db.QueryContext(ctx, "select version from database_versions")
+	if err != nil {
+		return fmt.Errorf("failed to query database versions: %v", err)
+	}
+	recorded := make(map[uint]bool)
+	for res.Next() {
+		var v uint
+		err = res.Scan(&v)
+		if err != nil {
+			_ = res.Close()
+			return fmt.Errorf("failed to scan database version: %v", err)
+		}
+		recorded[v] = true
+	}
+	_ = res.Close()
+
+	queries := New(m.db)
+	for _, v := range m.versions {
+		if v > current {
+			break
+		}
+		if recorded[v] {
+			continue
+		}
+		err = queries.InsertDatabaseVersion(ctx, int64(v))
+		if err != nil {
+			return fmt.Errorf("failed to record database version %d: %v", v, err)
+		}
+	}
+
+	return nil
+}
+
+// LintMigrations
+//
+//	Checks the migrations in the passed directory of the filesystem for
+//	malformed file names, duplicate versions, gaps in the numbering and
+//	up migrations without a matching down migration. Every problem found
+//	is included in the returned error.
+func LintMigrations(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to read migrations directory: %v", err)
+	}
+
+	problems := make([]string, 0)
+	ups := make(map[uint]string)
+	downs := make(map[uint]string)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		match := migrationFilePattern.FindStringSubmatch(name)
+		if match == nil {
+			problems = append(problems, fmt.Sprintf("%s: file name does not match <version>_<name>.<up|down>.sql", path.Join(dir, name)))
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid version: %v", name, err))
+			continue
+		}
+
+		files := ups
+		if match[3] == "down" {
+			files = downs
+		}
+
+		if existing, ok := files[uint(version)]; ok {
+			problems = append(problems, fmt.Sprintf("%s: duplicate %s migration for version %d (also %s)", name, match[3], version, existing))
+			continue
+		}
+		files[uint(version)] = name
+	}
+
+	versions := make([]uint, 0, len(ups))
+	for v := range ups {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	for i, v := range versions {
+		if v != uint(i+1) {
+			problems = append(problems, fmt.Sprintf("version %d: expected version %d, numbering has a gap", v, i+1))
+			break
+		}
+	}
+
+	for _, v := range versions {
+		if _, ok := downs[v]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: missing down migration", ups[v]))
+		}
+	}
+
+	for v, name := range downs {
+		if _, ok := ups[v]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: down migration has no up migration", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("invalid migrations:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
+
+// LintEmbeddedMigrations
+//
+//	Runs LintMigrations against the migrations embedded in this package
+func LintEmbeddedMigrations() error {
+	return LintMigrations(migrations, "migrations")
+}
+
+// migrationVersions
+//
+//	Returns the sorted versions of the up migrations in the directory
+func migrationVersions(fsys fs.FS, dir string) ([]uint, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %v", err)
+	}
+
+	versions := make([]uint, 0)
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil || match[3] != "up" {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q: %v", entry.Name(), err)
+		}
+		versions = append(versions, uint(version))
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	return versions, nil
+}
@@ -0,0 +1,66 @@
+package ti
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintEmbeddedMigrations(t *testing.T) {
+	assert.NoError(t, LintEmbeddedMigrations())
+}
+
+func TestLintMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/1_init.up.sql":   {Data: []byte("create table a (id int);")},
+		"migrations/1_init.down.sql": {Data: []byte("drop table a;")},
+		"migrations/2_init.up.sql":   {Data: []byte("create table b (id int);")},
+		"migrations/4_init.up.sql":   {Data: []byte("create table c (id int);")},
+		"migrations/4_init.down.sql": {Data: []byte("drop table c;")},
+		"migrations/5_init.down.sql": {Data: []byte("drop table d;")},
+		"migrations/readme.md":       {Data: []byte("test")},
+	}
+
+	err := LintMigrations(fsys, "migrations")
+	if !assert.Error(t, err) {
+		return
+	}
+
+	for _, problem := range []string{
+		"migrations/readme.md: file name does not match",
+		"version 4: expected version 3",
+		"2_init.up.sql: missing down migration",
+		"5_init.down.sql: down migration has no up migration",
+	} {
+		assert.True(t, strings.Contains(err.Error(), problem), "missing problem %q in %v", problem, err)
+	}
+}
+
+func TestMigrator_Status(t *testing.T) {
+	db, err := CreateDatabase("gigo-dev-tidb", "4000", "mysql", "gigo-dev",
+		"gigo-dev",
+		"gigo_dev_test")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	migrator, err := NewMigrator(db.DB)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	status, err := migrator.Status()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, status.Dirty)
+	assert.Equal(t, status.Latest, status.Version)
+	assert.Empty(t, status.Pending)
+
+	var count uint
+	err = db.DB.QueryRow("select count(*) from database_versions").Scan(&count)
+	assert.NoError(t, err)
+	assert.Equal(t, status.Version, count)
+}
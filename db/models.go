@@ -0,0 +1,642 @@
+package ti
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type AccountDeletion struct {
+	UserID      int64
+	UserName    string
+	RequestedAt time.Time
+	CompletedAt sql.NullTime
+}
+
+type AccountDeletionStep struct {
+	UserID    int64
+	Step      string
+	Status    int32
+	Attempts  int32
+	LastError sql.NullString
+	UpdatedAt time.Time
+}
+
+type Attempt struct {
+	ID                int64
+	PostTitle         string
+	Description       string
+	Author            string
+	AuthorID          int64
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	RepoID            int64
+	AuthorTier        int32
+	Coffee            int64
+	PostID            int64
+	Closed            bool
+	Success           bool
+	ClosedDate        sql.NullTime
+	Tier              int32
+	ParentAttempt     sql.NullInt64
+	WorkspaceSettings json.RawMessage
+	PostType          int32
+}
+
+type AttemptAward struct {
+	AttemptID int64
+	AwardID   int64
+}
+
+type AuditLog struct {
+	ID         int64
+	ActorID    sql.NullInt64
+	Action     string
+	TargetType string
+	TargetID   int64
+	Diff       json.RawMessage
+	Ip         sql.NullString
+	RequestID  sql.NullString
+	CreatedAt  time.Time
+}
+
+type Award struct {
+	ID    int64
+	Types int32
+	Award string
+}
+
+type BroadcastEvent struct {
+	ID            int64
+	UserID        int64
+	UserName      string
+	Message       string
+	BroadcastType int32
+	TimePosted    time.Time
+}
+
+type Chat struct {
+	ID              int64
+	Name            string
+	Type            int32
+	LastMessageTime sql.NullTime
+	LastMessage     sql.NullInt64
+}
+
+type ChatMessage struct {
+	ID        int64
+	ChatID    int64
+	AuthorID  int64
+	Author    string
+	Message   string
+	CreatedAt time.Time
+	Revision  int64
+	Type      int32
+}
+
+type ChatUser struct {
+	ChatID          int64
+	UserID          int64
+	CreatedAt       time.Time
+	LastReadMessage sql.NullInt64
+	Muted           sql.NullBool
+}
+
+type Coffee struct {
+	ID           int64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	PostID       sql.NullInt64
+	AttemptID    sql.NullInt64
+	UserID       int64
+	DiscussionID int64
+}
+
+type Comment struct {
+	ID              sql.NullInt64
+	Body            string
+	Author          string
+	AuthorID        int64
+	CreatedAt       time.Time
+	AuthorTier      int32
+	Coffee          int64
+	DiscussionID    int64
+	Leads           bool
+	Revision        int32
+	DiscussionLevel int32
+}
+
+type CommentAward struct {
+	CommentID int64
+	AwardID   int64
+	Revision  int32
+}
+
+type CuratedPost struct {
+	ID           int64
+	PostID       int64
+	PostLanguage int32
+}
+
+type CuratedPostType struct {
+	CuratedID       int64
+	ProficiencyType int32
+}
+
+type DatabaseVersion struct {
+	Version int64
+	Date    time.Time
+}
+
+type Discussion struct {
+	ID              sql.NullInt64
+	Body            string
+	Author          string
+	AuthorID        int64
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	AuthorTier      int32
+	Coffee          int64
+	PostID          int64
+	Title           string
+	Leads           bool
+	Revision        int32
+	DiscussionLevel int32
+}
+
+type DiscussionAward struct {
+	DiscussionID int64
+	AwardID      int64
+	Revision     int32
+}
+
+type DiscussionTag struct {
+	DiscussionID int64
+	TagID        int64
+	Revision     int32
+}
+
+type DiscussionUpVote struct {
+	DiscussionID int64
+	UpVoteID     int64
+	UserID       int64
+}
+
+type EphemeralSharedWorkspace struct {
+	WorkspaceID int64
+	Ip          int64
+	Date        time.Time
+	UserID      int64
+	ChallengeID int64
+}
+
+type ExclusiveContentPurchase struct {
+	UserID int64
+	Post   int64
+	Date   time.Time
+}
+
+type Follower struct {
+	Follower  int64
+	Following int64
+}
+
+type Friend struct {
+	ID         int64
+	UserID     int64
+	UserName   string
+	Friend     int64
+	FriendName string
+	Date       time.Time
+}
+
+type FriendRequest struct {
+	ID             int64
+	UserID         int64
+	UserName       string
+	Friend         int64
+	FriendName     string
+	Response       sql.NullBool
+	Date           time.Time
+	NotificationID int64
+}
+
+type ImplicitRec struct {
+	ID               int64
+	UserID           int64
+	PostID           int64
+	SessionID        []byte
+	ImplicitAction   int32
+	CreatedAt        time.Time
+	UserTierAtAction int32
+}
+
+type Nemesis struct {
+	ID                        int64
+	AntagonistID              int64
+	AntagonistName            string
+	AntagonistTowersCaptured  int64
+	ProtagonistID             int64
+	ProtagonistName           string
+	ProtagonistTowersCaptured int64
+	TimeOfVillainy            time.Time
+	Victor                    sql.NullInt64
+	IsAccepted                bool
+	EndTime                   sql.NullTime
+}
+
+type NemesisHistory struct {
+	ID                    int64
+	MatchID               int64
+	AntagonistID          int64
+	ProtagonistID         int64
+	ProtagonistTowersHeld int64
+	AntagonistTowersHeld  int64
+	ProtagonistTotalXp    int64
+	AntagonistTotalXp     int64
+	IsAlerted             bool
+	CreatedAt             time.Time
+}
+
+type Notification struct {
+	ID                int64
+	UserID            int64
+	Message           string
+	NotificationType  int32
+	CreatedAt         time.Time
+	Acknowledged      bool
+	InteractingUserID sql.NullInt64
+	CollapsedCount    int32
+}
+
+type NotificationDigest struct {
+	UserID     int64
+	LastSentAt time.Time
+}
+
+type NotificationPreference struct {
+	UserID           int64
+	NotificationType int32
+	InApp            bool
+	Email            bool
+	Muted            bool
+	UpdatedAt        time.Time
+}
+
+type OutboxMessage struct {
+	ID        int64
+	Subject   string
+	Payload   []byte
+	CreatedAt time.Time
+	SentAt    sql.NullTime
+	Attempts  int32
+	LastError sql.NullString
+}
+
+type Post struct {
+	ID                      int64
+	Title                   string
+	Description             string
+	Author                  string
+	AuthorID                int64
+	CreatedAt               time.Time
+	UpdatedAt               time.Time
+	RepoID                  int64
+	TopReply                sql.NullInt64
+	Tier                    int32
+	Coffee                  int64
+	PostType                int32
+	Views                   int64
+	Completions             int64
+	Attempts                int64
+	Published               bool
+	Visibility              int32
+	ChallengeCost           sql.NullString
+	StripePriceID           sql.NullString
+	WorkspaceConfig         int64
+	WorkspaceConfigRevision int32
+	WorkspaceSettings       json.RawMessage
+	Leads                   bool
+	Embedded                bool
+	Deleted                 bool
+	ExclusiveDescription    sql.NullString
+	ShareHash               []byte
+}
+
+type PostAward struct {
+	PostID  int64
+	AwardID int64
+}
+
+type PostLang struct {
+	PostID int32
+	LangID int64
+}
+
+type PostTag struct {
+	PostID int64
+	TagID  int64
+}
+
+type RecommendedPost struct {
+	ID            int64
+	UserID        int64
+	PostID        int64
+	Type          int32
+	ReferenceID   int64
+	Score         float64
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	ReferenceTier int32
+	Accepted      bool
+	Views         int64
+}
+
+type ReportIssue struct {
+	ID     int64
+	UserID int64
+	Date   time.Time
+	Issue  string
+	Page   string
+}
+
+type Reward struct {
+	ID            int64
+	ColorPalette  string
+	RenderInFront bool
+	Name          string
+}
+
+type SearchRec struct {
+	ID               int64
+	UserID           int64
+	Query            string
+	SelectedPostID   sql.NullInt64
+	SelectedPostName sql.NullString
+	CreatedAt        time.Time
+}
+
+type SearchRecPost struct {
+	SearchID int64
+	PostID   int64
+}
+
+type StatsXp struct {
+	StatsID    int64
+	Expiration time.Time
+}
+
+type Tag struct {
+	ID         int64
+	Value      string
+	Official   bool
+	UsageCount int64
+}
+
+type ThreadComment struct {
+	ID              int64
+	Body            string
+	Author          string
+	AuthorID        int64
+	CreatedAt       time.Time
+	AuthorTier      int32
+	Coffee          int64
+	CommentID       int64
+	Leads           bool
+	Revision        int32
+	DiscussionLevel int32
+}
+
+type ThreadReply struct {
+	ID              int64
+	Body            string
+	Author          string
+	AuthorID        int64
+	CreatedAt       time.Time
+	AuthorTier      int32
+	Coffee          int64
+	ThreadCommentID int64
+	Revision        int32
+	DiscussionLevel int32
+}
+
+type UpVote struct {
+	ID             int64
+	DiscussionType int32
+	DiscussionID   int64
+	UserID         int64
+}
+
+type User struct {
+	ID                  int64
+	Email               string
+	UserStatus          int32
+	UserName            string
+	Password            string
+	Phone               string
+	Bio                 string
+	Xp                  int64
+	Level               int32
+	Tier                int32
+	UserRank            int32
+	Coffee              int64
+	Otp                 sql.NullString
+	OtpValidated        sql.NullBool
+	StripeUser          sql.NullString
+	StripeSubscription  sql.NullString
+	FirstName           sql.NullString
+	LastName            sql.NullString
+	AuthRole            int32
+	GiteaID             int64
+	ExternalAuth        sql.NullString
+	CreatedAt           time.Time
+	WorkspaceSettings   json.RawMessage
+	FollowerCount       int64
+	StartUserInfo       json.RawMessage
+	HighestScore        int64
+	Timezone            string
+	AvatarSettings      json.RawMessage
+	BroadcastThreshold  int64
+	AvatarReward        sql.NullInt64
+	EncryptedServiceKey []byte
+	StripeAccount       sql.NullString
+	ExclusiveAgreement  bool
+	ResetToken          sql.NullString
+	HasBroadcast        bool
+	HolidayThemes       bool
+	Tutorials           json.RawMessage
+	IsEphemeral         bool
+}
+
+type UserActiveTime struct {
+	ID        int64
+	UserID    int64
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+type UserBadge struct {
+	UserID  int64
+	BadgeID int64
+}
+
+type UserBlock struct {
+	UserID    int64
+	BlockedID int64
+	CreatedAt time.Time
+}
+
+type UserDailyUsage struct {
+	UserID      int64
+	StartTime   time.Time
+	EndTime     sql.NullTime
+	OpenSession int32
+	Date        time.Time
+}
+
+type UserFreePremium struct {
+	ID        int64
+	UserID    int64
+	StartDate time.Time
+	EndDate   time.Time
+	Length    string
+}
+
+type UserRewardsInventory struct {
+	RewardID int64
+	UserID   int64
+}
+
+type UserSavedPost struct {
+	UserID int64
+	PostID int64
+}
+
+type UserSessionKey struct {
+	ID         int64
+	Key        []byte
+	Expiration time.Time
+}
+
+type UserStat struct {
+	ID                  int64
+	UserID              int64
+	ChallengesCompleted sql.NullInt32
+	StreakActive        sql.NullBool
+	CurrentStreak       sql.NullInt32
+	LongestStreak       int32
+	TotalTimeSpent      int64
+	AvgTime             int64
+	DaysOnPlatform      int32
+	DaysOnFire          int32
+	StreakFreezes       int32
+	StreakFreezeUsed    bool
+	XpGained            int64
+	Date                time.Time
+	Expiration          time.Time
+	Closed              bool
+}
+
+type VolpoolVolume struct {
+	ID           int64
+	Size         int32
+	State        int32
+	PvcName      string
+	WorkspaceID  sql.NullInt64
+	StorageClass sql.NullString
+}
+
+type Workspace struct {
+	ID                int64
+	CodeSourceID      int64
+	CodeSourceType    int32
+	RepoID            int64
+	CreatedAt         time.Time
+	OwnerID           int64
+	TemplateID        int64
+	Expiration        time.Time
+	Commit            string
+	State             int64
+	InitState         int32
+	InitFailure       json.RawMessage
+	LastStateUpdate   time.Time
+	WorkspaceSettings json.RawMessage
+	OverAllocated     json.RawMessage
+	Ports             json.RawMessage
+	IsEphemeral       bool
+}
+
+type WorkspaceAgent struct {
+	ID                int64
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	FirstConnect      sql.NullTime
+	LastConnect       sql.NullTime
+	LastDisconnect    sql.NullTime
+	LastConnectedNode sql.NullInt64
+	DisconnectCount   int32
+	State             int32
+	WorkspaceID       int64
+	Version           string
+	OwnerID           int64
+	Secret            []byte
+}
+
+type WorkspaceAgentStat struct {
+	ID           int64
+	AgentID      int64
+	WorkspaceID  int64
+	Timestamp    time.Time
+	ConnsByProto json.RawMessage
+	NumComms     int64
+	RxPackets    int64
+	RxBytes      int64
+	TxPackets    int64
+	TxBytes      int64
+}
+
+type WorkspaceConfig struct {
+	ID          int64
+	Title       string
+	Description string
+	Content     string
+	AuthorID    int64
+	Revision    int32
+	Official    bool
+}
+
+type WorkspaceConfigLang struct {
+	CfgID    int32
+	LangID   int64
+	Revision int32
+}
+
+type WorkspaceConfigTag struct {
+	CfgID    int64
+	TagID    int64
+	Revision int32
+}
+
+type XpBoost struct {
+	ID         int64
+	UserID     int64
+	EndDate    sql.NullTime
+	Multiplier float64
+}
+
+type XpReason struct {
+	ID             int64
+	UserID         int64
+	Date           time.Time
+	Reason         string
+	Xp             int64
+	IdempotencyKey sql.NullString
+}
+
+type Zookies struct {
+	RID     string
+	SID     string
+	Z       string
+	Time    time.Time
+	EndDate sql.NullTime
+}
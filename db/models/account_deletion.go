@@ -0,0 +1,733 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultTombstoneUserID is the author id that replaces the id of a deleted user
+	DefaultTombstoneUserID int64 = 0
+	// DefaultTombstoneUserName is the author name that replaces the name of a deleted user
+	DefaultTombstoneUserName = "[deleted]"
+
+	// searchUpdateBatchSize is the number of documents updated in a single search request
+	searchUpdateBatchSize = 1000
+)
+
+var (
+	// ErrAccountDeletionNotRequested is returned when a deletion is run
+	// for a user that does not have a pending deletion
+	ErrAccountDeletionNotRequested = errors.New("account deletion has not been requested")
+	// ErrAccountDeletionActiveWorkspaces is returned by the private data step
+	// while the user still owns workspaces that have not been destroyed
+	ErrAccountDeletionActiveWorkspaces = errors.New("user owns workspaces that have not been destroyed")
+)
+
+type AccountDeletionStepStatus int
+
+const (
+	AccountDeletionStepPending AccountDeletionStepStatus = iota
+	AccountDeletionStepCompleted
+	AccountDeletionStepFailed
+	AccountDeletionStepSkipped
+)
+
+func (s AccountDeletionStepStatus) String() string {
+	switch s {
+	case AccountDeletionStepPending:
+		return "Pending"
+	case AccountDeletionStepCompleted:
+		return "Completed"
+	case AccountDeletionStepFailed:
+		return "Failed"
+	case AccountDeletionStepSkipped:
+		return "Skipped"
+	}
+	return "Unknown"
+}
+
+// AccountDeletionUserDeleter
+//
+//	Deletes the user from the version control system. Deleting a user
+//	that does not exist must succeed. Implemented by git.VCSClient.
+type AccountDeletionUserDeleter interface {
+	DeleteUser(userName string) error
+}
+
+// AccountDeletionSearchEngine
+//
+//	Updates the search indices of a deleted user. Implemented by
+//	search.MeiliSearchEngine.
+type AccountDeletionSearchEngine interface {
+	UpdateDocuments(index string, documents ...interface{}) error
+	DeleteDocuments(index string, ids ...interface{}) error
+}
+
+// AccountDeletionPermissions
+//
+//	Removes every permission relation that has the user as its
+//	subject or resource
+type AccountDeletionPermissions interface {
+	DeleteUserRelations(ctx context.Context, userID int64) error
+}
+
+// AccountDeletionStepState
+//
+//	Persisted state of a single step of an account deletion
+type AccountDeletionStepState struct {
+	Step      string                    `json:"step" sql:"step"`
+	Status    AccountDeletionStepStatus `json:"status" sql:"status"`
+	Attempts  int                       `json:"attempts" sql:"attempts"`
+	LastError *string                   `json:"last_error" sql:"last_error"`
+	UpdatedAt time.Time                 `json:"updated_at" sql:"updated_at"`
+}
+
+// AccountDeletionStatus
+//
+//	Persisted state of an account deletion
+type AccountDeletionStatus struct {
+	UserID      int64                       `json:"user_id" sql:"user_id"`
+	UserName    string                      `json:"user_name" sql:"user_name"`
+	RequestedAt time.Time                   `json:"requested_at" sql:"requested_at"`
+	CompletedAt *time.Time                  `json:"completed_at" sql:"completed_at"`
+	Steps       []*AccountDeletionStepState `json:"steps"`
+}
+
+// accountDeletionStep
+//
+//	Step of an account deletion. Steps must be idempotent since a step
+//	that was interrupted is executed again when the deletion is resumed.
+//	A step returns false if it was skipped because the system it cleans
+//	up is not configured. Skipped steps are retried by a deleter that has
+//	the system configured, which is reported by configured; steps without
+//	configured never depend on an optional system.
+type accountDeletionStep struct {
+	name       string
+	run        func(d *AccountDeleter, ctx context.Context, span *trace.Span, callerName *string, status *AccountDeletionStatus) (bool, error)
+	configured func(d *AccountDeleter) bool
+}
+
+// accountDeletionSteps are executed in order. The search documents are
+// updated before the content is anonymized since the authored content can
+// no longer be found once it has been anonymized, and the user's row is
+// deleted last so the deletion can be inspected until it completes.
+var accountDeletionSteps = []accountDeletionStep{
+	{name: "revoke_sessions", run: (*AccountDeleter).revokeSessions, configured: func(d *AccountDeleter) bool { return d.rdb != nil }},
+	{name: "update_search", run: (*AccountDeleter).updateSearch, configured: func(d *AccountDeleter) bool { return d.search != nil }},
+	{name: "delete_permissions", run: (*AccountDeleter).deletePermissions, configured: func(d *AccountDeleter) bool { return d.permissions != nil }},
+	{name: "delete_vcs_user", run: (*AccountDeleter).deleteVCSUser, configured: func(d *AccountDeleter) bool { return d.vcs != nil }},
+	{name: "anonymize_content", run: (*AccountDeleter).anonymizeContent},
+	{name: "delete_private_data", run: (*AccountDeleter).deletePrivateData},
+	{name: "delete_user", run: (*AccountDeleter).deleteUser},
+}
+
+// accountDeletionAuthoredTables are the tables of public content that
+// is kept and attributed to the tombstone user
+var accountDeletionAuthoredTables = []string{
+	"post",
+	"attempt",
+	"discussion",
+	"comment",
+	"thread_comment",
+	"thread_reply",
+	"chat_messages",
+}
+
+// accountDeletionPrivateData are the statements that remove the user's
+// private data. Every placeholder is bound to the id of the user.
+var accountDeletionPrivateData = []string{
+	"delete from user_daily_usage where user_id = ?",
+	"delete from stats_xp where stats_id in (select _id from user_stats where user_id = ?)",
+	"delete from user_stats where user_id = ?",
+	"delete from notification where user_id = ?",
+	"update notification set interacting_user_id = null where interacting_user_id = ?",
+	"delete from notification_preferences where user_id = ?",
+	"delete from notification_digests where user_id = ?",
+	"delete from xp_reasons where user_id = ?",
+	"delete from xp_boosts where user_id = ?",
+	"delete from coffee where user_id = ?",
+	"delete from up_vote where user_id = ?",
+	"delete from discussion_up_vote where user_id = ?",
+	"delete from friends where user_id = ? or friend = ?",
+	"delete from friend_requests where user_id = ? or friend = ?",
+	"delete from follower where follower = ? or following = ?",
+	"delete from user_blocks where user_id = ? or blocked_id = ?",
+	"delete from search_rec_posts where search_id in (select _id from search_rec where user_id = ?)",
+	"delete from search_rec where user_id = ?",
+	"delete from implicit_rec where user_id = ?",
+	"delete from recommended_post where user_id = ?",
+	"delete from user_saved_posts where user_id = ?",
+	"delete from user_badges where user_id = ?",
+	"delete from user_rewards_inventory where user_id = ?",
+	"delete from user_active_times where user_id = ?",
+	"delete from user_free_premium where user_id = ?",
+	"delete from exclusive_content_purchases where user_id = ?",
+	"delete from report_issue where user_id = ?",
+	"delete from chat_users where user_id = ?",
+	"delete from nemesis_history where antagonist_id = ? or protagonist_id = ?",
+	"delete from nemesis where antagonist_id = ? or protagonist_id = ?",
+	"delete from ephemeral_shared_workspaces where user_id = ?",
+	"delete from workspace_agent_stats where workspace_id in (select _id from workspaces where owner_id = ?)",
+	"delete from workspace_agent where owner_id = ?",
+	"delete from workspaces where owner_id = ?",
+}
+
+// AccountDeleterOptions
+//
+//	Options for an AccountDeleter. The external systems are optional;
+//	the steps of the systems that are not configured are skipped and
+//	retried by a deleter that has the system configured.
+type AccountDeleterOptions struct {
+	DB          *ti.Database
+	RDB         redis.UniversalClient
+	VCS         AccountDeletionUserDeleter
+	Search      AccountDeletionSearchEngine
+	Permissions AccountDeletionPermissions
+	// TombstoneUserID and TombstoneUserName replace the author of the
+	// user's public content; default to DefaultTombstoneUserID and
+	// DefaultTombstoneUserName
+	TombstoneUserID   int64
+	TombstoneUserName string
+	// SearchUserIndex is the index holding the user documents; defaults to users
+	SearchUserIndex string
+	// SearchContentIndices maps the search indices that hold authored
+	// content to the table of the content; defaults to the posts,
+	// discussion, comment and thread_comment indices
+	SearchContentIndices map[string]string
+}
+
+// AccountDeleter
+//
+//	Deletes user accounts. A deletion is first requested, which records
+//	the user's name so the external systems can be cleaned up after the
+//	user's row is gone, and then run. Running a deletion executes each
+//	step that has not completed and persists the status of the step, so a
+//	deletion that failed or crashed is continued by running it again.
+type AccountDeleter struct {
+	db                   *ti.Database
+	rdb                  redis.UniversalClient
+	vcs                  AccountDeletionUserDeleter
+	search               AccountDeletionSearchEngine
+	permissions          AccountDeletionPermissions
+	tombstoneUserID      int64
+	tombstoneUserName    string
+	searchUserIndex      string
+	searchContentIndices map[string]string
+}
+
+// NewAccountDeleter
+//
+//	Creates a new AccountDeleter
+func NewAccountDeleter(opts AccountDeleterOptions) (*AccountDeleter, error) {
+	if opts.DB == nil {
+		return nil, fmt.Errorf("account deleter requires a database")
+	}
+	if opts.TombstoneUserName == "" {
+		opts.TombstoneUserID = DefaultTombstoneUserID
+		opts.TombstoneUserName = DefaultTombstoneUserName
+	}
+	if opts.SearchUserIndex == "" {
+		opts.SearchUserIndex = "users"
+	}
+	if opts.SearchContentIndices == nil {
+		opts.SearchContentIndices = map[string]string{
+			"posts":          "post",
+			"discussion":     "discussion",
+			"comment":        "comment",
+			"thread_comment": "thread_comment",
+		}
+	}
+
+	return &AccountDeleter{
+		db:                   opts.DB,
+		rdb:                  opts.RDB,
+		vcs:                  opts.VCS,
+		search:               opts.Search,
+		permissions:          opts.Permissions,
+		tombstoneUserID:      opts.TombstoneUserID,
+		tombstoneUserName:    opts.TombstoneUserName,
+		searchUserIndex:      opts.SearchUserIndex,
+		searchContentIndices: opts.SearchContentIndices,
+	}, nil
+}
+
+// Request
+//
+//	Requests the deletion of a user's account. Requesting the deletion
+//	of a user that already has a deletion returns the existing deletion.
+//	The deletion is inserted and read back in the same transaction so the
+//	returned status never depends on a read replica catching up.
+func (d *AccountDeleter) Request(ctx context.Context, span *trace.Span, callerName *string, userID int64) (*AccountDeletionStatus, error) {
+	var status *AccountDeletionStatus
+	err := d.db.RunInTx(ctx, span, callerName, nil, func(ctx context.Context, tx *ti.Tx) error {
+		query := func(query string, args ...interface{}) (*sql.Rows, error) {
+			return tx.QueryContext(ctx, callerName, query, args...)
+		}
+
+		// return the existing deletion since the user may already be deleted
+		var err error
+		status, err = loadAccountDeletionStatus(query, userID)
+		if err == nil || !errors.Is(err, ErrAccountDeletionNotRequested) {
+			return err
+		}
+
+		res, err := query("select user_name from users where _id = ?", userID)
+		if err != nil {
+			return fmt.Errorf("failed to load user %d for deletion: %w", userID, err)
+		}
+		var userName string
+		found := res.Next()
+		if found {
+			err = res.Scan(&userName)
+		}
+		if err == nil {
+			err = res.Err()
+		}
+		_ = res.Close()
+		if err != nil {
+			return fmt.Errorf("failed to load user %d for deletion: %w", userID, err)
+		}
+		if !found {
+			return fmt.Errorf("failed to load user %d for deletion: %w", userID, sql.ErrNoRows)
+		}
+
+		_, err = tx.ExecContext(ctx, callerName,
+			"insert ignore into account_deletions(user_id, user_name, requested_at) values (?, ?, ?)",
+			userID, userName, time.Now(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert account deletion: %w", err)
+		}
+
+		status, err = loadAccountDeletionStatus(query, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// Status
+//
+//	Returns the persisted state of a user's account deletion.
+//	ErrAccountDeletionNotRequested is returned if the deletion of
+//	the user has not been requested.
+func (d *AccountDeleter) Status(ctx context.Context, span *trace.Span, callerName *string, userID int64) (*AccountDeletionStatus, error) {
+	return loadAccountDeletionStatus(func(query string, args ...interface{}) (*sql.Rows, error) {
+		return d.db.QueryContext(ctx, span, callerName, query, args...)
+	}, userID)
+}
+
+// loadAccountDeletionStatus
+//
+//	Loads the state of a user's account deletion using the passed query
+//	function so the state can be read from the database or a transaction
+func loadAccountDeletionStatus(query func(query string, args ...interface{}) (*sql.Rows, error), userID int64) (*AccountDeletionStatus, error) {
+	res, err := query("select user_name, requested_at, completed_at from account_deletions where user_id = ?", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account deletion: %w", err)
+	}
+
+	status := &AccountDeletionStatus{UserID: userID}
+	found := res.Next()
+	if found {
+		err = res.Scan(&status.UserName, &status.RequestedAt, &status.CompletedAt)
+	}
+	if err == nil {
+		err = res.Err()
+	}
+	_ = res.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account deletion: %w", err)
+	}
+	if !found {
+		return nil, ErrAccountDeletionNotRequested
+	}
+
+	res, err = query("select step, status, attempts, last_error, updated_at from account_deletion_steps where user_id = ?", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account deletion steps: %w", err)
+	}
+	defer res.Close()
+
+	persisted := make(map[string]*AccountDeletionStepState)
+	for res.Next() {
+		step := new(AccountDeletionStepState)
+		err = res.Scan(&step.Step, &step.Status, &step.Attempts, &step.LastError, &step.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan account deletion step: %v", err)
+		}
+		persisted[step.Step] = step
+	}
+	if err := res.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query account deletion steps: %v", err)
+	}
+
+	// report the steps in execution order including the ones that have not run yet
+	status.Steps = make([]*AccountDeletionStepState, 0, len(accountDeletionSteps))
+	for _, step := range accountDeletionSteps {
+		state, ok := persisted[step.name]
+		if !ok {
+			state = &AccountDeletionStepState{Step: step.name, Status: AccountDeletionStepPending}
+		}
+		status.Steps = append(status.Steps, state)
+	}
+
+	return status, nil
+}
+
+// Pending
+//
+//	Returns the ids of the users with deletions that have not completed
+//	or whose steps of the systems this deleter has configured were
+//	skipped or failed on a retry, ordered by the time of their request
+func (d *AccountDeleter) Pending(ctx context.Context, span *trace.Span, callerName *string, limit int) ([]int64, error) {
+	query := "select user_id from account_deletions where completed_at is null"
+	args := make([]interface{}, 0)
+
+	retryable := d.retryableSteps()
+	if len(retryable) > 0 {
+		placeholders := make([]string, 0, len(retryable))
+		args = append(args, AccountDeletionStepCompleted)
+		for _, step := range retryable {
+			placeholders = append(placeholders, "?")
+			args = append(args, step)
+		}
+		query += " or user_id in (select user_id from account_deletion_steps where status != ? and step in (" +
+			strings.Join(placeholders, ", ") + "))"
+	}
+	args = append(args, limit)
+
+	res, err := d.db.QueryContext(ctx, span, callerName, query+" order by requested_at limit ?", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending account deletions: %v", err)
+	}
+	defer res.Close()
+
+	ids := make([]int64, 0)
+	for res.Next() {
+		var id int64
+		err = res.Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan pending account deletion: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, res.Err()
+}
+
+// retryableSteps
+//
+//	Returns the names of the steps that can be skipped and whose system
+//	is configured for this deleter
+func (d *AccountDeleter) retryableSteps() []string {
+	steps := make([]string, 0)
+	for _, step := range accountDeletionSteps {
+		if step.configured != nil && step.configured(d) {
+			steps = append(steps, step.name)
+		}
+	}
+	return steps
+}
+
+// Run
+//
+//	Runs the steps of a requested deletion that have not completed. The
+//	deletion stops at the first step that fails and returns its error;
+//	running the deletion again retries the failed step. Steps that were
+//	skipped are retried once their system is configured, including for a
+//	deletion that has completed; otherwise running a completed deletion
+//	does nothing.
+func (d *AccountDeleter) Run(ctx context.Context, span *trace.Span, callerName *string, userID int64) (*AccountDeletionStatus, error) {
+	// read the progress of the deletion from the primary since a lagging
+	// replica would cause completed steps to be run again
+	ctx = ti.WithPrimaryReads(ctx)
+
+	status, err := d.Status(ctx, span, callerName, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, step := range accountDeletionSteps {
+		state := status.Steps[i]
+		if state.Status == AccountDeletionStepCompleted {
+			continue
+		}
+		if state.Status == AccountDeletionStepSkipped && (step.configured == nil || !step.configured(d)) {
+			continue
+		}
+
+		ran, stepErr := step.run(d, ctx, span, callerName, status)
+
+		state.Attempts++
+		state.UpdatedAt = time.Now()
+		state.LastError = nil
+		switch {
+		case stepErr != nil:
+			state.Status = AccountDeletionStepFailed
+			msg := stepErr.Error()
+			state.LastError = &msg
+		case !ran:
+			state.Status = AccountDeletionStepSkipped
+		default:
+			state.Status = AccountDeletionStepCompleted
+		}
+
+		_, err = d.db.ExecContext(ctx, span, callerName,
+			"insert into account_deletion_steps(user_id, step, status, attempts, last_error, updated_at) values (?, ?, ?, ?, ?, ?) "+
+				"on duplicate key update status = values(status), attempts = values(attempts), last_error = values(last_error), updated_at = values(updated_at)",
+			userID, state.Step, state.Status, state.Attempts, state.LastError, state.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to persist account deletion step %s: %v", step.name, err)
+		}
+
+		if stepErr != nil {
+			return status, fmt.Errorf("account deletion step %s failed: %w", step.name, stepErr)
+		}
+	}
+
+	if status.CompletedAt != nil {
+		return status, nil
+	}
+
+	now := time.Now()
+	_, err = d.db.ExecContext(ctx, span, callerName,
+		"update account_deletions set completed_at = ? where user_id = ?", now, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete account deletion: %v", err)
+	}
+	status.CompletedAt = &now
+
+	return status, nil
+}
+
+// revokeSessions
+//
+//	Removes the user's session from redis and its key from the database
+func (d *AccountDeleter) revokeSessions(ctx context.Context, span *trace.Span, callerName *string, status *AccountDeletionStatus) (bool, error) {
+	if d.rdb == nil {
+		return false, nil
+	}
+
+	key := fmt.Sprintf("gigo-user-sess-%d", status.UserID)
+	buf, err := d.rdb.Get(ctx, key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to load user session: %v", err)
+	}
+
+	var session UserSession
+	err = json.Unmarshal(buf, &session)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode user session: %v", err)
+	}
+
+	_, err = d.db.ExecContext(ctx, span, callerName, "delete from user_session_key where _id = ?", session.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user session key: %v", err)
+	}
+
+	err = d.rdb.Del(ctx, key).Err()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user session: %v", err)
+	}
+
+	return true, nil
+}
+
+// updateSearch
+//
+//	Removes the user's document and replaces the author of the user's
+//	content in the search indices
+func (d *AccountDeleter) updateSearch(ctx context.Context, span *trace.Span, callerName *string, status *AccountDeletionStatus) (bool, error) {
+	if d.search == nil {
+		return false, nil
+	}
+
+	err := d.search.DeleteDocuments(d.searchUserIndex, status.UserID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user search document: %v", err)
+	}
+
+	for index, table := range d.searchContentIndices {
+		res, err := d.db.QueryContext(ctx, span, callerName,
+			fmt.Sprintf("select _id from %s where author_id = ?", table), status.UserID,
+		)
+		if err != nil {
+			return false, fmt.Errorf("failed to query %s for search update: %v", table, err)
+		}
+
+		documents := make([]interface{}, 0)
+		for res.Next() {
+			var id int64
+			err = res.Scan(&id)
+			if err != nil {
+				_ = res.Close()
+				return false, fmt.Errorf("failed to scan %s for search update: %v", table, err)
+			}
+			// partial updates only replace the passed fields of the document
+			documents = append(documents, map[string]interface{}{
+				"_id":       id,
+				"author":    d.tombstoneUserName,
+				"author_id": d.tombstoneUserID,
+			})
+		}
+		err = res.Err()
+		_ = res.Close()
+		if err != nil {
+			return false, fmt.Errorf("failed to query %s for search update: %v", table, err)
+		}
+
+		for start := 0; start < len(documents); start += searchUpdateBatchSize {
+			end := start + searchUpdateBatchSize
+			if end > len(documents) {
+				end = len(documents)
+			}
+			err = d.search.UpdateDocuments(index, documents[start:end]...)
+			if err != nil {
+				return false, fmt.Errorf("failed to update search index %s: %v", index, err)
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// deletePermissions
+//
+//	Removes the user's permission relations
+func (d *AccountDeleter) deletePermissions(ctx context.Context, span *trace.Span, callerName *string, status *AccountDeletionStatus) (bool, error) {
+	if d.permissions == nil {
+		return false, nil
+	}
+
+	err := d.permissions.DeleteUserRelations(ctx, status.UserID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user permissions: %v", err)
+	}
+
+	return true, nil
+}
+
+// deleteVCSUser
+//
+//	Deletes the user from the version control system
+func (d *AccountDeleter) deleteVCSUser(ctx context.Context, span *trace.Span, callerName *string, status *AccountDeletionStatus) (bool, error) {
+	if d.vcs == nil {
+		return false, nil
+	}
+
+	err := d.vcs.DeleteUser(status.UserName)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// anonymizeContent
+//
+//	Attributes the user's public content to the tombstone user
+func (d *AccountDeleter) anonymizeContent(ctx context.Context, span *trace.Span, callerName *string, status *AccountDeletionStatus) (bool, error) {
+	err := d.db.RunInTx(ctx, span, callerName, nil, func(ctx context.Context, tx *ti.Tx) error {
+		for _, table := range accountDeletionAuthoredTables {
+			_, err := tx.ExecContext(ctx, callerName,
+				fmt.Sprintf("update %s set author = ?, author_id = ? where author_id = ?", table),
+				d.tombstoneUserName, d.tombstoneUserID, status.UserID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to anonymize %s: %w", table, err)
+			}
+		}
+
+		_, err := tx.ExecContext(ctx, callerName,
+			"update broadcast_event set user_name = ?, user_id = ? where user_id = ?",
+			d.tombstoneUserName, d.tombstoneUserID, status.UserID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to anonymize broadcast_event: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// deletePrivateData
+//
+//	Deletes the user's private data. Each statement is executed on its
+//	own so that a user with a large history does not exceed the size limit
+//	of a single transaction. The workspaces of the user must have been
+//	destroyed before their rows can be removed.
+func (d *AccountDeleter) deletePrivateData(ctx context.Context, span *trace.Span, callerName *string, status *AccountDeletionStatus) (bool, error) {
+	var active int
+	err := d.db.QueryRowContext(ctx, span, callerName,
+		"select count(*) from workspaces where owner_id = ? and state != ?", status.UserID, WorkspaceDeleted,
+	).Scan(&active)
+	if err != nil {
+		return false, fmt.Errorf("failed to count user workspaces: %v", err)
+	}
+	if active > 0 {
+		return false, fmt.Errorf("%w: %d workspaces", ErrAccountDeletionActiveWorkspaces, active)
+	}
+
+	for _, statement := range accountDeletionPrivateData {
+		args := make([]interface{}, 0, 2)
+		for i := 0; i < countPlaceholders(statement); i++ {
+			args = append(args, status.UserID)
+		}
+
+		_, err = d.db.ExecContext(ctx, span, callerName, statement, args...)
+		if err != nil {
+			return false, fmt.Errorf("failed to delete private data: %v\n    statement: %s", err, statement)
+		}
+	}
+
+	return true, nil
+}
+
+// deleteUser
+//
+//	Deletes the user's row
+func (d *AccountDeleter) deleteUser(ctx context.Context, span *trace.Span, callerName *string, status *AccountDeletionStatus) (bool, error) {
+	_, err := d.db.ExecContext(ctx, span, callerName, "delete from users where _id = ?", status.UserID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user: %v", err)
+	}
+
+	return true, nil
+}
+
+// countPlaceholders
+//
+//	Returns the number of placeholders in the statement
+func countPlaceholders(statement string) int {
+	count := 0
+	for _, c := range statement {
+		if c == '?' {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,190 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+)
+
+type testAccountDeletionVCS struct {
+	calls int
+	err   error
+}
+
+func (v *testAccountDeletionVCS) DeleteUser(userName string) error {
+	v.calls++
+	return v.err
+}
+
+type testAccountDeletionPermissions struct {
+	calls int
+}
+
+func (p *testAccountDeletionPermissions) DeleteUserRelations(ctx context.Context, userID int64) error {
+	p.calls++
+	return nil
+}
+
+func TestAccountDeleter_Run(t *testing.T) {
+	db, err := ti.CreateDatabase("gigo-dev-tidb", "4000", "mysql", "gigo-dev", "gigo-dev", "gigo_dev_test")
+	if err != nil {
+		t.Fatal("\nAccount Deletion Failed\n    Error: ", err)
+	}
+
+	defer db.DB.Exec("delete from users where _id = 6942069")
+	defer db.DB.Exec("delete from notification where user_id = 6942069")
+	defer db.DB.Exec("delete from account_deletions where user_id = 6942069")
+	defer db.DB.Exec("delete from account_deletion_steps where user_id = 6942069")
+	defer db.DB.Exec("delete from discussion where _id = 69")
+
+	user, err := CreateUser(6942069, "test", "testpass", "testemail@email.com",
+		"phone", UserStatusBasic, "test", []int64{1, 2}, []int64{1, 2, 3},
+		"first", "last", 23, "", DefaultUserStart, "America/Chicago",
+		AvatarSettings{}, 0)
+	if err != nil {
+		t.Fatal("\nAccount Deletion Failed\n    Error: ", err)
+	}
+
+	statements, err := user.ToSQLNative()
+	if err != nil {
+		t.Fatal("\nAccount Deletion Failed\n    Error: ", err)
+	}
+
+	notification, err := CreateNotification(69, 6942069, "test", StreakInfo, time.Now(), false, nil)
+	if err != nil {
+		t.Fatal("\nAccount Deletion Failed\n    Error: ", err)
+	}
+	statements = append(statements, notification.ToSQLNative())
+
+	for _, statement := range statements {
+		_, err = db.DB.Exec(statement.Statement, statement.Values...)
+		if err != nil {
+			t.Fatal("\nAccount Deletion Failed\n    Error: ", err)
+		}
+	}
+
+	_, err = db.DB.Exec(
+		"insert into discussion(_id, body, author, author_id, created_at, updated_at, author_tier, coffee, post_id, title, leads, revision, discussion_level) values (69, 'test', 'test', 6942069, now(), now(), 0, 0, 1, 'test', false, 0, 0)",
+	)
+	if err != nil {
+		t.Fatal("\nAccount Deletion Failed\n    Error: ", err)
+	}
+
+	vcs := &testAccountDeletionVCS{err: errors.New("vcs unavailable")}
+	deleter, err := NewAccountDeleter(AccountDeleterOptions{DB: db, VCS: vcs})
+	if err != nil {
+		t.Fatal("\nAccount Deletion Failed\n    Error: ", err)
+	}
+
+	_, err = deleter.Run(context.TODO(), nil, nil, 6942069)
+	if !errors.Is(err, ErrAccountDeletionNotRequested) {
+		t.Fatal("\nAccount Deletion Failed\n    Error: ran a deletion that was not requested ", err)
+	}
+
+	status, err := deleter.Request(context.TODO(), nil, nil, 6942069)
+	if err != nil {
+		t.Fatal("\nAccount Deletion Failed\n    Error: ", err)
+	}
+	if status.UserName != "test" || len(status.Steps) != len(accountDeletionSteps) {
+		t.Fatalf("\nAccount Deletion Failed\n    Error: wrong status %+v", status)
+	}
+
+	// the failing vcs step stops the deletion before any data is removed
+	status, err = deleter.Run(context.TODO(), nil, nil, 6942069)
+	if err == nil {
+		t.Fatal("\nAccount Deletion Failed\n    Error: expected the vcs step to fail")
+	}
+	if status.Steps[3].Status != AccountDeletionStepFailed || status.Steps[3].LastError == nil || status.Steps[4].Status != AccountDeletionStepPending {
+		t.Fatalf("\nAccount Deletion Failed\n    Error: wrong steps after failure %+v", status.Steps[3])
+	}
+
+	// the deletion resumes from the failed step
+	vcs.err = nil
+	status, err = deleter.Run(context.TODO(), nil, nil, 6942069)
+	if err != nil {
+		t.Fatal("\nAccount Deletion Failed\n    Error: ", err)
+	}
+	if status.CompletedAt == nil || vcs.calls != 2 {
+		t.Fatalf("\nAccount Deletion Failed\n    Error: deletion did not complete %+v", status)
+	}
+
+	status, err = deleter.Status(context.TODO(), nil, nil, 6942069)
+	if err != nil {
+		t.Fatal("\nAccount Deletion Failed\n    Error: ", err)
+	}
+	for i, step := range status.Steps {
+		expected := AccountDeletionStepCompleted
+		if i < 3 {
+			expected = AccountDeletionStepSkipped
+		}
+		if step.Status != expected {
+			t.Fatalf("\nAccount Deletion Failed\n    Error: step %s is %s", step.Step, step.Status)
+		}
+	}
+
+	// a deleter with the permissions configured retries the skipped step
+	// of the completed deletion and leaves the other skipped steps alone
+	permissions := &testAccountDeletionPermissions{}
+	retrier, err := NewAccountDeleter(AccountDeleterOptions{DB: db, VCS: vcs, Permissions: permissions})
+	if err != nil {
+		t.Fatal("\nAccount Deletion Failed\n    Error: ", err)
+	}
+
+	pending, err := retrier.Pending(context.TODO(), nil, nil, 1000)
+	if err != nil {
+		t.Fatal("\nAccount Deletion Failed\n    Error: ", err)
+	}
+	found := false
+	for _, id := range pending {
+		found = found || id == 6942069
+	}
+	if !found {
+		t.Fatal("\nAccount Deletion Failed\n    Error: deletion with a retryable skipped step is not pending")
+	}
+
+	completedAt := *status.CompletedAt
+	status, err = retrier.Run(context.TODO(), nil, nil, 6942069)
+	if err != nil {
+		t.Fatal("\nAccount Deletion Failed\n    Error: ", err)
+	}
+	if permissions.calls != 1 || vcs.calls != 2 || status.Steps[2].Status != AccountDeletionStepCompleted ||
+		status.Steps[0].Status != AccountDeletionStepSkipped || !status.CompletedAt.Equal(completedAt) {
+		t.Fatalf("\nAccount Deletion Failed\n    Error: skipped step was not retried %+v", status.Steps[2])
+	}
+
+	pending, err = retrier.Pending(context.TODO(), nil, nil, 1000)
+	if err != nil {
+		t.Fatal("\nAccount Deletion Failed\n    Error: ", err)
+	}
+	for _, id := range pending {
+		if id == 6942069 {
+			t.Fatal("\nAccount Deletion Failed\n    Error: deletion is still pending after the retry")
+		}
+	}
+
+	var count int
+	err = db.DB.QueryRow("select count(*) from users where _id = 6942069").Scan(&count)
+	if err != nil || count != 0 {
+		t.Fatal("\nAccount Deletion Failed\n    Error: user was not deleted ", err)
+	}
+
+	err = db.DB.QueryRow("select count(*) from notification where user_id = 6942069").Scan(&count)
+	if err != nil || count != 0 {
+		t.Fatal("\nAccount Deletion Failed\n    Error: notifications were not deleted ", err)
+	}
+
+	var author string
+	var authorID int64
+	err = db.DB.QueryRow("select author, author_id from discussion where _id = 69").Scan(&author, &authorID)
+	if err != nil {
+		t.Fatal("\nAccount Deletion Failed\n    Error: ", err)
+	}
+	if author != DefaultTombstoneUserName || authorID != DefaultTombstoneUserID {
+		t.Fatalf("\nAccount Deletion Failed\n    Error: discussion was not anonymized %s %d", author, authorID)
+	}
+
+	t.Log("\nAccount Deletion Succeeded")
+}
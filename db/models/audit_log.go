@@ -0,0 +1,491 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/gage-technologies/gigo-lib/config"
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/gage-technologies/gigo-lib/logging"
+	"github.com/kisielk/sqlstruct"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultAuditLogLimit is the number of entries returned by a query
+// that does not set a limit
+const DefaultAuditLogLimit = 100
+
+// AuditRedacted replaces the values of sensitive fields in audit diffs
+const AuditRedacted = "[redacted]"
+
+type AuditAction string
+
+const (
+	AuditUserEdit         AuditAction = "user.edit"
+	AuditUserRoleChange   AuditAction = "user.role_change"
+	AuditUserOtpReset     AuditAction = "user.otp_reset"
+	AuditPostVisibility   AuditAction = "post.visibility_change"
+	AuditAccountDeletion  AuditAction = "user.account_deletion"
+	AuditUserStatusChange AuditAction = "user.status_change"
+)
+
+type AuditTargetType string
+
+const (
+	AuditTargetUser       AuditTargetType = "user"
+	AuditTargetPost       AuditTargetType = "post"
+	AuditTargetAttempt    AuditTargetType = "attempt"
+	AuditTargetDiscussion AuditTargetType = "discussion"
+)
+
+// auditSensitiveFields are the json fields of audited models whose values
+// are never written to the audit log. A change to one of these fields is
+// still recorded with both values replaced by AuditRedacted.
+var auditSensitiveFields = map[string]bool{
+	"password":              true,
+	"encrypted_service_key": true,
+	"otp":                   true,
+	"reset_token":           true,
+	"external_auth":         true,
+}
+
+// AuditChange
+//
+//	Value of a single field before and after an audited change
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type AuditLogEntry struct {
+	ID         int64                  `json:"_id" sql:"_id"`
+	ActorID    *int64                 `json:"actor_id" sql:"actor_id"`
+	Action     AuditAction            `json:"action" sql:"action"`
+	TargetType AuditTargetType        `json:"target_type" sql:"target_type"`
+	TargetID   int64                  `json:"target_id" sql:"target_id"`
+	Diff       map[string]AuditChange `json:"diff" sql:"diff"`
+	IP         *string                `json:"ip" sql:"ip"`
+	RequestID  *string                `json:"request_id" sql:"request_id"`
+	CreatedAt  time.Time              `json:"created_at" sql:"created_at"`
+}
+
+type AuditLogEntrySQL struct {
+	ID         int64           `json:"_id" sql:"_id"`
+	ActorID    *int64          `json:"actor_id" sql:"actor_id"`
+	Action     AuditAction     `json:"action" sql:"action"`
+	TargetType AuditTargetType `json:"target_type" sql:"target_type"`
+	TargetID   int64           `json:"target_id" sql:"target_id"`
+	Diff       []byte          `json:"diff" sql:"diff"`
+	IP         *string         `json:"ip" sql:"ip"`
+	RequestID  *string         `json:"request_id" sql:"request_id"`
+	CreatedAt  time.Time       `json:"created_at" sql:"created_at"`
+}
+
+type AuditLogEntryFrontend struct {
+	ID         string                 `json:"_id"`
+	ActorID    *string                `json:"actor_id"`
+	Action     AuditAction            `json:"action"`
+	TargetType AuditTargetType        `json:"target_type"`
+	TargetID   string                 `json:"target_id"`
+	Diff       map[string]AuditChange `json:"diff"`
+	IP         *string                `json:"ip"`
+	RequestID  *string                `json:"request_id"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+func CreateAuditLogEntry(id int64, actorID *int64, action AuditAction, targetType AuditTargetType, targetID int64,
+	diff map[string]AuditChange, ip *string, requestID *string) *AuditLogEntry {
+	if diff == nil {
+		diff = make(map[string]AuditChange)
+	}
+
+	return &AuditLogEntry{
+		ID:         id,
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Diff:       diff,
+		IP:         ip,
+		RequestID:  requestID,
+		CreatedAt:  time.Now(),
+	}
+}
+
+func AuditLogEntryFromSQLNative(rows *sql.Rows) (*AuditLogEntry, error) {
+	entrySQL := new(AuditLogEntrySQL)
+	err := sqlstruct.Scan(entrySQL, rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan audit log entry: %v", err)
+	}
+
+	diff := make(map[string]AuditChange)
+	if len(entrySQL.Diff) > 0 {
+		err = json.Unmarshal(entrySQL.Diff, &diff)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode audit log diff: %v", err)
+		}
+	}
+
+	return &AuditLogEntry{
+		ID:         entrySQL.ID,
+		ActorID:    entrySQL.ActorID,
+		Action:     entrySQL.Action,
+		TargetType: entrySQL.TargetType,
+		TargetID:   entrySQL.TargetID,
+		Diff:       diff,
+		IP:         entrySQL.IP,
+		RequestID:  entrySQL.RequestID,
+		CreatedAt:  entrySQL.CreatedAt,
+	}, nil
+}
+
+func (i *AuditLogEntry) ToFrontend() *AuditLogEntryFrontend {
+	var actorID *string
+	if i.ActorID != nil {
+		id := fmt.Sprintf("%d", *i.ActorID)
+		actorID = &id
+	}
+
+	return &AuditLogEntryFrontend{
+		ID:         fmt.Sprintf("%d", i.ID),
+		ActorID:    actorID,
+		Action:     i.Action,
+		TargetType: i.TargetType,
+		TargetID:   fmt.Sprintf("%d", i.TargetID),
+		Diff:       i.Diff,
+		IP:         i.IP,
+		RequestID:  i.RequestID,
+		CreatedAt:  i.CreatedAt,
+	}
+}
+
+func (i *AuditLogEntry) ToSQLNative() (*SQLInsertStatement, error) {
+	diff, err := json.Marshal(i.Diff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit log diff: %v", err)
+	}
+
+	return &SQLInsertStatement{
+		Statement: "insert into audit_log(_id, actor_id, action, target_type, target_id, diff, ip, request_id, created_at) values (?, ?, ?, ?, ?, ?, ?, ?, ?);",
+		Values:    []interface{}{i.ID, i.ActorID, i.Action, i.TargetType, i.TargetID, diff, i.IP, i.RequestID, i.CreatedAt},
+	}, nil
+}
+
+// AuditDiff
+//
+//	Returns the fields that differ between the json encodings of before
+//	and after. Either value may be nil to record the creation or removal
+//	of an entity. The values of sensitive fields are redacted.
+func AuditDiff(before interface{}, after interface{}) (map[string]AuditChange, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit before state: %v", err)
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit after state: %v", err)
+	}
+
+	diff := make(map[string]AuditChange)
+	for key, beforeValue := range beforeFields {
+		afterValue, ok := afterFields[key]
+		if ok && reflect.DeepEqual(beforeValue, afterValue) {
+			continue
+		}
+		diff[key] = AuditChange{Before: beforeValue, After: afterValue}
+	}
+	for key, afterValue := range afterFields {
+		if _, ok := beforeFields[key]; !ok {
+			diff[key] = AuditChange{After: afterValue}
+		}
+	}
+
+	for key, change := range diff {
+		if !auditSensitiveFields[strings.ToLower(key)] {
+			continue
+		}
+		if change.Before != nil {
+			change.Before = AuditRedacted
+		}
+		if change.After != nil {
+			change.After = AuditRedacted
+		}
+		diff[key] = change
+	}
+
+	return diff, nil
+}
+
+// auditFields
+//
+//	Decodes the json encoding of the value into its top level fields
+func auditFields(value interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if value == nil || (reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil()) {
+		return fields, nil
+	}
+
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(buf, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// NewAuditMirror
+//
+//	Creates the logger that mirrors audit entries to Elasticsearch from
+//	the logging config. The entries are written to the logger's index
+//	with the -audit suffix. Returns nil if the config does not set any
+//	Elasticsearch nodes.
+func NewAuditMirror(cfg config.LoggerConfig) (logging.Logger, error) {
+	if len(cfg.ESConfig.ESNodes) == 0 {
+		return nil, nil
+	}
+
+	logger, err := logging.CreateESLogger(
+		cfg.ESConfig.ESNodes,
+		cfg.ESConfig.Username,
+		cfg.ESConfig.ESPass,
+		cfg.ESConfig.Index+"-audit",
+		cfg.WorkerId,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audit mirror: %v", err)
+	}
+
+	return logger, nil
+}
+
+// AuditLoggerOptions
+//
+//	Options for an AuditLogger
+type AuditLoggerOptions struct {
+	DB *ti.Database
+	SF *snowflake.Node
+	// Mirror optionally receives a copy of every entry after it is written
+	Mirror logging.Logger
+}
+
+// AuditQuery
+//
+//	Filters of an audit log query. Unset filters match every entry.
+type AuditQuery struct {
+	ActorID    *int64
+	TargetType AuditTargetType
+	TargetID   *int64
+	Action     AuditAction
+	// Since and Until bound the creation time of the entries; Until is exclusive
+	Since time.Time
+	Until time.Time
+	// BeforeID pages through the results by returning the entries older
+	// than the last entry of the previous page
+	BeforeID *int64
+	// Limit defaults to DefaultAuditLogLimit
+	Limit int
+}
+
+// AuditLogger
+//
+//	Writes and queries the append-only audit log. Entries are never
+//	updated or deleted.
+type AuditLogger struct {
+	db     *ti.Database
+	sf     *snowflake.Node
+	mirror logging.Logger
+}
+
+// NewAuditLogger
+//
+//	Creates a new AuditLogger
+func NewAuditLogger(opts AuditLoggerOptions) (*AuditLogger, error) {
+	if opts.DB == nil {
+		return nil, fmt.Errorf("audit logger requires a database")
+	}
+	if opts.SF == nil {
+		return nil, fmt.Errorf("audit logger requires a snowflake node")
+	}
+
+	return &AuditLogger{
+		db:     opts.DB,
+		sf:     opts.SF,
+		mirror: opts.Mirror,
+	}, nil
+}
+
+// Record
+//
+//	Writes an audit entry for a change to the target. The before and
+//	after states are diffed with AuditDiff. The ip and request id are
+//	optional.
+func (l *AuditLogger) Record(ctx context.Context, span *trace.Span, callerName *string, actorID *int64, action AuditAction,
+	targetType AuditTargetType, targetID int64, before interface{}, after interface{}, ip *string, requestID *string) (*AuditLogEntry, error) {
+	entry, statement, err := l.prepare(actorID, action, targetType, targetID, before, after, ip, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = l.db.ExecContext(ctx, span, callerName, statement.Statement, statement.Values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert audit log entry: %v", err)
+	}
+
+	l.Mirror(entry)
+
+	return entry, nil
+}
+
+// RecordTx
+//
+//	Writes an audit entry within the transaction that performs the change
+//	so that the change and its entry are committed together. The entry is
+//	not mirrored because the transaction may still roll back or be
+//	retried; callers pass the returned entry to Mirror once the
+//	transaction commits.
+func (l *AuditLogger) RecordTx(ctx context.Context, tx *ti.Tx, callerName *string, actorID *int64, action AuditAction,
+	targetType AuditTargetType, targetID int64, before interface{}, after interface{}, ip *string, requestID *string) (*AuditLogEntry, error) {
+	entry, statement, err := l.prepare(actorID, action, targetType, targetID, before, after, ip, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, callerName, statement.Statement, statement.Values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert audit log entry: %v", err)
+	}
+
+	return entry, nil
+}
+
+func (l *AuditLogger) prepare(actorID *int64, action AuditAction, targetType AuditTargetType, targetID int64,
+	before interface{}, after interface{}, ip *string, requestID *string) (*AuditLogEntry, *SQLInsertStatement, error) {
+	diff, err := AuditDiff(before, after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entry := CreateAuditLogEntry(l.sf.Generate().Int64(), actorID, action, targetType, targetID, diff, ip, requestID)
+	statement, err := entry.ToSQLNative()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return entry, statement, nil
+}
+
+// Mirror
+//
+//	Sends the entry to the mirror. The mirror is best effort and never
+//	fails the write. Record mirrors its entries itself; entries written
+//	with RecordTx are mirrored by the caller after the commit.
+func (l *AuditLogger) Mirror(entry *AuditLogEntry) {
+	if l.mirror == nil {
+		return
+	}
+
+	buf, err := json.Marshal(entry.ToFrontend())
+	if err != nil {
+		l.mirror.Errorf("failed to marshal audit log entry %d: %v", entry.ID, err)
+		return
+	}
+	l.mirror.Info(string(buf))
+}
+
+// Query
+//
+//	Returns the entries matching the query ordered from newest to oldest
+func (l *AuditLogger) Query(ctx context.Context, span *trace.Span, callerName *string, query AuditQuery) ([]*AuditLogEntry, error) {
+	conditions := make([]string, 0)
+	values := make([]interface{}, 0)
+
+	if query.ActorID != nil {
+		conditions = append(conditions, "actor_id = ?")
+		values = append(values, *query.ActorID)
+	}
+	if query.TargetType != "" {
+		conditions = append(conditions, "target_type = ?")
+		values = append(values, query.TargetType)
+	}
+	if query.TargetID != nil {
+		conditions = append(conditions, "target_id = ?")
+		values = append(values, *query.TargetID)
+	}
+	if query.Action != "" {
+		conditions = append(conditions, "action = ?")
+		values = append(values, query.Action)
+	}
+	if !query.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		values = append(values, query.Since)
+	}
+	if !query.Until.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		values = append(values, query.Until)
+	}
+	if query.BeforeID != nil {
+		conditions = append(conditions, "_id < ?")
+		values = append(values, *query.BeforeID)
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultAuditLogLimit
+	}
+
+	statement := "select * from audit_log"
+	if len(conditions) > 0 {
+		statement += " where " + strings.Join(conditions, " and ")
+	}
+	// snowflake ids are ordered by creation so the id orders entries
+	// created in the same second
+	statement += " order by created_at desc, _id desc limit ?"
+	values = append(values, limit)
+
+	res, err := l.db.QueryContext(ctx, span, callerName, statement, values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %v", err)
+	}
+	defer res.Close()
+
+	entries := make([]*AuditLogEntry, 0)
+	for res.Next() {
+		entry, err := AuditLogEntryFromSQLNative(res)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := res.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %v", err)
+	}
+
+	return entries, nil
+}
+
+// ByActor
+//
+//	Returns the newest entries of changes made by the actor
+func (l *AuditLogger) ByActor(ctx context.Context, span *trace.Span, callerName *string, actorID int64, since time.Time, until time.Time, limit int) ([]*AuditLogEntry, error) {
+	return l.Query(ctx, span, callerName, AuditQuery{ActorID: &actorID, Since: since, Until: until, Limit: limit})
+}
+
+// ByTarget
+//
+//	Returns the newest entries of changes made to the target
+func (l *AuditLogger) ByTarget(ctx context.Context, span *trace.Span, callerName *string, targetType AuditTargetType, targetID int64, since time.Time, until time.Time, limit int) ([]*AuditLogEntry, error) {
+	return l.Query(ctx, span, callerName, AuditQuery{TargetType: targetType, TargetID: &targetID, Since: since, Until: until, Limit: limit})
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/gage-technologies/gigo-lib/logging"
+)
+
+func TestAuditDiff(t *testing.T) {
+	before := &User{ID: 69, UserName: "test", Password: "old", AuthRole: BaseUser}
+	after := &User{ID: 69, UserName: "test2", Password: "new", AuthRole: Admin}
+
+	diff, err := AuditDiff(before, after)
+	if err != nil {
+		t.Fatal("\nAudit Diff Failed\n    Error: ", err)
+	}
+
+	if len(diff) != 3 {
+		t.Fatalf("\nAudit Diff Failed\n    Error: wrong diff %+v", diff)
+	}
+	if diff["user_name"].Before != "test" || diff["user_name"].After != "test2" {
+		t.Fatalf("\nAudit Diff Failed\n    Error: wrong user name change %+v", diff["user_name"])
+	}
+	if diff["password"].Before != AuditRedacted || diff["password"].After != AuditRedacted {
+		t.Fatalf("\nAudit Diff Failed\n    Error: password was not redacted %+v", diff["password"])
+	}
+
+	// a nil state records the creation of the entity
+	diff, err = AuditDiff(nil, map[string]interface{}{"visibility": "public"})
+	if err != nil {
+		t.Fatal("\nAudit Diff Failed\n    Error: ", err)
+	}
+	if len(diff) != 1 || diff["visibility"].Before != nil || diff["visibility"].After != "public" {
+		t.Fatalf("\nAudit Diff Failed\n    Error: wrong creation diff %+v", diff)
+	}
+
+	t.Log("\nAudit Diff Succeeded")
+}
+
+// auditTestMirror
+//
+//	Mirror that records the entries it receives
+type auditTestMirror struct {
+	logging.Logger
+	entries []string
+}
+
+func (m *auditTestMirror) Info(args ...interface{}) {
+	m.entries = append(m.entries, fmt.Sprint(args...))
+}
+
+func TestAuditLogger_Record(t *testing.T) {
+	db, err := ti.CreateDatabase("gigo-dev-tidb", "4000", "mysql", "gigo-dev", "gigo-dev", "gigo_dev_test")
+	if err != nil {
+		t.Fatal("\nAudit Log Record Failed\n    Error: ", err)
+	}
+
+	defer db.DB.Exec("delete from audit_log where target_id = 6942069")
+
+	sf, err := snowflake.NewNode(1)
+	if err != nil {
+		t.Fatal("\nAudit Log Record Failed\n    Error: ", err)
+	}
+
+	mirror := &auditTestMirror{}
+	logger, err := NewAuditLogger(AuditLoggerOptions{DB: db, SF: sf, Mirror: mirror})
+	if err != nil {
+		t.Fatal("\nAudit Log Record Failed\n    Error: ", err)
+	}
+
+	start := time.Now().Add(-time.Second)
+	actor := int64(420)
+	ip := "127.0.0.1"
+	requestID := "test-request"
+
+	_, err = logger.Record(context.TODO(), nil, nil, &actor, AuditUserRoleChange, AuditTargetUser, 6942069,
+		map[string]interface{}{"auth_role": BaseUser}, map[string]interface{}{"auth_role": Admin}, &ip, &requestID)
+	if err != nil {
+		t.Fatal("\nAudit Log Record Failed\n    Error: ", err)
+	}
+
+	if len(mirror.entries) != 1 {
+		t.Fatalf("\nAudit Log Record Failed\n    Error: wrong mirrored entries %v", mirror.entries)
+	}
+
+	// entries of a rolled back transaction are never mirrored
+	err = db.RunInTx(context.TODO(), nil, nil, nil, func(ctx context.Context, tx *ti.Tx) error {
+		_, err := logger.RecordTx(ctx, tx, nil, nil, AuditUserOtpReset, AuditTargetUser, 6942069, nil, nil, nil, nil)
+		if err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	if err == nil || len(mirror.entries) != 1 {
+		t.Fatalf("\nAudit Log Record Failed\n    Error: rolled back entry was mirrored %v %v", mirror.entries, err)
+	}
+
+	var entry *AuditLogEntry
+	err = db.RunInTx(context.TODO(), nil, nil, nil, func(ctx context.Context, tx *ti.Tx) error {
+		var err error
+		entry, err = logger.RecordTx(ctx, tx, nil, nil, AuditUserOtpReset, AuditTargetUser, 6942069,
+			map[string]interface{}{"otp_validated": true}, map[string]interface{}{"otp_validated": false}, nil, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal("\nAudit Log Record Failed\n    Error: ", err)
+	}
+	logger.Mirror(entry)
+	if len(mirror.entries) != 2 {
+		t.Fatalf("\nAudit Log Record Failed\n    Error: wrong mirrored entries %v", mirror.entries)
+	}
+
+	entries, err := logger.ByTarget(context.TODO(), nil, nil, AuditTargetUser, 6942069, start, time.Time{}, 0)
+	if err != nil {
+		t.Fatal("\nAudit Log Record Failed\n    Error: ", err)
+	}
+	if len(entries) != 2 || entries[0].Action != AuditUserOtpReset || entries[1].Action != AuditUserRoleChange {
+		t.Fatalf("\nAudit Log Record Failed\n    Error: wrong entries %+v", entries)
+	}
+	if entries[1].IP == nil || *entries[1].IP != ip || entries[1].Diff["auth_role"].After != float64(Admin) {
+		t.Fatalf("\nAudit Log Record Failed\n    Error: wrong entry %+v", entries[1])
+	}
+
+	entries, err = logger.ByActor(context.TODO(), nil, nil, actor, start, time.Time{}, 0)
+	if err != nil {
+		t.Fatal("\nAudit Log Record Failed\n    Error: ", err)
+	}
+	if len(entries) != 1 || entries[0].Action != AuditUserRoleChange {
+		t.Fatalf("\nAudit Log Record Failed\n    Error: wrong actor entries %+v", entries)
+	}
+
+	t.Log("\nAudit Log Record Succeeded")
+}
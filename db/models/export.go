@@ -0,0 +1,613 @@
+package models
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/gage-technologies/gigo-lib/storage"
+	"github.com/kisielk/sqlstruct"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultUserExportFileDirs are the storage directories that hold a user's
+// files. Each directory is formatted with the id of the user.
+var DefaultUserExportFileDirs = []string{"user/%d"}
+
+// UserExportManifestVersion is the version of the manifest format
+const UserExportManifestVersion = 1
+
+// userExportTable
+//
+//	Table included in a user export. The query selects the user's rows
+//	with every placeholder bound to the user's id and load converts the
+//	current row into the value that is written to the export.
+type userExportTable struct {
+	name  string
+	query string
+	load  func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error)
+}
+
+// userExportTables are the user owned tables that are included in an export
+var userExportTables = []userExportTable{
+	{
+		name:  "user",
+		query: "select * from users where _id = ?",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			user, err := UserFromSQLNative(e.db, rows)
+			if err != nil {
+				return nil, err
+			}
+			return user.ToFrontend()
+		},
+	},
+	{
+		name:  "user_stats",
+		query: "select * from user_stats where user_id = ? order by date",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			stats, err := UserStatsFromSQLNative(e.db, rows)
+			if err != nil {
+				return nil, err
+			}
+			return stats.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "posts",
+		query: "select * from post where author_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			post, err := PostFromSQLNative(e.db, rows)
+			if err != nil {
+				return nil, err
+			}
+			return post.ToFrontend()
+		},
+	},
+	{
+		name:  "attempts",
+		query: "select * from attempt where author_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			attempt, err := AttemptFromSQLNative(e.db, rows)
+			if err != nil {
+				return nil, err
+			}
+			return attempt.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "discussions",
+		query: "select * from discussion where author_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			discussion, err := DiscussionFromSQLNative(e.db, rows)
+			if err != nil {
+				return nil, err
+			}
+			return discussion.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "comments",
+		query: "select * from comment where author_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			comment, err := CommentFromSQLNative(e.db, rows)
+			if err != nil {
+				return nil, err
+			}
+			return comment.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "thread_comments",
+		query: "select * from thread_comment where author_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			comment, err := ThreadCommentFromSQLNative(rows)
+			if err != nil {
+				return nil, err
+			}
+			return comment.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "thread_replies",
+		query: "select * from thread_reply where author_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			reply, err := ThreadReplyFromSQLNative(rows)
+			if err != nil {
+				return nil, err
+			}
+			return reply.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "chats",
+		query: "select c.* from chat c join chat_users cu on cu.chat_id = c._id where cu.user_id = ? order by c._id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			chat, err := ChatFromSQLNative(userID, e.db, rows)
+			if err != nil {
+				return nil, err
+			}
+			return chat.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "chat_messages",
+		query: "select * from chat_messages where author_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			message, err := ChatMessageFromSQLNative(rows)
+			if err != nil {
+				return nil, err
+			}
+			return message.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "notifications",
+		query: "select * from notification where user_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			notification, err := NotificationFromSQLNative(rows)
+			if err != nil {
+				return nil, err
+			}
+			return notification.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "notification_preferences",
+		query: "select * from notification_preferences where user_id = ? order by notification_type",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			preference, err := NotificationPreferenceFromSQLNative(rows)
+			if err != nil {
+				return nil, err
+			}
+			return preference.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "xp_reasons",
+		query: "select * from xp_reasons where user_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			// XPReasonFromSQLNative consumes every row so the row is scanned directly
+			reason := new(XPReason)
+			err := sqlstruct.Scan(reason, rows)
+			if err != nil {
+				return nil, err
+			}
+			return reason.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "xp_boosts",
+		query: "select * from xp_boosts where user_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			boost := new(XPBoost)
+			err := sqlstruct.Scan(boost, rows)
+			if err != nil {
+				return nil, err
+			}
+			return boost.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "coffee",
+		query: "select * from coffee where user_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			coffee, err := CoffeeFromSQLNative(rows)
+			if err != nil {
+				return nil, err
+			}
+			return coffee.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "up_votes",
+		query: "select * from up_vote where user_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			vote, err := UpVoteFromSQLNative(rows)
+			if err != nil {
+				return nil, err
+			}
+			return vote.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "friends",
+		query: "select * from friends where user_id = ? or friend = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			friend, err := FriendsFromSQLNative(e.db, rows)
+			if err != nil {
+				return nil, err
+			}
+			return friend.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "friend_requests",
+		query: "select * from friend_requests where user_id = ? or friend = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			request, err := FriendRequestsFromSQLNative(e.db, rows)
+			if err != nil {
+				return nil, err
+			}
+			return request.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "followers",
+		query: "select * from follower where follower = ? or following = ?",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			follower, err := FollowerFromSQLNative(e.db, rows)
+			if err != nil {
+				return nil, err
+			}
+			return follower.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "blocked_users",
+		query: "select * from user_blocks where user_id = ? order by created_at",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			block := new(UserBlock)
+			err := sqlstruct.Scan(block, rows)
+			if err != nil {
+				return nil, err
+			}
+			return block.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "nemesis",
+		query: "select * from nemesis where antagonist_id = ? or protagonist_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			// NemesisFromSQLNative consumes every row so the row is scanned directly
+			nemesis := new(Nemesis)
+			err := sqlstruct.Scan(nemesis, rows)
+			if err != nil {
+				return nil, err
+			}
+			return nemesis.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "implicit_recommendations",
+		query: "select * from implicit_rec where user_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			rec, err := ImplicitRecFromSQLNative(rows)
+			if err != nil {
+				return nil, err
+			}
+			return rec.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "searches",
+		query: "select * from search_rec where user_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			rec, err := SearchRecFromSQLNative(e.db, rows)
+			if err != nil {
+				return nil, err
+			}
+			return rec.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "exclusive_content_purchases",
+		query: "select * from exclusive_content_purchases where user_id = ?",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			purchase, err := ExclusiveContentPurchasesFromSQLNative(e.db, rows)
+			if err != nil {
+				return nil, err
+			}
+			return purchase.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "free_premium",
+		query: "select _id as id, user_id, start_date, end_date, length from user_free_premium where user_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			return UserFreePremiumFromSQLNative(rows)
+		},
+	},
+	{
+		name:  "reported_issues",
+		query: "select _id as id, user_id, date, issue, page from report_issue where user_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			// ReportIssueFromSQLNative consumes every row so the row is scanned directly
+			issue := new(ReportIssue)
+			err := sqlstruct.Scan(issue, rows)
+			if err != nil {
+				return nil, err
+			}
+			return issue.ToFrontend(), nil
+		},
+	},
+	{
+		name:  "workspaces",
+		query: "select * from workspaces where owner_id = ? order by _id",
+		load: func(e *UserExporter, rows *sql.Rows, userID int64) (interface{}, error) {
+			workspace, err := WorkspaceFromSQLNative(rows)
+			if err != nil {
+				return nil, err
+			}
+			return workspace.ToFrontend(e.hostname, e.https), nil
+		},
+	},
+}
+
+// UserExportTable
+//
+//	Table entry of a user export manifest
+type UserExportTable struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+	Rows int    `json:"rows"`
+}
+
+// UserExportFile
+//
+//	File entry of a user export manifest
+type UserExportFile struct {
+	// Path is the path of the file in storage
+	Path string `json:"path"`
+	// File is the path of the file in the archive
+	File string `json:"file"`
+	Size int64  `json:"size"`
+}
+
+// UserExportManifest
+//
+//	Describes the contents of a user export archive. The manifest is
+//	written to manifest.json at the root of the archive.
+type UserExportManifest struct {
+	Version   int               `json:"version"`
+	UserID    string            `json:"user_id"`
+	CreatedAt time.Time         `json:"created_at"`
+	Tables    []UserExportTable `json:"tables"`
+	Files     []UserExportFile  `json:"files"`
+}
+
+// UserExporterOptions
+//
+//	Options for a UserExporter
+type UserExporterOptions struct {
+	DB      *ti.Database
+	Storage storage.Storage
+	// FileDirs are the storage directories that hold the user's files;
+	// defaults to DefaultUserExportFileDirs
+	FileDirs []string
+	// ExportDir is the storage directory that the archives are
+	// written to; defaults to exports
+	ExportDir string
+	// Hostname and HTTPS are used to format the ports of the user's workspaces
+	Hostname string
+	HTTPS    bool
+}
+
+// UserExporter
+//
+//	Exports all of the data held for a user into a zip archive. Every
+//	table is written as a JSON array of the rows' frontend representation
+//	under data/ and the user's files are copied under files/.
+type UserExporter struct {
+	db        *ti.Database
+	storage   storage.Storage
+	fileDirs  []string
+	exportDir string
+	hostname  string
+	https     bool
+}
+
+// NewUserExporter
+//
+//	Creates a new UserExporter
+func NewUserExporter(opts UserExporterOptions) (*UserExporter, error) {
+	if opts.DB == nil {
+		return nil, fmt.Errorf("user exporter requires a database")
+	}
+	if opts.Storage == nil {
+		return nil, fmt.Errorf("user exporter requires a storage engine")
+	}
+	if opts.FileDirs == nil {
+		opts.FileDirs = DefaultUserExportFileDirs
+	}
+	if opts.ExportDir == "" {
+		opts.ExportDir = "exports"
+	}
+
+	return &UserExporter{
+		db:        opts.DB,
+		storage:   opts.Storage,
+		fileDirs:  opts.FileDirs,
+		exportDir: opts.ExportDir,
+		hostname:  opts.Hostname,
+		https:     opts.HTTPS,
+	}, nil
+}
+
+// Export
+//
+//	Exports the data of the user and writes the archive to storage.
+//	Returns the storage path of the archive and its manifest. The
+//	archive is assembled in a temporary file so that large exports
+//	are not held in memory.
+func (e *UserExporter) Export(ctx context.Context, span *trace.Span, callerName *string, userID int64) (string, *UserExportManifest, error) {
+	tmp, err := os.CreateTemp("", fmt.Sprintf("gigo-export-%d-*.zip", userID))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary export file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	manifest, err := e.WriteArchive(ctx, span, callerName, userID, tmp)
+	if err != nil {
+		return "", nil, err
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to determine export size: %v", err)
+	}
+	_, err = tmp.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to rewind export file: %v", err)
+	}
+
+	exportPath := path.Join(e.exportDir, fmt.Sprintf("%d", userID), fmt.Sprintf("%d.zip", manifest.CreatedAt.Unix()))
+	// the storage engine closes the reader once the file has been written
+	err = e.storage.CreateFileStreamed(exportPath, size, io.NopCloser(tmp))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to write export to storage: %v", err)
+	}
+
+	return exportPath, manifest, nil
+}
+
+// WriteArchive
+//
+//	Writes the zip archive of the user's data to the writer
+func (e *UserExporter) WriteArchive(ctx context.Context, span *trace.Span, callerName *string, userID int64, w io.Writer) (*UserExportManifest, error) {
+	manifest := &UserExportManifest{
+		Version:   UserExportManifestVersion,
+		UserID:    fmt.Sprintf("%d", userID),
+		CreatedAt: time.Now(),
+		Tables:    make([]UserExportTable, 0, len(userExportTables)),
+		Files:     make([]UserExportFile, 0),
+	}
+
+	archive := zip.NewWriter(w)
+
+	for _, table := range userExportTables {
+		rows, err := e.loadTable(ctx, span, callerName, table, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		name := fmt.Sprintf("data/%s.json", table.name)
+		err = writeExportJSON(archive, name, rows)
+		if err != nil {
+			return nil, err
+		}
+
+		manifest.Tables = append(manifest.Tables, UserExportTable{
+			Name: table.name,
+			File: name,
+			Rows: len(rows),
+		})
+	}
+
+	for _, dir := range e.fileDirs {
+		files, err := e.writeFiles(archive, fmt.Sprintf(dir, userID))
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, files...)
+	}
+
+	err := writeExportJSON(archive, "manifest.json", manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	err = archive.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to close export archive: %v", err)
+	}
+
+	return manifest, nil
+}
+
+// loadTable
+//
+//	Loads the user's rows from an export table
+func (e *UserExporter) loadTable(ctx context.Context, span *trace.Span, callerName *string, table userExportTable, userID int64) ([]interface{}, error) {
+	args := make([]interface{}, strings.Count(table.query, "?"))
+	for i := range args {
+		args[i] = userID
+	}
+
+	res, err := e.db.QueryContext(ctx, span, callerName, table.query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s for export: %v", table.name, err)
+	}
+	defer res.Close()
+
+	rows := make([]interface{}, 0)
+	for res.Next() {
+		row, err := table.load(e, res, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s for export: %v", table.name, err)
+		}
+		rows = append(rows, row)
+	}
+	if err := res.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query %s for export: %v", table.name, err)
+	}
+
+	return rows, nil
+}
+
+// writeFiles
+//
+//	Copies the files in the storage directory into the archive
+func (e *UserExporter) writeFiles(archive *zip.Writer, dir string) ([]UserExportFile, error) {
+	// listing a directory that does not exist returns no paths
+	paths, err := e.storage.ListDir(dir, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list export directory %s: %v", dir, err)
+	}
+
+	files := make([]UserExportFile, 0, len(paths))
+	for _, p := range paths {
+		reader, err := e.storage.GetFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s for export: %v", p, err)
+		}
+		// files removed since the listing are skipped
+		if reader == nil {
+			continue
+		}
+
+		name := path.Join("files", p)
+		writer, err := archive.Create(name)
+		if err != nil {
+			_ = reader.Close()
+			return nil, fmt.Errorf("failed to add %s to export: %v", p, err)
+		}
+
+		size, err := io.Copy(writer, reader)
+		_ = reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy %s to export: %v", p, err)
+		}
+
+		files = append(files, UserExportFile{Path: p, File: name, Size: size})
+	}
+
+	return files, nil
+}
+
+// writeExportJSON
+//
+//	Writes the value to the archive as indented JSON
+func writeExportJSON(archive *zip.Writer, name string, value interface{}) error {
+	writer, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to export: %v", name, err)
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s for export: %v", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/gage-technologies/gigo-lib/storage"
+)
+
+func TestUserExporter_Export(t *testing.T) {
+	db, err := ti.CreateDatabase("gigo-dev-tidb", "4000", "mysql", "gigo-dev", "gigo-dev", "gigo_dev_test")
+	if err != nil {
+		t.Fatal("\nUser Export Failed\n    Error: ", err)
+	}
+
+	defer db.DB.Exec("delete from users where _id = 6942069")
+	defer db.DB.Exec("delete from notification where user_id = 6942069")
+
+	user, err := CreateUser(6942069, "test", "testpass", "testemail@email.com",
+		"phone", UserStatusBasic, "test", []int64{1, 2}, []int64{1, 2, 3},
+		"first", "last", 23, "", DefaultUserStart, "America/Chicago",
+		AvatarSettings{}, 0)
+	if err != nil {
+		t.Fatal("\nUser Export Failed\n    Error: ", err)
+	}
+
+	statements, err := user.ToSQLNative()
+	if err != nil {
+		t.Fatal("\nUser Export Failed\n    Error: ", err)
+	}
+
+	notification, err := CreateNotification(69, 6942069, "test", StreakInfo, time.Now(), false, nil)
+	if err != nil {
+		t.Fatal("\nUser Export Failed\n    Error: ", err)
+	}
+	statements = append(statements, notification.ToSQLNative())
+
+	for _, statement := range statements {
+		_, err = db.DB.Exec(statement.Statement, statement.Values...)
+		if err != nil {
+			t.Fatal("\nUser Export Failed\n    Error: ", err)
+		}
+	}
+
+	root, err := os.MkdirTemp("", "gigo-export-test")
+	if err != nil {
+		t.Fatal("\nUser Export Failed\n    Error: ", err)
+	}
+	defer os.RemoveAll(root)
+
+	storageEngine, err := storage.CreateFileSystemStorage(root)
+	if err != nil {
+		t.Fatal("\nUser Export Failed\n    Error: ", err)
+	}
+
+	err = storageEngine.CreateFile("user/6942069/profile-pic.svg", []byte("<svg></svg>"))
+	if err != nil {
+		t.Fatal("\nUser Export Failed\n    Error: ", err)
+	}
+
+	exporter, err := NewUserExporter(UserExporterOptions{DB: db, Storage: storageEngine})
+	if err != nil {
+		t.Fatal("\nUser Export Failed\n    Error: ", err)
+	}
+
+	exportPath, manifest, err := exporter.Export(context.TODO(), nil, nil, 6942069)
+	if err != nil {
+		t.Fatal("\nUser Export Failed\n    Error: ", err)
+	}
+
+	if len(manifest.Tables) != len(userExportTables) || len(manifest.Files) != 1 {
+		t.Fatalf("\nUser Export Failed\n    Error: wrong manifest %+v", manifest)
+	}
+
+	for _, table := range manifest.Tables {
+		if (table.Name == "user" || table.Name == "notifications") && table.Rows != 1 {
+			t.Fatalf("\nUser Export Failed\n    Error: wrong rows for %s: %d", table.Name, table.Rows)
+		}
+	}
+
+	reader, err := storageEngine.GetFile(exportPath)
+	if err != nil || reader == nil {
+		t.Fatal("\nUser Export Failed\n    Error: export was not written ", err)
+	}
+	buf, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatal("\nUser Export Failed\n    Error: ", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf), int64(len(buf)))
+	if err != nil {
+		t.Fatal("\nUser Export Failed\n    Error: ", err)
+	}
+
+	files := make(map[string]*zip.File)
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	for _, name := range []string{"manifest.json", "data/user.json", "data/notifications.json", "files/user/6942069/profile-pic.svg"} {
+		if files[name] == nil {
+			t.Fatalf("\nUser Export Failed\n    Error: %s is missing from the archive", name)
+		}
+	}
+
+	f, err := files["data/notifications.json"].Open()
+	if err != nil {
+		t.Fatal("\nUser Export Failed\n    Error: ", err)
+	}
+	defer f.Close()
+
+	var notifications []NotificationFrontend
+	err = json.NewDecoder(f).Decode(&notifications)
+	if err != nil {
+		t.Fatal("\nUser Export Failed\n    Error: ", err)
+	}
+
+	if len(notifications) != 1 || notifications[0].ID != "69" {
+		t.Fatalf("\nUser Export Failed\n    Error: wrong notifications %+v", notifications)
+	}
+
+	t.Log("\nUser Export Succeeded")
+}
@@ -0,0 +1,951 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/kisielk/sqlstruct"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrNemesisNotFound is returned when a match does not exist or is not
+	// in the state required by the operation
+	ErrNemesisNotFound = errors.New("nemesis match not found")
+	// ErrNemesisNotProtagonist is returned when a user other than the
+	// protagonist attempts to accept or decline a match
+	ErrNemesisNotProtagonist = errors.New("only the protagonist can respond to a nemesis match")
+)
+
+// NemesisMatchRules
+//
+//	Rules that determine which users can be matched as rivals and how
+//	good a match is. Zero values are replaced by the defaults.
+type NemesisMatchRules struct {
+	// MaxXPGap is the largest XP difference between rivals; defaults to 5000
+	MaxXPGap uint64
+	// MaxTierGap is the largest renown tier difference between rivals; defaults to 1
+	MaxTierGap int
+	// ActiveWithin is the time since a user's last activity in which they
+	// can be matched; defaults to 7 days
+	ActiveWithin time.Duration
+	// LanguageWindow is the time over which the languages of a user's
+	// attempts are collected; defaults to 90 days
+	LanguageWindow time.Duration
+	// RematchCooldown is the time before the same users can be matched
+	// again; defaults to 30 days
+	RematchCooldown time.Duration
+	// MatchDuration is the time between accepting a match and its
+	// resolution; defaults to 7 days
+	MatchDuration time.Duration
+	// PendingTTL is the time a match waits to be accepted before it
+	// expires; defaults to 2 days
+	PendingTTL time.Duration
+
+	// weights of the components of the match score; all default to 1
+	XPWeight       float64
+	TierWeight     float64
+	RecencyWeight  float64
+	LanguageWeight float64
+}
+
+func (r NemesisMatchRules) withDefaults() NemesisMatchRules {
+	if r.MaxXPGap == 0 {
+		r.MaxXPGap = 5000
+	}
+	if r.MaxTierGap <= 0 {
+		r.MaxTierGap = 1
+	}
+	if r.ActiveWithin <= 0 {
+		r.ActiveWithin = time.Hour * 24 * 7
+	}
+	if r.LanguageWindow <= 0 {
+		r.LanguageWindow = time.Hour * 24 * 90
+	}
+	if r.RematchCooldown <= 0 {
+		r.RematchCooldown = time.Hour * 24 * 30
+	}
+	if r.MatchDuration <= 0 {
+		r.MatchDuration = time.Hour * 24 * 7
+	}
+	if r.PendingTTL <= 0 {
+		r.PendingTTL = time.Hour * 24 * 2
+	}
+	if r.XPWeight == 0 && r.TierWeight == 0 && r.RecencyWeight == 0 && r.LanguageWeight == 0 {
+		r.XPWeight = 1
+		r.TierWeight = 1
+		r.RecencyWeight = 1
+		r.LanguageWeight = 1
+	}
+	return r
+}
+
+// NemesisCandidate
+//
+//	User that can be matched with a rival
+type NemesisCandidate struct {
+	UserID     int64
+	UserName   string
+	XP         uint64
+	Tier       TierType
+	LastActive time.Time
+	Languages  []ProgrammingLanguage
+}
+
+// NemesisMatch
+//
+//	Proposed rivalry between two candidates. The antagonist challenges
+//	the protagonist.
+type NemesisMatch struct {
+	Antagonist  *NemesisCandidate
+	Protagonist *NemesisCandidate
+	Score       float64
+}
+
+// NemesisPair
+//
+//	Unordered pair of users. NewNemesisPair orders the ids so a pair can
+//	be used as a map key regardless of the roles of the users.
+type NemesisPair [2]int64
+
+func NewNemesisPair(a int64, b int64) NemesisPair {
+	if a > b {
+		a, b = b, a
+	}
+	return NemesisPair{a, b}
+}
+
+// NemesisMatchmaker
+//
+//	Pairs candidates into rivalries. Candidates are scored by the
+//	closeness of their XP and renown tier, how recently both users were
+//	active and the overlap of the languages they use. Ties are broken by
+//	the random source so the result is deterministic for a given seed.
+type NemesisMatchmaker struct {
+	rules NemesisMatchRules
+	rng   *rand.Rand
+}
+
+// NewNemesisMatchmaker
+//
+//	Creates a new NemesisMatchmaker using a random source with the passed seed
+func NewNemesisMatchmaker(rules NemesisMatchRules, seed int64) *NemesisMatchmaker {
+	return &NemesisMatchmaker{
+		rules: rules.withDefaults(),
+		rng:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Rules
+//
+//	Returns the rules of the matchmaker with the defaults applied
+func (m *NemesisMatchmaker) Rules() NemesisMatchRules {
+	return m.rules
+}
+
+// Score
+//
+//	Returns the score of a match between the candidates in [0, 1] and
+//	false if the candidates cannot be matched
+func (m *NemesisMatchmaker) Score(a *NemesisCandidate, b *NemesisCandidate, now time.Time) (float64, bool) {
+	if a.UserID == b.UserID {
+		return 0, false
+	}
+
+	xpGap := a.XP - b.XP
+	if b.XP > a.XP {
+		xpGap = b.XP - a.XP
+	}
+	if xpGap > m.rules.MaxXPGap {
+		return 0, false
+	}
+
+	tierGap := int(a.Tier) - int(b.Tier)
+	if tierGap < 0 {
+		tierGap = -tierGap
+	}
+	if tierGap > m.rules.MaxTierGap {
+		return 0, false
+	}
+
+	// the least recently active user determines the recency of the match
+	idle := now.Sub(a.LastActive)
+	if bIdle := now.Sub(b.LastActive); bIdle > idle {
+		idle = bIdle
+	}
+	if idle > m.rules.ActiveWithin {
+		return 0, false
+	}
+	if idle < 0 {
+		idle = 0
+	}
+
+	xpScore := 1 - float64(xpGap)/float64(m.rules.MaxXPGap)
+	tierScore := 1 - float64(tierGap)/float64(m.rules.MaxTierGap+1)
+	recencyScore := 1 - float64(idle)/float64(m.rules.ActiveWithin)
+	languageScore := languageOverlap(a.Languages, b.Languages)
+
+	total := m.rules.XPWeight + m.rules.TierWeight + m.rules.RecencyWeight + m.rules.LanguageWeight
+	score := (m.rules.XPWeight*xpScore +
+		m.rules.TierWeight*tierScore +
+		m.rules.RecencyWeight*recencyScore +
+		m.rules.LanguageWeight*languageScore) / total
+
+	return score, true
+}
+
+// languageOverlap
+//
+//	Returns the jaccard similarity of the language sets
+func languageOverlap(a []ProgrammingLanguage, b []ProgrammingLanguage) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	set := make(map[ProgrammingLanguage]bool, len(a))
+	for _, lang := range a {
+		set[lang] = true
+	}
+
+	union := len(set)
+	shared := 0
+	seen := make(map[ProgrammingLanguage]bool, len(b))
+	for _, lang := range b {
+		if seen[lang] {
+			continue
+		}
+		seen[lang] = true
+		if set[lang] {
+			shared++
+		} else {
+			union++
+		}
+	}
+
+	return float64(shared) / float64(union)
+}
+
+// nemesisBucket
+//
+//	Tier and XP band of a candidate. Candidates can only be matched with
+//	candidates in the same or a neighbouring band whose tier is within
+//	the rules' tier gap.
+type nemesisBucket struct {
+	tier int
+	band uint64
+}
+
+// bucket
+//
+//	Returns the bucket of the candidate
+func (m *NemesisMatchmaker) bucket(candidate *NemesisCandidate) nemesisBucket {
+	return nemesisBucket{tier: int(candidate.Tier), band: candidate.XP / m.rules.MaxXPGap}
+}
+
+// neighbours
+//
+//	Returns the buckets that can hold rivals of a candidate in the bucket
+func (m *NemesisMatchmaker) neighbours(bucket nemesisBucket) []nemesisBucket {
+	neighbours := make([]nemesisBucket, 0, (2*m.rules.MaxTierGap+1)*3)
+	for tier := bucket.tier - m.rules.MaxTierGap; tier <= bucket.tier+m.rules.MaxTierGap; tier++ {
+		if bucket.band > 0 {
+			neighbours = append(neighbours, nemesisBucket{tier: tier, band: bucket.band - 1})
+		}
+		neighbours = append(neighbours, nemesisBucket{tier: tier, band: bucket.band})
+		neighbours = append(neighbours, nemesisBucket{tier: tier, band: bucket.band + 1})
+	}
+	return neighbours
+}
+
+// Pair
+//
+//	Pairs the candidates into matches. Each candidate is matched at most
+//	once and pairs in recent are never matched. The highest scoring
+//	eligible pairs are chosen first. Candidates are bucketed by tier and
+//	XP band so only candidates that can be matched are scored.
+func (m *NemesisMatchmaker) Pair(candidates []*NemesisCandidate, recent map[NemesisPair]bool, now time.Time) []*NemesisMatch {
+	// order the candidates by id before shuffling so the result does not
+	// depend on the order the candidates were loaded in
+	ordered := make([]*NemesisCandidate, len(candidates))
+	copy(ordered, candidates)
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].UserID < ordered[j].UserID
+	})
+	m.rng.Shuffle(len(ordered), func(i, j int) {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	})
+
+	buckets := make(map[nemesisBucket][]int)
+	for i, candidate := range ordered {
+		bucket := m.bucket(candidate)
+		buckets[bucket] = append(buckets[bucket], i)
+	}
+
+	type scoredPair struct {
+		a, b  int
+		score float64
+	}
+
+	pairs := make([]scoredPair, 0)
+	for i := range ordered {
+		for _, neighbour := range m.neighbours(m.bucket(ordered[i])) {
+			for _, j := range buckets[neighbour] {
+				// each pair is scored once from its first candidate
+				if j <= i {
+					continue
+				}
+				if recent[NewNemesisPair(ordered[i].UserID, ordered[j].UserID)] {
+					continue
+				}
+				score, ok := m.Score(ordered[i], ordered[j], now)
+				if !ok {
+					continue
+				}
+				pairs = append(pairs, scoredPair{a: i, b: j, score: score})
+			}
+		}
+	}
+
+	// equal scores keep the shuffled order of their candidates
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].score != pairs[j].score {
+			return pairs[i].score > pairs[j].score
+		}
+		if pairs[i].a != pairs[j].a {
+			return pairs[i].a < pairs[j].a
+		}
+		return pairs[i].b < pairs[j].b
+	})
+
+	matched := make([]bool, len(ordered))
+	matches := make([]*NemesisMatch, 0)
+	for _, pair := range pairs {
+		if matched[pair.a] || matched[pair.b] {
+			continue
+		}
+		matched[pair.a] = true
+		matched[pair.b] = true
+		matches = append(matches, &NemesisMatch{
+			Antagonist:  ordered[pair.a],
+			Protagonist: ordered[pair.b],
+			Score:       pair.score,
+		})
+	}
+
+	return matches
+}
+
+// FindRival
+//
+//	Returns the best rival for the candidate from the pool or nil if no
+//	candidate in the pool can be matched
+func (m *NemesisMatchmaker) FindRival(candidate *NemesisCandidate, pool []*NemesisCandidate, recent map[NemesisPair]bool, now time.Time) *NemesisMatch {
+	ordered := make([]*NemesisCandidate, len(pool))
+	copy(ordered, pool)
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].UserID < ordered[j].UserID
+	})
+	m.rng.Shuffle(len(ordered), func(i, j int) {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	})
+
+	var best *NemesisMatch
+	for _, rival := range ordered {
+		if recent[NewNemesisPair(candidate.UserID, rival.UserID)] {
+			continue
+		}
+		score, ok := m.Score(candidate, rival, now)
+		if !ok || (best != nil && score <= best.Score) {
+			continue
+		}
+		best = &NemesisMatch{Antagonist: candidate, Protagonist: rival, Score: score}
+	}
+
+	return best
+}
+
+// NemesisMatchmakingOptions
+//
+//	Options for a NemesisMatchmaking
+type NemesisMatchmakingOptions struct {
+	DB    *ti.Database
+	SF    *snowflake.Node
+	Rules NemesisMatchRules
+	// Seed seeds the random source used to break ties; defaults to the
+	// current time
+	Seed *int64
+}
+
+// NemesisMatchmaking
+//
+//	Creates, accepts and resolves nemesis matches. Matches are created
+//	pending and become active once the protagonist accepts them. Active
+//	matches are resolved when their end time passes and pending matches
+//	expire after the pending ttl.
+type NemesisMatchmaking struct {
+	db         *ti.Database
+	sf         *snowflake.Node
+	matchmaker *NemesisMatchmaker
+}
+
+// nemesisChunkSize is the number of user ids bound to a single in list so
+// large candidate pools stay well below the placeholder limit
+const nemesisChunkSize = 1000
+
+// nemesisChunks
+//
+//	Splits the user ids into chunks of nemesisChunkSize and returns the
+//	placeholders and arguments of the in list of each chunk
+func nemesisChunks(userIDs []int64) ([]string, [][]interface{}) {
+	placeholders := make([]string, 0, len(userIDs)/nemesisChunkSize+1)
+	args := make([][]interface{}, 0, len(userIDs)/nemesisChunkSize+1)
+	for start := 0; start < len(userIDs); start += nemesisChunkSize {
+		end := start + nemesisChunkSize
+		if end > len(userIDs) {
+			end = len(userIDs)
+		}
+
+		chunk := make([]interface{}, 0, end-start)
+		for _, userID := range userIDs[start:end] {
+			chunk = append(chunk, userID)
+		}
+		placeholders = append(placeholders, strings.TrimSuffix(strings.Repeat("?, ", len(chunk)), ", "))
+		args = append(args, chunk)
+	}
+	return placeholders, args
+}
+
+// NewNemesisMatchmaking
+//
+//	Creates a new NemesisMatchmaking
+func NewNemesisMatchmaking(opts NemesisMatchmakingOptions) (*NemesisMatchmaking, error) {
+	if opts.DB == nil {
+		return nil, fmt.Errorf("nemesis matchmaking requires a database")
+	}
+	if opts.SF == nil {
+		return nil, fmt.Errorf("nemesis matchmaking requires a snowflake node")
+	}
+
+	seed := time.Now().UnixNano()
+	if opts.Seed != nil {
+		seed = *opts.Seed
+	}
+
+	return &NemesisMatchmaking{
+		db:         opts.DB,
+		sf:         opts.SF,
+		matchmaker: NewNemesisMatchmaker(opts.Rules, seed),
+	}, nil
+}
+
+// LoadCandidates
+//
+//	Loads the users that were active within the rules' window and are not
+//	part of a pending or active match
+func (n *NemesisMatchmaking) LoadCandidates(ctx context.Context, span *trace.Span, callerName *string, now time.Time) ([]*NemesisCandidate, error) {
+	rules := n.matchmaker.Rules()
+
+	res, err := n.db.QueryContext(ctx, span, callerName,
+		"select u._id, u.user_name, u.xp, u.tier, max(a.end_time) from users u "+
+			"join user_active_times a on a.user_id = u._id "+
+			"where a.end_time >= ? "+
+			"and not exists (select 1 from nemesis n where (n.antagonist_id = u._id or n.protagonist_id = u._id) and n.victor is null) "+
+			"group by u._id, u.user_name, u.xp, u.tier",
+		now.Add(-rules.ActiveWithin),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query nemesis candidates: %v", err)
+	}
+
+	candidates := make([]*NemesisCandidate, 0)
+	byID := make(map[int64]*NemesisCandidate)
+	for res.Next() {
+		candidate := new(NemesisCandidate)
+		err = res.Scan(&candidate.UserID, &candidate.UserName, &candidate.XP, &candidate.Tier, &candidate.LastActive)
+		if err != nil {
+			_ = res.Close()
+			return nil, fmt.Errorf("failed to scan nemesis candidate: %v", err)
+		}
+		candidates = append(candidates, candidate)
+		byID[candidate.UserID] = candidate
+	}
+	err = res.Err()
+	_ = res.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query nemesis candidates: %v", err)
+	}
+
+	if len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	userIDs := make([]int64, 0, len(candidates))
+	for _, candidate := range candidates {
+		userIDs = append(userIDs, candidate.UserID)
+	}
+
+	placeholders, chunks := nemesisChunks(userIDs)
+	for i, args := range chunks {
+		res, err = n.db.QueryContext(ctx, span, callerName,
+			"select distinct a.author_id, l.lang_id from attempt a join post_langs l on l.post_id = a.post_id "+
+				"where a.author_id in ("+placeholders[i]+") and a.created_at >= ?",
+			append(args, now.Add(-rules.LanguageWindow))...,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query nemesis candidate languages: %v", err)
+		}
+
+		for res.Next() {
+			var userID int64
+			var lang ProgrammingLanguage
+			err = res.Scan(&userID, &lang)
+			if err != nil {
+				_ = res.Close()
+				return nil, fmt.Errorf("failed to scan nemesis candidate language: %v", err)
+			}
+			if candidate, ok := byID[userID]; ok {
+				candidate.Languages = append(candidate.Languages, lang)
+			}
+		}
+
+		err = res.Err()
+		_ = res.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to query nemesis candidate languages: %v", err)
+		}
+	}
+
+	return candidates, nil
+}
+
+// RecentOpponents
+//
+//	Returns the pairs of users that were matched within the rules'
+//	rematch cooldown
+func (n *NemesisMatchmaking) RecentOpponents(ctx context.Context, span *trace.Span, callerName *string, now time.Time) (map[NemesisPair]bool, error) {
+	since := now.Add(-n.matchmaker.Rules().RematchCooldown)
+
+	res, err := n.db.QueryContext(ctx, span, callerName,
+		"select antagonist_id, protagonist_id from nemesis where time_of_villainy >= ? "+
+			"union select antagonist_id, protagonist_id from nemesis_history where created_at >= ?",
+		since, since,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recent nemesis opponents: %v", err)
+	}
+	defer res.Close()
+
+	recent := make(map[NemesisPair]bool)
+	for res.Next() {
+		var antagonist, protagonist int64
+		err = res.Scan(&antagonist, &protagonist)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recent nemesis opponents: %v", err)
+		}
+		recent[NewNemesisPair(antagonist, protagonist)] = true
+	}
+
+	if err := res.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query recent nemesis opponents: %v", err)
+	}
+
+	return recent, nil
+}
+
+// BlockedPairs
+//
+//	Returns the pairs of the passed users where either user has blocked
+//	the other
+func (n *NemesisMatchmaking) BlockedPairs(ctx context.Context, span *trace.Span, callerName *string, userIDs []int64) (map[NemesisPair]bool, error) {
+	blocked := make(map[NemesisPair]bool)
+	if len(userIDs) < 2 {
+		return blocked, nil
+	}
+
+	// the blocks of each chunk of users are loaded and filtered to the
+	// passed users so the in list never holds more than a chunk
+	users := make(map[int64]bool, len(userIDs))
+	for _, userID := range userIDs {
+		users[userID] = true
+	}
+
+	placeholders, chunks := nemesisChunks(userIDs)
+	for i, args := range chunks {
+		res, err := n.db.QueryContext(ctx, span, callerName,
+			"select user_id, blocked_id from user_blocks where user_id in ("+placeholders[i]+")",
+			args...,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query blocked nemesis pairs: %v", err)
+		}
+
+		for res.Next() {
+			var userID, blockedID int64
+			err = res.Scan(&userID, &blockedID)
+			if err != nil {
+				_ = res.Close()
+				return nil, fmt.Errorf("failed to scan blocked nemesis pair: %v", err)
+			}
+			if users[blockedID] {
+				blocked[NewNemesisPair(userID, blockedID)] = true
+			}
+		}
+
+		err = res.Err()
+		_ = res.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to query blocked nemesis pairs: %v", err)
+		}
+	}
+
+	return blocked, nil
+}
+
+// Matchmake
+//
+//	Pairs the current candidates and creates a pending match for each pair.
+//	Users that were recently matched or that have blocked each other are
+//	never paired.
+func (n *NemesisMatchmaking) Matchmake(ctx context.Context, span *trace.Span, callerName *string, now time.Time) ([]*Nemesis, error) {
+	candidates, err := n.LoadCandidates(ctx, span, callerName, now)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded, err := n.RecentOpponents(ctx, span, callerName, now)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]int64, 0, len(candidates))
+	for _, candidate := range candidates {
+		userIDs = append(userIDs, candidate.UserID)
+	}
+	blocked, err := n.BlockedPairs(ctx, span, callerName, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	for pair := range blocked {
+		excluded[pair] = true
+	}
+
+	matches := n.matchmaker.Pair(candidates, excluded, now)
+	if len(matches) == 0 {
+		return []*Nemesis{}, nil
+	}
+
+	matched := make([]int64, 0, len(matches)*2)
+	for _, match := range matches {
+		matched = append(matched, match.Antagonist.UserID, match.Protagonist.UserID)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i] < matched[j]
+	})
+	placeholders, chunks := nemesisChunks(matched)
+
+	created := make([]*Nemesis, 0, len(matches))
+	err = n.db.RunInTx(ctx, span, callerName, nil, func(ctx context.Context, tx *ti.Tx) error {
+		created = created[:0]
+
+		// lock the matched users in id order and re-check that they are not
+		// part of a match so concurrent runs cannot match a user twice
+		busy := make(map[int64]bool)
+		for i, args := range chunks {
+			res, err := tx.QueryContext(ctx, callerName,
+				"select _id from users where _id in ("+placeholders[i]+") order by _id for update", args...,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to lock nemesis candidates: %w", err)
+			}
+			_ = res.Close()
+
+			res, err = tx.QueryContext(ctx, callerName,
+				"select antagonist_id, protagonist_id from nemesis where victor is null "+
+					"and (antagonist_id in ("+placeholders[i]+") or protagonist_id in ("+placeholders[i]+"))",
+				append(append([]interface{}{}, args...), args...)...,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to query active nemesis matches: %w", err)
+			}
+			for res.Next() {
+				var antagonist, protagonist int64
+				err = res.Scan(&antagonist, &protagonist)
+				if err != nil {
+					_ = res.Close()
+					return fmt.Errorf("failed to scan active nemesis match: %w", err)
+				}
+				busy[antagonist] = true
+				busy[protagonist] = true
+			}
+			err = res.Err()
+			_ = res.Close()
+			if err != nil {
+				return fmt.Errorf("failed to query active nemesis matches: %w", err)
+			}
+		}
+
+		for _, match := range matches {
+			if busy[match.Antagonist.UserID] || busy[match.Protagonist.UserID] {
+				continue
+			}
+			nemesis := CreateNemesis(
+				n.sf.Generate().Int64(),
+				match.Antagonist.UserID, match.Antagonist.UserName,
+				match.Protagonist.UserID, match.Protagonist.UserName,
+				now, nil, false, nil, 0, 0,
+			)
+			for _, statement := range nemesis.ToSQLNative() {
+				_, err := tx.ExecContext(ctx, callerName, statement.Statement, statement.Values...)
+				if err != nil {
+					return fmt.Errorf("failed to insert nemesis match: %w", err)
+				}
+			}
+			created = append(created, nemesis)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// loadMatch
+//
+//	Loads a match by id
+func (n *NemesisMatchmaking) loadMatch(ctx context.Context, span *trace.Span, callerName *string, nemesisID int64) (*Nemesis, error) {
+	res, err := n.db.QueryContext(ctx, span, callerName, "select * from nemesis where _id = ?", nemesisID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query nemesis match: %v", err)
+	}
+	defer res.Close()
+
+	if !res.Next() {
+		if err := res.Err(); err != nil {
+			return nil, fmt.Errorf("failed to query nemesis match: %v", err)
+		}
+		return nil, ErrNemesisNotFound
+	}
+
+	// NemesisFromSQLNative consumes every row so the row is scanned directly
+	nemesis := new(Nemesis)
+	err = sqlstruct.Scan(nemesis, res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan nemesis match: %v", err)
+	}
+
+	return nemesis, nil
+}
+
+// Accept
+//
+//	Accepts a pending match on behalf of its protagonist. The match ends
+//	after the rules' match duration.
+func (n *NemesisMatchmaking) Accept(ctx context.Context, span *trace.Span, callerName *string, nemesisID int64, userID int64, now time.Time) (*Nemesis, error) {
+	nemesis, err := n.loadMatch(ctx, span, callerName, nemesisID)
+	if err != nil {
+		return nil, err
+	}
+	if nemesis.IsAccepted || nemesis.Victor != nil {
+		return nil, ErrNemesisNotFound
+	}
+	if nemesis.ProtagonistID != userID {
+		return nil, ErrNemesisNotProtagonist
+	}
+
+	endTime := now.Add(n.matchmaker.Rules().MatchDuration)
+	res, err := n.db.ExecContext(ctx, span, callerName,
+		"update nemesis set is_accepted = true, end_time = ? where _id = ? and is_accepted = false and victor is null",
+		endTime, nemesisID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept nemesis match: %v", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		// the match was accepted or resolved concurrently
+		return nil, ErrNemesisNotFound
+	}
+
+	nemesis.IsAccepted = true
+	nemesis.EndTime = &endTime
+	return nemesis, nil
+}
+
+// Decline
+//
+//	Declines a pending match on behalf of its protagonist. Declined
+//	matches are removed but still count towards the rematch cooldown.
+func (n *NemesisMatchmaking) Decline(ctx context.Context, span *trace.Span, callerName *string, nemesisID int64, userID int64) error {
+	nemesis, err := n.loadMatch(ctx, span, callerName, nemesisID)
+	if err != nil {
+		return err
+	}
+	if nemesis.IsAccepted || nemesis.Victor != nil {
+		return ErrNemesisNotFound
+	}
+	if nemesis.ProtagonistID != userID {
+		return ErrNemesisNotProtagonist
+	}
+
+	// record the pairing in the history before removing the match so
+	// that the users are not matched again before the cooldown passes
+	history := &NemesisHistory{
+		ID:            n.sf.Generate().Int64(),
+		MatchID:       nemesis.ID,
+		AntagonistID:  nemesis.AntagonistID,
+		ProtagonistID: nemesis.ProtagonistID,
+		IsAlerted:     true,
+		CreatedAt:     nemesis.TimeOfVillainy,
+	}
+
+	return n.db.RunInTx(ctx, span, callerName, nil, func(ctx context.Context, tx *ti.Tx) error {
+		for _, statement := range history.ToSQLNative() {
+			_, err := tx.ExecContext(ctx, callerName, statement.Statement, statement.Values...)
+			if err != nil {
+				return fmt.Errorf("failed to insert nemesis history: %w", err)
+			}
+		}
+
+		_, err := tx.ExecContext(ctx, callerName,
+			"delete from nemesis where _id = ? and is_accepted = false and victor is null", nemesisID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to decline nemesis match: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Resolve
+//
+//	Resolves the active matches whose end time has passed and removes
+//	the pending matches that expired, recording them in the history. The victor is the user that
+//	captured the most towers; ties are broken by the XP the users held at
+//	their latest history entry and finally in favour of the protagonist.
+//	Returns the resolved matches.
+func (n *NemesisMatchmaking) Resolve(ctx context.Context, span *trace.Span, callerName *string, now time.Time) ([]*Nemesis, error) {
+	// expired matches are recorded in the history like declined matches so
+	// the users are not matched again before the cooldown passes
+	err := n.db.RunInTx(ctx, span, callerName, nil, func(ctx context.Context, tx *ti.Tx) error {
+		res, err := tx.QueryContext(ctx, callerName,
+			"select * from nemesis where is_accepted = false and victor is null and time_of_villainy < ? for update",
+			now.Add(-n.matchmaker.Rules().PendingTTL),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to query expired nemesis matches: %w", err)
+		}
+
+		expired := make([]*Nemesis, 0)
+		for res.Next() {
+			nemesis := new(Nemesis)
+			err = sqlstruct.Scan(nemesis, res)
+			if err != nil {
+				_ = res.Close()
+				return fmt.Errorf("failed to scan expired nemesis match: %w", err)
+			}
+			expired = append(expired, nemesis)
+		}
+		err = res.Err()
+		_ = res.Close()
+		if err != nil {
+			return fmt.Errorf("failed to query expired nemesis matches: %w", err)
+		}
+
+		for _, nemesis := range expired {
+			history := &NemesisHistory{
+				ID:            n.sf.Generate().Int64(),
+				MatchID:       nemesis.ID,
+				AntagonistID:  nemesis.AntagonistID,
+				ProtagonistID: nemesis.ProtagonistID,
+				IsAlerted:     true,
+				CreatedAt:     nemesis.TimeOfVillainy,
+			}
+			for _, statement := range history.ToSQLNative() {
+				_, err := tx.ExecContext(ctx, callerName, statement.Statement, statement.Values...)
+				if err != nil {
+					return fmt.Errorf("failed to insert nemesis history: %w", err)
+				}
+			}
+
+			_, err := tx.ExecContext(ctx, callerName, "delete from nemesis where _id = ?", nemesis.ID)
+			if err != nil {
+				return fmt.Errorf("failed to expire pending nemesis match: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire pending nemesis matches: %v", err)
+	}
+
+	res, err := n.db.QueryContext(ctx, span, callerName,
+		"select * from nemesis where is_accepted = true and victor is null and end_time <= ? order by end_time, _id", now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ended nemesis matches: %v", err)
+	}
+
+	ended := make([]*Nemesis, 0)
+	for res.Next() {
+		nemesis := new(Nemesis)
+		err = sqlstruct.Scan(nemesis, res)
+		if err != nil {
+			_ = res.Close()
+			return nil, fmt.Errorf("failed to scan ended nemesis match: %v", err)
+		}
+		ended = append(ended, nemesis)
+	}
+	err = res.Err()
+	_ = res.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ended nemesis matches: %v", err)
+	}
+
+	resolved := make([]*Nemesis, 0, len(ended))
+	for _, nemesis := range ended {
+		victor := nemesis.ProtagonistID
+		switch {
+		case nemesis.AntagonistTowersCaptured > nemesis.ProtagonistTowersCaptured:
+			victor = nemesis.AntagonistID
+		case nemesis.AntagonistTowersCaptured == nemesis.ProtagonistTowersCaptured:
+			var antagonistXP, protagonistXP int64
+			err = n.db.QueryRowContext(ctx, span, callerName,
+				"select antagonist_total_xp, protagonist_total_xp from nemesis_history where match_id = ? order by created_at desc, _id desc limit 1",
+				nemesis.ID,
+			).Scan(&antagonistXP, &protagonistXP)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("failed to query nemesis history: %v", err)
+			}
+			if antagonistXP > protagonistXP {
+				victor = nemesis.AntagonistID
+			}
+		}
+
+		update, err := n.db.ExecContext(ctx, span, callerName,
+			"update nemesis set victor = ? where _id = ? and victor is null", victor, nemesis.ID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve nemesis match: %v", err)
+		}
+		if affected, err := update.RowsAffected(); err != nil || affected == 0 {
+			// resolved concurrently
+			continue
+		}
+
+		nemesis.Victor = &victor
+		resolved = append(resolved, nemesis)
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,334 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	ti "github.com/gage-technologies/gigo-lib/db"
+)
+
+func testNemesisCandidates(now time.Time) []*NemesisCandidate {
+	return []*NemesisCandidate{
+		{UserID: 1, UserName: "one", XP: 1000, Tier: Tier1, LastActive: now.Add(-time.Hour), Languages: []ProgrammingLanguage{5, 6}},
+		{UserID: 2, UserName: "two", XP: 1100, Tier: Tier1, LastActive: now.Add(-time.Hour), Languages: []ProgrammingLanguage{5, 6}},
+		{UserID: 3, UserName: "three", XP: 1050, Tier: Tier1, LastActive: now.Add(-time.Hour), Languages: []ProgrammingLanguage{7}},
+		{UserID: 4, UserName: "four", XP: 1050, Tier: Tier1, LastActive: now.Add(-time.Hour), Languages: []ProgrammingLanguage{7}},
+		// too far ahead in xp and tier to be matched with anyone
+		{UserID: 5, UserName: "five", XP: 90000, Tier: Tier6, LastActive: now.Add(-time.Hour)},
+		// inactive
+		{UserID: 6, UserName: "six", XP: 1000, Tier: Tier1, LastActive: now.Add(-time.Hour * 24 * 30)},
+	}
+}
+
+func TestNemesisMatchmaker_Pair(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	matchmaker := NewNemesisMatchmaker(NemesisMatchRules{}, 69)
+	matches := matchmaker.Pair(testNemesisCandidates(now), nil, now)
+
+	if len(matches) != 2 {
+		t.Fatalf("\nNemesis Pair Failed\n    Error: expected 2 matches, got %d", len(matches))
+	}
+
+	// the shared languages make 1-2 and 3-4 the best pairs
+	pairs := map[NemesisPair]bool{}
+	for _, match := range matches {
+		pairs[NewNemesisPair(match.Antagonist.UserID, match.Protagonist.UserID)] = true
+	}
+	if !pairs[NewNemesisPair(1, 2)] || !pairs[NewNemesisPair(3, 4)] {
+		t.Fatalf("\nNemesis Pair Failed\n    Error: wrong pairs %v", pairs)
+	}
+
+	// recent rematches are avoided
+	recent := map[NemesisPair]bool{NewNemesisPair(1, 2): true}
+	matches = NewNemesisMatchmaker(NemesisMatchRules{}, 69).Pair(testNemesisCandidates(now), recent, now)
+	for _, match := range matches {
+		if NewNemesisPair(match.Antagonist.UserID, match.Protagonist.UserID) == NewNemesisPair(1, 2) {
+			t.Fatal("\nNemesis Pair Failed\n    Error: recent rivals were matched again")
+		}
+	}
+	if len(matches) != 1 {
+		t.Fatalf("\nNemesis Pair Failed\n    Error: expected 1 match without the rematch, got %d", len(matches))
+	}
+
+	t.Log("\nNemesis Pair Succeeded")
+}
+
+func TestNemesisMatchmaker_Buckets(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// rivals on either side of an xp band or one tier apart are still
+	// matched while candidates outside the gaps are not
+	candidates := []*NemesisCandidate{
+		{UserID: 1, XP: 4990, Tier: Tier1, LastActive: now},
+		{UserID: 2, XP: 5010, Tier: Tier1, LastActive: now},
+		{UserID: 3, XP: 20000, Tier: Tier2, LastActive: now},
+		{UserID: 4, XP: 20500, Tier: Tier3, LastActive: now},
+		{UserID: 5, XP: 40000, Tier: Tier3, LastActive: now},
+		{UserID: 6, XP: 40000, Tier: Tier5, LastActive: now},
+	}
+
+	pairs := map[NemesisPair]bool{}
+	for _, match := range NewNemesisMatchmaker(NemesisMatchRules{}, 69).Pair(candidates, nil, now) {
+		pairs[NewNemesisPair(match.Antagonist.UserID, match.Protagonist.UserID)] = true
+	}
+	if len(pairs) != 2 || !pairs[NewNemesisPair(1, 2)] || !pairs[NewNemesisPair(3, 4)] {
+		t.Fatalf("\nNemesis Buckets Failed\n    Error: wrong pairs %v", pairs)
+	}
+
+	// a large pool is paired without exceeding the gaps
+	matchmaker := NewNemesisMatchmaker(NemesisMatchRules{}, 69)
+	pool := make([]*NemesisCandidate, 0, 5000)
+	for i := int64(1); i <= 5000; i++ {
+		pool = append(pool, &NemesisCandidate{UserID: i, XP: uint64(i * 97 % 100000), Tier: TierType(i % 10), LastActive: now})
+	}
+	matches := matchmaker.Pair(pool, nil, now)
+	if len(matches) == 0 {
+		t.Fatal("\nNemesis Buckets Failed\n    Error: large pool was not paired")
+	}
+	for _, match := range matches {
+		if _, ok := matchmaker.Score(match.Antagonist, match.Protagonist, now); !ok {
+			t.Fatalf("\nNemesis Buckets Failed\n    Error: ineligible pair %+v %+v", match.Antagonist, match.Protagonist)
+		}
+	}
+
+	t.Log("\nNemesis Buckets Succeeded")
+}
+
+func TestNemesisMatchmaker_Deterministic(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// identical candidates only differ by the tie break of the random source
+	candidates := make([]*NemesisCandidate, 0)
+	for i := int64(1); i <= 8; i++ {
+		candidates = append(candidates, &NemesisCandidate{UserID: i, XP: 1000, LastActive: now})
+	}
+
+	run := func(seed int64) []NemesisPair {
+		pairs := make([]NemesisPair, 0)
+		for _, match := range NewNemesisMatchmaker(NemesisMatchRules{}, seed).Pair(candidates, nil, now) {
+			pairs = append(pairs, NemesisPair{match.Antagonist.UserID, match.Protagonist.UserID})
+		}
+		return pairs
+	}
+
+	first := run(420)
+	second := run(420)
+	if len(first) != 4 || len(first) != len(second) {
+		t.Fatalf("\nNemesis Deterministic Failed\n    Error: wrong match counts %d %d", len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("\nNemesis Deterministic Failed\n    Error: seeded runs differ %v %v", first, second)
+		}
+	}
+
+	// reversing the input does not change the result for the same seed
+	reversed := make([]*NemesisCandidate, len(candidates))
+	for i := range candidates {
+		reversed[len(candidates)-1-i] = candidates[i]
+	}
+	for i, match := range NewNemesisMatchmaker(NemesisMatchRules{}, 420).Pair(reversed, nil, now) {
+		if (NemesisPair{match.Antagonist.UserID, match.Protagonist.UserID}) != first[i] {
+			t.Fatal("\nNemesis Deterministic Failed\n    Error: result depends on the candidate order")
+		}
+	}
+
+	rival := NewNemesisMatchmaker(NemesisMatchRules{}, 420).FindRival(candidates[0], candidates, nil, now)
+	if rival == nil || rival.Protagonist.UserID == candidates[0].UserID {
+		t.Fatalf("\nNemesis Deterministic Failed\n    Error: wrong rival %+v", rival)
+	}
+
+	t.Log("\nNemesis Deterministic Succeeded")
+}
+
+func TestNemesisMatchmaking_Lifecycle(t *testing.T) {
+	db, err := ti.CreateDatabase("gigo-dev-tidb", "4000", "mysql", "gigo-dev", "gigo-dev", "gigo_dev_test")
+	if err != nil {
+		t.Fatal("\nNemesis Lifecycle Failed\n    Error: ", err)
+	}
+
+	defer db.DB.Exec("delete from nemesis where antagonist_id in (6942068, 6942069)")
+	defer db.DB.Exec("delete from nemesis_history where antagonist_id in (6942068, 6942069)")
+
+	sf, err := snowflake.NewNode(1)
+	if err != nil {
+		t.Fatal("\nNemesis Lifecycle Failed\n    Error: ", err)
+	}
+
+	seed := int64(69)
+	matchmaking, err := NewNemesisMatchmaking(NemesisMatchmakingOptions{DB: db, SF: sf, Seed: &seed})
+	if err != nil {
+		t.Fatal("\nNemesis Lifecycle Failed\n    Error: ", err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	nemesis := CreateNemesis(sf.Generate().Int64(), 6942069, "antagonist", 6942068, "protagonist", now, nil, false, nil, 0, 0)
+	for _, statement := range nemesis.ToSQLNative() {
+		_, err = db.DB.Exec(statement.Statement, statement.Values...)
+		if err != nil {
+			t.Fatal("\nNemesis Lifecycle Failed\n    Error: ", err)
+		}
+	}
+
+	// a pending match that was never accepted expires into the history
+	expired := CreateNemesis(sf.Generate().Int64(), 6942068, "protagonist", 6942069, "antagonist", now.Add(-time.Hour*24*3), nil, false, nil, 0, 0)
+	for _, statement := range expired.ToSQLNative() {
+		_, err = db.DB.Exec(statement.Statement, statement.Values...)
+		if err != nil {
+			t.Fatal("\nNemesis Lifecycle Failed\n    Error: ", err)
+		}
+	}
+
+	_, err = matchmaking.Accept(context.TODO(), nil, nil, nemesis.ID, 6942069, now)
+	if !errors.Is(err, ErrNemesisNotProtagonist) {
+		t.Fatal("\nNemesis Lifecycle Failed\n    Error: antagonist accepted the match ", err)
+	}
+
+	accepted, err := matchmaking.Accept(context.TODO(), nil, nil, nemesis.ID, 6942068, now)
+	if err != nil {
+		t.Fatal("\nNemesis Lifecycle Failed\n    Error: ", err)
+	}
+	if !accepted.IsAccepted || accepted.EndTime == nil || !accepted.EndTime.Equal(now.Add(time.Hour*24*7)) {
+		t.Fatalf("\nNemesis Lifecycle Failed\n    Error: wrong accepted match %+v", accepted)
+	}
+
+	_, err = db.DB.Exec("update nemesis set antagonist_towers_captured = 3, protagonist_towers_captured = 1 where _id = ?", nemesis.ID)
+	if err != nil {
+		t.Fatal("\nNemesis Lifecycle Failed\n    Error: ", err)
+	}
+
+	// the match is not resolved before it ends
+	resolved, err := matchmaking.Resolve(context.TODO(), nil, nil, now)
+	if err != nil {
+		t.Fatal("\nNemesis Lifecycle Failed\n    Error: ", err)
+	}
+	for _, match := range resolved {
+		if match.ID == nemesis.ID {
+			t.Fatal("\nNemesis Lifecycle Failed\n    Error: match was resolved before it ended")
+		}
+	}
+
+	var expiredMatches, expiredHistory int
+	err = db.DB.QueryRow("select count(*) from nemesis where _id = ?", expired.ID).Scan(&expiredMatches)
+	if err != nil {
+		t.Fatal("\nNemesis Lifecycle Failed\n    Error: ", err)
+	}
+	err = db.DB.QueryRow("select count(*) from nemesis_history where match_id = ?", expired.ID).Scan(&expiredHistory)
+	if err != nil {
+		t.Fatal("\nNemesis Lifecycle Failed\n    Error: ", err)
+	}
+	if expiredMatches != 0 || expiredHistory != 1 {
+		t.Fatalf("\nNemesis Lifecycle Failed\n    Error: expired match was not moved to the history %d %d", expiredMatches, expiredHistory)
+	}
+
+	resolved, err = matchmaking.Resolve(context.TODO(), nil, nil, now.Add(time.Hour*24*8))
+	if err != nil {
+		t.Fatal("\nNemesis Lifecycle Failed\n    Error: ", err)
+	}
+
+	found := false
+	for _, match := range resolved {
+		if match.ID != nemesis.ID {
+			continue
+		}
+		found = true
+		if match.Victor == nil || *match.Victor != 6942069 {
+			t.Fatalf("\nNemesis Lifecycle Failed\n    Error: wrong victor %v", match.Victor)
+		}
+	}
+	if !found {
+		t.Fatal("\nNemesis Lifecycle Failed\n    Error: match was not resolved")
+	}
+
+	recent, err := matchmaking.RecentOpponents(context.TODO(), nil, nil, now)
+	if err != nil {
+		t.Fatal("\nNemesis Lifecycle Failed\n    Error: ", err)
+	}
+	if !recent[NewNemesisPair(6942068, 6942069)] {
+		t.Fatal("\nNemesis Lifecycle Failed\n    Error: match is missing from the recent opponents")
+	}
+
+	t.Log("\nNemesis Lifecycle Succeeded")
+}
+
+func TestNemesisMatchmaking_Blocked(t *testing.T) {
+	db, err := ti.CreateDatabase("gigo-dev-tidb", "4000", "mysql", "gigo-dev", "gigo-dev", "gigo_dev_test")
+	if err != nil {
+		t.Fatal("\nNemesis Blocked Failed\n    Error: ", err)
+	}
+
+	defer db.DB.Exec("delete from users where _id in (6942050, 6942051)")
+	defer db.DB.Exec("delete from user_active_times where user_id in (6942050, 6942051)")
+	defer db.DB.Exec("delete from user_blocks where user_id in (6942050, 6942051)")
+	defer db.DB.Exec("delete from nemesis where antagonist_id in (6942050, 6942051) or protagonist_id in (6942050, 6942051)")
+
+	sf, err := snowflake.NewNode(1)
+	if err != nil {
+		t.Fatal("\nNemesis Blocked Failed\n    Error: ", err)
+	}
+
+	// two active users with identical stats that would be the ideal rivals
+	now := time.Now().Truncate(time.Second)
+	for _, id := range []int64{6942050, 6942051} {
+		user, err := CreateUser(id, fmt.Sprintf("test%d", id), "testpass", "testemail@email.com",
+			"phone", UserStatusBasic, "test", []int64{1, 2}, []int64{1, 2, 3},
+			"first", "last", 23, "", DefaultUserStart, "America/Chicago",
+			AvatarSettings{}, 0)
+		if err != nil {
+			t.Fatal("\nNemesis Blocked Failed\n    Error: ", err)
+		}
+
+		statements, err := user.ToSQLNative()
+		if err != nil {
+			t.Fatal("\nNemesis Blocked Failed\n    Error: ", err)
+		}
+		for _, statement := range statements {
+			_, err = db.DB.Exec(statement.Statement, statement.Values...)
+			if err != nil {
+				t.Fatal("\nNemesis Blocked Failed\n    Error: ", err)
+			}
+		}
+
+		_, err = db.DB.Exec("insert into user_active_times(_id, user_id, start_time, end_time) values (?, ?, ?, ?)",
+			sf.Generate().Int64(), id, now.Add(-time.Hour*2), now.Add(-time.Hour))
+		if err != nil {
+			t.Fatal("\nNemesis Blocked Failed\n    Error: ", err)
+		}
+	}
+
+	_, err = db.DB.Exec("insert into user_blocks(user_id, blocked_id, created_at) values (6942051, 6942050, ?)", now)
+	if err != nil {
+		t.Fatal("\nNemesis Blocked Failed\n    Error: ", err)
+	}
+
+	seed := int64(69)
+	matchmaking, err := NewNemesisMatchmaking(NemesisMatchmakingOptions{DB: db, SF: sf, Seed: &seed})
+	if err != nil {
+		t.Fatal("\nNemesis Blocked Failed\n    Error: ", err)
+	}
+
+	blocked, err := matchmaking.BlockedPairs(context.TODO(), nil, nil, []int64{6942050, 6942051, 6942069})
+	if err != nil {
+		t.Fatal("\nNemesis Blocked Failed\n    Error: ", err)
+	}
+	if len(blocked) != 1 || !blocked[NewNemesisPair(6942050, 6942051)] {
+		t.Fatalf("\nNemesis Blocked Failed\n    Error: wrong blocked pairs %v", blocked)
+	}
+
+	created, err := matchmaking.Matchmake(context.TODO(), nil, nil, now)
+	if err != nil {
+		t.Fatal("\nNemesis Blocked Failed\n    Error: ", err)
+	}
+	for _, nemesis := range created {
+		if NewNemesisPair(nemesis.AntagonistID, nemesis.ProtagonistID) == NewNemesisPair(6942050, 6942051) {
+			t.Fatal("\nNemesis Blocked Failed\n    Error: users that blocked each other were matched")
+		}
+	}
+
+	t.Log("\nNemesis Blocked Succeeded")
+}
@@ -38,6 +38,7 @@ type Notification struct {
 	CreatedAt         time.Time        `json:"created_at" sql:"created_at"`
 	Acknowledged      bool             `json:"acknowledged" sql:"acknowledged"`
 	InteractingUserID *int64           `json:"interacting_user_id" sql:"interacting_user_id"`
+	CollapsedCount    int              `json:"collapsed_count" sql:"collapsed_count"`
 }
 
 type NotificationSQL struct {
@@ -48,6 +49,7 @@ type NotificationSQL struct {
 	CreatedAt         time.Time        `json:"created_at" sql:"created_at"`
 	Acknowledged      bool             `json:"acknowledged" sql:"acknowledged"`
 	InteractingUserID *int64           `json:"interacting_user_id" sql:"interacting_user_id"`
+	CollapsedCount    int              `json:"collapsed_count" sql:"collapsed_count"`
 }
 
 type NotificationFrontend struct {
@@ -58,6 +60,7 @@ type NotificationFrontend struct {
 	CreatedAt         time.Time        `json:"created_at" sql:"created_at"`
 	Acknowledged      bool             `json:"acknowledged" sql:"acknowledged"`
 	InteractingUserID *string          `json:"interacting_user_id" sql:"interacting_user_id"`
+	CollapsedCount    int              `json:"collapsed_count" sql:"collapsed_count"`
 }
 
 func CreateNotification(id int64, userID int64, message string, notificationType NotificationType, createdAt time.Time, acknowledged bool, interactingUser *int64) (*Notification, error) {
@@ -92,6 +95,7 @@ func NotificationFromSQLNative(rows *sql.Rows) (*Notification, error) {
 		CreatedAt:         notificationSql.CreatedAt,
 		Acknowledged:      notificationSql.Acknowledged,
 		InteractingUserID: notificationSql.InteractingUserID,
+		CollapsedCount:    notificationSql.CollapsedCount,
 	}
 
 	return notification, nil
@@ -113,6 +117,7 @@ func (i *Notification) ToFrontend() *NotificationFrontend {
 		CreatedAt:         i.CreatedAt,
 		Acknowledged:      i.Acknowledged,
 		InteractingUserID: &interactingUser,
+		CollapsedCount:    i.CollapsedCount,
 	}
 
 	return mf
@@ -122,7 +127,21 @@ func (i *Notification) ToSQLNative() *SQLInsertStatement {
 
 	// create insertion statement and return
 	return &SQLInsertStatement{
-		Statement: "insert ignore into notification(_id, user_id, message, notification_type, created_at, acknowledged, interacting_user_id) values(?, ?, ?, ?, ?, ?, ?);",
-		Values:    []interface{}{i.ID, i.UserID, i.Message, i.NotificationType, i.CreatedAt, i.Acknowledged, i.InteractingUserID},
+		Statement: "insert ignore into notification(_id, user_id, message, notification_type, created_at, acknowledged, interacting_user_id, collapsed_count) values(?, ?, ?, ?, ?, ?, ?, ?);",
+		Values:    []interface{}{i.ID, i.UserID, i.Message, i.NotificationType, i.CreatedAt, i.Acknowledged, i.InteractingUserID, i.CollapsedCount},
 	}
 }
+
+// PrimaryKey
+//
+//	Returns the id of the notification; implements RepositoryModel
+func (i *Notification) PrimaryKey() int64 {
+	return i.ID
+}
+
+// InsertStatements
+//
+//	Returns the insert statements of the notification; implements RepositoryModel
+func (i *Notification) InsertStatements() ([]*SQLInsertStatement, error) {
+	return []*SQLInsertStatement{i.ToSQLNative()}, nil
+}
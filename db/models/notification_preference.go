@@ -0,0 +1,157 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/kisielk/sqlstruct"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NotificationTypes lists every notification type
+var NotificationTypes = []NotificationType{
+	FriendRequest,
+	NemesisRequest,
+	NemesisAlert,
+	StreakInfo,
+}
+
+type NotificationPreference struct {
+	UserID           int64            `json:"user_id" sql:"user_id"`
+	NotificationType NotificationType `json:"notification_type" sql:"notification_type"`
+	InApp            bool             `json:"in_app" sql:"in_app"`
+	Email            bool             `json:"email" sql:"email"`
+	Muted            bool             `json:"muted" sql:"muted"`
+	UpdatedAt        time.Time        `json:"updated_at" sql:"updated_at"`
+}
+
+type NotificationPreferenceSQL struct {
+	UserID           int64            `json:"user_id" sql:"user_id"`
+	NotificationType NotificationType `json:"notification_type" sql:"notification_type"`
+	InApp            bool             `json:"in_app" sql:"in_app"`
+	Email            bool             `json:"email" sql:"email"`
+	Muted            bool             `json:"muted" sql:"muted"`
+	UpdatedAt        time.Time        `json:"updated_at" sql:"updated_at"`
+}
+
+type NotificationPreferenceFrontend struct {
+	UserID           string           `json:"user_id"`
+	NotificationType NotificationType `json:"notification_type"`
+	InApp            bool             `json:"in_app"`
+	Email            bool             `json:"email"`
+	Muted            bool             `json:"muted"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+}
+
+func CreateNotificationPreference(userID int64, notificationType NotificationType, inApp bool, email bool, muted bool) *NotificationPreference {
+	return &NotificationPreference{
+		UserID:           userID,
+		NotificationType: notificationType,
+		InApp:            inApp,
+		Email:            email,
+		Muted:            muted,
+		UpdatedAt:        time.Now(),
+	}
+}
+
+// DefaultNotificationPreference
+//
+//	Returns the preference used for a type the user has not configured.
+//	Notifications are shown in app and are not emailed by default.
+func DefaultNotificationPreference(userID int64, notificationType NotificationType) *NotificationPreference {
+	return &NotificationPreference{
+		UserID:           userID,
+		NotificationType: notificationType,
+		InApp:            true,
+	}
+}
+
+func NotificationPreferenceFromSQLNative(rows *sql.Rows) (*NotificationPreference, error) {
+	preferenceSQL := new(NotificationPreferenceSQL)
+	err := sqlstruct.Scan(preferenceSQL, rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan notification preference: %v", err)
+	}
+
+	return &NotificationPreference{
+		UserID:           preferenceSQL.UserID,
+		NotificationType: preferenceSQL.NotificationType,
+		InApp:            preferenceSQL.InApp,
+		Email:            preferenceSQL.Email,
+		Muted:            preferenceSQL.Muted,
+		UpdatedAt:        preferenceSQL.UpdatedAt,
+	}, nil
+}
+
+func (i *NotificationPreference) ToFrontend() *NotificationPreferenceFrontend {
+	return &NotificationPreferenceFrontend{
+		UserID:           fmt.Sprintf("%d", i.UserID),
+		NotificationType: i.NotificationType,
+		InApp:            i.InApp,
+		Email:            i.Email,
+		Muted:            i.Muted,
+		UpdatedAt:        i.UpdatedAt,
+	}
+}
+
+func (i *NotificationPreference) ToSQLNative() *SQLInsertStatement {
+	return &SQLInsertStatement{
+		Statement: "insert into notification_preferences(user_id, notification_type, in_app, email, muted, updated_at) values (?, ?, ?, ?, ?, ?) " +
+			"on duplicate key update in_app = values(in_app), email = values(email), muted = values(muted), updated_at = values(updated_at);",
+		Values: []interface{}{i.UserID, i.NotificationType, i.InApp, i.Email, i.Muted, i.UpdatedAt},
+	}
+}
+
+// Delivers
+//
+//	Returns true if notifications of the type are delivered in app or by email
+func (i *NotificationPreference) Delivers() bool {
+	return !i.Muted && (i.InApp || i.Email)
+}
+
+// GetNotificationPreferences
+//
+//	Returns the user's preference for every notification type. Types the
+//	user has not configured use DefaultNotificationPreference.
+func GetNotificationPreferences(ctx context.Context, db *ti.Database, span *trace.Span, callerName *string, userID int64) (map[NotificationType]*NotificationPreference, error) {
+	res, err := db.QueryContext(ctx, span, callerName, "select * from notification_preferences where user_id = ?", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification preferences: %v", err)
+	}
+	defer res.Close()
+
+	preferences := make(map[NotificationType]*NotificationPreference, len(NotificationTypes))
+	for _, notificationType := range NotificationTypes {
+		preferences[notificationType] = DefaultNotificationPreference(userID, notificationType)
+	}
+
+	for res.Next() {
+		preference, err := NotificationPreferenceFromSQLNative(res)
+		if err != nil {
+			return nil, err
+		}
+		preferences[preference.NotificationType] = preference
+	}
+
+	if err := res.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query notification preferences: %v", err)
+	}
+
+	return preferences, nil
+}
+
+// SetNotificationPreference
+//
+//	Stores the user's preference for a notification type
+func SetNotificationPreference(ctx context.Context, db *ti.Database, span *trace.Span, callerName *string, preference *NotificationPreference) error {
+	preference.UpdatedAt = time.Now()
+	statement := preference.ToSQLNative()
+	_, err := db.ExecContext(ctx, span, callerName, statement.Statement, statement.Values...)
+	if err != nil {
+		return fmt.Errorf("failed to store notification preference: %v", err)
+	}
+	return nil
+}
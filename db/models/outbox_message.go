@@ -0,0 +1,115 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/kisielk/sqlstruct"
+)
+
+// OutboxMessage
+//
+//	A jetstream message that has been written to the database inside
+//	the same transaction as the rows it describes. Messages are relayed
+//	to jetstream by the outbox relay once the transaction has committed.
+type OutboxMessage struct {
+	ID        int64      `json:"_id" sql:"_id"`
+	Subject   string     `json:"subject" sql:"subject"`
+	Payload   []byte     `json:"payload" sql:"payload"`
+	CreatedAt time.Time  `json:"created_at" sql:"created_at"`
+	SentAt    *time.Time `json:"sent_at" sql:"sent_at"`
+	Attempts  int        `json:"attempts" sql:"attempts"`
+	LastError *string    `json:"last_error" sql:"last_error"`
+}
+
+type OutboxMessageSQL struct {
+	ID        int64      `json:"_id" sql:"_id"`
+	Subject   string     `json:"subject" sql:"subject"`
+	Payload   []byte     `json:"payload" sql:"payload"`
+	CreatedAt time.Time  `json:"created_at" sql:"created_at"`
+	SentAt    *time.Time `json:"sent_at" sql:"sent_at"`
+	Attempts  int        `json:"attempts" sql:"attempts"`
+	LastError *string    `json:"last_error" sql:"last_error"`
+}
+
+func CreateOutboxMessage(id int64, subject string, payload []byte) *OutboxMessage {
+	return &OutboxMessage{
+		ID:        id,
+		Subject:   subject,
+		Payload:   payload,
+		CreatedAt: time.Now(),
+	}
+}
+
+// CreateOutboxMessageGob
+//
+//	Creates a new outbox message by gob encoding the passed
+//	message the same way that it would be published directly
+//	to jetstream.
+func CreateOutboxMessageGob(id int64, subject string, msg interface{}) (*OutboxMessage, error) {
+	buf := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buf).Encode(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode outbox message: %v", err)
+	}
+
+	return CreateOutboxMessage(id, subject, buf.Bytes()), nil
+}
+
+func OutboxMessageFromSQLNative(rows *sql.Rows) (*OutboxMessage, error) {
+	// create new outbox message object to load into
+	outboxSQL := new(OutboxMessageSQL)
+
+	// scan row into outbox message object
+	err := sqlstruct.Scan(outboxSQL, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboxMessage{
+		ID:        outboxSQL.ID,
+		Subject:   outboxSQL.Subject,
+		Payload:   outboxSQL.Payload,
+		CreatedAt: outboxSQL.CreatedAt,
+		SentAt:    outboxSQL.SentAt,
+		Attempts:  outboxSQL.Attempts,
+		LastError: outboxSQL.LastError,
+	}, nil
+}
+
+// MsgID
+//
+//	Returns the jetstream message id used to de-duplicate
+//	repeated publishes of the outbox message.
+func (m *OutboxMessage) MsgID() string {
+	return fmt.Sprintf("outbox-%d", m.ID)
+}
+
+func (m *OutboxMessage) ToSQLNative() []*SQLInsertStatement {
+	return []*SQLInsertStatement{
+		{
+			Statement: "insert ignore into outbox_messages(_id, subject, payload, created_at, sent_at, attempts, last_error) values(?, ?, ?, ?, ?, ?, ?);",
+			Values:    []interface{}{m.ID, m.Subject, m.Payload, m.CreatedAt, m.SentAt, m.Attempts, m.LastError},
+		},
+	}
+}
+
+// WriteOutboxMessage
+//
+//	Writes the outbox message using the passed transaction. The message
+//	will only be visible to the relay if the transaction commits.
+func WriteOutboxMessage(ctx context.Context, tx *ti.Tx, msg *OutboxMessage) error {
+	callerName := "WriteOutboxMessage"
+	for _, statement := range msg.ToSQLNative() {
+		_, err := tx.ExecContext(ctx, &callerName, statement.Statement, statement.Values...)
+		if err != nil {
+			return fmt.Errorf("failed to insert outbox message: %w", err)
+		}
+	}
+	return nil
+}
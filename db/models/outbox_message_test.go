@@ -0,0 +1,98 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"testing"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+)
+
+type testOutboxPayload struct {
+	ID   int64
+	Name string
+}
+
+func TestCreateOutboxMessageGob(t *testing.T) {
+	msg, err := CreateOutboxMessageGob(69420, "WORKSPACE.Create", testOutboxPayload{ID: 42, Name: "test"})
+	if err != nil {
+		t.Fatal("\nCreate Outbox Message Failed\n    Error: ", err)
+	}
+
+	if msg.ID != 69420 {
+		t.Fatal("\nCreate Outbox Message Failed\n    Error: wrong id")
+	}
+
+	if msg.Subject != "WORKSPACE.Create" {
+		t.Fatal("\nCreate Outbox Message Failed\n    Error: wrong subject")
+	}
+
+	if msg.MsgID() != "outbox-69420" {
+		t.Fatal("\nCreate Outbox Message Failed\n    Error: wrong message id ", msg.MsgID())
+	}
+
+	var payload testOutboxPayload
+	err = gob.NewDecoder(bytes.NewReader(msg.Payload)).Decode(&payload)
+	if err != nil {
+		t.Fatal("\nCreate Outbox Message Failed\n    Error: ", err)
+	}
+
+	if payload.ID != 42 || payload.Name != "test" {
+		t.Fatalf("\nCreate Outbox Message Failed\n    Error: wrong payload %+v", payload)
+	}
+
+	t.Log("\nCreate Outbox Message Succeeded")
+}
+
+func TestWriteOutboxMessage(t *testing.T) {
+	db, err := ti.CreateDatabase("gigo-dev-tidb", "4000", "mysql", "gigo-dev", "gigo-dev", "gigo_dev_test")
+	if err != nil {
+		t.Fatal("\nWrite Outbox Message Failed\n    Error: ", err)
+	}
+
+	defer db.DB.Exec("delete from outbox_messages")
+
+	msg := CreateOutboxMessage(69420, "WORKSPACE.Create", []byte("test"))
+
+	tx, err := db.BeginTx(context.TODO(), nil, nil, nil)
+	if err != nil {
+		t.Fatal("\nWrite Outbox Message Failed\n    Error: ", err)
+	}
+
+	err = WriteOutboxMessage(context.TODO(), tx, msg)
+	if err != nil {
+		_ = tx.Rollback()
+		t.Fatal("\nWrite Outbox Message Failed\n    Error: ", err)
+	}
+
+	err = tx.Commit(nil)
+	if err != nil {
+		t.Fatal("\nWrite Outbox Message Failed\n    Error: ", err)
+	}
+
+	rows, err := db.DB.Query("select * from outbox_messages where _id = ?", msg.ID)
+	if err != nil {
+		t.Fatal("\nWrite Outbox Message Failed\n    Error: ", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		t.Fatal("\nWrite Outbox Message Failed\n    Error: no rows found")
+	}
+
+	loaded, err := OutboxMessageFromSQLNative(rows)
+	if err != nil {
+		t.Fatal("\nWrite Outbox Message Failed\n    Error: ", err)
+	}
+
+	if loaded.Subject != msg.Subject || string(loaded.Payload) != "test" {
+		t.Fatalf("\nWrite Outbox Message Failed\n    Error: wrong message loaded %+v", loaded)
+	}
+
+	if loaded.SentAt != nil {
+		t.Fatal("\nWrite Outbox Message Failed\n    Error: message should be pending")
+	}
+
+	t.Log("\nWrite Outbox Message Succeeded")
+}
@@ -0,0 +1,797 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultRecommendationActionWeights is the strength of the interest that
+// each implicit action signals. Clicking off a post and the actions on a
+// user's own projects carry no weight.
+var DefaultRecommendationActionWeights = map[ImplicitAction]float64{
+	ImplicitTypeClicked:          1,
+	ImplicitTypeInteractiveStart: 2,
+	ImplicitTypeInteractiveEnd:   3,
+	ImplicitTypeAttemptStart:     3,
+	ImplicitTypeChallengeStart:   3,
+	ImplicitTypeAttemptEnd:       4,
+	ImplicitTypeChallengeEnd:     5,
+}
+
+// RecommendationRules
+//
+//	Rules that determine how recommendations are scored and how many are
+//	produced. Zero values are replaced by the defaults.
+type RecommendationRules struct {
+	// ActionWeights is the weight of each implicit action; defaults to
+	// DefaultRecommendationActionWeights
+	ActionWeights map[ImplicitAction]float64
+	// Window is the time over which implicit actions are collected;
+	// defaults to 90 days
+	Window time.Duration
+	// TopK is the number of recommendations produced per user; defaults to 10
+	TopK int
+	// Neighbors is the number of similar posts kept for each post;
+	// defaults to 50
+	Neighbors int
+	// MaxUserItems is the number of a user's most preferred posts that are
+	// used to build similarities and recommendations; defaults to 100
+	MaxUserItems int
+	// ContentCandidates caps the posts that are compared to a post because
+	// they share a tag or language with it; defaults to 200
+	ContentCandidates int
+	// ContentWeight is the share of the tag and language similarity in the
+	// blended similarity, the rest being co-occurrence; defaults to 0.3
+	ContentWeight float64
+	// weights of the tag and language overlap in the content similarity;
+	// both default to 1
+	TagWeight      float64
+	LanguageWeight float64
+	// TTL is the time until a recommendation expires; defaults to 7 days
+	TTL time.Duration
+	// BatchSize is the number of users whose recommendations are written
+	// in a single transaction; defaults to 100
+	BatchSize int
+}
+
+func (r RecommendationRules) withDefaults() RecommendationRules {
+	if r.ActionWeights == nil {
+		r.ActionWeights = DefaultRecommendationActionWeights
+	}
+	if r.Window == 0 {
+		r.Window = time.Hour * 24 * 90
+	}
+	if r.TopK == 0 {
+		r.TopK = 10
+	}
+	if r.Neighbors == 0 {
+		r.Neighbors = 50
+	}
+	if r.MaxUserItems == 0 {
+		r.MaxUserItems = 100
+	}
+	if r.ContentCandidates == 0 {
+		r.ContentCandidates = 200
+	}
+	if r.ContentWeight == 0 {
+		r.ContentWeight = 0.3
+	}
+	if r.ContentWeight > 1 {
+		r.ContentWeight = 1
+	}
+	if r.TagWeight == 0 {
+		r.TagWeight = 1
+	}
+	if r.LanguageWeight == 0 {
+		r.LanguageWeight = 1
+	}
+	if r.TTL == 0 {
+		r.TTL = time.Hour * 24 * 7
+	}
+	if r.BatchSize == 0 {
+		r.BatchSize = 100
+	}
+	return r
+}
+
+// RecommendationItem
+//
+//	Post that can be recommended
+type RecommendationItem struct {
+	PostID    int64
+	AuthorID  int64
+	Tier      TierType
+	Tags      []int64
+	Languages []ProgrammingLanguage
+}
+
+// RecommendationInteraction
+//
+//	Number of times a user performed an implicit action on a post
+type RecommendationInteraction struct {
+	UserID int64
+	PostID int64
+	Action ImplicitAction
+	Count  int64
+}
+
+// Recommendation
+//
+//	Post recommended to a user. The reference is the post of the user that
+//	contributed the most to the score.
+type Recommendation struct {
+	UserID        int64
+	PostID        int64
+	Type          RecommendationType
+	ReferenceID   int64
+	ReferenceTier TierType
+	Score         float64
+	// Collaborative and Content are the shares of the score that come from
+	// co-occurrence and from tag and language overlap
+	Collaborative float64
+	Content       float64
+}
+
+// recommendationNeighbor
+//
+//	Post similar to another post
+type recommendationNeighbor struct {
+	postID        int64
+	collaborative float64
+	content       float64
+	similarity    float64
+}
+
+// recommendationPreference
+//
+//	Preference of a user for a post
+type recommendationPreference struct {
+	postID int64
+	weight float64
+}
+
+// RecommendationEngine
+//
+//	Produces item-based recommendations from implicit feedback. The
+//	preference of a user for a post is the log damped sum of the weights of
+//	their actions on it. Posts are similar when the same users prefer them
+//	(cosine similarity of their preference vectors) and when they share tags
+//	and languages (jaccard similarity). Posts are recommended by the
+//	similarity to the posts a user prefers weighted by the preference.
+type RecommendationEngine struct {
+	rules       RecommendationRules
+	items       map[int64]*RecommendationItem
+	preferences map[int64][]recommendationPreference
+	// interacted holds every post each user performed any action on
+	interacted   map[int64]map[int64]bool
+	similarities map[int64]map[int64]float64
+	tagIndex     map[int64][]int64
+	langIndex    map[ProgrammingLanguage][]int64
+	neighbors    map[int64][]recommendationNeighbor
+}
+
+// NewRecommendationEngine
+//
+//	Creates a new RecommendationEngine over the items. Interactions with
+//	posts that are not in the items are ignored.
+func NewRecommendationEngine(rules RecommendationRules, items []*RecommendationItem, interactions []RecommendationInteraction) *RecommendationEngine {
+	e := &RecommendationEngine{
+		rules:        rules.withDefaults(),
+		items:        make(map[int64]*RecommendationItem, len(items)),
+		preferences:  make(map[int64][]recommendationPreference),
+		interacted:   make(map[int64]map[int64]bool),
+		similarities: make(map[int64]map[int64]float64),
+		tagIndex:     make(map[int64][]int64),
+		langIndex:    make(map[ProgrammingLanguage][]int64),
+		neighbors:    make(map[int64][]recommendationNeighbor),
+	}
+
+	for _, item := range items {
+		if _, ok := e.items[item.PostID]; ok {
+			continue
+		}
+		e.items[item.PostID] = item
+		for _, tag := range uniqueTags(item.Tags) {
+			e.tagIndex[tag] = append(e.tagIndex[tag], item.PostID)
+		}
+		for _, lang := range uniqueLanguages(item.Languages) {
+			e.langIndex[lang] = append(e.langIndex[lang], item.PostID)
+		}
+	}
+
+	e.loadPreferences(interactions)
+	e.loadSimilarities()
+
+	return e
+}
+
+// Rules
+//
+//	Returns the rules of the engine with the defaults applied
+func (e *RecommendationEngine) Rules() RecommendationRules {
+	return e.rules
+}
+
+// Users
+//
+//	Returns the users that have a preference for at least one post in
+//	ascending order
+func (e *RecommendationEngine) Users() []int64 {
+	users := make([]int64, 0, len(e.preferences))
+	for userID := range e.preferences {
+		users = append(users, userID)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i] < users[j]
+	})
+	return users
+}
+
+// loadPreferences
+//
+//	Sums the weighted actions of each user into their preferences and keeps
+//	the most preferred posts of each user. Every post a user acted on is
+//	recorded so that it is never recommended to them, including posts
+//	outside of the most preferred and posts with actions carrying no weight.
+func (e *RecommendationEngine) loadPreferences(interactions []RecommendationInteraction) {
+	sums := make(map[int64]map[int64]float64)
+	for _, interaction := range interactions {
+		if e.interacted[interaction.UserID] == nil {
+			e.interacted[interaction.UserID] = make(map[int64]bool)
+		}
+		e.interacted[interaction.UserID][interaction.PostID] = true
+
+		weight := e.rules.ActionWeights[interaction.Action]
+		if weight <= 0 || interaction.Count <= 0 {
+			continue
+		}
+		if _, ok := e.items[interaction.PostID]; !ok {
+			continue
+		}
+		if sums[interaction.UserID] == nil {
+			sums[interaction.UserID] = make(map[int64]float64)
+		}
+		sums[interaction.UserID][interaction.PostID] += weight * float64(interaction.Count)
+	}
+
+	for userID, posts := range sums {
+		preferences := make([]recommendationPreference, 0, len(posts))
+		for postID, sum := range posts {
+			// damp repeated actions so a handful of posts cannot dominate
+			preferences = append(preferences, recommendationPreference{postID: postID, weight: math.Log1p(sum)})
+		}
+		sort.Slice(preferences, func(i, j int) bool {
+			if preferences[i].weight != preferences[j].weight {
+				return preferences[i].weight > preferences[j].weight
+			}
+			return preferences[i].postID < preferences[j].postID
+		})
+		if len(preferences) > e.rules.MaxUserItems {
+			preferences = preferences[:e.rules.MaxUserItems]
+		}
+		e.preferences[userID] = preferences
+	}
+}
+
+// loadSimilarities
+//
+//	Builds the cosine similarity of every pair of posts that are preferred
+//	by the same user
+func (e *RecommendationEngine) loadSimilarities() {
+	dots := make(map[int64]map[int64]float64)
+	norms := make(map[int64]float64)
+
+	for _, preferences := range e.preferences {
+		for i, a := range preferences {
+			norms[a.postID] += a.weight * a.weight
+			for _, b := range preferences[i+1:] {
+				if dots[a.postID] == nil {
+					dots[a.postID] = make(map[int64]float64)
+				}
+				if dots[b.postID] == nil {
+					dots[b.postID] = make(map[int64]float64)
+				}
+				product := a.weight * b.weight
+				dots[a.postID][b.postID] += product
+				dots[b.postID][a.postID] += product
+			}
+		}
+	}
+
+	for a, row := range dots {
+		similarities := make(map[int64]float64, len(row))
+		for b, dot := range row {
+			similarities[b] = dot / math.Sqrt(norms[a]*norms[b])
+		}
+		e.similarities[a] = similarities
+	}
+}
+
+// ContentSimilarity
+//
+//	Returns the weighted jaccard similarity of the tags and languages of
+//	two posts
+func (e *RecommendationEngine) ContentSimilarity(a *RecommendationItem, b *RecommendationItem) float64 {
+	total := e.rules.TagWeight + e.rules.LanguageWeight
+	if total <= 0 {
+		return 0
+	}
+	return (e.rules.TagWeight*tagOverlap(a.Tags, b.Tags) + e.rules.LanguageWeight*languageOverlap(a.Languages, b.Languages)) / total
+}
+
+// Similarity
+//
+//	Returns the co-occurrence, content and blended similarity of two posts
+func (e *RecommendationEngine) Similarity(a int64, b int64) (collaborative float64, content float64, blended float64) {
+	itemA, okA := e.items[a]
+	itemB, okB := e.items[b]
+	if !okA || !okB || a == b {
+		return 0, 0, 0
+	}
+
+	collaborative = e.similarities[a][b]
+	content = e.ContentSimilarity(itemA, itemB)
+	blended = (1-e.rules.ContentWeight)*collaborative + e.rules.ContentWeight*content
+	return collaborative, content, blended
+}
+
+// neighborsOf
+//
+//	Returns the posts most similar to the post. Candidates are the posts
+//	that co-occur with it and up to ContentCandidates posts that share a
+//	tag or language with it. Neighbors are cached since they are shared by
+//	all users.
+func (e *RecommendationEngine) neighborsOf(postID int64) []recommendationNeighbor {
+	if neighbors, ok := e.neighbors[postID]; ok {
+		return neighbors
+	}
+
+	item, ok := e.items[postID]
+	if !ok {
+		return nil
+	}
+
+	candidates := make(map[int64]bool, len(e.similarities[postID]))
+	for candidate := range e.similarities[postID] {
+		candidates[candidate] = true
+	}
+	for _, candidate := range e.contentCandidates(item) {
+		candidates[candidate] = true
+	}
+
+	neighbors := make([]recommendationNeighbor, 0, len(candidates))
+	for candidate := range candidates {
+		collaborative, content, similarity := e.Similarity(postID, candidate)
+		if similarity <= 0 {
+			continue
+		}
+		neighbors = append(neighbors, recommendationNeighbor{
+			postID:        candidate,
+			collaborative: collaborative,
+			content:       content,
+			similarity:    similarity,
+		})
+	}
+
+	sort.Slice(neighbors, func(i, j int) bool {
+		if neighbors[i].similarity != neighbors[j].similarity {
+			return neighbors[i].similarity > neighbors[j].similarity
+		}
+		return neighbors[i].postID < neighbors[j].postID
+	})
+	if len(neighbors) > e.rules.Neighbors {
+		neighbors = neighbors[:e.rules.Neighbors]
+	}
+
+	e.neighbors[postID] = neighbors
+	return neighbors
+}
+
+// contentCandidates
+//
+//	Returns up to ContentCandidates posts that share a tag or language with
+//	the item. Rare tags are the most specific so they are used first and
+//	the languages, which are shared by large parts of the catalogue, only
+//	fill the remaining candidates. The work per item is bounded by the cap
+//	rather than the size of the catalogue.
+func (e *RecommendationEngine) contentCandidates(item *RecommendationItem) []int64 {
+	lists := make([][]int64, 0, len(item.Tags)+len(item.Languages))
+	tags := uniqueTags(item.Tags)
+	sort.Slice(tags, func(i, j int) bool {
+		if len(e.tagIndex[tags[i]]) != len(e.tagIndex[tags[j]]) {
+			return len(e.tagIndex[tags[i]]) < len(e.tagIndex[tags[j]])
+		}
+		return tags[i] < tags[j]
+	})
+	for _, tag := range tags {
+		lists = append(lists, e.tagIndex[tag])
+	}
+	for _, lang := range uniqueLanguages(item.Languages) {
+		lists = append(lists, e.langIndex[lang])
+	}
+
+	added := make(map[int64]bool)
+	candidates := make([]int64, 0)
+	for _, list := range lists {
+		for _, candidate := range list {
+			if len(candidates) >= e.rules.ContentCandidates {
+				return candidates
+			}
+			if candidate == item.PostID || added[candidate] {
+				continue
+			}
+			added[candidate] = true
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+// Recommend
+//
+//	Returns the top recommendations for the user ordered from the highest
+//	to the lowest score. Posts the user already interacted with and posts
+//	authored by the user are never recommended.
+func (e *RecommendationEngine) Recommend(userID int64) []*Recommendation {
+	preferences := e.preferences[userID]
+	if len(preferences) == 0 {
+		return []*Recommendation{}
+	}
+
+	seen := e.interacted[userID]
+	total := 0.0
+	for _, preference := range preferences {
+		total += preference.weight
+	}
+
+	type accumulator struct {
+		recommendation *Recommendation
+		reference      float64
+	}
+
+	scores := make(map[int64]*accumulator)
+	for _, preference := range preferences {
+		for _, neighbor := range e.neighborsOf(preference.postID) {
+			if seen[neighbor.postID] || e.items[neighbor.postID].AuthorID == userID {
+				continue
+			}
+
+			acc, ok := scores[neighbor.postID]
+			if !ok {
+				acc = &accumulator{recommendation: &Recommendation{UserID: userID, PostID: neighbor.postID}}
+				scores[neighbor.postID] = acc
+			}
+
+			contribution := preference.weight * neighbor.similarity
+			acc.recommendation.Score += contribution
+			acc.recommendation.Collaborative += preference.weight * (1 - e.rules.ContentWeight) * neighbor.collaborative
+			acc.recommendation.Content += preference.weight * e.rules.ContentWeight * neighbor.content
+			if contribution > acc.reference {
+				acc.reference = contribution
+				acc.recommendation.ReferenceID = preference.postID
+				acc.recommendation.ReferenceTier = e.items[preference.postID].Tier
+			}
+		}
+	}
+
+	recommendations := make([]*Recommendation, 0, len(scores))
+	for _, acc := range scores {
+		recommendation := acc.recommendation
+		recommendation.Score /= total
+		recommendation.Collaborative /= total
+		recommendation.Content /= total
+
+		switch {
+		case recommendation.Collaborative > 0 && recommendation.Content > 0:
+			recommendation.Type = RecommendationTypeHybrid
+		case recommendation.Collaborative > 0:
+			recommendation.Type = RecommendationTypeCollaborative
+		default:
+			recommendation.Type = RecommendationTypeSematic
+		}
+
+		recommendations = append(recommendations, recommendation)
+	}
+
+	sort.Slice(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].PostID < recommendations[j].PostID
+	})
+	if len(recommendations) > e.rules.TopK {
+		recommendations = recommendations[:e.rules.TopK]
+	}
+
+	return recommendations
+}
+
+// tagOverlap
+//
+//	Returns the jaccard similarity of the tag sets
+func tagOverlap(a []int64, b []int64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	set := make(map[int64]bool, len(a))
+	for _, tag := range a {
+		set[tag] = true
+	}
+
+	union := len(set)
+	shared := 0
+	seen := make(map[int64]bool, len(b))
+	for _, tag := range b {
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		if set[tag] {
+			shared++
+		} else {
+			union++
+		}
+	}
+
+	return float64(shared) / float64(union)
+}
+
+func uniqueTags(tags []int64) []int64 {
+	seen := make(map[int64]bool, len(tags))
+	unique := make([]int64, 0, len(tags))
+	for _, tag := range tags {
+		if !seen[tag] {
+			seen[tag] = true
+			unique = append(unique, tag)
+		}
+	}
+	return unique
+}
+
+func uniqueLanguages(langs []ProgrammingLanguage) []ProgrammingLanguage {
+	seen := make(map[ProgrammingLanguage]bool, len(langs))
+	unique := make([]ProgrammingLanguage, 0, len(langs))
+	for _, lang := range langs {
+		if !seen[lang] {
+			seen[lang] = true
+			unique = append(unique, lang)
+		}
+	}
+	return unique
+}
+
+// RecommendationPipelineOptions
+//
+//	Options for a RecommendationPipeline
+type RecommendationPipelineOptions struct {
+	DB    *ti.Database
+	SF    *snowflake.Node
+	Rules RecommendationRules
+}
+
+// RecommendationPipeline
+//
+//	Offline pipeline that loads the implicit feedback and the public posts,
+//	scores them with a RecommendationEngine and replaces the unaccepted
+//	recommendations of every user with feedback in batches
+type RecommendationPipeline struct {
+	db    *ti.Database
+	sf    *snowflake.Node
+	rules RecommendationRules
+}
+
+// NewRecommendationPipeline
+//
+//	Creates a new RecommendationPipeline
+func NewRecommendationPipeline(opts RecommendationPipelineOptions) (*RecommendationPipeline, error) {
+	if opts.DB == nil {
+		return nil, fmt.Errorf("recommendation pipeline requires a database")
+	}
+	if opts.SF == nil {
+		return nil, fmt.Errorf("recommendation pipeline requires a snowflake node")
+	}
+
+	return &RecommendationPipeline{
+		db:    opts.DB,
+		sf:    opts.SF,
+		rules: opts.Rules.withDefaults(),
+	}, nil
+}
+
+// LoadItems
+//
+//	Loads the published public posts with their tags and languages
+func (p *RecommendationPipeline) LoadItems(ctx context.Context, span *trace.Span, callerName *string) ([]*RecommendationItem, error) {
+	const filter = "p.published = true and p.deleted = false and p.visibility = ?"
+
+	res, err := p.db.QueryContext(ctx, span, callerName,
+		"select p._id, p.author_id, p.tier from post p where "+filter, PublicVisibility,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recommendation items: %v", err)
+	}
+
+	items := make([]*RecommendationItem, 0)
+	byID := make(map[int64]*RecommendationItem)
+	for res.Next() {
+		item := new(RecommendationItem)
+		err = res.Scan(&item.PostID, &item.AuthorID, &item.Tier)
+		if err != nil {
+			_ = res.Close()
+			return nil, fmt.Errorf("failed to scan recommendation item: %v", err)
+		}
+		items = append(items, item)
+		byID[item.PostID] = item
+	}
+	err = res.Err()
+	_ = res.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recommendation items: %v", err)
+	}
+
+	res, err = p.db.QueryContext(ctx, span, callerName,
+		"select t.post_id, t.tag_id from post_tags t join post p on p._id = t.post_id where "+filter, PublicVisibility,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recommendation item tags: %v", err)
+	}
+	for res.Next() {
+		var postID, tagID int64
+		err = res.Scan(&postID, &tagID)
+		if err != nil {
+			_ = res.Close()
+			return nil, fmt.Errorf("failed to scan recommendation item tag: %v", err)
+		}
+		if item, ok := byID[postID]; ok {
+			item.Tags = append(item.Tags, tagID)
+		}
+	}
+	err = res.Err()
+	_ = res.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recommendation item tags: %v", err)
+	}
+
+	res, err = p.db.QueryContext(ctx, span, callerName,
+		"select l.post_id, l.lang_id from post_langs l join post p on p._id = l.post_id where "+filter, PublicVisibility,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recommendation item languages: %v", err)
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var postID int64
+		var lang ProgrammingLanguage
+		err = res.Scan(&postID, &lang)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recommendation item language: %v", err)
+		}
+		if item, ok := byID[postID]; ok {
+			item.Languages = append(item.Languages, lang)
+		}
+	}
+
+	if err := res.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query recommendation item languages: %v", err)
+	}
+
+	return items, nil
+}
+
+// LoadInteractions
+//
+//	Loads the number of times each user performed each implicit action on
+//	each post since the passed time
+func (p *RecommendationPipeline) LoadInteractions(ctx context.Context, span *trace.Span, callerName *string, since time.Time) ([]RecommendationInteraction, error) {
+	res, err := p.db.QueryContext(ctx, span, callerName,
+		"select user_id, post_id, implicit_action, count(*) from implicit_rec where created_at >= ? "+
+			"group by user_id, post_id, implicit_action",
+		since,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recommendation interactions: %v", err)
+	}
+	defer res.Close()
+
+	interactions := make([]RecommendationInteraction, 0)
+	for res.Next() {
+		var interaction RecommendationInteraction
+		err = res.Scan(&interaction.UserID, &interaction.PostID, &interaction.Action, &interaction.Count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recommendation interaction: %v", err)
+		}
+		interactions = append(interactions, interaction)
+	}
+
+	if err := res.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query recommendation interactions: %v", err)
+	}
+
+	return interactions, nil
+}
+
+// Run
+//
+//	Produces the recommendations of every user with implicit feedback in
+//	the rules' window and writes them in batches of users. The unaccepted
+//	recommendations of each user in a batch are replaced in the same
+//	transaction. Returns the number of recommendations written.
+func (p *RecommendationPipeline) Run(ctx context.Context, span *trace.Span, callerName *string, now time.Time) (int, error) {
+	items, err := p.LoadItems(ctx, span, callerName)
+	if err != nil {
+		return 0, err
+	}
+
+	interactions, err := p.LoadInteractions(ctx, span, callerName, now.Add(-p.rules.Window))
+	if err != nil {
+		return 0, err
+	}
+
+	engine := NewRecommendationEngine(p.rules, items, interactions)
+	users := engine.Users()
+
+	written := 0
+	for start := 0; start < len(users); start += p.rules.BatchSize {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+
+		end := start + p.rules.BatchSize
+		if end > len(users) {
+			end = len(users)
+		}
+		batch := users[start:end]
+
+		placeholders := make([]string, 0, len(batch))
+		args := make([]interface{}, 0, len(batch))
+		for _, userID := range batch {
+			placeholders = append(placeholders, "?")
+			args = append(args, userID)
+		}
+
+		builder := ti.NewBulkInsertBuilder(ti.BulkInsertOptions{})
+		err = builder.Add("delete from recommended_post where user_id in ("+strings.Join(placeholders, ", ")+") and accepted = false", args...)
+		if err != nil {
+			return written, fmt.Errorf("failed to build recommendation batch: %v", err)
+		}
+
+		count := 0
+		for _, userID := range batch {
+			for _, recommendation := range engine.Recommend(userID) {
+				post, err := CreateRecommendedPost(
+					p.sf.Generate().Int64(), userID, recommendation.PostID, recommendation.Type,
+					recommendation.ReferenceID, float32(recommendation.Score), now, now.Add(p.rules.TTL),
+					recommendation.ReferenceTier,
+				)
+				if err != nil {
+					return written, fmt.Errorf("failed to create recommended post: %v", err)
+				}
+
+				statement := post.ToSQLNative()
+				err = builder.Add(statement.Statement, statement.Values...)
+				if err != nil {
+					return written, fmt.Errorf("failed to build recommendation batch: %v", err)
+				}
+				count++
+			}
+		}
+
+		err = builder.Exec(ctx, p.db, span, callerName)
+		if err != nil {
+			return written, fmt.Errorf("failed to write recommendation batch: %v", err)
+		}
+		written += count
+	}
+
+	return written, nil
+}
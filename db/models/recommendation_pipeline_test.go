@@ -0,0 +1,238 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/google/uuid"
+)
+
+func testRecommendationItems() []*RecommendationItem {
+	return []*RecommendationItem{
+		{PostID: 1, AuthorID: 100, Tier: Tier1, Tags: []int64{1, 2}, Languages: []ProgrammingLanguage{Go}},
+		{PostID: 2, AuthorID: 100, Tier: Tier2, Tags: []int64{1, 2}, Languages: []ProgrammingLanguage{Go}},
+		{PostID: 3, AuthorID: 100, Tier: Tier3, Tags: []int64{9}, Languages: []ProgrammingLanguage{Python}},
+		// authored by user 200 who must never be recommended their own post
+		{PostID: 4, AuthorID: 200, Tier: Tier1, Tags: []int64{1}, Languages: []ProgrammingLanguage{Go}},
+		// shares nothing with the other posts
+		{PostID: 5, AuthorID: 100, Tier: Tier1, Languages: []ProgrammingLanguage{Rust}},
+	}
+}
+
+func testRecommendationInteractions() []RecommendationInteraction {
+	return []RecommendationInteraction{
+		{UserID: 10, PostID: 1, Action: ImplicitTypeClicked, Count: 1},
+		{UserID: 10, PostID: 1, Action: ImplicitTypeChallengeEnd, Count: 1},
+		{UserID: 11, PostID: 1, Action: ImplicitTypeAttemptEnd, Count: 1},
+		{UserID: 11, PostID: 3, Action: ImplicitTypeAttemptEnd, Count: 1},
+		{UserID: 14, PostID: 1, Action: ImplicitTypeClicked, Count: 1},
+		{UserID: 14, PostID: 2, Action: ImplicitTypeClicked, Count: 1},
+		{UserID: 200, PostID: 1, Action: ImplicitTypeClicked, Count: 1},
+		// actions without weight and posts outside of the items are ignored
+		{UserID: 13, PostID: 5, Action: ImplicitTypeClickedOwnedProject, Count: 3},
+		{UserID: 13, PostID: 99, Action: ImplicitTypeChallengeEnd, Count: 1},
+	}
+}
+
+func TestRecommendationEngine_Recommend(t *testing.T) {
+	engine := NewRecommendationEngine(RecommendationRules{}, testRecommendationItems(), testRecommendationInteractions())
+
+	users := engine.Users()
+	if len(users) != 4 || users[0] != 10 || users[1] != 11 || users[2] != 14 || users[3] != 200 {
+		t.Fatalf("\nRecommendation Engine Failed\n    Error: wrong users %v", users)
+	}
+
+	collaborative, content, _ := engine.Similarity(1, 3)
+	if collaborative <= 0 || content != 0 {
+		t.Fatalf("\nRecommendation Engine Failed\n    Error: wrong similarity of 1 and 3: %f %f", collaborative, content)
+	}
+	collaborative, content, _ = engine.Similarity(1, 4)
+	if collaborative != 0 || content != 0.75 {
+		t.Fatalf("\nRecommendation Engine Failed\n    Error: wrong similarity of 1 and 4: %f %f", collaborative, content)
+	}
+
+	// post 2 co-occurs with and matches post 1, post 3 only co-occurs and post 4 only matches
+	recommendations := engine.Recommend(10)
+	expected := []struct {
+		postID  int64
+		recType RecommendationType
+	}{
+		{2, RecommendationTypeHybrid},
+		{3, RecommendationTypeCollaborative},
+		{4, RecommendationTypeSematic},
+	}
+	if len(recommendations) != len(expected) {
+		t.Fatalf("\nRecommendation Engine Failed\n    Error: expected %d recommendations, got %d", len(expected), len(recommendations))
+	}
+	for i, recommendation := range recommendations {
+		if recommendation.UserID != 10 || recommendation.PostID != expected[i].postID || recommendation.Type != expected[i].recType {
+			t.Fatalf("\nRecommendation Engine Failed\n    Error: wrong recommendation %d %+v", i, recommendation)
+		}
+		if recommendation.ReferenceID != 1 || recommendation.ReferenceTier != Tier1 {
+			t.Fatalf("\nRecommendation Engine Failed\n    Error: wrong reference %+v", recommendation)
+		}
+		if i > 0 && recommendation.Score > recommendations[i-1].Score {
+			t.Fatalf("\nRecommendation Engine Failed\n    Error: recommendations are out of order %+v", recommendations)
+		}
+	}
+
+	// users are not recommended their own posts
+	for _, recommendation := range engine.Recommend(200) {
+		if recommendation.PostID == 4 {
+			t.Fatal("\nRecommendation Engine Failed\n    Error: user was recommended their own post")
+		}
+	}
+
+	if len(engine.Recommend(13)) != 0 {
+		t.Fatal("\nRecommendation Engine Failed\n    Error: user without weighted actions was recommended posts")
+	}
+
+	limited := NewRecommendationEngine(RecommendationRules{TopK: 1}, testRecommendationItems(), testRecommendationInteractions())
+	top := limited.Recommend(10)
+	if len(top) != 1 || top[0].PostID != 2 || top[0].Score != recommendations[0].Score {
+		t.Fatalf("\nRecommendation Engine Failed\n    Error: wrong top recommendation %+v", top)
+	}
+
+	t.Log("\nRecommendation Engine Succeeded")
+}
+
+func TestRecommendationEngine_Seen(t *testing.T) {
+	items := testRecommendationItems()
+	interactions := testRecommendationInteractions()
+	// user 12 attempted post 2 beyond their most preferred post and only
+	// clicked off of post 4, which carries no weight
+	interactions = append(interactions,
+		RecommendationInteraction{UserID: 12, PostID: 1, Action: ImplicitTypeChallengeEnd, Count: 3},
+		RecommendationInteraction{UserID: 12, PostID: 2, Action: ImplicitTypeAttemptStart, Count: 1},
+		RecommendationInteraction{UserID: 12, PostID: 4, Action: ImplicitTypeClickedOff, Count: 1},
+	)
+
+	engine := NewRecommendationEngine(RecommendationRules{MaxUserItems: 1}, items, interactions)
+	for _, recommendation := range engine.Recommend(12) {
+		if recommendation.PostID == 1 || recommendation.PostID == 2 || recommendation.PostID == 4 {
+			t.Fatalf("\nRecommendation Engine Failed\n    Error: user was recommended a post they interacted with %+v", recommendation)
+		}
+	}
+
+	// the content candidates are capped with the rarest tags first
+	capped := NewRecommendationEngine(RecommendationRules{ContentCandidates: 1}, items, interactions)
+	candidates := capped.contentCandidates(items[0])
+	if len(candidates) != 1 || candidates[0] != 2 {
+		t.Fatalf("\nRecommendation Engine Failed\n    Error: wrong content candidates %v", candidates)
+	}
+
+	t.Log("\nRecommendation Engine Succeeded")
+}
+
+func TestRecommendationPipeline_Run(t *testing.T) {
+	db, err := ti.CreateDatabase("gigo-dev-tidb", "4000", "mysql", "gigo-dev", "gigo-dev", "gigo_dev_test")
+	if err != nil {
+		t.Fatal("\nRecommendation Pipeline Failed\n    Error: ", err)
+	}
+
+	defer db.DB.Exec("delete from post where _id in (6942001, 6942002, 6942003)")
+	defer db.DB.Exec("delete from post_tags where post_id in (6942001, 6942002, 6942003)")
+	defer db.DB.Exec("delete from post_langs where post_id in (6942001, 6942002, 6942003)")
+	defer db.DB.Exec("delete from implicit_rec where user_id in (6942069, 6942068)")
+	defer db.DB.Exec("delete from recommended_post where user_id in (6942069, 6942068)")
+
+	sf, err := snowflake.NewNode(1)
+	if err != nil {
+		t.Fatal("\nRecommendation Pipeline Failed\n    Error: ", err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	for _, postID := range []int64{6942001, 6942002, 6942003} {
+		post, err := CreatePost(postID, "test", "recommendation", "author", 420, now, now, 69, Tier1, nil, nil, 0,
+			0, 0, 0, 0, []ProgrammingLanguage{Go}, PublicVisibility, []int64{6942}, nil, nil, 1, 0,
+			&DefaultWorkspaceSettings, false, false, nil)
+		if err != nil {
+			t.Fatal("\nRecommendation Pipeline Failed\n    Error: ", err)
+		}
+		post.Published = true
+
+		statements, err := post.ToSQLNative()
+		if err != nil {
+			t.Fatal("\nRecommendation Pipeline Failed\n    Error: ", err)
+		}
+		for _, statement := range statements {
+			_, err = db.DB.Exec(statement.Statement, statement.Values...)
+			if err != nil {
+				t.Fatal("\nRecommendation Pipeline Failed\n    Error: ", err)
+			}
+		}
+	}
+
+	actions := []*ImplicitRec{
+		CreateImplicitRec(sf.Generate().Int64(), 6942069, 6942001, uuid.New(), ImplicitTypeChallengeEnd, now, Tier1),
+		CreateImplicitRec(sf.Generate().Int64(), 6942068, 6942001, uuid.New(), ImplicitTypeChallengeEnd, now, Tier1),
+		CreateImplicitRec(sf.Generate().Int64(), 6942068, 6942002, uuid.New(), ImplicitTypeChallengeEnd, now, Tier1),
+	}
+	for _, action := range actions {
+		statement := action.ToSQLNative()
+		_, err = db.DB.Exec(statement.Statement, statement.Values...)
+		if err != nil {
+			t.Fatal("\nRecommendation Pipeline Failed\n    Error: ", err)
+		}
+	}
+
+	// stale recommendations are replaced while accepted ones are kept
+	stale, _ := CreateRecommendedPost(sf.Generate().Int64(), 6942069, 6942003, RecommendationTypeSematic, 6942001, 1, now, now, Tier1)
+	accepted, _ := CreateRecommendedPost(sf.Generate().Int64(), 6942068, 6942003, RecommendationTypeSematic, 6942001, 1, now, now, Tier1)
+	accepted.Accepted = true
+	for _, recommendation := range []*RecommendedPost{stale, accepted} {
+		statement := recommendation.ToSQLNative()
+		_, err = db.DB.Exec(statement.Statement, statement.Values...)
+		if err != nil {
+			t.Fatal("\nRecommendation Pipeline Failed\n    Error: ", err)
+		}
+	}
+
+	pipeline, err := NewRecommendationPipeline(RecommendationPipelineOptions{DB: db, SF: sf, Rules: RecommendationRules{BatchSize: 1}})
+	if err != nil {
+		t.Fatal("\nRecommendation Pipeline Failed\n    Error: ", err)
+	}
+
+	_, err = pipeline.Run(context.TODO(), nil, nil, now)
+	if err != nil {
+		t.Fatal("\nRecommendation Pipeline Failed\n    Error: ", err)
+	}
+
+	res, err := db.DB.Query("select * from recommended_post where user_id = ? and post_id in (6942001, 6942002, 6942003) order by score desc, post_id",
+		6942069,
+	)
+	if err != nil {
+		t.Fatal("\nRecommendation Pipeline Failed\n    Error: ", err)
+	}
+	defer res.Close()
+
+	recommendations := make([]*RecommendedPost, 0)
+	for res.Next() {
+		recommendation, err := RecommendedPostFromSQLNative(res)
+		if err != nil {
+			t.Fatal("\nRecommendation Pipeline Failed\n    Error: ", err)
+		}
+		recommendations = append(recommendations, recommendation)
+	}
+
+	// post 2 was completed alongside post 1 and shares its tags while post 3 only shares its tags
+	if len(recommendations) != 2 || recommendations[0].PostID != 6942002 || recommendations[1].PostID != 6942003 {
+		t.Fatalf("\nRecommendation Pipeline Failed\n    Error: wrong recommendations %+v", recommendations)
+	}
+	if recommendations[0].Type != RecommendationTypeHybrid || recommendations[1].Type != RecommendationTypeSematic ||
+		recommendations[1].ID == stale.ID || recommendations[0].ReferenceID != 6942001 ||
+		!recommendations[0].ExpiresAt.Equal(now.Add(time.Hour*24*7)) {
+		t.Fatalf("\nRecommendation Pipeline Failed\n    Error: wrong recommendations %+v", recommendations)
+	}
+
+	var acceptedCount int
+	err = db.DB.QueryRow("select count(*) from recommended_post where _id = ? and accepted = true", accepted.ID).Scan(&acceptedCount)
+	if err != nil || acceptedCount != 1 {
+		t.Fatal("\nRecommendation Pipeline Failed\n    Error: accepted recommendation was removed ", err)
+	}
+
+	t.Log("\nRecommendation Pipeline Succeeded")
+}
@@ -1,1127 +1,249 @@
 package models
 
-type Renown1Struct struct {
-	Level1  uint64
-	Level2  uint64
-	Level3  uint64
-	Level4  uint64
-	Level5  uint64
-	Level6  uint64
-	Level7  uint64
-	Level8  uint64
-	Level9  uint64
-	Level10 uint64
-}
-
-func (r *Renown1Struct) populate() {
-	r.Level1 = 100
-	r.Level2 = 220
-	r.Level3 = 360
-	r.Level4 = 520
-	r.Level5 = 700
-	r.Level6 = 900
-	r.Level7 = 1120
-	r.Level8 = 1360
-	r.Level9 = 1620
-	r.Level10 = 1900
-}
-
-func GetRenown1() *Renown1Struct {
-	r := new(Renown1Struct)
-	r.populate()
-	return r
-}
-
-func (r Renown1Struct) determineRenownLevel(value uint64) (bool, LevelType, uint64, uint64) {
-	var b bool
-	var i LevelType
-	var min uint64
-	var max uint64
-	if value < r.Level1 {
-		b = true
-		i = 0
-		min = 0
-		max = r.Level1
-	}
-	if value >= r.Level1 {
-		b = true
-		i = 1
-		min = r.Level1
-		max = r.Level2
-	}
-	if value >= r.Level2 {
-		b = true
-		i = 2
-		min = r.Level2
-		max = r.Level3
-	}
-	if value >= r.Level3 {
-		b = true
-		i = 3
-		min = r.Level3
-		max = r.Level4
-	}
-	if value >= r.Level4 {
-		b = true
-		i = 4
-		min = r.Level4
-		max = r.Level5
-	}
-	if value >= r.Level5 {
-		b = true
-		i = 5
-		min = r.Level5
-		max = r.Level6
-	}
-	if value >= r.Level6 {
-		b = true
-		i = 6
-		min = r.Level6
-		max = r.Level7
-	}
-	if value >= r.Level7 {
-		b = true
-		i = 7
-		min = r.Level7
-		max = r.Level8
-	}
-	if value >= r.Level8 {
-		b = true
-		i = 8
-		min = r.Level8
-		max = r.Level9
-	}
-	if value >= r.Level9 {
-		b = true
-		i = 9
-		min = r.Level9
-		max = r.Level10
-	}
-	if value >= r.Level10 {
-		b = false
-		i = 0
-	}
-	return b, i, min, max
-}
-
-///////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed renown/*.json
+var renownCurves embed.FS
 
-type Renown2Struct struct {
-	Level1  uint64
-	Level2  uint64
-	Level3  uint64
-	Level4  uint64
-	Level5  uint64
-	Level6  uint64
-	Level7  uint64
-	Level8  uint64
-	Level9  uint64
-	Level10 uint64
+// ErrUnknownRenownCurve is returned when a renown curve version does not exist
+var ErrUnknownRenownCurve = errors.New("unknown renown curve version")
+
+// RenownTier
+//
+//	Single tier of a renown curve
+type RenownTier struct {
+	// Levels holds the XP required to reach each level of the tier in
+	// ascending order. XP below the first level of a tier and above the
+	// last level of the previous tier is level 0 of the tier.
+	Levels []uint64 `json:"levels" yaml:"levels"`
+}
+
+// RenownCurve
+//
+//	Versioned progression of tiers and levels over a user's XP. Curves
+//	are loaded from JSON or YAML so that the progression can be rebalanced
+//	by publishing a new version instead of changing code.
+type RenownCurve struct {
+	Version int          `json:"version" yaml:"version"`
+	Tiers   []RenownTier `json:"tiers" yaml:"tiers"`
+
+	// tierMax holds the last level of each tier for the tier lookup
+	tierMax []uint64
 }
 
-func (r *Renown2Struct) populate() {
-	r.Level1 = 2200
-	r.Level2 = 2520
-	r.Level3 = 2860
-	r.Level4 = 3220
-	r.Level5 = 3600
-	r.Level6 = 4000
-	r.Level7 = 4420
-	r.Level8 = 4860
-	r.Level9 = 5320
-	r.Level10 = 5800
+// RenownPosition
+//
+//	Position of an XP value on a renown curve
+type RenownPosition struct {
+	Tier  TierType
+	Level LevelType
+	// Min is the XP at which the current level was reached; 0 for level 0
+	Min uint64
+	// Max is the XP at which the next level is reached. Max equals Min
+	// once the last level of the curve has been reached.
+	Max uint64
 }
 
-func GetRenown2() *Renown2Struct {
-	r := new(Renown2Struct)
-	r.populate()
-	return r
-}
+// currentRenownCurve is the curve used by DetermineUserRenownLevel
+var currentRenownCurve atomic.Pointer[RenownCurve]
 
-func (r Renown2Struct) determineRenown2Level(value uint64) (bool, LevelType, uint64, uint64) {
-	var b bool
-	var i LevelType
-	var min uint64
-	var max uint64
-	if value < r.Level1 {
-		b = true
-		i = 0
-		min = 0
-		max = r.Level1
-	}
-	if value >= r.Level1 {
-		b = true
-		i = 1
-		min = r.Level1
-		max = r.Level2
-	}
-	if value >= r.Level2 {
-		b = true
-		i = 2
-		min = r.Level2
-		max = r.Level3
+func init() {
+	curve, err := LoadEmbeddedRenownCurve(0)
+	if err != nil {
+		panic(fmt.Sprintf("failed to load embedded renown curve: %v", err))
 	}
-	if value >= r.Level3 {
-		b = true
-		i = 3
-		min = r.Level3
-		max = r.Level4
-	}
-	if value >= r.Level4 {
-		b = true
-		i = 4
-		min = r.Level4
-		max = r.Level5
-	}
-	if value >= r.Level5 {
-		b = true
-		i = 5
-		min = r.Level5
-		max = r.Level6
-	}
-	if value >= r.Level6 {
-		b = true
-		i = 6
-		min = r.Level6
-		max = r.Level7
-	}
-	if value >= r.Level7 {
-		b = true
-		i = 7
-		min = r.Level7
-		max = r.Level8
-	}
-	if value >= r.Level8 {
-		b = true
-		i = 8
-		min = r.Level8
-		max = r.Level9
-	}
-	if value >= r.Level9 {
-		b = true
-		i = 9
-		min = r.Level9
-		max = r.Level10
-	}
-	if value >= r.Level10 {
-		b = false
-		i = 0
-	}
-	return b, i, min, max
-}
-
-///////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
-
-type Renown3Struct struct {
-	Level1  uint64
-	Level2  uint64
-	Level3  uint64
-	Level4  uint64
-	Level5  uint64
-	Level6  uint64
-	Level7  uint64
-	Level8  uint64
-	Level9  uint64
-	Level10 uint64
+	currentRenownCurve.Store(curve)
 }
 
-func (r *Renown3Struct) populate() {
-	r.Level1 = 6300
-	r.Level2 = 6820
-	r.Level3 = 7360
-	r.Level4 = 7920
-	r.Level5 = 8500
-	r.Level6 = 9100
-	r.Level7 = 9720
-	r.Level8 = 10360
-	r.Level9 = 11020
-	r.Level10 = 11700
-}
-
-func GetRenown3() *Renown3Struct {
-	r := new(Renown3Struct)
-	r.populate()
-	return r
-}
+// ParseRenownCurve
+//
+//	Parses and validates a renown curve from JSON or YAML
+func ParseRenownCurve(data []byte, format string) (*RenownCurve, error) {
+	var curve RenownCurve
+	var err error
 
-func (r Renown3Struct) determineRenown3Level(value uint64) (bool, LevelType, uint64, uint64) {
-	var b bool
-	var i LevelType
-	var min uint64
-	var max uint64
-
-	if value < r.Level1 {
-		b = true
-		i = 0
-		min = 0
-		max = r.Level1
-	}
-	if value >= r.Level1 {
-		b = true
-		i = 1
-		min = r.Level1
-		max = r.Level2
-	}
-	if value >= r.Level2 {
-		b = true
-		i = 2
-		min = r.Level2
-		max = r.Level3
-	}
-	if value >= r.Level3 {
-		b = true
-		i = 3
-		min = r.Level3
-		max = r.Level4
-	}
-	if value >= r.Level4 {
-		b = true
-		i = 4
-		min = r.Level4
-		max = r.Level5
-	}
-	if value >= r.Level5 {
-		b = true
-		i = 5
-		min = r.Level5
-		max = r.Level6
-	}
-	if value >= r.Level6 {
-		b = true
-		i = 6
-		min = r.Level6
-		max = r.Level7
-	}
-	if value >= r.Level7 {
-		b = true
-		i = 7
-		min = r.Level7
-		max = r.Level8
+	switch strings.ToLower(format) {
+	case "json":
+		err = json.Unmarshal(data, &curve)
+	case "yaml", "yml":
+		err = yaml.Unmarshal(data, &curve)
+	default:
+		return nil, fmt.Errorf("unsupported renown curve format %q", format)
 	}
-	if value >= r.Level8 {
-		b = true
-		i = 8
-		min = r.Level8
-		max = r.Level9
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse renown curve: %v", err)
+	}
+
+	err = curve.init()
+	if err != nil {
+		return nil, err
 	}
-	if value >= r.Level9 {
-		b = true
-		i = 9
-		min = r.Level9
-		max = r.Level10
-	}
-	if value >= r.Level10 {
-		b = false
-		i = 0
-	}
-	return b, i, min, max
-}
-
-///////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
-
-type Renown4Struct struct {
-	Level1  uint64
-	Level2  uint64
-	Level3  uint64
-	Level4  uint64
-	Level5  uint64
-	Level6  uint64
-	Level7  uint64
-	Level8  uint64
-	Level9  uint64
-	Level10 uint64
+
+	return &curve, nil
 }
 
-func (r *Renown4Struct) populate() {
-	r.Level1 = 12400
-	r.Level2 = 13120
-	r.Level3 = 13860
-	r.Level4 = 14620
-	r.Level5 = 15400
-	r.Level6 = 16200
-	r.Level7 = 17020
-	r.Level8 = 17860
-	r.Level9 = 18720
-	r.Level10 = 19600
-}
-
-func GetRenown4() *Renown4Struct {
-	r := new(Renown4Struct)
-	r.populate()
-	return r
-}
-
-func (r Renown4Struct) determineRenown4Level(value uint64) (bool, LevelType, uint64, uint64) {
-	var b bool
-	var i LevelType
-	var min uint64
-	var max uint64
-	if value < r.Level1 {
-		b = true
-		i = 0
-		min = 0
-		max = r.Level1
-	}
-	if value >= r.Level1 {
-		b = true
-		i = 1
-		min = r.Level1
-		max = r.Level2
-	}
-	if value >= r.Level2 {
-		b = true
-		i = 2
-		min = r.Level2
-		max = r.Level3
+// LoadRenownCurveFile
+//
+//	Loads a renown curve from a JSON or YAML file. The format is
+//	determined by the file extension.
+func LoadRenownCurveFile(path string) (*RenownCurve, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read renown curve: %v", err)
 	}
-	if value >= r.Level3 {
-		b = true
-		i = 3
-		min = r.Level3
-		max = r.Level4
-	}
-	if value >= r.Level4 {
-		b = true
-		i = 4
-		min = r.Level4
-		max = r.Level5
-	}
-	if value >= r.Level5 {
-		b = true
-		i = 5
-		min = r.Level5
-		max = r.Level6
-	}
-	if value >= r.Level6 {
-		b = true
-		i = 6
-		min = r.Level6
-		max = r.Level7
-	}
-	if value >= r.Level7 {
-		b = true
-		i = 7
-		min = r.Level7
-		max = r.Level8
-	}
-	if value >= r.Level8 {
-		b = true
-		i = 8
-		min = r.Level8
-		max = r.Level9
-	}
-	if value >= r.Level9 {
-		b = true
-		i = 9
-		min = r.Level9
-		max = r.Level10
-	}
-	if value >= r.Level10 {
-		b = false
-		i = 0
-	}
-	return b, i, min, max
-}
 
-///////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
-
-type Renown5Struct struct {
-	Level1  uint64
-	Level2  uint64
-	Level3  uint64
-	Level4  uint64
-	Level5  uint64
-	Level6  uint64
-	Level7  uint64
-	Level8  uint64
-	Level9  uint64
-	Level10 uint64
+	return ParseRenownCurve(data, strings.TrimPrefix(filepath.Ext(path), "."))
 }
 
-func (r *Renown5Struct) populate() {
-	r.Level1 = 20500
-	r.Level2 = 21420
-	r.Level3 = 22360
-	r.Level4 = 23320
-	r.Level5 = 24300
-	r.Level6 = 25300
-	r.Level7 = 26320
-	r.Level8 = 27360
-	r.Level9 = 28420
-	r.Level10 = 29500
-}
-
-func GetRenown5() *Renown5Struct {
-	r := new(Renown5Struct)
-	r.populate()
-	return r
-}
-
-func (r Renown5Struct) determineRenown5Level(value uint64) (bool, LevelType, uint64, uint64) {
-	var b bool
-	var i LevelType
-	var min uint64
-	var max uint64
-	if value < r.Level1 {
-		b = true
-		i = 0
-		min = 0
-		max = r.Level1
-	}
-	if value >= r.Level1 {
-		b = true
-		i = 1
-		min = r.Level1
-		max = r.Level2
-	}
-	if value >= r.Level2 {
-		b = true
-		i = 2
-		min = r.Level2
-		max = r.Level3
-	}
-	if value >= r.Level3 {
-		b = true
-		i = 3
-		min = r.Level3
-		max = r.Level4
-	}
-	if value >= r.Level4 {
-		b = true
-		i = 4
-		min = r.Level4
-		max = r.Level5
-	}
-	if value >= r.Level5 {
-		b = true
-		i = 5
-		min = r.Level5
-		max = r.Level6
-	}
-	if value >= r.Level6 {
-		b = true
-		i = 6
-		min = r.Level6
-		max = r.Level7
-	}
-	if value >= r.Level7 {
-		b = true
-		i = 7
-		min = r.Level7
-		max = r.Level8
-	}
-	if value >= r.Level8 {
-		b = true
-		i = 8
-		min = r.Level8
-		max = r.Level9
+// LoadEmbeddedRenownCurve
+//
+//	Loads a version of the renown curves embedded in this package.
+//	Passing 0 loads the latest version.
+func LoadEmbeddedRenownCurve(version int) (*RenownCurve, error) {
+	files, err := fs.Glob(renownCurves, "renown/curve_v*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list renown curves: %v", err)
 	}
-	if value >= r.Level9 {
-		b = true
-		i = 9
-		min = r.Level9
-		max = r.Level10
-	}
-	if value >= r.Level10 {
-		b = false
-		i = 0
-	}
-	return b, i, min, max
-}
-
-///////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
-
-type Renown6Struct struct {
-	Level1  uint64
-	Level2  uint64
-	Level3  uint64
-	Level4  uint64
-	Level5  uint64
-	Level6  uint64
-	Level7  uint64
-	Level8  uint64
-	Level9  uint64
-	Level10 uint64
-}
 
-func (r *Renown6Struct) populate() {
-	r.Level1 = 30600
-	r.Level2 = 31720
-	r.Level3 = 32860
-	r.Level4 = 34020
-	r.Level5 = 35200
-	r.Level6 = 36400
-	r.Level7 = 37620
-	r.Level8 = 38860
-	r.Level9 = 40120
-	r.Level10 = 41400
-}
+	var latest *RenownCurve
+	for _, file := range files {
+		data, err := fs.ReadFile(renownCurves, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read renown curve %s: %v", file, err)
+		}
 
-func GetRenown6() *Renown6Struct {
-	r := new(Renown6Struct)
-	r.populate()
-	return r
-}
+		curve, err := ParseRenownCurve(data, "json")
+		if err != nil {
+			return nil, fmt.Errorf("invalid renown curve %s: %v", file, err)
+		}
 
-func (r Renown6Struct) determineRenown6Level(value uint64) (bool, LevelType, uint64, uint64) {
-	var b bool
-	var i LevelType
-	var min uint64
-	var max uint64
-	if value < r.Level1 {
-		b = true
-		i = 0
-		min = 0
-		max = r.Level1
-	}
-	if value >= r.Level1 {
-		b = true
-		i = 1
-		min = r.Level1
-		max = r.Level2
-	}
-	if value >= r.Level2 {
-		b = true
-		i = 2
-		min = r.Level2
-		max = r.Level3
+		if version > 0 && curve.Version == version {
+			return curve, nil
+		}
+		if latest == nil || curve.Version > latest.Version {
+			latest = curve
+		}
 	}
-	if value >= r.Level3 {
-		b = true
-		i = 3
-		min = r.Level3
-		max = r.Level4
-	}
-	if value >= r.Level4 {
-		b = true
-		i = 4
-		min = r.Level4
-		max = r.Level5
-	}
-	if value >= r.Level5 {
-		b = true
-		i = 5
-		min = r.Level5
-		max = r.Level6
-	}
-	if value >= r.Level6 {
-		b = true
-		i = 6
-		min = r.Level6
-		max = r.Level7
-	}
-	if value >= r.Level7 {
-		b = true
-		i = 7
-		min = r.Level7
-		max = r.Level8
-	}
-	if value >= r.Level8 {
-		b = true
-		i = 8
-		min = r.Level8
-		max = r.Level9
-	}
-	if value >= r.Level9 {
-		b = true
-		i = 9
-		min = r.Level9
-		max = r.Level10
-	}
-	if value >= r.Level10 {
-		b = false
-		i = 0
-	}
-	return b, i, min, max
-}
 
-///////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
-
-type Renown7Struct struct {
-	Level1  uint64
-	Level2  uint64
-	Level3  uint64
-	Level4  uint64
-	Level5  uint64
-	Level6  uint64
-	Level7  uint64
-	Level8  uint64
-	Level9  uint64
-	Level10 uint64
-}
+	if version > 0 || latest == nil {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownRenownCurve, version)
+	}
 
-func (r *Renown7Struct) populate() {
-	r.Level1 = 42700
-	r.Level2 = 44020
-	r.Level3 = 45360
-	r.Level4 = 46720
-	r.Level5 = 48100
-	r.Level6 = 49500
-	r.Level7 = 50920
-	r.Level8 = 52360
-	r.Level9 = 53820
-	r.Level10 = 55300
+	return latest, nil
 }
 
-func GetRenown7() *Renown7Struct {
-	r := new(Renown7Struct)
-	r.populate()
-	return r
+// CurrentRenownCurve
+//
+//	Returns the curve used to determine the renown of users
+func CurrentRenownCurve() *RenownCurve {
+	return currentRenownCurve.Load()
 }
 
-func (r Renown7Struct) determineRenown7Level(value uint64) (bool, LevelType, uint64, uint64) {
-	var b bool
-	var i LevelType
-	var min uint64
-	var max uint64
-	if value < r.Level1 {
-		b = true
-		i = 0
-		min = 0
-		max = r.Level1
-	}
-	if value >= r.Level1 {
-		b = true
-		i = 1
-		min = r.Level1
-		max = r.Level2
-	}
-	if value >= r.Level2 {
-		b = true
-		i = 2
-		min = r.Level2
-		max = r.Level3
-	}
-	if value >= r.Level3 {
-		b = true
-		i = 3
-		min = r.Level3
-		max = r.Level4
-	}
-	if value >= r.Level4 {
-		b = true
-		i = 4
-		min = r.Level4
-		max = r.Level5
-	}
-	if value >= r.Level5 {
-		b = true
-		i = 5
-		min = r.Level5
-		max = r.Level6
+// SetRenownCurve
+//
+//	Replaces the curve used to determine the renown of users
+func SetRenownCurve(curve *RenownCurve) error {
+	if curve == nil {
+		return fmt.Errorf("renown curve cannot be nil")
 	}
-	if value >= r.Level6 {
-		b = true
-		i = 6
-		min = r.Level6
-		max = r.Level7
+	err := curve.init()
+	if err != nil {
+		return err
 	}
-	if value >= r.Level7 {
-		b = true
-		i = 7
-		min = r.Level7
-		max = r.Level8
-	}
-	if value >= r.Level8 {
-		b = true
-		i = 8
-		min = r.Level8
-		max = r.Level9
-	}
-	if value >= r.Level9 {
-		b = true
-		i = 9
-		min = r.Level9
-		max = r.Level10
-	}
-	if value >= r.Level10 {
-		b = false
-		i = 0
-	}
-	return b, i, min, max
+	currentRenownCurve.Store(curve)
+	return nil
 }
 
-///////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// init
+//
+//	Validates the curve and builds the lookup index
+func (c *RenownCurve) init() error {
+	if len(c.Tiers) == 0 {
+		return fmt.Errorf("renown curve %d has no tiers", c.Version)
+	}
 
-type Renown8Struct struct {
-	Level1  uint64
-	Level2  uint64
-	Level3  uint64
-	Level4  uint64
-	Level5  uint64
-	Level6  uint64
-	Level7  uint64
-	Level8  uint64
-	Level9  uint64
-	Level10 uint64
-}
+	tierMax := make([]uint64, 0, len(c.Tiers))
+	var previous uint64
+	for i, tier := range c.Tiers {
+		if len(tier.Levels) == 0 {
+			return fmt.Errorf("tier %d of renown curve %d has no levels", i+1, c.Version)
+		}
+		for j, xp := range tier.Levels {
+			if (i > 0 || j > 0) && xp <= previous {
+				return fmt.Errorf("level %d of tier %d of renown curve %d must require more xp than the previous level", j+1, i+1, c.Version)
+			}
+			previous = xp
+		}
+		tierMax = append(tierMax, tier.Levels[len(tier.Levels)-1])
+	}
+	c.tierMax = tierMax
 
-func (r *Renown8Struct) populate() {
-	r.Level1 = 56800
-	r.Level2 = 58320
-	r.Level3 = 59860
-	r.Level4 = 61420
-	r.Level5 = 63000
-	r.Level6 = 64600
-	r.Level7 = 66220
-	r.Level8 = 67860
-	r.Level9 = 69520
-	r.Level10 = 71200
+	return nil
 }
 
-func GetRenown8() *Renown8Struct {
-	r := new(Renown8Struct)
-	r.populate()
-	return r
-}
+// Lookup
+//
+//	Returns the tier, level and the XP range of the level for the passed XP
+func (c *RenownCurve) Lookup(xp uint64) RenownPosition {
+	// the tier is the first tier that has not been completed
+	tier := sort.Search(len(c.tierMax), func(i int) bool { return xp < c.tierMax[i] })
 
-func (r Renown8Struct) determineRenown8Level(value uint64) (bool, LevelType, uint64, uint64) {
-	var b bool
-	var i LevelType
-	var min uint64
-	var max uint64
-	if value < r.Level1 {
-		b = true
-		i = 0
-		min = 0
-		max = r.Level1
-	}
-	if value >= r.Level1 {
-		b = true
-		i = 1
-		min = r.Level1
-		max = r.Level2
-	}
-	if value >= r.Level2 {
-		b = true
-		i = 2
-		min = r.Level2
-		max = r.Level3
-	}
-	if value >= r.Level3 {
-		b = true
-		i = 3
-		min = r.Level3
-		max = r.Level4
-	}
-	if value >= r.Level4 {
-		b = true
-		i = 4
-		min = r.Level4
-		max = r.Level5
-	}
-	if value >= r.Level5 {
-		b = true
-		i = 5
-		min = r.Level5
-		max = r.Level6
-	}
-	if value >= r.Level6 {
-		b = true
-		i = 6
-		min = r.Level6
-		max = r.Level7
-	}
-	if value >= r.Level7 {
-		b = true
-		i = 7
-		min = r.Level7
-		max = r.Level8
-	}
-	if value >= r.Level8 {
-		b = true
-		i = 8
-		min = r.Level8
-		max = r.Level9
+	// xp beyond the last level caps at the last level of the last tier
+	if tier == len(c.tierMax) {
+		last := c.tierMax[len(c.tierMax)-1]
+		return RenownPosition{
+			Tier:  TierType(len(c.Tiers) - 1),
+			Level: LevelType(len(c.Tiers[len(c.Tiers)-1].Levels) - 1),
+			Min:   last,
+			Max:   last,
+		}
 	}
-	if value >= r.Level9 {
-		b = true
-		i = 9
-		min = r.Level9
-		max = r.Level10
-	}
-	if value >= r.Level10 {
-		b = false
-		i = 0
-	}
-	return b, i, min, max
-}
-
-///////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
-
-type Renown9Struct struct {
-	Level1  uint64
-	Level2  uint64
-	Level3  uint64
-	Level4  uint64
-	Level5  uint64
-	Level6  uint64
-	Level7  uint64
-	Level8  uint64
-	Level9  uint64
-	Level10 uint64
-}
 
-func (r *Renown9Struct) populate() {
-	r.Level1 = 72900
-	r.Level2 = 74620
-	r.Level3 = 76360
-	r.Level4 = 78120
-	r.Level5 = 79900
-	r.Level6 = 81700
-	r.Level7 = 83520
-	r.Level8 = 85360
-	r.Level9 = 87220
-	r.Level10 = 89100
-}
+	levels := c.Tiers[tier].Levels
+	level := sort.Search(len(levels), func(i int) bool { return xp < levels[i] })
 
-func GetRenown9() *Renown9Struct {
-	r := new(Renown9Struct)
-	r.populate()
-	return r
-}
-
-func (r Renown9Struct) determineRenown9Level(value uint64) (bool, LevelType, uint64, uint64) {
-	var b bool
-	var i LevelType
-	var min uint64
-	var max uint64
-	if value < r.Level1 {
-		b = true
-		i = 0
-		min = 0
-		max = r.Level1
-	}
-	if value >= r.Level1 {
-		b = true
-		i = 1
-		min = r.Level1
-		max = r.Level2
+	position := RenownPosition{
+		Tier:  TierType(tier),
+		Level: LevelType(level),
+		Max:   levels[level],
 	}
-	if value >= r.Level2 {
-		b = true
-		i = 2
-		min = r.Level2
-		max = r.Level3
+	if level > 0 {
+		position.Min = levels[level-1]
 	}
-	if value >= r.Level3 {
-		b = true
-		i = 3
-		min = r.Level3
-		max = r.Level4
-	}
-	if value >= r.Level4 {
-		b = true
-		i = 4
-		min = r.Level4
-		max = r.Level5
-	}
-	if value >= r.Level5 {
-		b = true
-		i = 5
-		min = r.Level5
-		max = r.Level6
-	}
-	if value >= r.Level6 {
-		b = true
-		i = 6
-		min = r.Level6
-		max = r.Level7
-	}
-	if value >= r.Level7 {
-		b = true
-		i = 7
-		min = r.Level7
-		max = r.Level8
-	}
-	if value >= r.Level8 {
-		b = true
-		i = 8
-		min = r.Level8
-		max = r.Level9
-	}
-	if value >= r.Level9 {
-		b = true
-		i = 9
-		min = r.Level9
-		max = r.Level10
-	}
-	if value >= r.Level10 {
-		b = false
-		i = 0
-	}
-	return b, i, min, max
-}
-
-///////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
-
-type Renown10Struct struct {
-	Level1  uint64
-	Level2  uint64
-	Level3  uint64
-	Level4  uint64
-	Level5  uint64
-	Level6  uint64
-	Level7  uint64
-	Level8  uint64
-	Level9  uint64
-	Level10 uint64
-}
-
-func (r *Renown10Struct) populate() {
-	r.Level1 = 91000
-	r.Level2 = 92920
-	r.Level3 = 94860
-	r.Level4 = 96820
-	r.Level5 = 98800
-	r.Level6 = 100800
-	r.Level7 = 102820
-	r.Level8 = 104860
-	r.Level9 = 106920
-	r.Level10 = 109000
-}
 
-func GetRenown10() *Renown10Struct {
-	r := new(Renown10Struct)
-	r.populate()
-	return r
+	return position
 }
 
-func (r Renown10Struct) determineRenown10Level(value uint64) (bool, LevelType, uint64, uint64) {
-	var b bool
-	var i LevelType
-	var min uint64
-	var max uint64
-	if value < r.Level1 {
-		b = true
-		i = 0
-		min = 0
-		max = r.Level1
-	}
-	if value >= r.Level1 {
-		b = true
-		i = 1
-		min = r.Level1
-		max = r.Level2
-	}
-	if value >= r.Level2 {
-		b = true
-		i = 2
-		min = r.Level2
-		max = r.Level3
-	}
-	if value >= r.Level3 {
-		b = true
-		i = 3
-		min = r.Level3
-		max = r.Level4
-	}
-	if value >= r.Level4 {
-		b = true
-		i = 4
-		min = r.Level4
-		max = r.Level5
-	}
-	if value >= r.Level5 {
-		b = true
-		i = 5
-		min = r.Level5
-		max = r.Level6
-	}
-	if value >= r.Level6 {
-		b = true
-		i = 6
-		min = r.Level6
-		max = r.Level7
-	}
-	if value >= r.Level7 {
-		b = true
-		i = 7
-		min = r.Level7
-		max = r.Level8
-	}
-	if value >= r.Level8 {
-		b = true
-		i = 8
-		min = r.Level8
-		max = r.Level9
+// XPToNextLevel
+//
+//	Returns the XP required to reach the next level; 0 once the
+//	last level of the curve has been reached
+func (c *RenownCurve) XPToNextLevel(xp uint64) uint64 {
+	position := c.Lookup(xp)
+	if position.Max <= xp {
+		return 0
 	}
-	if value >= r.Level9 {
-		b = true
-		i = 9
-		min = r.Level9
-		max = r.Level10
-	}
-	if value >= r.Level10 {
-		b = true
-		i = 9
-		min = r.Level10
-		max = r.Level10
-	}
-	return b, i, min, max
+	return position.Max - xp
 }
 
-// DetermineUserRenownLevel renown, level based on amount of xp user has
+// DetermineUserRenownLevel
+//
+//	Returns the tier, level and the XP range of the level for the
+//	passed XP using the current renown curve
 func DetermineUserRenownLevel(value uint64) (TierType, LevelType, uint64, uint64) {
-	// check if user is in renown 1, then return level
-	b, i, min, max := GetRenown1().determineRenownLevel(value)
-	if b == true {
-		return 0, i, min, max
-	}
-
-	// check if user is in renown 2, then return level
-	b, i, min, max = GetRenown2().determineRenown2Level(value)
-	if b == true {
-		return 1, i, min, max
-	}
-
-	// check if user is in renown 3, then return level
-	b, i, min, max = GetRenown3().determineRenown3Level(value)
-	if b == true {
-		return 2, i, min, max
-	}
-
-	// check if user is in renown 4, then return level
-	b, i, min, max = GetRenown4().determineRenown4Level(value)
-	if b == true {
-		return 3, i, min, max
-	}
-
-	// check if user is in renown 5, then return level
-	b, i, min, max = GetRenown5().determineRenown5Level(value)
-	if b == true {
-		return 4, i, min, max
-	}
-
-	// check if user is in renown 6, then return level
-	b, i, min, max = GetRenown6().determineRenown6Level(value)
-	if b == true {
-		return 5, i, min, max
-	}
-
-	// check if user is in renown 7, then return level
-	b, i, min, max = GetRenown7().determineRenown7Level(value)
-	if b == true {
-		return 6, i, min, max
-	}
-
-	// check if user is in renown 8, then return level
-	b, i, min, max = GetRenown8().determineRenown8Level(value)
-	if b == true {
-		return 7, i, min, max
-	}
-
-	// check if user is in renown 9, then return level
-	b, i, min, max = GetRenown9().determineRenown9Level(value)
-	if b == true {
-		return 8, i, min, max
-	}
-
-	// check if user is in renown 10, then return level
-	b, i, min, max = GetRenown10().determineRenown10Level(value)
-	if b == true {
-		return 9, i, min, max
-	}
-	/// returning 69's as an error identifier
-	return 69, 69, 69, 69
+	position := CurrentRenownCurve().Lookup(value)
+	return position.Tier, position.Level, position.Min, position.Max
 }
@@ -1,8 +1,80 @@
 package models
 
-//func TestDetermineUserRenownLevel(t *testing.T) {
-//	renown, level, xp := DetermineUserRenownLevel(100)
-//	if renown != Tier2 || level != Level3 || xp != 220 {
-//		t.Fatalf("DetermineUserRenownLevel(100) failed: %d, %d, %d", renown, level, xp)
-//	}
-//}
+import (
+	"errors"
+	"testing"
+)
+
+func TestDetermineUserRenownLevel(t *testing.T) {
+	tests := []struct {
+		xp    uint64
+		tier  TierType
+		level LevelType
+		min   uint64
+		max   uint64
+	}{
+		{0, Tier1, Level1, 0, 100},
+		{99, Tier1, Level1, 0, 100},
+		{100, Tier1, Level2, 100, 220},
+		{1899, Tier1, Level10, 1620, 1900},
+		// xp between two tiers is level 0 of the next tier
+		{1900, Tier2, Level1, 0, 2200},
+		{2200, Tier2, Level2, 2200, 2520},
+		{41400, Tier7, Level1, 0, 42700},
+		{108999, Tier10, Level10, 106920, 109000},
+		// xp beyond the curve caps at the last level
+		{109000, Tier10, Level10, 109000, 109000},
+		{500000, Tier10, Level10, 109000, 109000},
+	}
+
+	for _, tt := range tests {
+		tier, level, min, max := DetermineUserRenownLevel(tt.xp)
+		if tier != tt.tier || level != tt.level || min != tt.min || max != tt.max {
+			t.Fatalf("\nDetermineUserRenownLevel failed\n    Error: %d returned %d, %d, %d, %d", tt.xp, tier, level, min, max)
+		}
+	}
+
+	t.Log("\nDetermineUserRenownLevel succeeded")
+}
+
+func TestRenownCurve_XPToNextLevel(t *testing.T) {
+	curve := CurrentRenownCurve()
+
+	if curve.XPToNextLevel(50) != 50 {
+		t.Fatalf("\nRenownCurve XPToNextLevel failed\n    Error: incorrect xp returned %d", curve.XPToNextLevel(50))
+	}
+
+	if curve.XPToNextLevel(1950) != 250 {
+		t.Fatalf("\nRenownCurve XPToNextLevel failed\n    Error: incorrect xp returned %d", curve.XPToNextLevel(1950))
+	}
+
+	if curve.XPToNextLevel(200000) != 0 {
+		t.Fatalf("\nRenownCurve XPToNextLevel failed\n    Error: incorrect xp returned %d", curve.XPToNextLevel(200000))
+	}
+
+	t.Log("\nRenownCurve XPToNextLevel succeeded")
+}
+
+func TestParseRenownCurve(t *testing.T) {
+	curve, err := ParseRenownCurve([]byte("version: 2\ntiers:\n  - levels: [10, 20]\n  - levels: [40, 80]\n"), "yaml")
+	if err != nil {
+		t.Fatal("\nParseRenownCurve failed\n    Error: ", err)
+	}
+
+	position := curve.Lookup(30)
+	if curve.Version != 2 || position.Tier != Tier2 || position.Level != Level1 || position.Max != 40 {
+		t.Fatalf("\nParseRenownCurve failed\n    Error: incorrect position returned %+v", position)
+	}
+
+	_, err = ParseRenownCurve([]byte(`{"version": 3, "tiers": [{"levels": [10, 20]}, {"levels": [20, 30]}]}`), "json")
+	if err == nil {
+		t.Fatal("\nParseRenownCurve failed\n    Error: overlapping tiers were accepted")
+	}
+
+	_, err = LoadEmbeddedRenownCurve(1000)
+	if !errors.Is(err, ErrUnknownRenownCurve) {
+		t.Fatal("\nParseRenownCurve failed\n    Error: expected unknown curve, got ", err)
+	}
+
+	t.Log("\nParseRenownCurve succeeded")
+}
@@ -0,0 +1,353 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/kisielk/sqlstruct"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrModelNotFound is returned when a model does not exist
+var ErrModelNotFound = errors.New("model not found")
+
+// RepositoryModel
+//
+//	Interface that a model must implement to be managed by a Repository
+type RepositoryModel interface {
+	// PrimaryKey returns the value of the model's _id column
+	PrimaryKey() int64
+	// InsertStatements returns the statements that insert the model
+	// and all of its child rows
+	InsertStatements() ([]*SQLInsertStatement, error)
+}
+
+// RepositoryOptions
+//
+//	Options for a Repository
+type RepositoryOptions[T RepositoryModel] struct {
+	DB *ti.Database
+	// Table is the table that holds the model
+	Table string
+	// IDColumn is the primary key column of the table; defaults to _id
+	IDColumn string
+	// Scan loads a model from the current row. This is usually the
+	// model's FromSQLNative function.
+	Scan func(rows *sql.Rows) (T, error)
+}
+
+// Repository
+//
+//	Generic data access for a single model. Queries are executed through
+//	ti.Database so they are traced like the rest of the database calls and
+//	the columns that can be updated are derived from the model's sql tags.
+type Repository[T RepositoryModel] struct {
+	db       *ti.Database
+	table    string
+	idColumn string
+	scan     func(rows *sql.Rows) (T, error)
+	columns  map[string]bool
+}
+
+// NewRepository
+//
+//	Creates a new Repository for the model type T
+func NewRepository[T RepositoryModel](opts RepositoryOptions[T]) (*Repository[T], error) {
+	if opts.DB == nil {
+		return nil, fmt.Errorf("repository requires a database")
+	}
+	if opts.Table == "" {
+		return nil, fmt.Errorf("repository requires a table")
+	}
+	if opts.Scan == nil {
+		return nil, fmt.Errorf("repository requires a scan function")
+	}
+
+	idColumn := opts.IDColumn
+	if idColumn == "" {
+		idColumn = "_id"
+	}
+
+	columns, err := modelColumns[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repository[T]{
+		db:       opts.DB,
+		table:    opts.Table,
+		idColumn: idColumn,
+		scan:     opts.Scan,
+		columns:  columns,
+	}, nil
+}
+
+// Insert
+//
+//	Inserts the model by executing all of its insert statements inside a
+//	single transaction. The transaction is retried on write conflicts.
+func (r *Repository[T]) Insert(ctx context.Context, span *trace.Span, callerName *string, model T) error {
+	return r.InsertMany(ctx, span, callerName, model)
+}
+
+// InsertMany
+//
+//	Inserts the models inside a single transaction. The insert statements
+//	of the models are merged into multi-row statements so that list fields
+//	and multiple models do not require a round trip per row.
+func (r *Repository[T]) InsertMany(ctx context.Context, span *trace.Span, callerName *string, models ...T) error {
+	builder := ti.NewBulkInsertBuilder(ti.BulkInsertOptions{})
+	for _, model := range models {
+		statements, err := model.InsertStatements()
+		if err != nil {
+			return fmt.Errorf("failed to create insert statements for %s: %v", r.table, err)
+		}
+		for _, statement := range statements {
+			err = builder.Add(statement.Statement, statement.Values...)
+			if err != nil {
+				return fmt.Errorf("failed to add insert statement for %s: %v", r.table, err)
+			}
+		}
+	}
+
+	err := builder.Exec(ctx, r.db, span, callerName)
+	if err != nil {
+		return fmt.Errorf("failed to insert into %s: %w", r.table, err)
+	}
+
+	return nil
+}
+
+// GetByID
+//
+//	Retrieves a model by its primary key. ErrModelNotFound is
+//	returned if the model does not exist.
+func (r *Repository[T]) GetByID(ctx context.Context, span *trace.Span, callerName *string, id int64) (T, error) {
+	var zero T
+
+	res, err := r.db.QueryContext(ctx, span, callerName,
+		fmt.Sprintf("select * from %s where %s = ? limit 1", r.table, r.idColumn), id,
+	)
+	if err != nil {
+		return zero, fmt.Errorf("failed to query %s: %v", r.table, err)
+	}
+	defer res.Close()
+
+	if !res.Next() {
+		if err := res.Err(); err != nil {
+			return zero, fmt.Errorf("failed to query %s: %v", r.table, err)
+		}
+		return zero, ErrModelNotFound
+	}
+
+	model, err := r.scan(res)
+	if err != nil {
+		return zero, fmt.Errorf("failed to scan %s: %v", r.table, err)
+	}
+
+	return model, nil
+}
+
+// ListOptions
+//
+//	Options for a paged list query
+type ListOptions struct {
+	// After is the cursor returned by the previous page; 0 starts from the beginning
+	After int64
+	// Limit is the maximum number of models in the page
+	Limit int
+	// Descending orders the models from the newest id to the oldest
+	Descending bool
+	// Where is an optional condition added to the query; it must
+	// only reference columns and use ? for its arguments
+	Where string
+	Args  []interface{}
+}
+
+// List
+//
+//	Returns a page of models ordered by primary key and the cursor to
+//	load the next page with. Pages are selected with keyset pagination so
+//	the cost of a page does not grow with its position. The returned
+//	cursor is 0 once there are no more models.
+func (r *Repository[T]) List(ctx context.Context, span *trace.Span, callerName *string, opts ListOptions) ([]T, int64, error) {
+	if opts.Limit <= 0 {
+		return nil, 0, fmt.Errorf("invalid list limit %d", opts.Limit)
+	}
+
+	conditions := make([]string, 0, 2)
+	args := make([]interface{}, 0, len(opts.Args)+2)
+
+	order := "asc"
+	if opts.After > 0 {
+		if opts.Descending {
+			conditions = append(conditions, r.idColumn+" < ?")
+		} else {
+			conditions = append(conditions, r.idColumn+" > ?")
+		}
+		args = append(args, opts.After)
+	}
+	if opts.Descending {
+		order = "desc"
+	}
+
+	if opts.Where != "" {
+		conditions = append(conditions, "("+opts.Where+")")
+		args = append(args, opts.Args...)
+	}
+
+	query := "select * from " + r.table
+	if len(conditions) > 0 {
+		query += " where " + strings.Join(conditions, " and ")
+	}
+	// load one extra row to know whether there is another page
+	query += fmt.Sprintf(" order by %s %s limit ?", r.idColumn, order)
+	args = append(args, opts.Limit+1)
+
+	res, err := r.db.QueryContext(ctx, span, callerName, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query %s: %v", r.table, err)
+	}
+	defer res.Close()
+
+	models := make([]T, 0, opts.Limit)
+	for res.Next() {
+		model, err := r.scan(res)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan %s: %v", r.table, err)
+		}
+		models = append(models, model)
+	}
+	if err := res.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to query %s: %v", r.table, err)
+	}
+
+	var cursor int64
+	if len(models) > opts.Limit {
+		models = models[:opts.Limit]
+		cursor = models[len(models)-1].PrimaryKey()
+	}
+
+	return models, cursor, nil
+}
+
+// UpdateFields
+//
+//	Updates the passed columns of a model. Only columns declared on the
+//	model with a sql tag can be updated. ErrModelNotFound is returned if
+//	the model does not exist.
+func (r *Repository[T]) UpdateFields(ctx context.Context, span *trace.Span, callerName *string, id int64, fields map[string]interface{}) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("no fields to update")
+	}
+
+	// sort the columns so the statement is stable for the same fields
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		if name == r.idColumn {
+			return fmt.Errorf("cannot update the primary key of %s", r.table)
+		}
+		if !r.columns[name] {
+			return fmt.Errorf("unknown column %q for %s", name, r.table)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	assignments := make([]string, 0, len(names))
+	args := make([]interface{}, 0, len(names)+1)
+	for _, name := range names {
+		assignments = append(assignments, name+" = ?")
+		args = append(args, fields[name])
+	}
+	args = append(args, id)
+
+	res, err := r.db.ExecContext(ctx, span, callerName,
+		fmt.Sprintf("update %s set %s where %s = ?", r.table, strings.Join(assignments, ", "), r.idColumn), args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %v", r.table, err)
+	}
+
+	return r.checkAffected(ctx, span, callerName, res, id)
+}
+
+// Delete
+//
+//	Deletes a model by its primary key. Only the row in the model's table
+//	is removed; child rows are left for the caller to remove.
+//	ErrModelNotFound is returned if the model does not exist.
+func (r *Repository[T]) Delete(ctx context.Context, span *trace.Span, callerName *string, id int64) error {
+	res, err := r.db.ExecContext(ctx, span, callerName,
+		fmt.Sprintf("delete from %s where %s = ?", r.table, r.idColumn), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete from %s: %v", r.table, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve deleted rows from %s: %v", r.table, err)
+	}
+	if affected == 0 {
+		return ErrModelNotFound
+	}
+
+	return nil
+}
+
+// checkAffected
+//
+//	Returns ErrModelNotFound if an update did not match a row. MySQL only
+//	counts changed rows so an update that matched a row without changing
+//	it is confirmed with an existence check.
+func (r *Repository[T]) checkAffected(ctx context.Context, span *trace.Span, callerName *string, res sql.Result, id int64) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve updated rows from %s: %v", r.table, err)
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = r.db.QueryRowContext(ctx, span, callerName,
+		fmt.Sprintf("select exists(select 1 from %s where %s = ?)", r.table, r.idColumn), id,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check for %s: %v", r.table, err)
+	}
+	if !exists {
+		return ErrModelNotFound
+	}
+
+	return nil
+}
+
+// modelColumns
+//
+//	Returns the set of columns declared on the model with sql tags
+func modelColumns[T RepositoryModel]() (map[string]bool, error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("repository model must be a struct or a pointer to a struct, got %s", typ)
+	}
+
+	columns := make(map[string]bool)
+	for _, column := range strings.Split(sqlstruct.Columns(reflect.Zero(typ).Interface()), ", ") {
+		if column != "" {
+			columns[column] = true
+		}
+	}
+
+	return columns, nil
+}
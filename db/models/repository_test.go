@@ -0,0 +1,113 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+)
+
+func TestRepository(t *testing.T) {
+	db, err := ti.CreateDatabase("gigo-dev-tidb", "4000", "mysql", "gigo-dev", "gigo-dev", "gigo_dev_test")
+	if err != nil {
+		t.Fatal("\nRepository Failed\n    Error: ", err)
+	}
+
+	defer db.DB.Exec("delete from tag")
+
+	repo, err := NewRepository(RepositoryOptions[*Tag]{
+		DB:    db,
+		Table: "tag",
+		Scan:  TagFromSQLNative,
+	})
+	if err != nil {
+		t.Fatal("\nRepository Failed\n    Error: ", err)
+	}
+
+	ctx := context.Background()
+	callerName := "TestRepository"
+
+	for i := int64(1); i <= 2; i++ {
+		err = repo.Insert(ctx, nil, &callerName, CreateTag(i, "test"))
+		if err != nil {
+			t.Fatal("\nRepository Failed\n    Error: ", err)
+		}
+	}
+
+	err = repo.InsertMany(ctx, nil, &callerName, CreateTag(3, "test"), CreateTag(4, "test"), CreateTag(5, "test"))
+	if err != nil {
+		t.Fatal("\nRepository Failed\n    Error: ", err)
+	}
+
+	tag, err := repo.GetByID(ctx, nil, &callerName, 3)
+	if err != nil {
+		t.Fatal("\nRepository Failed\n    Error: ", err)
+	}
+	if tag.ID != 3 || tag.Value != "test" {
+		t.Fatalf("\nRepository Failed\n    Error: incorrect tag returned: %+v", tag)
+	}
+
+	// page through the tags two at a time
+	ids := make([]int64, 0)
+	var cursor int64
+	for {
+		page, next, err := repo.List(ctx, nil, &callerName, ListOptions{After: cursor, Limit: 2})
+		if err != nil {
+			t.Fatal("\nRepository Failed\n    Error: ", err)
+		}
+		for _, tag := range page {
+			ids = append(ids, tag.ID)
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	if len(ids) != 5 || ids[0] != 1 || ids[4] != 5 {
+		t.Fatalf("\nRepository Failed\n    Error: incorrect pages returned: %v", ids)
+	}
+
+	page, _, err := repo.List(ctx, nil, &callerName, ListOptions{Limit: 10, Descending: true, Where: "_id > ?", Args: []interface{}{2}})
+	if err != nil {
+		t.Fatal("\nRepository Failed\n    Error: ", err)
+	}
+	if len(page) != 3 || page[0].ID != 5 {
+		t.Fatalf("\nRepository Failed\n    Error: incorrect filtered page returned: %d", len(page))
+	}
+
+	err = repo.UpdateFields(ctx, nil, &callerName, 3, map[string]interface{}{"official": true, "usage_count": 42})
+	if err != nil {
+		t.Fatal("\nRepository Failed\n    Error: ", err)
+	}
+
+	tag, err = repo.GetByID(ctx, nil, &callerName, 3)
+	if err != nil {
+		t.Fatal("\nRepository Failed\n    Error: ", err)
+	}
+	if !tag.Official || tag.UsageCount != 42 {
+		t.Fatalf("\nRepository Failed\n    Error: fields were not updated: %+v", tag)
+	}
+
+	err = repo.UpdateFields(ctx, nil, &callerName, 3, map[string]interface{}{"missing": 1})
+	if err == nil {
+		t.Fatal("\nRepository Failed\n    Error: unknown column was accepted")
+	}
+
+	err = repo.Delete(ctx, nil, &callerName, 3)
+	if err != nil {
+		t.Fatal("\nRepository Failed\n    Error: ", err)
+	}
+
+	_, err = repo.GetByID(ctx, nil, &callerName, 3)
+	if !errors.Is(err, ErrModelNotFound) {
+		t.Fatal("\nRepository Failed\n    Error: expected not found, got ", err)
+	}
+
+	err = repo.Delete(ctx, nil, &callerName, 3)
+	if !errors.Is(err, ErrModelNotFound) {
+		t.Fatal("\nRepository Failed\n    Error: expected not found, got ", err)
+	}
+
+	t.Log("\nRepository Succeeded")
+}
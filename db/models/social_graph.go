@@ -0,0 +1,450 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultFollowCountsTTL is the time follow counts are cached when the
+// options do not set a ttl
+const DefaultFollowCountsTTL = time.Minute * 5
+
+// ErrSocialBlocked is returned when an interaction is attempted between
+// users where either user has blocked the other
+var ErrSocialBlocked = errors.New("interaction blocked")
+
+type SocialInteraction int
+
+const (
+	SocialFriendRequest SocialInteraction = iota
+	SocialNemesisRequest
+	SocialDirectMessage
+)
+
+func (s SocialInteraction) String() string {
+	switch s {
+	case SocialFriendRequest:
+		return "FriendRequest"
+	case SocialNemesisRequest:
+		return "NemesisRequest"
+	case SocialDirectMessage:
+		return "DirectMessage"
+	}
+	return "Unknown"
+}
+
+// SocialUser
+//
+//	User returned by the social graph queries
+type SocialUser struct {
+	ID       int64  `json:"_id" sql:"_id"`
+	UserName string `json:"user_name" sql:"user_name"`
+}
+
+type SocialUserFrontend struct {
+	ID       string `json:"_id"`
+	UserName string `json:"user_name"`
+}
+
+func (i *SocialUser) ToFrontend() *SocialUserFrontend {
+	return &SocialUserFrontend{
+		ID:       fmt.Sprintf("%d", i.ID),
+		UserName: i.UserName,
+	}
+}
+
+// FriendSuggestion
+//
+//	User that is a friend of the user's friends but not yet the user's
+//	friend. Mutual is the number of friends the users have in common.
+type FriendSuggestion struct {
+	SocialUser
+	Mutual int64 `json:"mutual" sql:"mutual"`
+}
+
+type FriendSuggestionFrontend struct {
+	SocialUserFrontend
+	Mutual int64 `json:"mutual"`
+}
+
+func (i *FriendSuggestion) ToFrontend() *FriendSuggestionFrontend {
+	return &FriendSuggestionFrontend{
+		SocialUserFrontend: *i.SocialUser.ToFrontend(),
+		Mutual:             i.Mutual,
+	}
+}
+
+// UserBlock
+//
+//	Block of a user by another user
+type UserBlock struct {
+	UserID    int64     `json:"user_id" sql:"user_id"`
+	BlockedID int64     `json:"blocked_id" sql:"blocked_id"`
+	CreatedAt time.Time `json:"created_at" sql:"created_at"`
+}
+
+type UserBlockFrontend struct {
+	UserID    string    `json:"user_id"`
+	BlockedID string    `json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (i *UserBlock) ToFrontend() *UserBlockFrontend {
+	return &UserBlockFrontend{
+		UserID:    fmt.Sprintf("%d", i.UserID),
+		BlockedID: fmt.Sprintf("%d", i.BlockedID),
+		CreatedAt: i.CreatedAt,
+	}
+}
+
+// FollowCounts
+//
+//	Number of users following the user and followed by the user
+type FollowCounts struct {
+	Followers int64 `json:"followers"`
+	Following int64 `json:"following"`
+}
+
+// SocialGraphOptions
+//
+//	Options for a SocialGraph
+type SocialGraphOptions struct {
+	DB *ti.Database
+	// RDB optionally caches follow counts
+	RDB redis.UniversalClient
+	// FollowCountsTTL defaults to DefaultFollowCountsTTL
+	FollowCountsTTL time.Duration
+}
+
+// SocialGraph
+//
+//	Graph operations over the friends, friend requests, followers and
+//	block lists of users. Friendships are treated as undirected so a
+//	friendship is found regardless of which user sent the request.
+type SocialGraph struct {
+	db              *ti.Database
+	rdb             redis.UniversalClient
+	followCountsTTL time.Duration
+}
+
+// NewSocialGraph
+//
+//	Creates a new SocialGraph
+func NewSocialGraph(opts SocialGraphOptions) (*SocialGraph, error) {
+	if opts.DB == nil {
+		return nil, fmt.Errorf("social graph requires a database")
+	}
+	if opts.FollowCountsTTL <= 0 {
+		opts.FollowCountsTTL = DefaultFollowCountsTTL
+	}
+
+	return &SocialGraph{
+		db:              opts.DB,
+		rdb:             opts.RDB,
+		followCountsTTL: opts.FollowCountsTTL,
+	}, nil
+}
+
+// friendIDsQuery
+//
+//	Returns the subquery selecting the ids of the user's friends as id
+//	and its arguments
+func friendIDsQuery(userID int64) (string, []interface{}) {
+	return "select friend as id from friends where user_id = ? union select user_id as id from friends where friend = ?",
+		[]interface{}{userID, userID}
+}
+
+func (g *SocialGraph) querySocialUsers(ctx context.Context, span *trace.Span, callerName *string, query string, args ...interface{}) ([]*SocialUser, error) {
+	res, err := g.db.QueryContext(ctx, span, callerName, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query social graph: %v", err)
+	}
+	defer res.Close()
+
+	users := make([]*SocialUser, 0)
+	for res.Next() {
+		user := new(SocialUser)
+		err = res.Scan(&user.ID, &user.UserName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan social graph user: %v", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := res.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query social graph: %v", err)
+	}
+
+	return users, nil
+}
+
+// MutualFriends
+//
+//	Returns the friends the users have in common ordered by name
+func (g *SocialGraph) MutualFriends(ctx context.Context, span *trace.Span, callerName *string, userID int64, otherID int64, limit int) ([]*SocialUser, error) {
+	userFriends, userArgs := friendIDsQuery(userID)
+	otherFriends, otherArgs := friendIDsQuery(otherID)
+
+	args := append(userArgs, otherArgs...)
+	args = append(args, limit)
+
+	return g.querySocialUsers(ctx, span, callerName,
+		"select u._id, u.user_name from users u "+
+			"join ("+userFriends+") a on a.id = u._id "+
+			"join ("+otherFriends+") b on b.id = u._id "+
+			"order by u.user_name limit ?",
+		args...,
+	)
+}
+
+// FriendSuggestions
+//
+//	Returns the friends of the user's friends ranked by the number of
+//	friends they have in common with the user. Users that are already
+//	friends, have a pending request with the user or are blocked in
+//	either direction are not suggested.
+func (g *SocialGraph) FriendSuggestions(ctx context.Context, span *trace.Span, callerName *string, userID int64, limit int) ([]*FriendSuggestion, error) {
+	friends, friendArgs := friendIDsQuery(userID)
+
+	args := make([]interface{}, 0)
+	args = append(args, friendArgs...)
+	args = append(args, friendArgs...)
+	args = append(args, userID)
+	args = append(args, friendArgs...)
+	args = append(args, userID, userID, userID, userID, limit)
+
+	// the via column counts each mutual friend once even if the
+	// friendship was stored in both directions
+	res, err := g.db.QueryContext(ctx, span, callerName,
+		"select u._id, u.user_name, count(distinct c.via) as mutual from ("+
+			"select fr.friend as id, f.id as via from friends fr join ("+friends+") f on fr.user_id = f.id "+
+			"union all "+
+			"select fr.user_id as id, f.id as via from friends fr join ("+friends+") f on fr.friend = f.id"+
+			") c join users u on u._id = c.id "+
+			"where c.id != ? "+
+			"and c.id not in ("+friends+") "+
+			"and not exists (select 1 from friend_requests r where r.response is null and ((r.user_id = ? and r.friend = c.id) or (r.friend = ? and r.user_id = c.id))) "+
+			"and not exists (select 1 from user_blocks b where (b.user_id = ? and b.blocked_id = c.id) or (b.blocked_id = ? and b.user_id = c.id)) "+
+			"group by u._id, u.user_name order by mutual desc, u._id limit ?",
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query friend suggestions: %v", err)
+	}
+	defer res.Close()
+
+	suggestions := make([]*FriendSuggestion, 0)
+	for res.Next() {
+		suggestion := new(FriendSuggestion)
+		err = res.Scan(&suggestion.ID, &suggestion.UserName, &suggestion.Mutual)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan friend suggestion: %v", err)
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	if err := res.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query friend suggestions: %v", err)
+	}
+
+	return suggestions, nil
+}
+
+func followCountsKey(userID int64) string {
+	return fmt.Sprintf("gigo-follow-counts-%d", userID)
+}
+
+// FollowCounts
+//
+//	Returns the number of followers of the user and the number of users
+//	the user follows. The counts are cached when a redis client is
+//	configured.
+func (g *SocialGraph) FollowCounts(ctx context.Context, span *trace.Span, callerName *string, userID int64) (*FollowCounts, error) {
+	if g.rdb != nil {
+		cached, err := g.rdb.HGetAll(ctx, followCountsKey(userID)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load cached follow counts: %v", err)
+		}
+		if len(cached) == 2 {
+			followers, errFollowers := strconv.ParseInt(cached["followers"], 10, 64)
+			following, errFollowing := strconv.ParseInt(cached["following"], 10, 64)
+			if errFollowers == nil && errFollowing == nil {
+				return &FollowCounts{Followers: followers, Following: following}, nil
+			}
+		}
+	}
+
+	// count on the primary so that the cache is not filled with counts
+	// from a replica that has not seen the latest follows
+	counts := new(FollowCounts)
+	err := g.db.QueryRowContext(ti.WithPrimaryReads(ctx), span, callerName,
+		"select (select count(*) from follower where following = ?), (select count(*) from follower where follower = ?)",
+		userID, userID,
+	).Scan(&counts.Followers, &counts.Following)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query follow counts: %v", err)
+	}
+
+	if g.rdb != nil {
+		key := followCountsKey(userID)
+		pipe := g.rdb.TxPipeline()
+		pipe.HSet(ctx, key, "followers", counts.Followers, "following", counts.Following)
+		pipe.Expire(ctx, key, g.followCountsTTL)
+		_, err = pipe.Exec(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to cache follow counts: %v", err)
+		}
+	}
+
+	return counts, nil
+}
+
+// InvalidateFollowCounts
+//
+//	Removes the cached follow counts of the users. Must be called after
+//	a follow is created or removed.
+func (g *SocialGraph) InvalidateFollowCounts(ctx context.Context, userIDs ...int64) error {
+	if g.rdb == nil || len(userIDs) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		keys = append(keys, followCountsKey(id))
+	}
+
+	err := g.rdb.Del(ctx, keys...).Err()
+	if err != nil {
+		return fmt.Errorf("failed to invalidate follow counts: %v", err)
+	}
+
+	return nil
+}
+
+// Block
+//
+//	Blocks the other user. The friendship, pending friend requests and
+//	follows between the users are removed.
+func (g *SocialGraph) Block(ctx context.Context, span *trace.Span, callerName *string, userID int64, blockedID int64) error {
+	if userID == blockedID {
+		return fmt.Errorf("users cannot block themselves")
+	}
+
+	err := g.db.RunInTx(ctx, span, callerName, nil, func(ctx context.Context, tx *ti.Tx) error {
+		_, err := tx.ExecContext(ctx, callerName,
+			"insert ignore into user_blocks(user_id, blocked_id, created_at) values (?, ?, ?)",
+			userID, blockedID, time.Now(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert block: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, callerName,
+			"delete from friends where (user_id = ? and friend = ?) or (user_id = ? and friend = ?)",
+			userID, blockedID, blockedID, userID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to remove friendship: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, callerName,
+			"delete from friend_requests where response is null and ((user_id = ? and friend = ?) or (user_id = ? and friend = ?))",
+			userID, blockedID, blockedID, userID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to remove friend requests: %w", err)
+		}
+
+		// remove the follows in each direction and keep the follower count of the followed user in sync
+		for _, follow := range [][2]int64{{userID, blockedID}, {blockedID, userID}} {
+			res, err := tx.ExecContext(ctx, callerName,
+				"delete from follower where follower = ? and following = ?", follow[0], follow[1],
+			)
+			if err != nil {
+				return fmt.Errorf("failed to remove follow: %w", err)
+			}
+			removed, err := res.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("failed to remove follow: %w", err)
+			}
+			if removed == 0 {
+				continue
+			}
+			_, err = tx.ExecContext(ctx, callerName,
+				"update users set follower_count = follower_count - 1 where _id = ? and follower_count > 0", follow[1],
+			)
+			if err != nil {
+				return fmt.Errorf("failed to update follower count: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return g.InvalidateFollowCounts(ctx, userID, blockedID)
+}
+
+// Unblock
+//
+//	Removes the block of the other user. Removed friendships and follows
+//	are not restored.
+func (g *SocialGraph) Unblock(ctx context.Context, span *trace.Span, callerName *string, userID int64, blockedID int64) error {
+	_, err := g.db.ExecContext(ctx, span, callerName,
+		"delete from user_blocks where user_id = ? and blocked_id = ?", userID, blockedID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove block: %v", err)
+	}
+	return nil
+}
+
+// BlockedUsers
+//
+//	Returns the users blocked by the user ordered by name
+func (g *SocialGraph) BlockedUsers(ctx context.Context, span *trace.Span, callerName *string, userID int64) ([]*SocialUser, error) {
+	return g.querySocialUsers(ctx, span, callerName,
+		"select u._id, u.user_name from user_blocks b join users u on u._id = b.blocked_id where b.user_id = ? order by u.user_name",
+		userID,
+	)
+}
+
+// IsBlocked
+//
+//	Returns true if either user has blocked the other
+func (g *SocialGraph) IsBlocked(ctx context.Context, span *trace.Span, callerName *string, userID int64, otherID int64) (bool, error) {
+	var blocked bool
+	err := g.db.QueryRowContext(ctx, span, callerName,
+		"select exists(select 1 from user_blocks where (user_id = ? and blocked_id = ?) or (user_id = ? and blocked_id = ?))",
+		userID, otherID, otherID, userID,
+	).Scan(&blocked)
+	if err != nil {
+		return false, fmt.Errorf("failed to query block: %v", err)
+	}
+	return blocked, nil
+}
+
+// EnsureCanInteract
+//
+//	Returns ErrSocialBlocked if the actor cannot send the interaction to
+//	the target because either user has blocked the other. Must be checked
+//	before creating friend requests, nemesis requests and direct messages.
+func (g *SocialGraph) EnsureCanInteract(ctx context.Context, span *trace.Span, callerName *string, actorID int64, targetID int64, interaction SocialInteraction) error {
+	blocked, err := g.IsBlocked(ctx, span, callerName, actorID, targetID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return fmt.Errorf("%w: %s from %d to %d", ErrSocialBlocked, interaction, actorID, targetID)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+)
+
+func TestSocialGraph(t *testing.T) {
+	db, err := ti.CreateDatabase("gigo-dev-tidb", "4000", "mysql", "gigo-dev", "gigo-dev", "gigo_dev_test")
+	if err != nil {
+		t.Fatal("\nSocial Graph Failed\n    Error: ", err)
+	}
+
+	defer db.DB.Exec("delete from users where _id between 6942060 and 6942069")
+	defer db.DB.Exec("delete from friends where user_id between 6942060 and 6942069 or friend between 6942060 and 6942069")
+	defer db.DB.Exec("delete from friend_requests where user_id between 6942060 and 6942069")
+	defer db.DB.Exec("delete from follower where follower between 6942060 and 6942069")
+	defer db.DB.Exec("delete from user_blocks where user_id between 6942060 and 6942069")
+
+	// 60 is friends with 61 and 62, who are both friends with 63; 64 is a
+	// friend of 61 only and 65 is a friend of 62 that blocked 60
+	users := []int64{6942060, 6942061, 6942062, 6942063, 6942064, 6942065}
+	for _, id := range users {
+		user, err := CreateUser(id, fmt.Sprintf("test%d", id), "testpass", "testemail@email.com",
+			"phone", UserStatusBasic, "test", []int64{1, 2}, []int64{1, 2, 3},
+			"first", "last", 23, "", DefaultUserStart, "America/Chicago",
+			AvatarSettings{}, 0)
+		if err != nil {
+			t.Fatal("\nSocial Graph Failed\n    Error: ", err)
+		}
+
+		statements, err := user.ToSQLNative()
+		if err != nil {
+			t.Fatal("\nSocial Graph Failed\n    Error: ", err)
+		}
+		for _, statement := range statements {
+			_, err = db.DB.Exec(statement.Statement, statement.Values...)
+			if err != nil {
+				t.Fatal("\nSocial Graph Failed\n    Error: ", err)
+			}
+		}
+	}
+
+	edges := [][2]int64{
+		{6942060, 6942061},
+		{6942062, 6942060},
+		{6942061, 6942063},
+		{6942063, 6942062},
+		{6942061, 6942064},
+		{6942062, 6942065},
+	}
+	for i, edge := range edges {
+		friend, err := CreateFriends(int64(6942060+i), edge[0], "test", edge[1], "test", time.Now())
+		if err != nil {
+			t.Fatal("\nSocial Graph Failed\n    Error: ", err)
+		}
+		statement := friend.ToSQLNative()
+		_, err = db.DB.Exec(statement.Statement, statement.Values...)
+		if err != nil {
+			t.Fatal("\nSocial Graph Failed\n    Error: ", err)
+		}
+	}
+
+	_, err = db.DB.Exec("insert into follower(follower, following) values (6942065, 6942060), (6942061, 6942060)")
+	if err != nil {
+		t.Fatal("\nSocial Graph Failed\n    Error: ", err)
+	}
+
+	graph, err := NewSocialGraph(SocialGraphOptions{DB: db})
+	if err != nil {
+		t.Fatal("\nSocial Graph Failed\n    Error: ", err)
+	}
+
+	mutual, err := graph.MutualFriends(context.TODO(), nil, nil, 6942060, 6942063, 10)
+	if err != nil {
+		t.Fatal("\nSocial Graph Failed\n    Error: ", err)
+	}
+	if len(mutual) != 2 || mutual[0].ID != 6942061 || mutual[1].ID != 6942062 {
+		t.Fatalf("\nSocial Graph Failed\n    Error: wrong mutual friends %+v", mutual)
+	}
+
+	err = graph.Block(context.TODO(), nil, nil, 6942065, 6942060)
+	if err != nil {
+		t.Fatal("\nSocial Graph Failed\n    Error: ", err)
+	}
+
+	suggestions, err := graph.FriendSuggestions(context.TODO(), nil, nil, 6942060, 10)
+	if err != nil {
+		t.Fatal("\nSocial Graph Failed\n    Error: ", err)
+	}
+	if len(suggestions) != 2 || suggestions[0].ID != 6942063 || suggestions[0].Mutual != 2 || suggestions[1].ID != 6942064 || suggestions[1].Mutual != 1 {
+		t.Fatalf("\nSocial Graph Failed\n    Error: wrong suggestions %+v", suggestions)
+	}
+
+	counts, err := graph.FollowCounts(context.TODO(), nil, nil, 6942060)
+	if err != nil {
+		t.Fatal("\nSocial Graph Failed\n    Error: ", err)
+	}
+	if counts.Followers != 1 || counts.Following != 0 {
+		t.Fatalf("\nSocial Graph Failed\n    Error: block did not remove the follow %+v", counts)
+	}
+
+	err = graph.EnsureCanInteract(context.TODO(), nil, nil, 6942060, 6942065, SocialFriendRequest)
+	if !errors.Is(err, ErrSocialBlocked) {
+		t.Fatal("\nSocial Graph Failed\n    Error: blocked friend request was allowed ", err)
+	}
+
+	blocked, err := graph.BlockedUsers(context.TODO(), nil, nil, 6942065)
+	if err != nil {
+		t.Fatal("\nSocial Graph Failed\n    Error: ", err)
+	}
+	if len(blocked) != 1 || blocked[0].ID != 6942060 {
+		t.Fatalf("\nSocial Graph Failed\n    Error: wrong blocked users %+v", blocked)
+	}
+
+	err = graph.Unblock(context.TODO(), nil, nil, 6942065, 6942060)
+	if err != nil {
+		t.Fatal("\nSocial Graph Failed\n    Error: ", err)
+	}
+
+	err = graph.EnsureCanInteract(context.TODO(), nil, nil, 6942060, 6942065, SocialDirectMessage)
+	if err != nil {
+		t.Fatal("\nSocial Graph Failed\n    Error: ", err)
+	}
+
+	t.Log("\nSocial Graph Succeeded")
+}
@@ -0,0 +1,359 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultStreakMinDailyUsage is the time a user must spend on the platform
+// in a day for the day to count towards their streak when the rules do not
+// set a minimum
+const DefaultStreakMinDailyUsage = time.Minute * 30
+
+// StreakRules
+//
+//	Rules that determine how usage is converted into a streak
+type StreakRules struct {
+	// MinDailyUsage is the time a user must spend on the platform in
+	// a day for the day to count towards the streak; defaults to
+	// DefaultStreakMinDailyUsage
+	MinDailyUsage time.Duration
+}
+
+// StreakState
+//
+//	Streak of a user computed from their usage
+type StreakState struct {
+	StreakActive     bool
+	CurrentStreak    int
+	LongestStreak    int
+	DaysOnFire       int
+	DaysOnPlatform   int
+	StreakFreezes    int
+	StreakFreezeUsed bool
+	// FreezesConsumed is the number of freezes used to preserve the streak
+	FreezesConsumed int
+	TotalTimeSpent  time.Duration
+	AvgTime         time.Duration
+	// DayStart and NextRollover bound the user's current day
+	DayStart     time.Time
+	NextRollover time.Time
+}
+
+// StreakEngine
+//
+//	Computes streaks from the daily usage of a user. Days are bounded by
+//	midnight in the user's timezone so a day can be 23 or 25 hours long
+//	when the timezone changes to or from daylight saving time. The engine
+//	does not access the database; callers load and store the UserStats.
+//
+//	A day counts towards the streak once the user's usage in the day
+//	reaches the minimum daily usage. When a day closes without reaching
+//	the minimum a streak freeze is consumed to preserve the streak, and
+//	the streak is reset if no freezes remain. The current day never breaks
+//	the streak since the user can still reach the minimum before it closes.
+type StreakEngine struct {
+	location *time.Location
+	rules    StreakRules
+}
+
+// NewStreakEngine
+//
+//	Creates a new StreakEngine for the passed IANA timezone. An empty
+//	timezone uses UTC.
+func NewStreakEngine(timezone string, rules StreakRules) (*StreakEngine, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load timezone %q: %v", timezone, err)
+	}
+
+	if rules.MinDailyUsage <= 0 {
+		rules.MinDailyUsage = DefaultStreakMinDailyUsage
+	}
+
+	return &StreakEngine{
+		location: location,
+		rules:    rules,
+	}, nil
+}
+
+// DayBounds
+//
+//	Returns the start of the day containing t and the start of the
+//	following day in the user's timezone
+func (e *StreakEngine) DayBounds(t time.Time) (time.Time, time.Time) {
+	year, month, day := t.In(e.location).Date()
+	return e.dateBounds(year, month, day)
+}
+
+// dateBounds
+//
+//	Returns the start of the calendar date and the start of the following
+//	calendar date in the user's timezone. The following date is derived
+//	from the calendar date rather than from the start of the day so the
+//	bounds always advance by exactly one date.
+func (e *StreakEngine) dateBounds(year int, month time.Month, day int) (time.Time, time.Time) {
+	return e.dayStart(year, month, day), e.dayStart(year, month, day+1)
+}
+
+// dayStart
+//
+//	Returns the first instant of the calendar date in the user's timezone.
+//	In timezones that skip midnight when changing to daylight saving time
+//	the day starts at the transition rather than at midnight, which
+//	time.Date would otherwise resolve to 23:00 of the previous day.
+func (e *StreakEngine) dayStart(year int, month time.Month, day int) time.Time {
+	// normalize overflowing days into a calendar date
+	year, month, day = time.Date(year, month, day, 12, 0, 0, 0, time.UTC).Date()
+
+	start := time.Date(year, month, day, 0, 0, 0, 0, e.location)
+	if y, m, d := start.Date(); y == year && m == month && d == day {
+		return start
+	}
+
+	// midnight does not exist so the date starts when the zone of the
+	// resolved time ends
+	_, end := start.ZoneBounds()
+	if end.After(start) {
+		return end
+	}
+	return start
+}
+
+// NextRollover
+//
+//	Returns the time at which the day containing t closes
+func (e *StreakEngine) NextRollover(t time.Time) time.Time {
+	_, end := e.DayBounds(t)
+	return end
+}
+
+// DayUsage
+//
+//	Returns the time spent in the intervals between the start and end.
+//	Intervals that are still open are counted until the end.
+func DayUsage(intervals []*DailyUsage, start time.Time, end time.Time) time.Duration {
+	var total time.Duration
+	for _, interval := range intervals {
+		from := interval.StartTime
+		to := end
+		if interval.EndTime != nil && interval.EndTime.Before(end) {
+			to = *interval.EndTime
+		}
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(from) {
+			total += to.Sub(from)
+		}
+	}
+	return total
+}
+
+// NewDay
+//
+//	Creates the stats of the day containing t for a user without any
+//	previous stats
+func (e *StreakEngine) NewDay(id int64, userID int64, freezes int, t time.Time) *UserStats {
+	start, end := e.DayBounds(t)
+	return &UserStats{
+		ID:             id,
+		UserID:         userID,
+		StreakFreezes:  freezes,
+		DailyIntervals: make([]*DailyUsage, 0),
+		Date:           start,
+		Expiration:     end,
+	}
+}
+
+// Evaluate
+//
+//	Updates the streak of the day with the usage recorded until now.
+//	The streak advances the first time the day's usage reaches the
+//	minimum daily usage. Returns true if the streak advanced.
+func (e *StreakEngine) Evaluate(stats *UserStats, now time.Time) bool {
+	if stats.StreakActive || stats.Closed {
+		return false
+	}
+
+	end := stats.Expiration
+	if now.Before(end) {
+		end = now
+	}
+	if DayUsage(stats.DailyIntervals, stats.Date, end) < e.rules.MinDailyUsage {
+		return false
+	}
+
+	stats.StreakActive = true
+	stats.CurrentStreak++
+	stats.DaysOnFire++
+	if stats.CurrentStreak > stats.LongestStreak {
+		stats.LongestStreak = stats.CurrentStreak
+	}
+	return true
+}
+
+// Rollover
+//
+//	Closes the day and returns the stats of the following day. The
+//	closing day is evaluated, a freeze is consumed or the streak is reset
+//	if the day did not count towards the streak and the day's usage is
+//	added to the totals. Sessions that are still open when the day closes
+//	are split at the rollover so that each day only holds its own usage.
+func (e *StreakEngine) Rollover(stats *UserStats, nextID int64) *UserStats {
+	year, month, day := stats.Date.In(e.location).Date()
+	start, end := e.dateBounds(year, month, day)
+
+	e.Evaluate(stats, end)
+	if !stats.StreakActive && stats.CurrentStreak > 0 {
+		if stats.StreakFreezes > 0 {
+			stats.StreakFreezes--
+			stats.StreakFreezeUsed = true
+		} else {
+			stats.CurrentStreak = 0
+		}
+	}
+
+	// move the part of each interval after the rollover to the next day
+	closing := make([]*DailyUsage, 0, len(stats.DailyIntervals))
+	next := make([]*DailyUsage, 0)
+	for _, interval := range stats.DailyIntervals {
+		if !interval.StartTime.Before(end) {
+			next = append(next, interval)
+			continue
+		}
+		if interval.EndTime == nil || interval.EndTime.After(end) {
+			rollover := end
+			next = append(next, &DailyUsage{
+				StartTime:   end,
+				EndTime:     interval.EndTime,
+				OpenSession: interval.OpenSession,
+			})
+			interval = &DailyUsage{
+				StartTime: interval.StartTime,
+				EndTime:   &rollover,
+			}
+		}
+		closing = append(closing, interval)
+	}
+
+	usage := DayUsage(closing, start, end)
+	if usage > 0 {
+		stats.DaysOnPlatform++
+		stats.TotalTimeSpent += usage
+	}
+	if stats.DaysOnPlatform > 0 {
+		stats.AvgTime = stats.TotalTimeSpent / time.Duration(stats.DaysOnPlatform)
+	}
+	stats.DailyIntervals = closing
+	stats.Closed = true
+
+	nextStart, nextEnd := e.dateBounds(year, month, day+1)
+	return &UserStats{
+		ID:                  nextID,
+		UserID:              stats.UserID,
+		ChallengesCompleted: stats.ChallengesCompleted,
+		CurrentStreak:       stats.CurrentStreak,
+		LongestStreak:       stats.LongestStreak,
+		TotalTimeSpent:      stats.TotalTimeSpent,
+		AvgTime:             stats.AvgTime,
+		DailyIntervals:      next,
+		DaysOnPlatform:      stats.DaysOnPlatform,
+		DaysOnFire:          stats.DaysOnFire,
+		StreakFreezes:       stats.StreakFreezes,
+		Date:                nextStart,
+		Expiration:          nextEnd,
+	}
+}
+
+// Rebuild
+//
+//	Rebuilds the stats history of a user from their raw usage. A row is
+//	created for every day from the first day with usage until the day
+//	containing now; every row except the last is closed. The freezes are
+//	the user's inventory at the start of the history. Challenges and XP
+//	cannot be derived from usage and are left empty.
+func (e *StreakEngine) Rebuild(userID int64, usage []*DailyUsage, freezes int, now time.Time, nextID func() int64) []*UserStats {
+	intervals := make([]*DailyUsage, 0, len(usage))
+	for _, interval := range usage {
+		if interval.StartTime.Before(now) {
+			intervals = append(intervals, interval)
+		}
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].StartTime.Before(intervals[j].StartTime)
+	})
+
+	first := now
+	if len(intervals) > 0 {
+		first = intervals[0].StartTime
+	}
+
+	history := make([]*UserStats, 0)
+	day := e.NewDay(nextID(), userID, freezes, first)
+	for {
+		// assign the intervals that start before the day closes
+		for len(intervals) > 0 && intervals[0].StartTime.Before(day.Expiration) {
+			day.DailyIntervals = append(day.DailyIntervals, intervals[0])
+			intervals = intervals[1:]
+		}
+
+		history = append(history, day)
+		if now.Before(day.Expiration) {
+			e.Evaluate(day, now)
+			return history
+		}
+
+		day = e.Rollover(day, nextID())
+	}
+}
+
+// Compute
+//
+//	Computes the current streak of a user from their raw usage
+func (e *StreakEngine) Compute(usage []*DailyUsage, freezes int, now time.Time) *StreakState {
+	history := e.Rebuild(0, usage, freezes, now, func() int64 { return 0 })
+
+	consumed := 0
+	for _, day := range history {
+		if day.StreakFreezeUsed {
+			consumed++
+		}
+	}
+
+	current := history[len(history)-1]
+
+	// include the usage of the current day in the totals
+	totalTime := current.TotalTimeSpent
+	daysOnPlatform := current.DaysOnPlatform
+	if today := DayUsage(current.DailyIntervals, current.Date, now); today > 0 {
+		totalTime += today
+		daysOnPlatform++
+	}
+	var avgTime time.Duration
+	if daysOnPlatform > 0 {
+		avgTime = totalTime / time.Duration(daysOnPlatform)
+	}
+
+	// a freeze used on the previous day is still reflected in the current streak
+	freezeUsed := false
+	if len(history) > 1 {
+		freezeUsed = history[len(history)-2].StreakFreezeUsed
+	}
+
+	return &StreakState{
+		StreakActive:     current.StreakActive,
+		CurrentStreak:    current.CurrentStreak,
+		LongestStreak:    current.LongestStreak,
+		DaysOnFire:       current.DaysOnFire,
+		DaysOnPlatform:   daysOnPlatform,
+		StreakFreezes:    current.StreakFreezes,
+		StreakFreezeUsed: freezeUsed,
+		FreezesConsumed:  consumed,
+		TotalTimeSpent:   totalTime,
+		AvgTime:          avgTime,
+		DayStart:         current.Date,
+		NextRollover:     current.Expiration,
+	}
+}
@@ -0,0 +1,225 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func testStreakUsage(start time.Time, length time.Duration) *DailyUsage {
+	end := start.Add(length)
+	return &DailyUsage{StartTime: start, EndTime: &end}
+}
+
+func TestStreakEngine_DayBounds(t *testing.T) {
+	engine, err := NewStreakEngine("America/New_York", StreakRules{})
+	if err != nil {
+		t.Fatal("\nStreak Day Bounds Failed\n    Error: ", err)
+	}
+
+	loc, _ := time.LoadLocation("America/New_York")
+
+	tests := []struct {
+		name   string
+		t      time.Time
+		length time.Duration
+	}{
+		{"standard", time.Date(2023, 6, 1, 12, 0, 0, 0, loc), time.Hour * 24},
+		{"spring forward", time.Date(2023, 3, 12, 12, 0, 0, 0, loc), time.Hour * 23},
+		{"fall back", time.Date(2023, 11, 5, 12, 0, 0, 0, loc), time.Hour * 25},
+	}
+
+	for _, test := range tests {
+		start, end := engine.DayBounds(test.t)
+		if start.Hour() != 0 || start.Day() != test.t.Day() {
+			t.Fatalf("\nStreak Day Bounds Failed\n    Error: %s: wrong start %v", test.name, start)
+		}
+		if end.Sub(start) != test.length {
+			t.Fatalf("\nStreak Day Bounds Failed\n    Error: %s: day is %v long", test.name, end.Sub(start))
+		}
+		if !engine.NextRollover(test.t).Equal(end) {
+			t.Fatalf("\nStreak Day Bounds Failed\n    Error: %s: wrong rollover", test.name)
+		}
+	}
+
+	// the bounds are computed in the user's timezone regardless of the input's location
+	start, _ := engine.DayBounds(time.Date(2023, 6, 2, 3, 0, 0, 0, time.UTC))
+	if !start.Equal(time.Date(2023, 6, 1, 0, 0, 0, 0, loc)) {
+		t.Fatalf("\nStreak Day Bounds Failed\n    Error: wrong start for utc input %v", start)
+	}
+
+	t.Log("\nStreak Day Bounds Succeeded")
+}
+
+func TestStreakEngine_Compute(t *testing.T) {
+	engine, err := NewStreakEngine("America/Chicago", StreakRules{MinDailyUsage: time.Minute * 30})
+	if err != nil {
+		t.Fatal("\nStreak Compute Failed\n    Error: ", err)
+	}
+
+	loc, _ := time.LoadLocation("America/Chicago")
+	day := func(d int, hour int) time.Time {
+		return time.Date(2023, 3, d, hour, 0, 0, 0, loc)
+	}
+
+	usage := []*DailyUsage{
+		// three qualifying days across the daylight saving change on the 12th
+		testStreakUsage(day(10, 9), time.Hour),
+		testStreakUsage(day(11, 9), time.Hour),
+		testStreakUsage(day(12, 9), time.Hour),
+		// the 13th is missed and covered by a freeze
+		// a session spanning midnight qualifies the 14th and the 15th
+		testStreakUsage(day(14, 23), time.Hour*2),
+		// too short to qualify the 16th; the freezes are exhausted so the streak resets
+		testStreakUsage(day(16, 9), time.Minute*10),
+		testStreakUsage(day(17, 9), time.Hour),
+	}
+
+	state := engine.Compute(usage, 1, day(17, 20))
+
+	if state.CurrentStreak != 1 || !state.StreakActive {
+		t.Fatalf("\nStreak Compute Failed\n    Error: wrong current streak %+v", state)
+	}
+	if state.LongestStreak != 5 {
+		t.Fatalf("\nStreak Compute Failed\n    Error: wrong longest streak %+v", state)
+	}
+	if state.StreakFreezes != 0 || state.FreezesConsumed != 1 {
+		t.Fatalf("\nStreak Compute Failed\n    Error: wrong freezes %+v", state)
+	}
+	if state.DaysOnFire != 6 || state.DaysOnPlatform != 7 {
+		t.Fatalf("\nStreak Compute Failed\n    Error: wrong day counts %+v", state)
+	}
+	if state.TotalTimeSpent != time.Hour*6+time.Minute*10 {
+		t.Fatalf("\nStreak Compute Failed\n    Error: wrong total time %+v", state)
+	}
+	if !state.NextRollover.Equal(day(18, 0)) {
+		t.Fatalf("\nStreak Compute Failed\n    Error: wrong rollover %v", state.NextRollover)
+	}
+
+	// the current day does not break the streak before it closes
+	state = engine.Compute(usage[:3], 0, day(13, 8))
+	if state.CurrentStreak != 3 || state.StreakActive {
+		t.Fatalf("\nStreak Compute Failed\n    Error: current day broke the streak %+v", state)
+	}
+
+	t.Log("\nStreak Compute Succeeded")
+}
+
+func TestStreakEngine_Rebuild(t *testing.T) {
+	engine, err := NewStreakEngine("Europe/London", StreakRules{})
+	if err != nil {
+		t.Fatal("\nStreak Rebuild Failed\n    Error: ", err)
+	}
+
+	loc, _ := time.LoadLocation("Europe/London")
+	start := time.Date(2023, 3, 25, 23, 45, 0, 0, loc)
+
+	// an open session that started before the daylight saving change
+	usage := []*DailyUsage{{StartTime: start, OpenSession: 1}}
+
+	id := int64(0)
+	history := engine.Rebuild(69, usage, 0, time.Date(2023, 3, 27, 12, 0, 0, 0, loc), func() int64 {
+		id++
+		return id
+	})
+
+	if len(history) != 3 {
+		t.Fatalf("\nStreak Rebuild Failed\n    Error: expected 3 days, got %d", len(history))
+	}
+
+	lengths := []time.Duration{time.Minute * 15, time.Hour * 23, 0}
+	for i, day := range history {
+		if day.ID != int64(i+1) || day.UserID != 69 {
+			t.Fatalf("\nStreak Rebuild Failed\n    Error: wrong ids on day %d", i)
+		}
+		if day.Closed != (i < 2) {
+			t.Fatalf("\nStreak Rebuild Failed\n    Error: wrong closed state on day %d", i)
+		}
+		if i < 2 && DayUsage(day.DailyIntervals, day.Date, day.Expiration) != lengths[i] {
+			t.Fatalf("\nStreak Rebuild Failed\n    Error: wrong usage on day %d", i)
+		}
+		if i > 0 && !day.Date.Equal(history[i-1].Expiration) {
+			t.Fatalf("\nStreak Rebuild Failed\n    Error: day %d does not start at the previous rollover", i)
+		}
+	}
+
+	// the open session is carried into the current day
+	current := history[2]
+	if len(current.DailyIntervals) != 1 || current.DailyIntervals[0].EndTime != nil || current.DailyIntervals[0].OpenSession != 1 {
+		t.Fatalf("\nStreak Rebuild Failed\n    Error: open session was not carried %+v", current.DailyIntervals)
+	}
+
+	if current.CurrentStreak != 2 || !current.StreakActive || history[0].StreakActive || !history[1].StreakActive {
+		t.Fatalf("\nStreak Rebuild Failed\n    Error: wrong streak %+v", current)
+	}
+
+	t.Log("\nStreak Rebuild Succeeded")
+}
+
+func TestStreakEngine_SkippedMidnight(t *testing.T) {
+	// timezones that changed to daylight saving time at midnight so the
+	// day started at 01:00
+	tests := []struct {
+		timezone string
+		date     time.Time
+	}{
+		{"America/Sao_Paulo", time.Date(2018, 11, 4, 0, 0, 0, 0, time.UTC)},
+		{"America/Havana", time.Date(2018, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{"America/Santiago", time.Date(2018, 8, 12, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		engine, err := NewStreakEngine(test.timezone, StreakRules{})
+		if err != nil {
+			t.Fatal("\nStreak Skipped Midnight Failed\n    Error: ", err)
+		}
+
+		loc, _ := time.LoadLocation(test.timezone)
+		year, month, day := test.date.Date()
+
+		start, end := engine.DayBounds(time.Date(year, month, day, 12, 0, 0, 0, loc))
+		if !start.Equal(time.Date(year, month, day, 1, 0, 0, 0, loc)) || start.Day() != day || start.Hour() != 1 {
+			t.Fatalf("\nStreak Skipped Midnight Failed\n    Error: %s: wrong start %v", test.timezone, start)
+		}
+		if end.Sub(start) != time.Hour*23 {
+			t.Fatalf("\nStreak Skipped Midnight Failed\n    Error: %s: day is %v long", test.timezone, end.Sub(start))
+		}
+
+		// the previous day closes when the skipped day starts
+		_, previousEnd := engine.DayBounds(time.Date(year, month, day-1, 12, 0, 0, 0, loc))
+		if !previousEnd.Equal(start) {
+			t.Fatalf("\nStreak Skipped Midnight Failed\n    Error: %s: previous day closes at %v", test.timezone, previousEnd)
+		}
+
+		// two days of usage around the change rebuild into three days
+		usage := []*DailyUsage{
+			testStreakUsage(time.Date(year, month, day-1, 20, 0, 0, 0, loc), time.Hour),
+			testStreakUsage(time.Date(year, month, day, 20, 0, 0, 0, loc), time.Hour),
+		}
+		id := int64(0)
+		history := engine.Rebuild(69, usage, 0, time.Date(year, month, day+1, 12, 0, 0, 0, loc), func() int64 {
+			id++
+			if id > 10 {
+				t.Fatalf("\nStreak Skipped Midnight Failed\n    Error: %s: rebuild did not advance", test.timezone)
+			}
+			return id
+		})
+		if len(history) != 3 {
+			t.Fatalf("\nStreak Skipped Midnight Failed\n    Error: %s: expected 3 days, got %d", test.timezone, len(history))
+		}
+		for i, stats := range history {
+			if stats.Date.Day() != day-1+i {
+				t.Fatalf("\nStreak Skipped Midnight Failed\n    Error: %s: day %d starts on %v", test.timezone, i, stats.Date)
+			}
+			if i > 0 && !stats.Date.Equal(history[i-1].Expiration) {
+				t.Fatalf("\nStreak Skipped Midnight Failed\n    Error: %s: day %d does not start at the previous rollover", test.timezone, i)
+			}
+		}
+
+		state := engine.Compute(usage, 0, time.Date(year, month, day+1, 12, 0, 0, 0, loc))
+		if state.CurrentStreak != 2 || state.DaysOnPlatform != 2 {
+			t.Fatalf("\nStreak Skipped Midnight Failed\n    Error: %s: wrong streak %+v", test.timezone, state)
+		}
+	}
+
+	t.Log("\nStreak Skipped Midnight Succeeded")
+}
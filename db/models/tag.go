@@ -86,3 +86,17 @@ func (t *Tag) ToSQLNative() []*SQLInsertStatement {
 		},
 	}
 }
+
+// PrimaryKey
+//
+//	Returns the id of the tag; implements RepositoryModel
+func (t *Tag) PrimaryKey() int64 {
+	return t.ID
+}
+
+// InsertStatements
+//
+//	Returns the insert statements of the tag; implements RepositoryModel
+func (t *Tag) InsertStatements() ([]*SQLInsertStatement, error) {
+	return t.ToSQLNative(), nil
+}
@@ -397,3 +397,17 @@ func (w *Workspace) ToSQLNative() ([]*SQLInsertStatement, error) {
 		},
 	}, nil
 }
+
+// PrimaryKey
+//
+//	Returns the id of the workspace; implements RepositoryModel
+func (w *Workspace) PrimaryKey() int64 {
+	return w.ID
+}
+
+// InsertStatements
+//
+//	Returns the insert statements of the workspace; implements RepositoryModel
+func (w *Workspace) InsertStatements() ([]*SQLInsertStatement, error) {
+	return w.ToSQLNative()
+}
@@ -89,3 +89,17 @@ func (a *WorkspaceAgent) ToSQLNative() []*SQLInsertStatement {
 		},
 	}
 }
+
+// PrimaryKey
+//
+//	Returns the id of the workspace agent; implements RepositoryModel
+func (a *WorkspaceAgent) PrimaryKey() int64 {
+	return a.ID
+}
+
+// InsertStatements
+//
+//	Returns the insert statements of the workspace agent; implements RepositoryModel
+func (a *WorkspaceAgent) InsertStatements() ([]*SQLInsertStatement, error) {
+	return a.ToSQLNative(), nil
+}
@@ -0,0 +1,304 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+)
+
+var (
+	// ErrIllegalWorkspaceTransition is returned when a transition is not
+	// permitted from the workspace's current state
+	ErrIllegalWorkspaceTransition = errors.New("illegal workspace transition")
+	// ErrWorkspaceTransitionGuard is returned when a guard rejects a transition
+	// that would otherwise be legal
+	ErrWorkspaceTransitionGuard = errors.New("workspace transition rejected by guard")
+	// ErrWorkspaceStateConflict is returned when the workspace was modified
+	// by another process between being loaded and being transitioned
+	ErrWorkspaceStateConflict = errors.New("workspace state conflict")
+)
+
+// WorkspaceTransitionError
+//
+//	Error returned by the WorkspaceStateMachine when a transition fails.
+//	The Err field holds one of ErrIllegalWorkspaceTransition,
+//	ErrWorkspaceTransitionGuard or ErrWorkspaceStateConflict so callers
+//	can use errors.Is to determine the cause.
+type WorkspaceTransitionError struct {
+	WorkspaceID int64
+	From        string
+	To          string
+	Err         error
+	// Reason is the error returned by a rejecting guard
+	Reason error
+}
+
+func (e *WorkspaceTransitionError) Error() string {
+	if e.Reason != nil {
+		return fmt.Sprintf("%v: workspace %d %s -> %s: %v", e.Err, e.WorkspaceID, e.From, e.To, e.Reason)
+	}
+	return fmt.Sprintf("%v: workspace %d %s -> %s", e.Err, e.WorkspaceID, e.From, e.To)
+}
+
+func (e *WorkspaceTransitionError) Unwrap() error {
+	return e.Err
+}
+
+// workspaceTransitions defines every legal transition of the workspace state
+var workspaceTransitions = map[WorkspaceState][]WorkspaceState{
+	WorkspaceStarting:  {WorkspaceActive, WorkspaceStopping, WorkspaceRemoving, WorkspaceFailed},
+	WorkspaceActive:    {WorkspaceStopping, WorkspaceRemoving, WorkspaceFailed},
+	WorkspaceStopping:  {WorkspaceSuspended, WorkspaceRemoving, WorkspaceFailed},
+	WorkspaceSuspended: {WorkspaceStarting, WorkspaceRemoving},
+	WorkspaceRemoving:  {WorkspaceDeleted, WorkspaceFailed},
+	WorkspaceFailed:    {WorkspaceStarting, WorkspaceRemoving},
+	WorkspaceDeleted:   {},
+}
+
+// workspaceInitOrder defines the order of the workspace initialization steps.
+// Reading an existing workspace config replaces writing a new one so both
+// steps share the same position.
+var workspaceInitOrder = map[WorkspaceInitState]int{
+	WorkspaceInitProvisioning:                0,
+	WorkspaceInitRemoteInitialization:        1,
+	WorkspaceInitWriteGitConfig:              2,
+	WorkspaceInitWriteWorkspaceConfig:        3,
+	WorkspaceInitReadExistingWorkspaceConfig: 3,
+	WorkspaceInitGitClone:                    4,
+	WorkspaceInitGitCheckout:                 5,
+	WorkspaceInitCreateContainerDirectory:    6,
+	WorkspaceInitWriteContainerCompose:       7,
+	WorkspaceInitContainerComposeUp:          8,
+	WorkspaceInitVSCodeInstall:               9,
+	WorkspaceInitVSCodeExtensionInstall:      10,
+	WorkspaceInitShellExecutions:             11,
+	WorkspaceInitVSCodeLaunch:                12,
+	WorkspaceInitCompleted:                   13,
+}
+
+// CanTransitionTo
+//
+//	Returns whether the workspace state machine permits a
+//	transition from this state to the passed state.
+func (w WorkspaceState) CanTransitionTo(to WorkspaceState) bool {
+	for _, s := range workspaceTransitions[w] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransitionTo
+//
+//	Returns whether the workspace state machine permits a transition
+//	from this initialization state to the passed state. Initialization
+//	may only move forward or restart from provisioning.
+func (w WorkspaceInitState) CanTransitionTo(to WorkspaceInitState) bool {
+	fromPos, ok := workspaceInitOrder[w]
+	if !ok {
+		return false
+	}
+	toPos, ok := workspaceInitOrder[to]
+	if !ok {
+		return false
+	}
+	return to == WorkspaceInitProvisioning || toPos > fromPos
+}
+
+// WorkspaceTransitionGuard
+//
+//	Guard executed before a workspace transition. Returning
+//	an error rejects the transition.
+type WorkspaceTransitionGuard func(ctx context.Context, workspace *Workspace, to WorkspaceState) error
+
+// WorkspaceStatusPublisher
+//
+//	Publishes the status of a workspace after a successful transition.
+//	The models package cannot depend on the message queue so the
+//	implementation is provided by the caller (see mq.WorkspaceStatusPublisher).
+type WorkspaceStatusPublisher interface {
+	PublishWorkspaceStatus(ctx context.Context, workspace *Workspace) error
+}
+
+type workspaceTransition struct {
+	from WorkspaceState
+	to   WorkspaceState
+}
+
+// WorkspaceStateMachine
+//
+//	Validates and performs workspace state transitions. Transitions
+//	are written with a conditional update on the current state so
+//	that concurrent writers cannot silently overwrite each other.
+type WorkspaceStateMachine struct {
+	db        *ti.Database
+	publisher WorkspaceStatusPublisher
+	guards    map[workspaceTransition][]WorkspaceTransitionGuard
+}
+
+// NewWorkspaceStateMachine
+//
+//	Creates a new WorkspaceStateMachine with the default guards.
+//	The publisher may be nil to skip publishing status updates.
+func NewWorkspaceStateMachine(db *ti.Database, publisher WorkspaceStatusPublisher) *WorkspaceStateMachine {
+	m := &WorkspaceStateMachine{
+		db:        db,
+		publisher: publisher,
+		guards:    make(map[workspaceTransition][]WorkspaceTransitionGuard),
+	}
+
+	// a workspace cannot become active until initialization has completed
+	m.AddGuard(WorkspaceStarting, WorkspaceActive, func(ctx context.Context, workspace *Workspace, to WorkspaceState) error {
+		if workspace.InitState != WorkspaceInitCompleted {
+			return fmt.Errorf("initialization has not completed: %s", workspace.InitState)
+		}
+		return nil
+	})
+
+	return m
+}
+
+// AddGuard
+//
+//	Adds a guard that is executed before every transition between
+//	the passed states. Guards for illegal transitions are never run.
+func (m *WorkspaceStateMachine) AddGuard(from WorkspaceState, to WorkspaceState, guard WorkspaceTransitionGuard) {
+	key := workspaceTransition{from: from, to: to}
+	m.guards[key] = append(m.guards[key], guard)
+}
+
+// Transition
+//
+//	Transitions the workspace to the passed state. The transition is
+//	validated against the legal transitions and guards and then written
+//	conditionally on the workspace still being in its loaded state. On
+//	success the passed workspace is updated and its status is published.
+func (m *WorkspaceStateMachine) Transition(ctx context.Context, workspace *Workspace, to WorkspaceState) error {
+	from := workspace.State
+
+	if !from.CanTransitionTo(to) {
+		return &WorkspaceTransitionError{
+			WorkspaceID: workspace.ID,
+			From:        from.String(),
+			To:          to.String(),
+			Err:         ErrIllegalWorkspaceTransition,
+		}
+	}
+
+	for _, guard := range m.guards[workspaceTransition{from: from, to: to}] {
+		err := guard(ctx, workspace, to)
+		if err != nil {
+			return &WorkspaceTransitionError{
+				WorkspaceID: workspace.ID,
+				From:        from.String(),
+				To:          to.String(),
+				Err:         ErrWorkspaceTransitionGuard,
+				Reason:      err,
+			}
+		}
+	}
+
+	now := time.Now()
+
+	callerName := "WorkspaceStateMachineTransition"
+	res, err := m.db.ExecContext(ctx, nil, &callerName,
+		"update workspaces set state = ?, last_state_update = ? where _id = ? and state = ?",
+		to, now, workspace.ID, from,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update workspace state: %v", err)
+	}
+
+	err = m.checkConflict(res.RowsAffected, workspace.ID, from.String(), to.String())
+	if err != nil {
+		return err
+	}
+
+	workspace.State = to
+	workspace.LastStateUpdate = now
+
+	return m.publish(ctx, workspace)
+}
+
+// TransitionInitState
+//
+//	Transitions the initialization state of a workspace. Initialization
+//	can only progress while the workspace is starting. The update is
+//	conditional on both the state and the initialization state of the
+//	loaded workspace.
+func (m *WorkspaceStateMachine) TransitionInitState(ctx context.Context, workspace *Workspace, to WorkspaceInitState) error {
+	from := workspace.InitState
+
+	if workspace.State != WorkspaceStarting || !from.CanTransitionTo(to) {
+		return &WorkspaceTransitionError{
+			WorkspaceID: workspace.ID,
+			From:        fmt.Sprintf("%s/%s", workspace.State, from),
+			To:          fmt.Sprintf("%s/%s", workspace.State, to),
+			Err:         ErrIllegalWorkspaceTransition,
+		}
+	}
+
+	now := time.Now()
+
+	callerName := "WorkspaceStateMachineTransitionInitState"
+	res, err := m.db.ExecContext(ctx, nil, &callerName,
+		"update workspaces set init_state = ?, last_state_update = ? where _id = ? and state = ? and init_state = ?",
+		to, now, workspace.ID, workspace.State, from,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update workspace init state: %v", err)
+	}
+
+	err = m.checkConflict(res.RowsAffected, workspace.ID, from.String(), to.String())
+	if err != nil {
+		return err
+	}
+
+	workspace.InitState = to
+	workspace.LastStateUpdate = now
+
+	return m.publish(ctx, workspace)
+}
+
+// checkConflict
+//
+//	Returns a conflict error if the conditional update did not modify the workspace
+func (m *WorkspaceStateMachine) checkConflict(rowsAffected func() (int64, error), id int64, from string, to string) error {
+	rows, err := rowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve affected rows: %v", err)
+	}
+
+	if rows == 0 {
+		return &WorkspaceTransitionError{
+			WorkspaceID: id,
+			From:        from,
+			To:          to,
+			Err:         ErrWorkspaceStateConflict,
+		}
+	}
+
+	return nil
+}
+
+// publish
+//
+//	Publishes the workspace status if a publisher is configured. The
+//	transition has already been committed when this is called so a
+//	failure here does not roll back the new state.
+func (m *WorkspaceStateMachine) publish(ctx context.Context, workspace *Workspace) error {
+	if m.publisher == nil {
+		return nil
+	}
+
+	err := m.publisher.PublishWorkspaceStatus(ctx, workspace)
+	if err != nil {
+		return fmt.Errorf("workspace %d transitioned to %s but failed to publish status: %v", workspace.ID, workspace.State, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+)
+
+type testWorkspaceStatusPublisher struct {
+	published []WorkspaceState
+}
+
+func (p *testWorkspaceStatusPublisher) PublishWorkspaceStatus(ctx context.Context, workspace *Workspace) error {
+	p.published = append(p.published, workspace.State)
+	return nil
+}
+
+func TestWorkspaceState_CanTransitionTo(t *testing.T) {
+	if !WorkspaceSuspended.CanTransitionTo(WorkspaceStarting) {
+		t.Fatal("\nWorkspace State Transition Failed\n    Error: suspended -> starting should be legal")
+	}
+
+	if WorkspaceDeleted.CanTransitionTo(WorkspaceStarting) {
+		t.Fatal("\nWorkspace State Transition Failed\n    Error: deleted -> starting should be illegal")
+	}
+
+	if WorkspaceActive.CanTransitionTo(WorkspaceActive) {
+		t.Fatal("\nWorkspace State Transition Failed\n    Error: active -> active should be illegal")
+	}
+
+	if !WorkspaceInitReadExistingWorkspaceConfig.CanTransitionTo(WorkspaceInitGitClone) {
+		t.Fatal("\nWorkspace State Transition Failed\n    Error: read existing config -> git clone should be legal")
+	}
+
+	if WorkspaceInitGitClone.CanTransitionTo(WorkspaceInitWriteGitConfig) {
+		t.Fatal("\nWorkspace State Transition Failed\n    Error: git clone -> write git config should be illegal")
+	}
+
+	if !WorkspaceInitCompleted.CanTransitionTo(WorkspaceInitProvisioning) {
+		t.Fatal("\nWorkspace State Transition Failed\n    Error: completed -> provisioning should be legal")
+	}
+
+	t.Log("\nWorkspace State Transition Succeeded")
+}
+
+func TestWorkspaceStateMachine_Transition(t *testing.T) {
+	db, err := ti.CreateDatabase("gigo-dev-tidb", "4000", "mysql", "gigo-dev", "gigo-dev", "gigo_dev_test")
+	if err != nil {
+		t.Fatal("\nWorkspace State Machine Transition Failed\n    Error: ", err)
+	}
+
+	defer db.DB.Exec("delete from workspaces")
+
+	workspace, err := CreateWorkspace(69420, 420, 42069, CodeSourcePost, time.Now(), 69, 42, time.Now(), "test", &DefaultWorkspaceSettings, nil, nil)
+	if err != nil {
+		t.Fatal("\nWorkspace State Machine Transition Failed\n    Error: ", err)
+	}
+
+	statements, err := workspace.ToSQLNative()
+	if err != nil {
+		t.Fatal("\nWorkspace State Machine Transition Failed\n    Error: ", err)
+	}
+
+	for _, statement := range statements {
+		_, err = db.DB.Exec(statement.Statement, statement.Values...)
+		if err != nil {
+			t.Fatal("\nWorkspace State Machine Transition Failed\n    Error: ", err)
+		}
+	}
+
+	publisher := &testWorkspaceStatusPublisher{}
+	machine := NewWorkspaceStateMachine(db, publisher)
+
+	// the default guard should block activation until initialization completes
+	err = machine.Transition(context.TODO(), workspace, WorkspaceActive)
+	if !errors.Is(err, ErrWorkspaceTransitionGuard) {
+		t.Fatal("\nWorkspace State Machine Transition Failed\n    Error: expected guard error ", err)
+	}
+
+	err = machine.TransitionInitState(context.TODO(), workspace, WorkspaceInitCompleted)
+	if err != nil {
+		t.Fatal("\nWorkspace State Machine Transition Failed\n    Error: ", err)
+	}
+
+	// keep a stale copy of the workspace to simulate a concurrent writer
+	stale := *workspace
+
+	err = machine.Transition(context.TODO(), workspace, WorkspaceActive)
+	if err != nil {
+		t.Fatal("\nWorkspace State Machine Transition Failed\n    Error: ", err)
+	}
+
+	err = machine.Transition(context.TODO(), &stale, WorkspaceStopping)
+	if !errors.Is(err, ErrWorkspaceStateConflict) {
+		t.Fatal("\nWorkspace State Machine Transition Failed\n    Error: expected conflict error ", err)
+	}
+
+	err = machine.Transition(context.TODO(), workspace, WorkspaceSuspended)
+	var transitionErr *WorkspaceTransitionError
+	if !errors.As(err, &transitionErr) || !errors.Is(err, ErrIllegalWorkspaceTransition) {
+		t.Fatal("\nWorkspace State Machine Transition Failed\n    Error: expected illegal transition error ", err)
+	}
+
+	if len(publisher.published) != 2 || publisher.published[1] != WorkspaceActive {
+		t.Fatalf("\nWorkspace State Machine Transition Failed\n    Error: incorrect published states %v", publisher.published)
+	}
+
+	t.Log("\nWorkspace State Machine Transition Succeeded")
+}
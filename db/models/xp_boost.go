@@ -9,29 +9,36 @@ import (
 	"time"
 )
 
+// DefaultXPBoostMultiplier is the multiplier of boosts created without an explicit multiplier
+const DefaultXPBoostMultiplier = 2
+
 type XPBoost struct {
-	ID      int64      `json:"id" sql:"_id"`
-	UserID  int64      `json:"user_id" sql:"user_id"`
-	EndDate *time.Time `json:"end_date" sql:"end_date"`
+	ID         int64      `json:"id" sql:"_id"`
+	UserID     int64      `json:"user_id" sql:"user_id"`
+	EndDate    *time.Time `json:"end_date" sql:"end_date"`
+	Multiplier float64    `json:"multiplier" sql:"multiplier"`
 }
 
 type XPBoostSQL struct {
-	ID      int64      `json:"id" sql:"_id"`
-	UserID  int64      `json:"user_id" sql:"user_id"`
-	EndDate *time.Time `json:"end_date" sql:"end_date"`
+	ID         int64      `json:"id" sql:"_id"`
+	UserID     int64      `json:"user_id" sql:"user_id"`
+	EndDate    *time.Time `json:"end_date" sql:"end_date"`
+	Multiplier float64    `json:"multiplier" sql:"multiplier"`
 }
 
 type XPBoostFrontend struct {
-	ID      string     `json:"id" sql:"_id"`
-	UserID  string     `json:"user_id" sql:"user_id"`
-	EndDate *time.Time `json:"end_date" sql:"end_date"`
+	ID         string     `json:"id" sql:"_id"`
+	UserID     string     `json:"user_id" sql:"user_id"`
+	EndDate    *time.Time `json:"end_date" sql:"end_date"`
+	Multiplier float64    `json:"multiplier" sql:"multiplier"`
 }
 
 func CreateXPBoost(id int64, userId int64, endTime *time.Time) *XPBoost {
 	return &XPBoost{
-		ID:      id,
-		UserID:  userId,
-		EndDate: endTime,
+		ID:         id,
+		UserID:     userId,
+		EndDate:    endTime,
+		Multiplier: DefaultXPBoostMultiplier,
 	}
 }
 
@@ -49,9 +56,10 @@ func XPBoostFromSQLNative(db *ti.Database, rows *sql.Rows) (*XPBoost, error) {
 
 	// create new user stats
 	xPBoost := &XPBoost{
-		ID:      xPBoostSQL.ID,
-		UserID:  xPBoostSQL.UserID,
-		EndDate: xPBoostSQL.EndDate,
+		ID:         xPBoostSQL.ID,
+		UserID:     xPBoostSQL.UserID,
+		EndDate:    xPBoostSQL.EndDate,
+		Multiplier: xPBoostSQL.Multiplier,
 	}
 
 	return xPBoost, nil
@@ -59,9 +67,10 @@ func XPBoostFromSQLNative(db *ti.Database, rows *sql.Rows) (*XPBoost, error) {
 
 func (i *XPBoost) ToFrontend() *XPBoostFrontend {
 	return &XPBoostFrontend{
-		ID:      fmt.Sprintf("%d", i.ID),
-		UserID:  fmt.Sprintf("%d", i.UserID),
-		EndDate: i.EndDate,
+		ID:         fmt.Sprintf("%d", i.ID),
+		UserID:     fmt.Sprintf("%d", i.UserID),
+		EndDate:    i.EndDate,
+		Multiplier: i.Multiplier,
 	}
 }
 
@@ -69,8 +78,8 @@ func (i *XPBoost) ToSQLNative() []*SQLInsertStatement {
 	sqlStatements := make([]*SQLInsertStatement, 0)
 
 	sqlStatements = append(sqlStatements, &SQLInsertStatement{
-		Statement: "insert ignore into xp_boosts(_id, user_id, end_date) values (?, ?, ?);",
-		Values:    []interface{}{i.ID, i.UserID, i.EndDate},
+		Statement: "insert ignore into xp_boosts(_id, user_id, end_date, multiplier) values (?, ?, ?, ?);",
+		Values:    []interface{}{i.ID, i.UserID, i.EndDate, i.Multiplier},
 	})
 
 	// create insertion statement and return
@@ -0,0 +1,313 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/gage-technologies/gigo-lib/mq/streams"
+	"github.com/kisielk/sqlstruct"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrInvalidXPGrant is returned when an XP grant is missing its
+// idempotency key or does not grant a positive amount of XP
+var ErrInvalidXPGrant = errors.New("invalid xp grant")
+
+// XPGrantResult
+//
+//	Outcome of an XP grant
+type XPGrantResult struct {
+	// Reason is the xp_reasons row written for the grant
+	Reason *XPReason
+	// Multiplier is the product of the boosts that were active for the grant
+	Multiplier float64
+	OldXP      uint64
+	NewXP      uint64
+	OldTier    TierType
+	OldLevel   LevelType
+	NewTier    TierType
+	NewLevel   LevelType
+	// LevelUp is true if the grant moved the user to a new level or tier
+	LevelUp bool
+	// Duplicate is true if the idempotency key was already granted. The
+	// result then describes the earlier grant and the user is unchanged.
+	Duplicate bool
+}
+
+// XPLedgerOptions
+//
+//	Options for an XPLedger
+type XPLedgerOptions struct {
+	DB *ti.Database
+	SF *snowflake.Node
+	// Curve is the renown curve used to derive the user's level and
+	// tier; defaults to the curve returned by CurrentRenownCurve
+	Curve *RenownCurve
+	// LevelUpMsg builds the message written to the outbox when a grant
+	// moves a user to a new level or tier. The stream messages live in
+	// mq/models, which imports this package, so callers pass
+	// mq/models.NewXPLevelUpMsg.
+	LevelUpMsg func(userID int64, result *XPGrantResult) interface{}
+}
+
+// XPLedger
+//
+//	Grants XP to users. Every grant is recorded in xp_reasons with the
+//	idempotency key of the grant so that a grant which is retried, either
+//	by the caller or by a message being redelivered, is only applied once.
+type XPLedger struct {
+	db         *ti.Database
+	sf         *snowflake.Node
+	curve      *RenownCurve
+	levelUpMsg func(userID int64, result *XPGrantResult) interface{}
+}
+
+// NewXPLedger
+//
+//	Creates a new XPLedger
+func NewXPLedger(opts XPLedgerOptions) (*XPLedger, error) {
+	if opts.DB == nil {
+		return nil, fmt.Errorf("xp ledger requires a database")
+	}
+	if opts.SF == nil {
+		return nil, fmt.Errorf("xp ledger requires a snowflake node")
+	}
+	if opts.LevelUpMsg == nil {
+		return nil, fmt.Errorf("xp ledger requires a level up message builder")
+	}
+
+	return &XPLedger{
+		db:         opts.DB,
+		sf:         opts.SF,
+		curve:      opts.Curve,
+		levelUpMsg: opts.LevelUpMsg,
+	}, nil
+}
+
+// GrantXP
+//
+//	Grants XP to a user inside a single transaction. The amount is
+//	multiplied by the user's active XP boosts, the grant is recorded in
+//	xp_reasons and the user's XP, level and tier are updated from the
+//	renown curve. If the grant moves the user to a new level or tier the
+//	ledger's level up message is written to the outbox in the same
+//	transaction.
+//
+//	The user's row is locked for the duration of the transaction so
+//	concurrent grants for the same user are serialized. A grant with an
+//	idempotency key that has already been granted to the user returns the
+//	earlier grant with Duplicate set and leaves the user unchanged. Write
+//	conflicts and deadlocks retry the whole transaction so a retried grant
+//	repeats the idempotency check instead of counting the XP twice.
+func (l *XPLedger) GrantXP(ctx context.Context, span *trace.Span, callerName *string, userID int64, reason string, amount int64, idempotencyKey string) (*XPGrantResult, error) {
+	if idempotencyKey == "" {
+		return nil, fmt.Errorf("%w: missing idempotency key", ErrInvalidXPGrant)
+	}
+	if amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive, got %d", ErrInvalidXPGrant, amount)
+	}
+
+	curve := l.curve
+	if curve == nil {
+		curve = CurrentRenownCurve()
+	}
+
+	var result *XPGrantResult
+	err := l.db.RunInTx(ctx, span, callerName, nil, func(ctx context.Context, tx *ti.Tx) error {
+		var err error
+		result, err = l.grantXP(ctx, tx, callerName, curve, userID, reason, amount, idempotencyKey)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// grantXP
+//
+//	Executes a single attempt of GrantXP inside the passed transaction
+func (l *XPLedger) grantXP(ctx context.Context, tx *ti.Tx, callerName *string, curve *RenownCurve, userID int64, reason string, amount int64, idempotencyKey string) (*XPGrantResult, error) {
+	// lock the user so that concurrent grants cannot both miss the
+	// idempotency check or overwrite each other's XP
+	oldXP, oldLevel, oldTier, err := l.lockUser(ctx, tx, callerName, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := l.loadGrant(ctx, tx, callerName, userID, idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return &XPGrantResult{
+			Reason:    existing,
+			OldXP:     oldXP,
+			NewXP:     oldXP,
+			OldTier:   oldTier,
+			OldLevel:  oldLevel,
+			NewTier:   oldTier,
+			NewLevel:  oldLevel,
+			Duplicate: true,
+		}, nil
+	}
+
+	now := time.Now()
+
+	multiplier, err := l.activeMultiplier(ctx, tx, callerName, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	xp := int64(math.Round(float64(amount) * multiplier))
+
+	xpReason := CreateXPReason(l.sf.Generate().Int64(), userID, &now, reason, xp)
+	xpReason.IdempotencyKey = &idempotencyKey
+	for _, statement := range xpReason.ToSQLNative() {
+		_, err = tx.ExecContext(ctx, callerName, statement.Statement, statement.Values...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert xp reason: %w", err)
+		}
+	}
+
+	newXP := oldXP + uint64(xp)
+	position := curve.Lookup(newXP)
+
+	_, err = tx.ExecContext(ctx, callerName,
+		"update users set xp = ?, level = ?, tier = ? where _id = ?",
+		newXP, position.Level, position.Tier, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user xp: %w", err)
+	}
+
+	result := &XPGrantResult{
+		Reason:     xpReason,
+		Multiplier: multiplier,
+		OldXP:      oldXP,
+		NewXP:      newXP,
+		OldTier:    oldTier,
+		OldLevel:   oldLevel,
+		NewTier:    position.Tier,
+		NewLevel:   position.Level,
+		LevelUp:    position.Tier > oldTier || (position.Tier == oldTier && position.Level > oldLevel),
+	}
+
+	if result.LevelUp {
+		msg, err := CreateOutboxMessageGob(l.sf.Generate().Int64(), streams.SubjectStreakLevelUp, l.levelUpMsg(userID, result))
+		if err != nil {
+			return nil, err
+		}
+
+		err = WriteOutboxMessage(ctx, tx, msg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// lockUser
+//
+//	Locks the user's row for the rest of the transaction and
+//	returns the user's current XP, level and tier
+func (l *XPLedger) lockUser(ctx context.Context, tx *ti.Tx, callerName *string, userID int64) (uint64, LevelType, TierType, error) {
+	res, err := tx.QueryContext(ctx, callerName,
+		"select xp, level, tier from users where _id = ? for update", userID,
+	)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to lock user for xp grant: %w", err)
+	}
+	defer res.Close()
+
+	if !res.Next() {
+		if err := res.Err(); err != nil {
+			return 0, 0, 0, fmt.Errorf("failed to lock user for xp grant: %w", err)
+		}
+		return 0, 0, 0, fmt.Errorf("failed to grant xp to user %d: %w", userID, ErrModelNotFound)
+	}
+
+	var xp uint64
+	var level LevelType
+	var tier TierType
+	err = res.Scan(&xp, &level, &tier)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to scan user for xp grant: %w", err)
+	}
+
+	return xp, level, tier, nil
+}
+
+// loadGrant
+//
+//	Returns the xp reason recorded for the idempotency key or nil if
+//	the key has not been granted to the user
+func (l *XPLedger) loadGrant(ctx context.Context, tx *ti.Tx, callerName *string, userID int64, idempotencyKey string) (*XPReason, error) {
+	res, err := tx.QueryContext(ctx, callerName,
+		"select * from xp_reasons where user_id = ? and idempotency_key = ? limit 1", userID, idempotencyKey,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query xp reason: %w", err)
+	}
+	defer res.Close()
+
+	if !res.Next() {
+		if err := res.Err(); err != nil {
+			return nil, fmt.Errorf("failed to query xp reason: %w", err)
+		}
+		return nil, nil
+	}
+
+	reasonSQL := new(XPReasonSQL)
+	err = sqlstruct.Scan(reasonSQL, res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan xp reason: %w", err)
+	}
+
+	return &XPReason{
+		ID:             reasonSQL.ID,
+		UserID:         reasonSQL.UserID,
+		Date:           reasonSQL.Date,
+		Reason:         reasonSQL.Reason,
+		XP:             reasonSQL.XP,
+		IdempotencyKey: reasonSQL.IdempotencyKey,
+	}, nil
+}
+
+// activeMultiplier
+//
+//	Returns the product of the multipliers of the user's active XP
+//	boosts. Boosts without an end date never expire.
+func (l *XPLedger) activeMultiplier(ctx context.Context, tx *ti.Tx, callerName *string, userID int64, now time.Time) (float64, error) {
+	res, err := tx.QueryContext(ctx, callerName,
+		"select multiplier from xp_boosts where user_id = ? and (end_date is null or end_date > ?)", userID, now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query xp boosts: %w", err)
+	}
+	defer res.Close()
+
+	multiplier := 1.0
+	for res.Next() {
+		var boost float64
+		err = res.Scan(&boost)
+		if err != nil {
+			return 0, fmt.Errorf("failed to scan xp boost: %w", err)
+		}
+		// ignore boosts that would reduce or remove the XP granted
+		if boost > 1 {
+			multiplier *= boost
+		}
+	}
+	if err := res.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query xp boosts: %w", err)
+	}
+
+	return multiplier, nil
+}
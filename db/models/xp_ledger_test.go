@@ -0,0 +1,156 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/gage-technologies/gigo-lib/mq/streams"
+)
+
+// testLevelUpMsg
+//
+//	Publishes the grant result itself since the level up message of
+//	mq/models cannot be imported by the tests of this package
+func testLevelUpMsg(_ int64, result *XPGrantResult) interface{} {
+	return result
+}
+
+func TestXPLedger_GrantXP(t *testing.T) {
+	db, err := ti.CreateDatabase("gigo-dev-tidb", "4000", "mysql", "gigo-dev", "gigo-dev", "gigo_dev_test")
+	if err != nil {
+		t.Fatal("\nGrant XP Failed\n    Error: ", err)
+	}
+
+	defer db.DB.Exec("delete from users where _id = 6942069")
+	defer db.DB.Exec("delete from xp_reasons where user_id = 6942069")
+	defer db.DB.Exec("delete from xp_boosts where user_id = 6942069")
+	defer db.DB.Exec("delete from outbox_messages")
+
+	user, err := CreateUser(6942069, "test", "testpass", "testemail@email.com",
+		"phone", UserStatusBasic, "test", []int64{1, 2}, []int64{1, 2, 3},
+		"first", "last", 23, "", DefaultUserStart, "America/Chicago",
+		AvatarSettings{}, 0)
+	if err != nil {
+		t.Fatal("\nGrant XP Failed\n    Error: ", err)
+	}
+
+	statements, err := user.ToSQLNative()
+	if err != nil {
+		t.Fatal("\nGrant XP Failed\n    Error: ", err)
+	}
+	for _, statement := range statements {
+		_, err = db.DB.Exec(statement.Statement, statement.Values...)
+		if err != nil {
+			t.Fatal("\nGrant XP Failed\n    Error: ", err)
+		}
+	}
+
+	// one active boost and one expired boost
+	expired := time.Now().Add(-time.Hour)
+	for _, boost := range []*XPBoost{CreateXPBoost(1, 6942069, nil), CreateXPBoost(2, 6942069, &expired)} {
+		for _, statement := range boost.ToSQLNative() {
+			_, err = db.DB.Exec(statement.Statement, statement.Values...)
+			if err != nil {
+				t.Fatal("\nGrant XP Failed\n    Error: ", err)
+			}
+		}
+	}
+
+	sf, err := snowflake.NewNode(1)
+	if err != nil {
+		t.Fatal("\nGrant XP Failed\n    Error: ", err)
+	}
+
+	ledger, err := NewXPLedger(XPLedgerOptions{DB: db, SF: sf, LevelUpMsg: testLevelUpMsg})
+	if err != nil {
+		t.Fatal("\nGrant XP Failed\n    Error: ", err)
+	}
+
+	callerName := "TestXPLedger_GrantXP"
+	res, err := ledger.GrantXP(context.TODO(), nil, &callerName, 6942069, "test", 300, "test-key")
+	if err != nil {
+		t.Fatal("\nGrant XP Failed\n    Error: ", err)
+	}
+
+	if res.Duplicate || res.Multiplier != 2 || res.NewXP != 600 || res.Reason.XP != 600 {
+		t.Fatalf("\nGrant XP Failed\n    Error: wrong result %+v", res)
+	}
+
+	position := CurrentRenownCurve().Lookup(600)
+	if res.NewTier != position.Tier || res.NewLevel != position.Level || !res.LevelUp {
+		t.Fatalf("\nGrant XP Failed\n    Error: wrong renown %+v", res)
+	}
+
+	// retrying the grant must not apply the XP again
+	retry, err := ledger.GrantXP(context.TODO(), nil, &callerName, 6942069, "test", 300, "test-key")
+	if err != nil {
+		t.Fatal("\nGrant XP Failed\n    Error: ", err)
+	}
+
+	if !retry.Duplicate || retry.NewXP != 600 || retry.Reason.ID != res.Reason.ID {
+		t.Fatalf("\nGrant XP Failed\n    Error: retry was applied %+v", retry)
+	}
+
+	var xp uint64
+	var reasons int
+	err = db.DB.QueryRow("select xp from users where _id = 6942069").Scan(&xp)
+	if err != nil {
+		t.Fatal("\nGrant XP Failed\n    Error: ", err)
+	}
+	err = db.DB.QueryRow("select count(*) from xp_reasons where user_id = 6942069").Scan(&reasons)
+	if err != nil {
+		t.Fatal("\nGrant XP Failed\n    Error: ", err)
+	}
+
+	if xp != 600 || reasons != 1 {
+		t.Fatalf("\nGrant XP Failed\n    Error: xp %d with %d reasons", xp, reasons)
+	}
+
+	var payload []byte
+	err = db.DB.QueryRow("select payload from outbox_messages where subject = ?", streams.SubjectStreakLevelUp).Scan(&payload)
+	if err != nil {
+		t.Fatal("\nGrant XP Failed\n    Error: ", err)
+	}
+
+	var msg XPGrantResult
+	err = gob.NewDecoder(bytes.NewReader(payload)).Decode(&msg)
+	if err != nil {
+		t.Fatal("\nGrant XP Failed\n    Error: ", err)
+	}
+
+	if !msg.LevelUp || msg.NewXP != 600 || msg.Reason == nil || msg.Reason.ID != res.Reason.ID || msg.Reason.UserID != 6942069 {
+		t.Fatalf("\nGrant XP Failed\n    Error: wrong level up message %+v", msg)
+	}
+
+	t.Log("\nGrant XP Succeeded")
+}
+
+func TestXPLedger_GrantXPInvalid(t *testing.T) {
+	sf, err := snowflake.NewNode(1)
+	if err != nil {
+		t.Fatal("\nGrant XP Invalid Failed\n    Error: ", err)
+	}
+
+	ledger, err := NewXPLedger(XPLedgerOptions{DB: &ti.Database{}, SF: sf, LevelUpMsg: testLevelUpMsg})
+	if err != nil {
+		t.Fatal("\nGrant XP Invalid Failed\n    Error: ", err)
+	}
+
+	_, err = ledger.GrantXP(context.TODO(), nil, nil, 6942069, "test", 100, "")
+	if !errors.Is(err, ErrInvalidXPGrant) {
+		t.Fatal("\nGrant XP Invalid Failed\n    Error: missing key was accepted: ", err)
+	}
+
+	_, err = ledger.GrantXP(context.TODO(), nil, nil, 6942069, "test", 0, "test-key")
+	if !errors.Is(err, ErrInvalidXPGrant) {
+		t.Fatal("\nGrant XP Invalid Failed\n    Error: zero amount was accepted: ", err)
+	}
+
+	t.Log("\nGrant XP Invalid Succeeded")
+}
@@ -10,19 +10,21 @@ import (
 )
 
 type XPReason struct {
-	ID     int64      `json:"id" sql:"_id"`
-	UserID int64      `json:"user_id" sql:"user_id"`
-	Date   *time.Time `json:"date" sql:"date"`
-	Reason string     `json:"reason" sql:"reason"`
-	XP     int64      `json:"xp" sql:"xp"`
+	ID             int64      `json:"id" sql:"_id"`
+	UserID         int64      `json:"user_id" sql:"user_id"`
+	Date           *time.Time `json:"date" sql:"date"`
+	Reason         string     `json:"reason" sql:"reason"`
+	XP             int64      `json:"xp" sql:"xp"`
+	IdempotencyKey *string    `json:"idempotency_key" sql:"idempotency_key"`
 }
 
 type XPReasonSQL struct {
-	ID     int64      `json:"id" sql:"_id"`
-	UserID int64      `json:"user_id" sql:"user_id"`
-	Date   *time.Time `json:"date" sql:"date"`
-	Reason string     `json:"reason" sql:"reason"`
-	XP     int64      `json:"xp" sql:"xp"`
+	ID             int64      `json:"id" sql:"_id"`
+	UserID         int64      `json:"user_id" sql:"user_id"`
+	Date           *time.Time `json:"date" sql:"date"`
+	Reason         string     `json:"reason" sql:"reason"`
+	XP             int64      `json:"xp" sql:"xp"`
+	IdempotencyKey *string    `json:"idempotency_key" sql:"idempotency_key"`
 }
 
 type XPReasonFrontend struct {
@@ -57,11 +59,12 @@ func XPReasonFromSQLNative(db *ti.Database, rows *sql.Rows) (*XPReason, error) {
 
 	// create new user stats
 	xPReason := &XPReason{
-		ID:     xPReasonSQL.ID,
-		UserID: xPReasonSQL.UserID,
-		Date:   xPReasonSQL.Date,
-		Reason: xPReasonSQL.Reason,
-		XP:     xPReasonSQL.XP,
+		ID:             xPReasonSQL.ID,
+		UserID:         xPReasonSQL.UserID,
+		Date:           xPReasonSQL.Date,
+		Reason:         xPReasonSQL.Reason,
+		XP:             xPReasonSQL.XP,
+		IdempotencyKey: xPReasonSQL.IdempotencyKey,
 	}
 
 	return xPReason, nil
@@ -81,8 +84,8 @@ func (i *XPReason) ToSQLNative() []*SQLInsertStatement {
 	sqlStatements := make([]*SQLInsertStatement, 0)
 
 	sqlStatements = append(sqlStatements, &SQLInsertStatement{
-		Statement: "insert ignore into xp_reasons(_id, user_id, date, reason, xp) values (?, ?, ?, ?, ?);",
-		Values:    []interface{}{i.ID, i.UserID, i.Date, i.Reason, i.XP},
+		Statement: "insert ignore into xp_reasons(_id, user_id, date, reason, xp, idempotency_key) values (?, ?, ?, ?, ?, ?);",
+		Values:    []interface{}{i.ID, i.UserID, i.Date, i.Reason, i.XP, i.IdempotencyKey},
 	})
 
 	// create insertion statement and return
@@ -0,0 +1,276 @@
+package ti
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gage-technologies/gigo-lib/config"
+	"github.com/gage-technologies/gigo-lib/logging"
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	// DefaultMaxOpenConns is the maximum number of open connections
+	// used when the config does not set one
+	DefaultMaxOpenConns = 16384
+	// DefaultMaxIdleConns is the maximum number of idle connections
+	// used when the config does not set one
+	DefaultMaxIdleConns = 16384
+	// DefaultConnMaxLifetime is the maximum lifetime of a connection
+	// used when the config does not set one
+	DefaultConnMaxLifetime = time.Minute * 5
+
+	// tlsConfigName is the name the TLS config is registered under with the mysql driver
+	tlsConfigName = "gigo-titanium"
+
+	// DefaultDriverName is the database/sql driver used when the config
+	// does not set one
+	DefaultDriverName = "mysql"
+)
+
+// CreateDatabaseFromConfig
+//
+//	Opens a connection to the primary database and any configured read
+//	replicas, then applies all pending migrations to the primary. Slow
+//	queries are logged to the passed logger if it is not nil.
+func CreateDatabaseFromConfig(cfg config.TitaniumConfig, logger logging.Logger) (*Database, error) {
+	dataB, err := OpenDatabaseFromConfig(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := NewMigrator(dataB.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	err = migrator.Up()
+	if err != nil {
+		return nil, err
+	}
+
+	return dataB, nil
+}
+
+// OpenDatabaseFromConfig
+//
+//	Opens a connection to the primary database and any configured read
+//	replicas without applying any migrations. The database is created on
+//	the primary if it does not exist. Statements are instrumented unless
+//	the config disables it and slow queries are logged to the passed
+//	logger if it is not nil.
+func OpenDatabaseFromConfig(cfg config.TitaniumConfig, logger logging.Logger) (*Database, error) {
+	tlsName, err := registerTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	// create the database using a connection that is not bound to it
+	db, err := sql.Open(driverName(cfg), formatDSN(cfg, cfg.TitaniumHost, cfg.TitaniumPort, "", tlsName))
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec("create database if not exists " + cfg.TitaniumName)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	err = db.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	primary, err := openPool(cfg, cfg.TitaniumHost, cfg.TitaniumPort, tlsName)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make([]*sql.DB, 0, len(cfg.ReadReplicas))
+	replicaAddrs := make([]string, 0, len(cfg.ReadReplicas))
+	for _, replica := range cfg.ReadReplicas {
+		pool, err := openPool(cfg, replica.Host, replica.Port, tlsName)
+		if err != nil {
+			_ = primary.Close()
+			for _, r := range replicas {
+				_ = r.Close()
+			}
+			return nil, fmt.Errorf("failed to open read replica %s:%s: %v", replica.Host, replica.Port, err)
+		}
+		replicas = append(replicas, pool)
+		replicaAddrs = append(replicaAddrs, fmt.Sprintf("%s:%s", replica.Host, replica.Port))
+	}
+
+	dataB := &Database{
+		DB:           primary,
+		DBName:       cfg.TitaniumName,
+		Host:         cfg.TitaniumHost,
+		Port:         cfg.TitaniumPort,
+		User:         cfg.TitaniumUser,
+		Pass:         cfg.TitaniumPassword,
+		Queries:      New(primary),
+		replicas:     replicas,
+		replicaAddrs: replicaAddrs,
+	}
+
+	if !cfg.DisableInstrumentation {
+		err = dataB.instrument(InstrumentationOptions{
+			Logger:             logger,
+			SlowQueryThreshold: cfg.SlowQueryThreshold,
+			MaxFingerprints:    cfg.MaxQueryFingerprints,
+		})
+		if err != nil {
+			_ = Close(dataB)
+			return nil, err
+		}
+	}
+
+	return dataB, nil
+}
+
+// driverName
+//
+//	Returns the database/sql driver configured for the connections. Any
+//	driver must accept the mysql DSN format since the DSN is built for
+//	the mysql driver.
+func driverName(cfg config.TitaniumConfig) string {
+	if cfg.DriverName == "" {
+		return DefaultDriverName
+	}
+	return cfg.DriverName
+}
+
+// openPool
+//
+//	Opens and configures a connection pool for a single endpoint
+func openPool(cfg config.TitaniumConfig, host string, port string, tlsName string) (*sql.DB, error) {
+	db, err := sql.Open(driverName(cfg), formatDSN(cfg, host, port, cfg.TitaniumName, tlsName))
+	if err != nil {
+		return nil, err
+	}
+
+	maxOpen := cfg.MaxOpenConns
+	if maxOpen <= 0 {
+		maxOpen = DefaultMaxOpenConns
+	}
+	maxIdle := cfg.MaxIdleConns
+	if maxIdle <= 0 {
+		maxIdle = DefaultMaxIdleConns
+	}
+	lifetime := cfg.ConnMaxLifetime
+	if lifetime <= 0 {
+		lifetime = DefaultConnMaxLifetime
+	}
+
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxIdle)
+	db.SetConnMaxLifetime(lifetime)
+	if cfg.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+
+	return db, nil
+}
+
+// formatDSN
+//
+//	Formats the data source name for an endpoint from the config
+func formatDSN(cfg config.TitaniumConfig, host string, port string, databaseName string, tlsName string) string {
+	dsn := mysql.NewConfig()
+	dsn.User = cfg.TitaniumUser
+	dsn.Passwd = cfg.TitaniumPassword
+	dsn.Net = "tcp"
+	dsn.Addr = fmt.Sprintf("%s:%s", host, port)
+	dsn.DBName = databaseName
+	dsn.ParseTime = true
+	dsn.Timeout = cfg.DialTimeout
+	dsn.ReadTimeout = cfg.ReadTimeout
+	dsn.WriteTimeout = cfg.WriteTimeout
+	dsn.TLSConfig = tlsName
+	dsn.Params = map[string]string{
+		"tidb_skip_isolation_level_check": "1",
+	}
+	for k, v := range cfg.Params {
+		dsn.Params[k] = v
+	}
+	return dsn.FormatDSN()
+}
+
+// registerTLSConfig
+//
+//	Registers the TLS config with the mysql driver and returns the
+//	name to reference it by in the DSN. Returns an empty name when
+//	TLS is disabled.
+func registerTLSConfig(cfg config.TitaniumTLSConfig) (string, error) {
+	if !cfg.Enabled {
+		return "", nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if cfg.CAPath != "" {
+		ca, err := os.ReadFile(cfg.CAPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read database ca: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return "", fmt.Errorf("failed to parse database ca %s", cfg.CAPath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertPath != "" || cfg.KeyPath != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertPath, cfg.KeyPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to load database client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	err := mysql.RegisterTLSConfig(tlsConfigName, tlsConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to register database tls config: %v", err)
+	}
+
+	return tlsConfigName, nil
+}
+
+// primaryReadKey is the context key that marks reads that must be served by the primary
+type primaryReadKey struct{}
+
+// WithPrimaryReads
+//
+//	Returns a context that routes the reads made with it to the primary
+//	instead of the read replicas. Reads that must observe writes made
+//	immediately before them should use it since replicas may lag behind.
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadKey{}, true)
+}
+
+// reader
+//
+//	Returns the connection pool that read queries are routed to. Reads
+//	are distributed round-robin across the read replicas and fall back
+//	to the primary when no replicas are configured or the context was
+//	created by WithPrimaryReads.
+func (db *Database) reader(ctx context.Context) *sql.DB {
+	if len(db.replicas) == 0 {
+		return db.DB
+	}
+	if ctx != nil {
+		if primary, _ := ctx.Value(primaryReadKey{}).(bool); primary {
+			return db.DB
+		}
+	}
+	return db.replicas[int(db.next.Add(1)-1)%len(db.replicas)]
+}
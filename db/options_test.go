@@ -0,0 +1,67 @@
+package ti
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/gage-technologies/gigo-lib/config"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDSN(t *testing.T) {
+	cfg := config.TitaniumConfig{
+		TitaniumUser:     "gigo-dev",
+		TitaniumPassword: "gigo-dev",
+		ReadTimeout:      time.Second * 30,
+		WriteTimeout:     time.Second * 10,
+		DialTimeout:      time.Second * 5,
+		Params: map[string]string{
+			"tidb_txn_mode": "optimistic",
+		},
+	}
+
+	parsed, err := mysql.ParseDSN(formatDSN(cfg, "gigo-dev-tidb", "4000", "gigo_dev_test", ""))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "gigo-dev-tidb:4000", parsed.Addr)
+	assert.Equal(t, "gigo_dev_test", parsed.DBName)
+	assert.True(t, parsed.ParseTime)
+	assert.Equal(t, time.Second*30, parsed.ReadTimeout)
+	assert.Equal(t, time.Second*10, parsed.WriteTimeout)
+	assert.Equal(t, time.Second*5, parsed.Timeout)
+	assert.Equal(t, "1", parsed.Params["tidb_skip_isolation_level_check"])
+	assert.Equal(t, "optimistic", parsed.Params["tidb_txn_mode"])
+}
+
+func TestDatabase_Reader(t *testing.T) {
+	ctx := context.Background()
+	primary := &sql.DB{}
+	db := &Database{DB: primary}
+	assert.Same(t, primary, db.reader(ctx))
+
+	replicas := []*sql.DB{{}, {}}
+	db.replicas = replicas
+	assert.Same(t, replicas[0], db.reader(ctx))
+	assert.Same(t, replicas[1], db.reader(ctx))
+	assert.Same(t, replicas[0], db.reader(ctx))
+
+	// reads that must observe prior writes skip the replicas
+	assert.Same(t, primary, db.reader(WithPrimaryReads(ctx)))
+	assert.Same(t, replicas[1], db.reader(ctx))
+}
+
+func TestDriverName(t *testing.T) {
+	assert.Equal(t, DefaultDriverName, driverName(config.TitaniumConfig{}))
+	assert.Equal(t, "instrumented-mysql", legacyConfig("gigo-dev-tidb", "4000", "instrumented-mysql", "gigo-dev", "gigo-dev", "gigo_dev_test").DriverName)
+
+	// the passed driver is used to open the connections
+	_, err := OpenDatabase("gigo-dev-tidb", "4000", "gigo-unregistered-driver", "gigo-dev", "gigo-dev", "gigo_dev_test")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "gigo-unregistered-driver")
+	}
+}
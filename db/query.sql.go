@@ -1,6 +1,10 @@
 package ti
 
-import "context"
+import (
+	"context"
+	"database/sql"
+	"time"
+)
 
 const insertDatabaseVersion = `-- name: InsertDatabaseVersion :exec
 insert
@@ -14,3 +18,900 @@ func (q *Queries) InsertDatabaseVersion(ctx context.Context, version int64) erro
 	_, err := q.db.ExecContext(ctx, insertDatabaseVersion, version)
 	return err
 }
+
+const getUserByID = `-- name: GetUserByID :one
+select _id, email, user_status, user_name, password, phone, bio, xp, level, tier, user_rank, coffee, otp, otp_validated, stripe_user, stripe_subscription, first_name, last_name, auth_role, gitea_id, external_auth, created_at, workspace_settings, follower_count, start_user_info, highest_score, timezone, avatar_settings, broadcast_threshold, avatar_reward, encrypted_service_key, stripe_account, exclusive_agreement, reset_token, has_broadcast, holiday_themes, tutorials, is_ephemeral from users where _id = ? limit 1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.UserStatus,
+		&i.UserName,
+		&i.Password,
+		&i.Phone,
+		&i.Bio,
+		&i.Xp,
+		&i.Level,
+		&i.Tier,
+		&i.UserRank,
+		&i.Coffee,
+		&i.Otp,
+		&i.OtpValidated,
+		&i.StripeUser,
+		&i.StripeSubscription,
+		&i.FirstName,
+		&i.LastName,
+		&i.AuthRole,
+		&i.GiteaID,
+		&i.ExternalAuth,
+		&i.CreatedAt,
+		&i.WorkspaceSettings,
+		&i.FollowerCount,
+		&i.StartUserInfo,
+		&i.HighestScore,
+		&i.Timezone,
+		&i.AvatarSettings,
+		&i.BroadcastThreshold,
+		&i.AvatarReward,
+		&i.EncryptedServiceKey,
+		&i.StripeAccount,
+		&i.ExclusiveAgreement,
+		&i.ResetToken,
+		&i.HasBroadcast,
+		&i.HolidayThemes,
+		&i.Tutorials,
+		&i.IsEphemeral,
+	)
+	return i, err
+}
+
+const getUserByUserName = `-- name: GetUserByUserName :one
+select _id, email, user_status, user_name, password, phone, bio, xp, level, tier, user_rank, coffee, otp, otp_validated, stripe_user, stripe_subscription, first_name, last_name, auth_role, gitea_id, external_auth, created_at, workspace_settings, follower_count, start_user_info, highest_score, timezone, avatar_settings, broadcast_threshold, avatar_reward, encrypted_service_key, stripe_account, exclusive_agreement, reset_token, has_broadcast, holiday_themes, tutorials, is_ephemeral from users where user_name = ? limit 1
+`
+
+func (q *Queries) GetUserByUserName(ctx context.Context, userName string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByUserName, userName)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.UserStatus,
+		&i.UserName,
+		&i.Password,
+		&i.Phone,
+		&i.Bio,
+		&i.Xp,
+		&i.Level,
+		&i.Tier,
+		&i.UserRank,
+		&i.Coffee,
+		&i.Otp,
+		&i.OtpValidated,
+		&i.StripeUser,
+		&i.StripeSubscription,
+		&i.FirstName,
+		&i.LastName,
+		&i.AuthRole,
+		&i.GiteaID,
+		&i.ExternalAuth,
+		&i.CreatedAt,
+		&i.WorkspaceSettings,
+		&i.FollowerCount,
+		&i.StartUserInfo,
+		&i.HighestScore,
+		&i.Timezone,
+		&i.AvatarSettings,
+		&i.BroadcastThreshold,
+		&i.AvatarReward,
+		&i.EncryptedServiceKey,
+		&i.StripeAccount,
+		&i.ExclusiveAgreement,
+		&i.ResetToken,
+		&i.HasBroadcast,
+		&i.HolidayThemes,
+		&i.Tutorials,
+		&i.IsEphemeral,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+select _id, email, user_status, user_name, password, phone, bio, xp, level, tier, user_rank, coffee, otp, otp_validated, stripe_user, stripe_subscription, first_name, last_name, auth_role, gitea_id, external_auth, created_at, workspace_settings, follower_count, start_user_info, highest_score, timezone, avatar_settings, broadcast_threshold, avatar_reward, encrypted_service_key, stripe_account, exclusive_agreement, reset_token, has_broadcast, holiday_themes, tutorials, is_ephemeral from users where email = ? limit 1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.UserStatus,
+		&i.UserName,
+		&i.Password,
+		&i.Phone,
+		&i.Bio,
+		&i.Xp,
+		&i.Level,
+		&i.Tier,
+		&i.UserRank,
+		&i.Coffee,
+		&i.Otp,
+		&i.OtpValidated,
+		&i.StripeUser,
+		&i.StripeSubscription,
+		&i.FirstName,
+		&i.LastName,
+		&i.AuthRole,
+		&i.GiteaID,
+		&i.ExternalAuth,
+		&i.CreatedAt,
+		&i.WorkspaceSettings,
+		&i.FollowerCount,
+		&i.StartUserInfo,
+		&i.HighestScore,
+		&i.Timezone,
+		&i.AvatarSettings,
+		&i.BroadcastThreshold,
+		&i.AvatarReward,
+		&i.EncryptedServiceKey,
+		&i.StripeAccount,
+		&i.ExclusiveAgreement,
+		&i.ResetToken,
+		&i.HasBroadcast,
+		&i.HolidayThemes,
+		&i.Tutorials,
+		&i.IsEphemeral,
+	)
+	return i, err
+}
+
+const updateUserStatus = `-- name: UpdateUserStatus :exec
+update users set user_status = ? where _id = ?
+`
+
+type UpdateUserStatusParams struct {
+	UserStatus int32
+	ID         int64
+}
+
+func (q *Queries) UpdateUserStatus(ctx context.Context, arg UpdateUserStatusParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserStatus, arg.UserStatus, arg.ID)
+	return err
+}
+
+const getPostByID = `-- name: GetPostByID :one
+select _id, title, description, author, author_id, created_at, updated_at, repo_id, top_reply, tier, coffee, post_type, views, completions, attempts, published, visibility, challenge_cost, stripe_price_id, workspace_config, workspace_config_revision, workspace_settings, leads, embedded, deleted, exclusive_description, share_hash from post where _id = ? limit 1
+`
+
+func (q *Queries) GetPostByID(ctx context.Context, id int64) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostByID, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Description,
+		&i.Author,
+		&i.AuthorID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RepoID,
+		&i.TopReply,
+		&i.Tier,
+		&i.Coffee,
+		&i.PostType,
+		&i.Views,
+		&i.Completions,
+		&i.Attempts,
+		&i.Published,
+		&i.Visibility,
+		&i.ChallengeCost,
+		&i.StripePriceID,
+		&i.WorkspaceConfig,
+		&i.WorkspaceConfigRevision,
+		&i.WorkspaceSettings,
+		&i.Leads,
+		&i.Embedded,
+		&i.Deleted,
+		&i.ExclusiveDescription,
+		&i.ShareHash,
+	)
+	return i, err
+}
+
+const listPostsByAuthor = `-- name: ListPostsByAuthor :many
+-- Pages through the posts of an author from newest to oldest; pass the
+-- id of the last post in the previous page as the cursor.
+select _id, title, description, author, author_id, created_at, updated_at, repo_id, top_reply, tier, coffee, post_type, views, completions, attempts, published, visibility, challenge_cost, stripe_price_id, workspace_config, workspace_config_revision, workspace_settings, leads, embedded, deleted, exclusive_description, share_hash from post
+where author_id = ? and deleted = false and _id < ?
+order by _id desc
+limit ?
+`
+
+type ListPostsByAuthorParams struct {
+	AuthorID int64
+	ID       int64
+	Limit    int32
+}
+
+// Pages through the posts of an author from newest to oldest; pass the
+// id of the last post in the previous page as the cursor.
+func (q *Queries) ListPostsByAuthor(ctx context.Context, arg ListPostsByAuthorParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, listPostsByAuthor, arg.AuthorID, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Description,
+			&i.Author,
+			&i.AuthorID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RepoID,
+			&i.TopReply,
+			&i.Tier,
+			&i.Coffee,
+			&i.PostType,
+			&i.Views,
+			&i.Completions,
+			&i.Attempts,
+			&i.Published,
+			&i.Visibility,
+			&i.ChallengeCost,
+			&i.StripePriceID,
+			&i.WorkspaceConfig,
+			&i.WorkspaceConfigRevision,
+			&i.WorkspaceSettings,
+			&i.Leads,
+			&i.Embedded,
+			&i.Deleted,
+			&i.ExclusiveDescription,
+			&i.ShareHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updatePostPublished = `-- name: UpdatePostPublished :exec
+update post set published = ?, updated_at = ? where _id = ?
+`
+
+type UpdatePostPublishedParams struct {
+	Published bool
+	UpdatedAt time.Time
+	ID        int64
+}
+
+func (q *Queries) UpdatePostPublished(ctx context.Context, arg UpdatePostPublishedParams) error {
+	_, err := q.db.ExecContext(ctx, updatePostPublished, arg.Published, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const markPostDeleted = `-- name: MarkPostDeleted :execrows
+update post set deleted = true, updated_at = ? where _id = ? and deleted = false
+`
+
+type MarkPostDeletedParams struct {
+	UpdatedAt time.Time
+	ID        int64
+}
+
+func (q *Queries) MarkPostDeleted(ctx context.Context, arg MarkPostDeletedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markPostDeleted, arg.UpdatedAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getAttemptByID = `-- name: GetAttemptByID :one
+select _id, post_title, description, author, author_id, created_at, updated_at, repo_id, author_tier, coffee, post_id, closed, success, closed_date, tier, parent_attempt, workspace_settings, post_type from attempt where _id = ? limit 1
+`
+
+func (q *Queries) GetAttemptByID(ctx context.Context, id int64) (Attempt, error) {
+	row := q.db.QueryRowContext(ctx, getAttemptByID, id)
+	var i Attempt
+	err := row.Scan(
+		&i.ID,
+		&i.PostTitle,
+		&i.Description,
+		&i.Author,
+		&i.AuthorID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RepoID,
+		&i.AuthorTier,
+		&i.Coffee,
+		&i.PostID,
+		&i.Closed,
+		&i.Success,
+		&i.ClosedDate,
+		&i.Tier,
+		&i.ParentAttempt,
+		&i.WorkspaceSettings,
+		&i.PostType,
+	)
+	return i, err
+}
+
+const listAttemptsByAuthor = `-- name: ListAttemptsByAuthor :many
+-- Pages through the attempts of an author from newest to oldest
+select _id, post_title, description, author, author_id, created_at, updated_at, repo_id, author_tier, coffee, post_id, closed, success, closed_date, tier, parent_attempt, workspace_settings, post_type from attempt
+where author_id = ? and _id < ?
+order by _id desc
+limit ?
+`
+
+type ListAttemptsByAuthorParams struct {
+	AuthorID int64
+	ID       int64
+	Limit    int32
+}
+
+// Pages through the attempts of an author from newest to oldest
+func (q *Queries) ListAttemptsByAuthor(ctx context.Context, arg ListAttemptsByAuthorParams) ([]Attempt, error) {
+	rows, err := q.db.QueryContext(ctx, listAttemptsByAuthor, arg.AuthorID, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Attempt
+	for rows.Next() {
+		var i Attempt
+		if err := rows.Scan(
+			&i.ID,
+			&i.PostTitle,
+			&i.Description,
+			&i.Author,
+			&i.AuthorID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RepoID,
+			&i.AuthorTier,
+			&i.Coffee,
+			&i.PostID,
+			&i.Closed,
+			&i.Success,
+			&i.ClosedDate,
+			&i.Tier,
+			&i.ParentAttempt,
+			&i.WorkspaceSettings,
+			&i.PostType,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAttemptsByPost = `-- name: ListAttemptsByPost :many
+-- Pages through the attempts of a post from newest to oldest
+select _id, post_title, description, author, author_id, created_at, updated_at, repo_id, author_tier, coffee, post_id, closed, success, closed_date, tier, parent_attempt, workspace_settings, post_type from attempt
+where post_id = ? and _id < ?
+order by _id desc
+limit ?
+`
+
+type ListAttemptsByPostParams struct {
+	PostID int64
+	ID     int64
+	Limit  int32
+}
+
+// Pages through the attempts of a post from newest to oldest
+func (q *Queries) ListAttemptsByPost(ctx context.Context, arg ListAttemptsByPostParams) ([]Attempt, error) {
+	rows, err := q.db.QueryContext(ctx, listAttemptsByPost, arg.PostID, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Attempt
+	for rows.Next() {
+		var i Attempt
+		if err := rows.Scan(
+			&i.ID,
+			&i.PostTitle,
+			&i.Description,
+			&i.Author,
+			&i.AuthorID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RepoID,
+			&i.AuthorTier,
+			&i.Coffee,
+			&i.PostID,
+			&i.Closed,
+			&i.Success,
+			&i.ClosedDate,
+			&i.Tier,
+			&i.ParentAttempt,
+			&i.WorkspaceSettings,
+			&i.PostType,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const closeAttempt = `-- name: CloseAttempt :execrows
+update attempt set closed = true, success = ?, closed_date = ?, updated_at = ? where _id = ? and closed = false
+`
+
+type CloseAttemptParams struct {
+	Success    bool
+	ClosedDate sql.NullTime
+	UpdatedAt  time.Time
+	ID         int64
+}
+
+func (q *Queries) CloseAttempt(ctx context.Context, arg CloseAttemptParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, closeAttempt, arg.Success, arg.ClosedDate, arg.UpdatedAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getWorkspaceByID = `-- name: GetWorkspaceByID :one
+select _id, code_source_id, code_source_type, repo_id, created_at, owner_id, template_id, expiration, commit, state, init_state, init_failure, last_state_update, workspace_settings, over_allocated, ports, is_ephemeral from workspaces where _id = ? limit 1
+`
+
+func (q *Queries) GetWorkspaceByID(ctx context.Context, id int64) (Workspace, error) {
+	row := q.db.QueryRowContext(ctx, getWorkspaceByID, id)
+	var i Workspace
+	err := row.Scan(
+		&i.ID,
+		&i.CodeSourceID,
+		&i.CodeSourceType,
+		&i.RepoID,
+		&i.CreatedAt,
+		&i.OwnerID,
+		&i.TemplateID,
+		&i.Expiration,
+		&i.Commit,
+		&i.State,
+		&i.InitState,
+		&i.InitFailure,
+		&i.LastStateUpdate,
+		&i.WorkspaceSettings,
+		&i.OverAllocated,
+		&i.Ports,
+		&i.IsEphemeral,
+	)
+	return i, err
+}
+
+const listWorkspacesByOwner = `-- name: ListWorkspacesByOwner :many
+-- Pages through the workspaces of an owner from newest to oldest
+select _id, code_source_id, code_source_type, repo_id, created_at, owner_id, template_id, expiration, commit, state, init_state, init_failure, last_state_update, workspace_settings, over_allocated, ports, is_ephemeral from workspaces
+where owner_id = ? and _id < ?
+order by _id desc
+limit ?
+`
+
+type ListWorkspacesByOwnerParams struct {
+	OwnerID int64
+	ID      int64
+	Limit   int32
+}
+
+// Pages through the workspaces of an owner from newest to oldest
+func (q *Queries) ListWorkspacesByOwner(ctx context.Context, arg ListWorkspacesByOwnerParams) ([]Workspace, error) {
+	rows, err := q.db.QueryContext(ctx, listWorkspacesByOwner, arg.OwnerID, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Workspace
+	for rows.Next() {
+		var i Workspace
+		if err := rows.Scan(
+			&i.ID,
+			&i.CodeSourceID,
+			&i.CodeSourceType,
+			&i.RepoID,
+			&i.CreatedAt,
+			&i.OwnerID,
+			&i.TemplateID,
+			&i.Expiration,
+			&i.Commit,
+			&i.State,
+			&i.InitState,
+			&i.InitFailure,
+			&i.LastStateUpdate,
+			&i.WorkspaceSettings,
+			&i.OverAllocated,
+			&i.Ports,
+			&i.IsEphemeral,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWorkspacesByState = `-- name: ListWorkspacesByState :many
+-- Pages through the workspaces in a state from oldest to newest
+select _id, code_source_id, code_source_type, repo_id, created_at, owner_id, template_id, expiration, commit, state, init_state, init_failure, last_state_update, workspace_settings, over_allocated, ports, is_ephemeral from workspaces
+where state = ? and _id > ?
+order by _id
+limit ?
+`
+
+type ListWorkspacesByStateParams struct {
+	State int64
+	ID    int64
+	Limit int32
+}
+
+// Pages through the workspaces in a state from oldest to newest
+func (q *Queries) ListWorkspacesByState(ctx context.Context, arg ListWorkspacesByStateParams) ([]Workspace, error) {
+	rows, err := q.db.QueryContext(ctx, listWorkspacesByState, arg.State, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Workspace
+	for rows.Next() {
+		var i Workspace
+		if err := rows.Scan(
+			&i.ID,
+			&i.CodeSourceID,
+			&i.CodeSourceType,
+			&i.RepoID,
+			&i.CreatedAt,
+			&i.OwnerID,
+			&i.TemplateID,
+			&i.Expiration,
+			&i.Commit,
+			&i.State,
+			&i.InitState,
+			&i.InitFailure,
+			&i.LastStateUpdate,
+			&i.WorkspaceSettings,
+			&i.OverAllocated,
+			&i.Ports,
+			&i.IsEphemeral,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateWorkspaceState = `-- name: UpdateWorkspaceState :exec
+update workspaces set state = ?, last_state_update = ? where _id = ?
+`
+
+type UpdateWorkspaceStateParams struct {
+	State           int64
+	LastStateUpdate time.Time
+	ID              int64
+}
+
+func (q *Queries) UpdateWorkspaceState(ctx context.Context, arg UpdateWorkspaceStateParams) error {
+	_, err := q.db.ExecContext(ctx, updateWorkspaceState, arg.State, arg.LastStateUpdate, arg.ID)
+	return err
+}
+
+const updateWorkspaceInitState = `-- name: UpdateWorkspaceInitState :exec
+update workspaces set init_state = ?, last_state_update = ? where _id = ?
+`
+
+type UpdateWorkspaceInitStateParams struct {
+	InitState       int32
+	LastStateUpdate time.Time
+	ID              int64
+}
+
+func (q *Queries) UpdateWorkspaceInitState(ctx context.Context, arg UpdateWorkspaceInitStateParams) error {
+	_, err := q.db.ExecContext(ctx, updateWorkspaceInitState, arg.InitState, arg.LastStateUpdate, arg.ID)
+	return err
+}
+
+const getWorkspaceAgentByID = `-- name: GetWorkspaceAgentByID :one
+select _id, created_at, updated_at, first_connect, last_connect, last_disconnect, last_connected_node, disconnect_count, state, workspace_id, version, owner_id, secret from workspace_agent where _id = ? limit 1
+`
+
+func (q *Queries) GetWorkspaceAgentByID(ctx context.Context, id int64) (WorkspaceAgent, error) {
+	row := q.db.QueryRowContext(ctx, getWorkspaceAgentByID, id)
+	var i WorkspaceAgent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FirstConnect,
+		&i.LastConnect,
+		&i.LastDisconnect,
+		&i.LastConnectedNode,
+		&i.DisconnectCount,
+		&i.State,
+		&i.WorkspaceID,
+		&i.Version,
+		&i.OwnerID,
+		&i.Secret,
+	)
+	return i, err
+}
+
+const getLatestWorkspaceAgentByWorkspace = `-- name: GetLatestWorkspaceAgentByWorkspace :one
+select _id, created_at, updated_at, first_connect, last_connect, last_disconnect, last_connected_node, disconnect_count, state, workspace_id, version, owner_id, secret from workspace_agent
+where workspace_id = ?
+order by created_at desc
+limit 1
+`
+
+func (q *Queries) GetLatestWorkspaceAgentByWorkspace(ctx context.Context, workspaceID int64) (WorkspaceAgent, error) {
+	row := q.db.QueryRowContext(ctx, getLatestWorkspaceAgentByWorkspace, workspaceID)
+	var i WorkspaceAgent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FirstConnect,
+		&i.LastConnect,
+		&i.LastDisconnect,
+		&i.LastConnectedNode,
+		&i.DisconnectCount,
+		&i.State,
+		&i.WorkspaceID,
+		&i.Version,
+		&i.OwnerID,
+		&i.Secret,
+	)
+	return i, err
+}
+
+const listWorkspaceAgentsByOwner = `-- name: ListWorkspaceAgentsByOwner :many
+-- Pages through the workspace agents of an owner from newest to oldest
+select _id, created_at, updated_at, first_connect, last_connect, last_disconnect, last_connected_node, disconnect_count, state, workspace_id, version, owner_id, secret from workspace_agent
+where owner_id = ? and _id < ?
+order by _id desc
+limit ?
+`
+
+type ListWorkspaceAgentsByOwnerParams struct {
+	OwnerID int64
+	ID      int64
+	Limit   int32
+}
+
+// Pages through the workspace agents of an owner from newest to oldest
+func (q *Queries) ListWorkspaceAgentsByOwner(ctx context.Context, arg ListWorkspaceAgentsByOwnerParams) ([]WorkspaceAgent, error) {
+	rows, err := q.db.QueryContext(ctx, listWorkspaceAgentsByOwner, arg.OwnerID, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WorkspaceAgent
+	for rows.Next() {
+		var i WorkspaceAgent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FirstConnect,
+			&i.LastConnect,
+			&i.LastDisconnect,
+			&i.LastConnectedNode,
+			&i.DisconnectCount,
+			&i.State,
+			&i.WorkspaceID,
+			&i.Version,
+			&i.OwnerID,
+			&i.Secret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateWorkspaceAgentState = `-- name: UpdateWorkspaceAgentState :exec
+update workspace_agent set state = ?, updated_at = ? where _id = ?
+`
+
+type UpdateWorkspaceAgentStateParams struct {
+	State     int32
+	UpdatedAt time.Time
+	ID        int64
+}
+
+func (q *Queries) UpdateWorkspaceAgentState(ctx context.Context, arg UpdateWorkspaceAgentStateParams) error {
+	_, err := q.db.ExecContext(ctx, updateWorkspaceAgentState, arg.State, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const getNotificationByID = `-- name: GetNotificationByID :one
+select _id, user_id, message, notification_type, created_at, acknowledged, interacting_user_id, collapsed_count from notification where _id = ? limit 1
+`
+
+func (q *Queries) GetNotificationByID(ctx context.Context, id int64) (Notification, error) {
+	row := q.db.QueryRowContext(ctx, getNotificationByID, id)
+	var i Notification
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Message,
+		&i.NotificationType,
+		&i.CreatedAt,
+		&i.Acknowledged,
+		&i.InteractingUserID,
+		&i.CollapsedCount,
+	)
+	return i, err
+}
+
+const listNotificationsByUser = `-- name: ListNotificationsByUser :many
+-- Pages through the notifications of a user from newest to oldest
+select _id, user_id, message, notification_type, created_at, acknowledged, interacting_user_id, collapsed_count from notification
+where user_id = ? and _id < ?
+order by _id desc
+limit ?
+`
+
+type ListNotificationsByUserParams struct {
+	UserID int64
+	ID     int64
+	Limit  int32
+}
+
+// Pages through the notifications of a user from newest to oldest
+func (q *Queries) ListNotificationsByUser(ctx context.Context, arg ListNotificationsByUserParams) ([]Notification, error) {
+	rows, err := q.db.QueryContext(ctx, listNotificationsByUser, arg.UserID, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Notification
+	for rows.Next() {
+		var i Notification
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Message,
+			&i.NotificationType,
+			&i.CreatedAt,
+			&i.Acknowledged,
+			&i.InteractingUserID,
+			&i.CollapsedCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnacknowledgedNotificationsByUser = `-- name: ListUnacknowledgedNotificationsByUser :many
+select _id, user_id, message, notification_type, created_at, acknowledged, interacting_user_id, collapsed_count from notification
+where user_id = ? and acknowledged = false
+order by _id desc
+limit ?
+`
+
+type ListUnacknowledgedNotificationsByUserParams struct {
+	UserID int64
+	Limit  int32
+}
+
+func (q *Queries) ListUnacknowledgedNotificationsByUser(ctx context.Context, arg ListUnacknowledgedNotificationsByUserParams) ([]Notification, error) {
+	rows, err := q.db.QueryContext(ctx, listUnacknowledgedNotificationsByUser, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Notification
+	for rows.Next() {
+		var i Notification
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Message,
+			&i.NotificationType,
+			&i.CreatedAt,
+			&i.Acknowledged,
+			&i.InteractingUserID,
+			&i.CollapsedCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const acknowledgeNotification = `-- name: AcknowledgeNotification :execrows
+update notification set acknowledged = true where _id = ? and user_id = ?
+`
+
+type AcknowledgeNotificationParams struct {
+	ID     int64
+	UserID int64
+}
+
+func (q *Queries) AcknowledgeNotification(ctx context.Context, arg AcknowledgeNotificationParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, acknowledgeNotification, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const acknowledgeAllNotifications = `-- name: AcknowledgeAllNotifications :execrows
+update notification set acknowledged = true where user_id = ? and acknowledged = false
+`
+
+func (q *Queries) AcknowledgeAllNotifications(ctx context.Context, userID int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, acknowledgeAllNotifications, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
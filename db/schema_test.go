@@ -0,0 +1,227 @@
+package ti
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var (
+	schemaCommentPattern     = regexp.MustCompile(`--[^\n]*`)
+	schemaCreateTablePattern = regexp.MustCompile(`(?is)^create\s+table\s+(?:if\s+not\s+exists\s+)?` + "`?" + `(\w+)` + "`?" + `\s*\((.*)\)`)
+	schemaAlterTablePattern  = regexp.MustCompile(`(?is)^alter\s+table\s+(\w+)\s+(.*)$`)
+	schemaDropTablePattern   = regexp.MustCompile(`(?is)^drop\s+table\s+(?:if\s+exists\s+)?(\w+)`)
+	schemaAddColumnPattern   = regexp.MustCompile(`(?is)^add\s+column\s+(?:if\s+not\s+exists\s+)?` + "`?" + `(\w+)`)
+	schemaDropColumnPattern  = regexp.MustCompile(`(?is)^drop\s+column\s+(?:if\s+exists\s+)?` + "`?" + `(\w+)`)
+
+	queryNamePattern      = regexp.MustCompile(`(?m)^-- name: (\w+) :\w+\s*$`)
+	queryGeneratedPattern = regexp.MustCompile("(?s)const \\w+ = `-- name: (\\w+) :\\w+\n(.*?)`")
+	querySelectAllPattern = regexp.MustCompile(`(?is)^select\s+\*\s+from\s+(\w+)`)
+	querySelectPattern    = regexp.MustCompile(`(?is)^select\s+(.*?)\s+from\s+(\w+)`)
+)
+
+// schemaTables
+//
+//	Applies the create, alter and drop table statements in the sql to the
+//	tables and returns the resulting columns of each table
+func schemaTables(tables map[string]map[string]bool, sql string) {
+	sql = schemaCommentPattern.ReplaceAllString(sql, "")
+	for _, statement := range strings.Split(sql, ";") {
+		statement = strings.TrimSpace(statement)
+
+		if match := schemaCreateTablePattern.FindStringSubmatch(statement); match != nil {
+			columns := make(map[string]bool)
+			depth := 0
+			start := 0
+			body := match[2]
+			// split the definitions on the commas that are not nested in parentheses
+			for i := 0; i <= len(body); i++ {
+				if i < len(body) {
+					switch body[i] {
+					case '(':
+						depth++
+						continue
+					case ')':
+						depth--
+						continue
+					case ',':
+						if depth > 0 {
+							continue
+						}
+					default:
+						continue
+					}
+				}
+				fields := strings.Fields(body[start:i])
+				start = i + 1
+				if len(fields) == 0 {
+					continue
+				}
+				switch strings.ToLower(fields[0]) {
+				case "primary", "key", "index", "unique", "constraint", "foreign", "fulltext":
+					continue
+				}
+				columns[strings.ToLower(strings.Trim(fields[0], "`"))] = true
+			}
+			tables[strings.ToLower(match[1])] = columns
+			continue
+		}
+
+		if match := schemaDropTablePattern.FindStringSubmatch(statement); match != nil {
+			delete(tables, strings.ToLower(match[1]))
+			continue
+		}
+
+		if match := schemaAlterTablePattern.FindStringSubmatch(statement); match != nil {
+			columns, ok := tables[strings.ToLower(match[1])]
+			if !ok {
+				continue
+			}
+			for _, spec := range strings.Split(match[2], ",") {
+				spec = strings.TrimSpace(spec)
+				if m := schemaAddColumnPattern.FindStringSubmatch(spec); m != nil {
+					columns[strings.ToLower(m[1])] = true
+				}
+				if m := schemaDropColumnPattern.FindStringSubmatch(spec); m != nil {
+					delete(columns, strings.ToLower(m[1]))
+				}
+			}
+		}
+	}
+}
+
+func TestSchemaMatchesMigrations(t *testing.T) {
+	versions, err := migrationVersions(migrations, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// build the tables from the migrations in the order they are applied
+	migrated := make(map[string]map[string]bool)
+	for _, version := range versions {
+		matches, err := fs.Glob(migrations, path.Join("migrations", fmt.Sprintf("%d_*.up.sql", version)))
+		if err != nil || len(matches) != 1 {
+			t.Fatalf("failed to locate migration %d: %v", version, err)
+		}
+		buf, err := fs.ReadFile(migrations, matches[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		schemaTables(migrated, string(buf))
+	}
+
+	buf, err := os.ReadFile("gen/schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := make(map[string]map[string]bool)
+	schemaTables(schema, string(buf))
+
+	problems := make([]string, 0)
+	for table, columns := range migrated {
+		schemaColumns, ok := schema[table]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("table %s is missing from schema.sql", table))
+			continue
+		}
+		for column := range columns {
+			if !schemaColumns[column] {
+				problems = append(problems, fmt.Sprintf("column %s.%s is missing from schema.sql", table, column))
+			}
+		}
+		for column := range schemaColumns {
+			if !columns[column] {
+				problems = append(problems, fmt.Sprintf("column %s.%s is not created by any migration", table, column))
+			}
+		}
+	}
+	for table := range schema {
+		if _, ok := migrated[table]; !ok {
+			problems = append(problems, fmt.Sprintf("table %s is not created by any migration", table))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		t.Fatalf("schema.sql is out of sync with the migrations:\n  %s", strings.Join(problems, "\n  "))
+	}
+}
+
+// namedQueries
+//
+//	Splits the sql into the queries that follow each sqlc name annotation
+//	with their comments removed
+func namedQueries(sql string) map[string]string {
+	queries := make(map[string]string)
+	names := queryNamePattern.FindAllStringSubmatchIndex(sql, -1)
+	for i, match := range names {
+		end := len(sql)
+		if i+1 < len(names) {
+			end = names[i+1][0]
+		}
+		query := schemaCommentPattern.ReplaceAllString(sql[match[1]:end], "")
+		queries[sql[match[2]:match[3]]] = strings.TrimSpace(query)
+	}
+	return queries
+}
+
+func TestGeneratedQueriesMatchSchema(t *testing.T) {
+	buf, err := os.ReadFile("gen/schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := make(map[string]map[string]bool)
+	schemaTables(schema, string(buf))
+
+	buf, err = os.ReadFile("gen/query.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := namedQueries(string(buf))
+
+	buf, err = os.ReadFile("query.sql.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	problems := make([]string, 0)
+	for _, match := range queryGeneratedPattern.FindAllStringSubmatch(string(buf), -1) {
+		name := match[1]
+		// only queries selecting every column are expanded by the generator
+		selectAll := querySelectAllPattern.FindStringSubmatch(queries[name])
+		if selectAll == nil {
+			continue
+		}
+
+		generated := querySelectPattern.FindStringSubmatch(strings.TrimSpace(schemaCommentPattern.ReplaceAllString(match[2], "")))
+		if generated == nil {
+			problems = append(problems, fmt.Sprintf("%s does not select from %s", name, selectAll[1]))
+			continue
+		}
+
+		columns := schema[strings.ToLower(selectAll[1])]
+		selected := make(map[string]bool)
+		for _, column := range strings.Split(generated[1], ",") {
+			column = strings.ToLower(strings.Trim(strings.TrimSpace(column), "`"))
+			selected[column] = true
+			if !columns[column] {
+				problems = append(problems, fmt.Sprintf("%s selects %s.%s which is not in schema.sql", name, selectAll[1], column))
+			}
+		}
+		for column := range columns {
+			if !selected[column] {
+				problems = append(problems, fmt.Sprintf("%s does not select %s.%s", name, selectAll[1], column))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		t.Fatalf("query.sql.go is out of sync with schema.sql, regenerate it from gen/query.sql:\n  %s", strings.Join(problems, "\n  "))
+	}
+}
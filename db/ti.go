@@ -6,14 +6,10 @@ import (
 	"embed"
 	"errors"
 	"fmt"
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/mysql"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/gage-technologies/gigo-lib/config"
 	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
 	"go.opentelemetry.io/otel/trace"
-	"os"
-	"path/filepath"
+	"sync/atomic"
 	"time"
 )
 
@@ -28,91 +24,63 @@ type Database struct {
 	User   string
 	Pass   string
 	*Queries
-}
 
-func CreateDatabase(host string, port string, driverName string, username string, password string, databaseName string) (*Database, error) {
-	dataSourceName := fmt.Sprintf("%s:%s@tcp(%s:%s)/?parseTime=true&tidb_skip_isolation_level_check=1", username, password, host, port)
+	// replicas receive the read queries when read replicas are configured
+	replicas     []*sql.DB
+	replicaAddrs []string
+	next         atomic.Uint32
 
-	db, err := sql.Open(driverName, dataSourceName)
-	if err != nil {
-		return nil, err
-	}
-	_, err = db.Exec("create database if not exists " + databaseName)
-	if err != nil {
-		return nil, err
-	}
-
-	err = db.Close()
-	if err != nil {
-		return nil, err
-	}
-
-	dataSourceName = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&tidb_skip_isolation_level_check=1", username, password, host, port, databaseName)
-
-	db, err = sql.Open(driverName, dataSourceName)
-	if err != nil {
-		return nil, err
-	}
-
-	db.SetMaxIdleConns(16384)
-	db.SetMaxOpenConns(16384)
-	db.SetConnMaxLifetime(time.Minute * 5)
-
-	dataB := Database{
-		DB:     db,
-		DBName: databaseName,
-		Host:   host,
-		Port:   port,
-		User:   username,
-		Pass:   password,
-	}
+	// stats records the executed statements once the database is instrumented
+	stats atomic.Pointer[queryStats]
+	// poolCollectors remove the pool stats from the metrics when the database is closed
+	poolCollectors []func()
+}
 
-	// create a tmp directory and write all migrations to it
-	tmp, err := os.MkdirTemp("", "migrations")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp directory: %s", err)
-	}
-	defer os.RemoveAll(tmp)
-	migrationFiles, err := migrations.ReadDir("migrations")
-	if err != nil {
-		return nil, fmt.Errorf("failed to read migrations directory: %s", err)
-	}
-	for _, file := range migrationFiles {
-		buf, err := migrations.ReadFile(filepath.Join("migrations", file.Name()))
-		if err != nil {
-			return nil, fmt.Errorf("failed to read migration file: %s", err)
-		}
-		err = os.WriteFile(fmt.Sprintf("%s/%s", tmp, file.Name()), buf, 0600)
-		if err != nil {
-			return nil, fmt.Errorf("failed to write migration file: %s", err)
-		}
-	}
+// CreateDatabase
+//
+//	Opens a connection to the database and applies all pending migrations.
+//	Slow queries are counted but not logged; use CreateDatabaseFromConfig
+//	to pass a logger for them.
+func CreateDatabase(host string, port string, driverName string, username string, password string, databaseName string) (*Database, error) {
+	return CreateDatabaseFromConfig(legacyConfig(host, port, driverName, username, password, databaseName), nil)
+}
 
-	migrationDriver, err := mysql.WithInstance(db, &mysql.Config{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create migration driver: %s", err)
-	}
-	migrator, err := migrate.NewWithDatabaseInstance("file://"+tmp, "mysql", migrationDriver)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create migrator: %s", err)
-	}
+// OpenDatabase
+//
+//	Opens a connection to the database, creating the database if it
+//	does not exist, without applying any migrations. Slow queries are
+//	counted but not logged; use OpenDatabaseFromConfig to pass a logger
+//	for them.
+func OpenDatabase(host string, port string, driverName string, username string, password string, databaseName string) (*Database, error) {
+	return OpenDatabaseFromConfig(legacyConfig(host, port, driverName, username, password, databaseName), nil)
+}
 
-	err = migrator.Up()
-	if err != nil {
-		if err == migrate.ErrNoChange {
-			return &dataB, nil
-		}
-		return nil, fmt.Errorf("failed to run migrations: %s", err)
+// legacyConfig
+//
+//	Builds the config used by the positional database constructors
+func legacyConfig(host string, port string, driverName string, username string, password string, databaseName string) config.TitaniumConfig {
+	return config.TitaniumConfig{
+		TitaniumHost:     host,
+		TitaniumPort:     port,
+		DriverName:       driverName,
+		TitaniumName:     databaseName,
+		TitaniumUser:     username,
+		TitaniumPassword: password,
 	}
-
-	return &dataB, nil
 }
 
 func Close(db *Database) error {
+	db.unregisterPools()
 	err := db.DB.Close()
 	if err != nil {
 		return errors.New(fmt.Sprintf("Failed to close database: %d", err))
 	}
+	for _, replica := range db.replicas {
+		err = replica.Close()
+		if err != nil {
+			return errors.New(fmt.Sprintf("Failed to close read replica: %v", err))
+		}
+	}
 	return nil
 }
 
@@ -134,7 +102,10 @@ func (db *Database) QueryContext(ctx context.Context, span *trace.Span, callerNa
 		ctx = dctx
 	}
 
-	return db.DB.QueryContext(ctx, query, args...)
+	start := time.Now()
+	rows, err := db.reader(ctx).QueryContext(ctx, query, args...)
+	db.observe("query", callerName, query, args, start, err)
+	return rows, err
 }
 
 func (db *Database) QueryRowContext(ctx context.Context, span *trace.Span, callerName *string, query string, args ...interface{}) *sql.Row {
@@ -148,7 +119,10 @@ func (db *Database) QueryRowContext(ctx context.Context, span *trace.Span, calle
 		ctx = dctx
 	}
 
-	return db.DB.QueryRowContext(ctx, query, args...)
+	start := time.Now()
+	row := db.reader(ctx).QueryRowContext(ctx, query, args...)
+	db.observe("queryrow", callerName, query, args, start, row.Err())
+	return row
 }
 
 func (db *Database) ExecContext(ctx context.Context, span *trace.Span, callerName *string, query string, args ...interface{}) (sql.Result, error) {
@@ -163,7 +137,10 @@ func (db *Database) ExecContext(ctx context.Context, span *trace.Span, callerNam
 		ctx = dctx
 	}
 
-	return db.DB.ExecContext(ctx, query, args...)
+	start := time.Now()
+	res, err := db.DB.ExecContext(ctx, query, args...)
+	db.observe("exec", callerName, query, args, start, err)
+	return res, err
 }
 
 func (db *Database) PrepareContext(ctx context.Context, span *trace.Span, callerName *string, query string) (*sql.Stmt, error) {
@@ -192,7 +169,10 @@ func (db *Database) Query(ctx context.Context, span *trace.Span, callerName *str
 		ctx = dctx
 	}
 
-	return db.DB.Query(query, args...)
+	start := time.Now()
+	rows, err := db.reader(ctx).Query(query, args...)
+	db.observe("query", callerName, query, args, start, err)
+	return rows, err
 
 }
 
@@ -208,7 +188,10 @@ func (db *Database) QueryRow(ctx context.Context, span *trace.Span, callerName *
 		ctx = dctx
 	}
 
-	return db.DB.QueryRow(query, args...)
+	start := time.Now()
+	row := db.reader(ctx).QueryRow(query, args...)
+	db.observe("queryrow", callerName, query, args, start, row.Err())
+	return row
 
 }
 
@@ -224,7 +207,10 @@ func (db *Database) Exec(ctx context.Context, span *trace.Span, callerName *stri
 		ctx = dctx
 	}
 
-	return db.DB.Exec(query, args...)
+	start := time.Now()
+	res, err := db.DB.Exec(query, args...)
+	db.observe("exec", callerName, query, args, start, err)
+	return res, err
 
 }
 
@@ -249,6 +235,7 @@ type Tx struct {
 	span       *trace.Span
 	ctx        context.Context
 	callerName *string
+	stats      *queryStats
 }
 
 func (db *Database) BeginTx(ctx context.Context, span *trace.Span, callerName *string, opts *sql.TxOptions) (*Tx, error) {
@@ -266,7 +253,7 @@ func (db *Database) BeginTx(ctx context.Context, span *trace.Span, callerName *s
 		return nil, err
 	}
 
-	return &Tx{tx, span, ctx, callerName}, nil
+	return &Tx{tx, span, ctx, callerName, db.stats.Load()}, nil
 }
 
 func (tx *Tx) Query(callerName *string, query string, args ...any) (*sql.Rows, error) {
@@ -279,7 +266,10 @@ func (tx *Tx) Query(callerName *string, query string, args ...any) (*sql.Rows, e
 		defer dbspan.End()
 	}
 
-	return tx.Tx.Query(query, args...)
+	start := time.Now()
+	rows, err := tx.Tx.Query(query, args...)
+	tx.observe("query", callerName, query, args, start, err)
+	return rows, err
 }
 
 func (tx *Tx) QueryRow(callerName *string, query string, args ...any) *sql.Row {
@@ -292,7 +282,10 @@ func (tx *Tx) QueryRow(callerName *string, query string, args ...any) *sql.Row {
 		defer dbspan.End()
 	}
 
-	return tx.Tx.QueryRow(query, args...)
+	start := time.Now()
+	row := tx.Tx.QueryRow(query, args...)
+	tx.observe("queryrow", callerName, query, args, start, row.Err())
+	return row
 }
 
 func (tx *Tx) QueryContext(ctx context.Context, callerName *string, query string, args ...any) (*sql.Rows, error) {
@@ -307,7 +300,10 @@ func (tx *Tx) QueryContext(ctx context.Context, callerName *string, query string
 		ctx = tx.ctx
 	}
 
-	return tx.Tx.QueryContext(ctx, query, args...)
+	start := time.Now()
+	rows, err := tx.Tx.QueryContext(ctx, query, args...)
+	tx.observe("query", callerName, query, args, start, err)
+	return rows, err
 }
 
 func (tx *Tx) Exec(callerName *string, query string, args ...any) (sql.Result, error) {
@@ -320,7 +316,10 @@ func (tx *Tx) Exec(callerName *string, query string, args ...any) (sql.Result, e
 		defer dbspan.End()
 	}
 
-	return tx.Tx.Exec(query, args...)
+	start := time.Now()
+	res, err := tx.Tx.Exec(query, args...)
+	tx.observe("exec", callerName, query, args, start, err)
+	return res, err
 
 }
 
@@ -335,7 +334,10 @@ func (tx *Tx) ExecContext(ctx context.Context, callerName *string, query string,
 		ctx = tx.ctx
 	}
 
-	return tx.Tx.ExecContext(ctx, query, args...)
+	start := time.Now()
+	res, err := tx.Tx.ExecContext(ctx, query, args...)
+	tx.observe("exec", callerName, query, args, start, err)
+	return res, err
 
 }
 
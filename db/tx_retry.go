@@ -0,0 +1,182 @@
+package ti
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// retryableErrorCodes are the MySQL and TiDB error codes that indicate
+// a transaction can be safely retried from the beginning
+var retryableErrorCodes = map[uint16]string{
+	1205: "lock wait timeout",
+	1213: "deadlock",
+	8002: "write conflict on select for update",
+	8022: "transaction retry failed",
+	8028: "information schema changed",
+	9001: "pd server timeout",
+	9002: "tikv server timeout",
+	9004: "resolve lock timeout",
+	9005: "region unavailable",
+	9007: "write conflict",
+}
+
+// TxRetryPolicy
+//
+//	Controls how RunInTx retries transactions that fail with a
+//	retryable error
+type TxRetryPolicy struct {
+	// MaxAttempts is the maximum number of times the transaction is executed
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry; each retry doubles the delay
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries
+	MaxDelay time.Duration
+}
+
+// DefaultTxRetryPolicy is the policy used by RunInTx
+var DefaultTxRetryPolicy = TxRetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   time.Millisecond * 10,
+	MaxDelay:    time.Second,
+}
+
+// IsRetryableTxError
+//
+//	Returns whether the error is a MySQL or TiDB error that
+//	can be resolved by retrying the transaction
+func IsRetryableTxError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	_, ok := retryableErrorCodes[mysqlErr.Number]
+	return ok
+}
+
+// RunInTx
+//
+//	Executes the function inside a transaction and commits it. The
+//	transaction is rolled back if the function returns an error and the
+//	whole transaction is retried using DefaultTxRetryPolicy when the
+//	function or the commit fails with a retryable error. The function
+//	must not have side effects outside the transaction since it may be
+//	executed more than once.
+//
+//	Retryable errors are detected with errors.As so the function must
+//	wrap the errors of the transaction's statements with %w; errors
+//	wrapped with %v are never retried.
+func (db *Database) RunInTx(ctx context.Context, span *trace.Span, callerName *string, opts *sql.TxOptions, fn func(ctx context.Context, tx *Tx) error) error {
+	return db.RunInTxWithPolicy(ctx, span, callerName, opts, DefaultTxRetryPolicy, fn)
+}
+
+// RunInTxWithPolicy
+//
+//	Same as RunInTx but uses the passed retry policy. Every attempt is
+//	recorded as an event on the passed span and the final number of
+//	attempts is set as an attribute of the span.
+func (db *Database) RunInTxWithPolicy(ctx context.Context, span *trace.Span, callerName *string, opts *sql.TxOptions, policy TxRetryPolicy, fn func(ctx context.Context, tx *Tx) error) error {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 1
+	}
+
+	name := "run-in-tx"
+	if callerName != nil {
+		name = *callerName
+	}
+
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		err = db.runTxAttempt(ctx, span, &name, attempt, opts, fn)
+		if err == nil || !IsRetryableTxError(err) || attempt >= policy.MaxAttempts {
+			break
+		}
+
+		if span != nil {
+			(*span).AddEvent("db-tx-retry", trace.WithAttributes(
+				attribute.Int("db.tx.attempt", attempt),
+				attribute.String("db.tx.error", err.Error()),
+			))
+		}
+
+		// wait before retrying unless the context is done
+		timer := time.NewTimer(txRetryDelay(policy, attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = fmt.Errorf("%v: %v", ctx.Err(), err)
+		case <-timer.C:
+			continue
+		}
+		break
+	}
+
+	if span != nil {
+		(*span).SetAttributes(attribute.Int("db.tx.attempts", attempt))
+	}
+
+	if err != nil && attempt > 1 {
+		return fmt.Errorf("transaction failed after %d attempts: %w", attempt, err)
+	}
+	return err
+}
+
+// runTxAttempt
+//
+//	Executes a single attempt of a transaction. Commit and rollback end
+//	the span the transaction was started with so each attempt is traced
+//	with its own child span to leave the caller's span open.
+func (db *Database) runTxAttempt(ctx context.Context, span *trace.Span, callerName *string, attempt int, opts *sql.TxOptions, fn func(ctx context.Context, tx *Tx) error) error {
+	var attemptSpan *trace.Span
+	if span != nil {
+		actx, s := (*span).TracerProvider().Tracer("gigo-core").Start(ctx,
+			fmt.Sprintf("%v-db-tx-attempt-%d", *callerName, attempt),
+			trace.WithSpanKind(trace.SpanKindInternal),
+		)
+		ctx = actx
+		attemptSpan = &s
+	}
+
+	tx, err := db.BeginTx(ctx, attemptSpan, callerName, opts)
+	if err != nil {
+		if attemptSpan != nil {
+			(*attemptSpan).End()
+		}
+		return err
+	}
+
+	err = fn(ctx, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit(callerName)
+}
+
+// txRetryDelay
+//
+//	Returns the jittered exponential backoff before the next attempt
+func txRetryDelay(policy TxRetryPolicy, attempt int) time.Duration {
+	delay := policy.MaxDelay
+	// avoid overflowing the shift on long retry chains
+	if attempt <= 32 {
+		delay = policy.BaseDelay << (attempt - 1)
+	}
+	if delay <= 0 || (policy.MaxDelay > 0 && delay > policy.MaxDelay) {
+		delay = policy.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// full jitter spreads retries from competing transactions apart
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
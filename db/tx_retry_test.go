@@ -0,0 +1,117 @@
+package ti
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryableTxError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"plain", errors.New("boom"), false},
+		{"write conflict", &mysql.MySQLError{Number: 9007}, true},
+		{"deadlock", &mysql.MySQLError{Number: 1213}, true},
+		{"region unavailable", &mysql.MySQLError{Number: 9005}, true},
+		{"wrapped", fmt.Errorf("failed to insert: %w", &mysql.MySQLError{Number: 9007}), true},
+		{"duplicate key", &mysql.MySQLError{Number: 1062}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsRetryableTxError(tt.err))
+		})
+	}
+}
+
+func TestTxRetryDelay(t *testing.T) {
+	policy := TxRetryPolicy{
+		MaxAttempts: 10,
+		BaseDelay:   time.Millisecond * 10,
+		MaxDelay:    time.Millisecond * 50,
+	}
+
+	for attempt := 1; attempt <= 64; attempt++ {
+		delay := txRetryDelay(policy, attempt)
+		assert.Greater(t, delay, time.Duration(0))
+		assert.LessOrEqual(t, delay, policy.MaxDelay)
+		if attempt == 1 {
+			assert.LessOrEqual(t, delay, policy.BaseDelay)
+		}
+	}
+
+	assert.Equal(t, time.Duration(0), txRetryDelay(TxRetryPolicy{}, 3))
+}
+
+// txRetryTestDriver
+//
+//	Driver whose connections only support empty transactions
+type txRetryTestDriver struct{}
+
+func (txRetryTestDriver) Open(string) (driver.Conn, error) { return txRetryTestConn{}, nil }
+
+type txRetryTestConn struct{}
+
+func (txRetryTestConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("statements are not supported")
+}
+func (txRetryTestConn) Close() error              { return nil }
+func (txRetryTestConn) Begin() (driver.Tx, error) { return txRetryTestConn{}, nil }
+func (txRetryTestConn) Commit() error             { return nil }
+func (txRetryTestConn) Rollback() error           { return nil }
+
+func init() {
+	sql.Register("gigo-tx-retry-test", txRetryTestDriver{})
+}
+
+func TestDatabase_RunInTxWithPolicy(t *testing.T) {
+	pool, err := sql.Open("gigo-tx-retry-test", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer pool.Close()
+
+	db := &Database{DB: pool}
+	policy := TxRetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	// errors wrapped with %w inside the closure are retried
+	attempts := 0
+	err = db.RunInTxWithPolicy(context.TODO(), nil, nil, nil, policy, func(ctx context.Context, tx *Tx) error {
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("failed to insert: %w", &mysql.MySQLError{Number: 9007})
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	// retries stop at the policy's maximum attempts
+	attempts = 0
+	err = db.RunInTxWithPolicy(context.TODO(), nil, nil, nil, policy, func(ctx context.Context, tx *Tx) error {
+		attempts++
+		return fmt.Errorf("failed to insert: %w", &mysql.MySQLError{Number: 1213})
+	})
+	assert.True(t, IsRetryableTxError(err))
+	assert.Equal(t, 5, attempts)
+
+	// errors that are not retryable fail on the first attempt
+	attempts = 0
+	err = db.RunInTxWithPolicy(context.TODO(), nil, nil, nil, policy, func(ctx context.Context, tx *Tx) error {
+		attempts++
+		return fmt.Errorf("failed to insert: %w", &mysql.MySQLError{Number: 1062})
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
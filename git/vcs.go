@@ -148,6 +148,10 @@ func (v *VCSClient) EditUser(loginName string, userName string, fullName string,
 
 func (v *VCSClient) DeleteUser(userName string) error {
 	res, err := v.GiteaClient.AdminDeleteUser(userName)
+	// treat a user that does not exist as deleted so that deletions can be retried
+	if res != nil && res.StatusCode == 404 {
+		return nil
+	}
 	if err != nil {
 		return errors.New(fmt.Sprintf("failed to delete user, err: %v", err))
 	}
@@ -0,0 +1,299 @@
+package mq
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"sync"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/gage-technologies/gigo-lib/db/models"
+	"github.com/gage-technologies/gigo-lib/logging"
+	mqmodels "github.com/gage-technologies/gigo-lib/mq/models"
+	"github.com/gage-technologies/gigo-lib/mq/streams"
+	"github.com/nats-io/nats.go"
+)
+
+// ChatHistoryCursor
+//
+//	Position of the newest chat message that a client has seen and the
+//	revision of it that the client has seen. Messages newer than the
+//	cursor are replayed in full while older messages are only replayed
+//	when they have been edited.
+type ChatHistoryCursor struct {
+	MessageID int64
+	Revision  int64
+}
+
+// ChatHistoryHandler
+//
+//	Callback executed for every replayed and live chat message
+type ChatHistoryHandler func(msg *models.ChatMessage)
+
+// ChatHistoryOptions
+//
+//	Options for a ChatHistory
+type ChatHistoryOptions struct {
+	Js *JetstreamClient
+	// DB is used to load messages that have been evicted from the
+	// history stream; the fallback is disabled if DB is nil
+	DB     *ti.Database
+	Logger logging.Logger
+}
+
+// ChatHistory
+//
+//	Replayable log of chat messages backed by a limits based jetstream
+//	stream. Clients that reconnect resume from the last message they saw,
+//	replaying the messages they missed before switching to live delivery.
+//	Messages that have already been evicted from the stream are loaded
+//	from the chat_messages table.
+type ChatHistory struct {
+	js     *JetstreamClient
+	db     *ti.Database
+	logger logging.Logger
+}
+
+// NewChatHistory
+//
+//	Creates a new ChatHistory
+func NewChatHistory(opts ChatHistoryOptions) *ChatHistory {
+	return &ChatHistory{
+		js:     opts.Js,
+		db:     opts.DB,
+		logger: opts.Logger,
+	}
+}
+
+// Publish
+//
+//	Publishes a chat message to the live chat subject and records it in
+//	the chat history stream. The history is de-duplicated on the message
+//	id and revision so publishing the same revision twice is safe.
+func (h *ChatHistory) Publish(ctx context.Context, msg *models.ChatMessage) error {
+	buf := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buf).Encode(mqmodels.NewMessageMsg{Message: *msg})
+	if err != nil {
+		return fmt.Errorf("failed to encode chat message: %v", err)
+	}
+
+	_, err = h.js.Publish(
+		fmt.Sprintf(streams.SubjectChatHistoryDynamic, msg.ChatID), buf.Bytes(),
+		nats.MsgId(fmt.Sprintf("chat-%d-%d", msg.ID, msg.Revision)),
+		nats.Context(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record chat message in history: %v", err)
+	}
+
+	_, err = h.js.Publish(fmt.Sprintf(streams.SubjectChatMessagesDynamic, msg.ChatID), buf.Bytes(), nats.Context(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to publish chat message: %v", err)
+	}
+
+	return nil
+}
+
+// ChatHistorySubscription
+//
+//	Active replay and live delivery of a single chat
+type ChatHistorySubscription struct {
+	history *ChatHistory
+	ctx     context.Context
+	chatID  int64
+	handler ChatHistoryHandler
+	sub     *nats.Subscription
+	// cursor is the newest message delivered to the handler
+	cursor ChatHistoryCursor
+	// revisions holds the last revision delivered for each message so that
+	// edits of messages older than the cursor are still delivered
+	revisions map[int64]int64
+	// gapChecked is set once the range between the resume cursor and
+	// the oldest retained message has been loaded from the database
+	gapChecked bool
+	lock       *sync.Mutex
+}
+
+// Resume
+//
+//	Replays every message in the chat that is newer than the passed cursor
+//	and then continues with live delivery until the context is cancelled or
+//	the subscription is closed. The handler is never called concurrently and
+//	receives messages in order. A zero cursor skips the replay entirely and
+//	only delivers messages published from now on.
+func (h *ChatHistory) Resume(ctx context.Context, chatID int64, cursor ChatHistoryCursor, handler ChatHistoryHandler) (*ChatHistorySubscription, error) {
+	s := &ChatHistorySubscription{
+		history:   h,
+		ctx:       ctx,
+		chatID:    chatID,
+		handler:   handler,
+		cursor:    cursor,
+		revisions: map[int64]int64{cursor.MessageID: cursor.Revision},
+		lock:      &sync.Mutex{},
+	}
+
+	subject := fmt.Sprintf(streams.SubjectChatHistoryDynamic, chatID)
+	opts := []nats.SubOpt{nats.OrderedConsumer(), nats.DeliverAll()}
+
+	if cursor == (ChatHistoryCursor{}) {
+		// skip the replay for clients that have never seen the chat
+		s.gapChecked = true
+		opts = []nats.SubOpt{nats.OrderedConsumer(), nats.DeliverNew()}
+	} else {
+		// when the stream holds no messages for the chat the callback will
+		// not fire until a new message is published, so the range that was
+		// evicted from the stream is loaded from the database up front
+		_, err := h.js.GetLastMsg(streams.StreamChatHistory, subject)
+		if err != nil {
+			if !errors.Is(err, nats.ErrMsgNotFound) {
+				return nil, fmt.Errorf("failed to retrieve chat history: %v", err)
+			}
+			err = s.fillGap(0)
+			if err != nil {
+				return nil, err
+			}
+			s.gapChecked = true
+		}
+	}
+
+	sub, err := h.js.Subscribe(subject, s.handle, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to chat history: %v", err)
+	}
+	s.sub = sub
+
+	// close the subscription once the context is done
+	go func() {
+		<-ctx.Done()
+		s.Close()
+	}()
+
+	return s, nil
+}
+
+// Cursor
+//
+//	Returns the last message that was delivered to the handler
+func (s *ChatHistorySubscription) Cursor() ChatHistoryCursor {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.cursor
+}
+
+// Close
+//
+//	Stops the delivery of chat messages
+func (s *ChatHistorySubscription) Close() {
+	_ = s.sub.Unsubscribe()
+}
+
+// handle
+//
+//	Callback for messages delivered from the chat history stream
+func (s *ChatHistorySubscription) handle(msg *nats.Msg) {
+	var event mqmodels.NewMessageMsg
+	err := gob.NewDecoder(bytes.NewReader(msg.Data)).Decode(&event)
+	if err != nil {
+		s.history.logger.Errorf("failed to decode chat history message for chat %d: %v", s.chatID, err)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// the first retained message marks the start of the stream's range so
+	// anything published before that message has been evicted
+	if !s.gapChecked {
+		s.gapChecked = true
+		before := int64(-1)
+		switch {
+		case event.Message.Revision > 0:
+			// edits are published out of id order so any message newer
+			// than the cursor may have been evicted before the edit
+			before = 0
+		case event.Message.ID > s.cursor.MessageID:
+			before = event.Message.ID
+		}
+		if before >= 0 {
+			err = s.fillGapLocked(before)
+			if err != nil {
+				s.history.logger.Errorf("failed to load evicted chat history for chat %d: %v", s.chatID, err)
+			}
+		}
+	}
+
+	s.deliverLocked(&event.Message)
+}
+
+// fillGap
+//
+//	Acquires the lock and loads evicted messages from the database
+func (s *ChatHistorySubscription) fillGap(before int64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.fillGapLocked(before)
+}
+
+// fillGapLocked
+//
+//	Loads the messages newer than the cursor from the database and
+//	delivers them to the handler. A non-zero before limits the load
+//	to messages older than the passed id.
+func (s *ChatHistorySubscription) fillGapLocked(before int64) error {
+	if s.history.db == nil {
+		return nil
+	}
+
+	query := "select * from chat_messages where chat_id = ? and _id > ? order by _id"
+	args := []interface{}{s.chatID, s.cursor.MessageID}
+	if before > 0 {
+		query = "select * from chat_messages where chat_id = ? and _id > ? and _id < ? order by _id"
+		args = append(args, before)
+	}
+
+	// read the gap from the primary since the cursor moves past any message
+	// that a lagging replica has not received yet
+	callerName := "ChatHistoryFillGap"
+	res, err := s.history.db.QueryContext(ti.WithPrimaryReads(s.ctx), nil, &callerName, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query chat messages: %v", err)
+	}
+	defer res.Close()
+
+	for res.Next() {
+		msg, err := models.ChatMessageFromSQLNative(res)
+		if err != nil {
+			return fmt.Errorf("failed to load chat message: %v", err)
+		}
+		s.deliverLocked(msg)
+	}
+	if err := res.Err(); err != nil {
+		return fmt.Errorf("failed to query chat messages: %v", err)
+	}
+
+	return nil
+}
+
+// deliverLocked
+//
+//	Delivers the message to the handler if it is newer than the cursor or
+//	is a newer revision of a message that has already been seen. Messages
+//	older than the cursor that have not been delivered were seen by the
+//	client before it resumed, so only their edits are delivered.
+func (s *ChatHistorySubscription) deliverLocked(msg *models.ChatMessage) {
+	seen, ok := s.revisions[msg.ID]
+	if ok && msg.Revision <= seen {
+		return
+	}
+	if !ok && msg.ID <= s.cursor.MessageID && msg.Revision == 0 {
+		return
+	}
+
+	s.revisions[msg.ID] = msg.Revision
+	if msg.ID >= s.cursor.MessageID {
+		s.cursor = ChatHistoryCursor{MessageID: msg.ID, Revision: msg.Revision}
+	}
+	s.handler(msg)
+}
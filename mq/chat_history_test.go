@@ -0,0 +1,140 @@
+package mq_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gage-technologies/gigo-lib/db/models"
+	"github.com/gage-technologies/gigo-lib/logging"
+	"github.com/gage-technologies/gigo-lib/mq"
+	"github.com/gage-technologies/gigo-lib/mq/mqtest"
+)
+
+func TestChatHistory_Resume(t *testing.T) {
+	logger, err := logging.CreateBasicLogger(logging.NewDefaultBasicLoggerOptions("/tmp/gigo-core-mq-chat-history-test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history := mq.NewChatHistory(mq.ChatHistoryOptions{
+		Js:     mqtest.NewJetstreamClient(t),
+		Logger: logger,
+	})
+
+	for i := int64(1); i <= 5; i++ {
+		err = history.Publish(context.TODO(), models.CreateChatMessage(i, 42, 69, "test", "message", time.Now(), 0, models.ChatMessageTypeInsecure))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// publishing the same revision again should be de-duplicated
+	err = history.Publish(context.TODO(), models.CreateChatMessage(5, 42, 69, "test", "message", time.Now(), 0, models.ChatMessageTypeInsecure))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan *models.ChatMessage, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sub, err := history.Resume(ctx, 42, mq.ChatHistoryCursor{MessageID: 3}, func(msg *models.ChatMessage) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a live edit to the last message should be delivered after the replay
+	err = history.Publish(context.TODO(), models.CreateChatMessage(5, 42, 69, "test", "edited", time.Now(), 1, models.ChatMessageTypeInsecure))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []mq.ChatHistoryCursor{{MessageID: 4}, {MessageID: 5}, {MessageID: 5, Revision: 1}}
+	for _, e := range expected {
+		select {
+		case msg := <-received:
+			if msg.ID != e.MessageID || msg.Revision != e.Revision {
+				t.Fatalf("incorrect message: expected %+v got %d/%d", e, msg.ID, msg.Revision)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("timed out waiting for message %+v", e)
+		}
+	}
+
+	select {
+	case msg := <-received:
+		t.Fatalf("unexpected message: %d/%d", msg.ID, msg.Revision)
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	if sub.Cursor() != expected[2] {
+		t.Fatalf("incorrect cursor: %+v", sub.Cursor())
+	}
+}
+
+func TestChatHistory_ResumeEdits(t *testing.T) {
+	logger, err := logging.CreateBasicLogger(logging.NewDefaultBasicLoggerOptions("/tmp/gigo-core-mq-chat-history-test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history := mq.NewChatHistory(mq.ChatHistoryOptions{
+		Js:     mqtest.NewJetstreamClient(t),
+		Logger: logger,
+	})
+
+	publish := func(id int64, revision int64) {
+		err := history.Publish(context.TODO(), models.CreateChatMessage(id, 42, 69, "test", "message", time.Now(), revision, models.ChatMessageTypeInsecure))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := int64(1); i <= 5; i++ {
+		publish(i, 0)
+	}
+	// an older message is edited after newer messages were sent
+	publish(2, 1)
+
+	received := make(chan *models.ChatMessage, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sub, err := history.Resume(ctx, 42, mq.ChatHistoryCursor{MessageID: 3}, func(msg *models.ChatMessage) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// live edits of messages older than the cursor are delivered while
+	// revisions that have already been delivered are not
+	publish(4, 1)
+	publish(2, 1)
+	publish(6, 0)
+
+	expected := []mq.ChatHistoryCursor{{MessageID: 4}, {MessageID: 5}, {MessageID: 2, Revision: 1}, {MessageID: 4, Revision: 1}, {MessageID: 6}}
+	for _, e := range expected {
+		select {
+		case msg := <-received:
+			if msg.ID != e.MessageID || msg.Revision != e.Revision {
+				t.Fatalf("incorrect message: expected %+v got %d/%d", e, msg.ID, msg.Revision)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("timed out waiting for message %+v", e)
+		}
+	}
+
+	select {
+	case msg := <-received:
+		t.Fatalf("unexpected message: %d/%d", msg.ID, msg.Revision)
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	if sub.Cursor() != (mq.ChatHistoryCursor{MessageID: 6}) {
+		t.Fatalf("incorrect cursor: %+v", sub.Cursor())
+	}
+}
@@ -188,6 +188,36 @@ func (c *JetstreamClient) init() error {
 		return fmt.Errorf("could not initialize chat stream: %v", err)
 	}
 
+	err = c.initStream(
+		streams.StreamChatHistory,
+		streams.StreamSubjectsChatHistory,
+		streams.RetentionPolicyChatHistory,
+		streams.DuplicateFilterWindowChatHistory,
+	)
+	if err != nil {
+		return fmt.Errorf("could not initialize chat history stream: %v", err)
+	}
+
+	err = c.initStreamLimits(streams.StreamChatHistory, streams.MaxMsgsPerSubjectChatHistory, streams.MaxAgeChatHistory)
+	if err != nil {
+		return fmt.Errorf("could not initialize chat history stream limits: %v", err)
+	}
+
+	err = c.initStream(
+		streams.StreamScheduled,
+		streams.StreamSubjectsScheduled,
+		streams.RetentionPolicyScheduled,
+		streams.DuplicateFilterWindowScheduled,
+	)
+	if err != nil {
+		return fmt.Errorf("could not initialize scheduled stream: %v", err)
+	}
+
+	err = c.initStreamLimits(streams.StreamScheduled, streams.MaxMsgsPerSubjectScheduled, 0)
+	if err != nil {
+		return fmt.Errorf("could not initialize scheduled stream limits: %v", err)
+	}
+
 	return nil
 }
 
@@ -202,9 +232,10 @@ func (c *JetstreamClient) initStream(stream string, subjects []string, retention
 	// create stream if it doesn't exist
 	if s == nil {
 		_, err := c.AddStream(&nats.StreamConfig{
-			Name:      stream,
-			Subjects:  subjects,
-			Retention: retentionPolicy,
+			Name:       stream,
+			Subjects:   subjects,
+			Retention:  retentionPolicy,
+			Duplicates: duplicateWindow,
 		})
 		if err != nil && !strings.Contains(err.Error(), "stream name already in use") {
 			return fmt.Errorf("could not create stream: %v", err)
@@ -232,14 +263,22 @@ func (c *JetstreamClient) initStream(stream string, subjects []string, retention
 		reConfigure = true
 	}
 
+	// check if the duplicate window matches; a zero window leaves the
+	// server's default window in place
+	if duplicateWindow > 0 && s.Config.Duplicates != duplicateWindow {
+		reConfigure = true
+	}
+
 	// conditionally reconfigure stream
 	if reConfigure {
-		_, err := c.UpdateStream(&nats.StreamConfig{
-			Name:       stream,
-			Subjects:   subjects,
-			Retention:  retentionPolicy,
-			Duplicates: duplicateWindow,
-		})
+		// update the existing config so limits applied to the stream are preserved
+		cfg := s.Config
+		cfg.Subjects = subjects
+		cfg.Retention = retentionPolicy
+		if duplicateWindow > 0 {
+			cfg.Duplicates = duplicateWindow
+		}
+		_, err := c.UpdateStream(&cfg)
 		if err != nil {
 			return fmt.Errorf("could not reconfigure stream: %v", err)
 		}
@@ -247,3 +286,34 @@ func (c *JetstreamClient) initStream(stream string, subjects []string, retention
 
 	return nil
 }
+
+// initStreamLimits
+//
+//	Applies per-subject and age limits to a stream that has already
+//	been initialized. A value of 0 leaves the respective limit unset.
+func (c *JetstreamClient) initStreamLimits(stream string, maxMsgsPerSubject int64, maxAge time.Duration) error {
+	s, err := c.StreamInfo(stream)
+	if err != nil {
+		return fmt.Errorf("could not retrieve stream info: %v", err)
+	}
+
+	// jetstream represents an unset per-subject limit as -1
+	if maxMsgsPerSubject == 0 {
+		maxMsgsPerSubject = -1
+	}
+
+	// exit if the stream is already configured
+	if s.Config.MaxMsgsPerSubject == maxMsgsPerSubject && s.Config.MaxAge == maxAge {
+		return nil
+	}
+
+	cfg := s.Config
+	cfg.MaxMsgsPerSubject = maxMsgsPerSubject
+	cfg.MaxAge = maxAge
+	_, err = c.UpdateStream(&cfg)
+	if err != nil {
+		return fmt.Errorf("could not reconfigure stream limits: %v", err)
+	}
+
+	return nil
+}
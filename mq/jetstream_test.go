@@ -1,55 +1,89 @@
-package mq
+package mq_test
 
 import (
 	"testing"
 	"time"
 
-	"github.com/gage-technologies/gigo-lib/config"
 	"github.com/gage-technologies/gigo-lib/logging"
+	"github.com/gage-technologies/gigo-lib/mq"
+	"github.com/gage-technologies/gigo-lib/mq/mqtest"
 	"github.com/gage-technologies/gigo-lib/mq/streams"
 )
 
 func TestJetstreamClient(t *testing.T) {
-	logger, err := logging.CreateBasicLogger(logging.NewDefaultBasicLoggerOptions("/tmp/gigo-core-js-test.log"))
+	js := mqtest.NewJetstreamClient(t)
+
+	_, err := js.Publish(streams.SubjectWorkspaceStop, []byte("test"))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	js, err := NewJetstreamClient(config.JetstreamConfig{
-		Host:        "mq://127.0.0.1:4222",
-		MaxPubQueue: 256,
-	}, logger)
+	sub, err := js.SubscribeSync(streams.SubjectWorkspaceStop)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	defer js.Close()
+	msg, err := sub.NextMsg(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	_, err = js.Publish(streams.SubjectWorkspaceStop, []byte("test"))
+	if string(msg.Data) != "test" {
+		t.Fatal("incorrect message received:", string(msg.Data))
+	}
+
+	if msg.Subject != streams.SubjectWorkspaceStop {
+		t.Fatal("incorrect subject:", msg.Subject)
+	}
+
+	err = msg.AckSync()
 	if err != nil {
 		t.Fatal(err)
 	}
+}
 
-	sub, err := js.SubscribeSync(streams.SubjectWorkspaceStop)
+func TestJetstreamClient_ReconfigureStream(t *testing.T) {
+	logger, err := logging.CreateBasicLogger(logging.NewDefaultBasicLoggerOptions("/tmp/gigo-core-mq-reconfigure-test.log"))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	msg, err := sub.NextMsg(time.Second)
+	cfg := mqtest.RunJetstreamServer(t)
+	js, err := mq.NewJetstreamClient(cfg, logger)
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer js.Close()
 
-	if string(msg.Data) != "test" {
-		t.Fatal("incorrect message received:", string(msg.Data))
+	// simulate a stream created before the duplicate window was configured
+	// that has a limit applied to it
+	info, err := js.StreamInfo(streams.StreamWorkspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outdated := info.Config
+	outdated.Duplicates = time.Second
+	outdated.MaxMsgs = 420
+	_, err = js.UpdateStream(&outdated)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	if msg.Subject != streams.SubjectWorkspaceStop {
-		t.Fatal("incorrect subject:", msg.Subject)
+	// initializing a new client restores the duplicate window and keeps the limit
+	reinitialized, err := mq.NewJetstreamClient(cfg, logger)
+	if err != nil {
+		t.Fatal(err)
 	}
+	defer reinitialized.Close()
 
-	err = msg.AckSync()
+	info, err = reinitialized.StreamInfo(streams.StreamWorkspace)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if info.Config.Duplicates != streams.DuplicateFilterWindowWorkspace {
+		t.Fatalf("duplicate window was not restored: %v", info.Config.Duplicates)
+	}
+	if info.Config.MaxMsgs != 420 {
+		t.Fatalf("stream limits were reset: %d", info.Config.MaxMsgs)
+	}
 }
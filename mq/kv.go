@@ -0,0 +1,303 @@
+package mq
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/nats-io/nats.go"
+)
+
+// KVUpdate
+//
+//	A single change to a key in a typed key-value bucket. Value
+//	is nil when the key was deleted or purged or when the stored
+//	value could not be decoded, in which case Err is set.
+type KVUpdate[T any] struct {
+	Key      string
+	Value    *T
+	Revision uint64
+	Deleted  bool
+	Err      error
+}
+
+// TypedKeyValue
+//
+//	Wrapper around a jetstream key-value bucket that gob encodes
+//	values of type T the same way that messages are encoded for
+//	the jetstream streams.
+type TypedKeyValue[T any] struct {
+	kv nats.KeyValue
+}
+
+// NewTypedKeyValue
+//
+//	Creates a new TypedKeyValue for the passed bucket
+func NewTypedKeyValue[T any](kv nats.KeyValue) *TypedKeyValue[T] {
+	return &TypedKeyValue[T]{kv: kv}
+}
+
+// Bucket
+//
+//	Returns the underlying key-value bucket
+func (t *TypedKeyValue[T]) Bucket() nats.KeyValue {
+	return t.kv
+}
+
+// Get
+//
+//	Retrieves the latest value and revision for the key. Returns
+//	a nil value if the key does not exist or has been deleted.
+func (t *TypedKeyValue[T]) Get(key string) (*T, uint64, error) {
+	entry, err := t.kv.Get(key)
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return nil, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to get key %q: %v", key, err)
+	}
+
+	value, err := decodeKVValue[T](entry.Value())
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode key %q: %v", key, err)
+	}
+
+	return value, entry.Revision(), nil
+}
+
+// Put
+//
+//	Writes the value for the key and returns the new revision
+func (t *TypedKeyValue[T]) Put(key string, value *T) (uint64, error) {
+	buf, err := encodeKVValue(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode key %q: %v", key, err)
+	}
+
+	rev, err := t.kv.Put(key, buf)
+	if err != nil {
+		return 0, fmt.Errorf("failed to put key %q: %v", key, err)
+	}
+	return rev, nil
+}
+
+// Create
+//
+//	Writes the value for the key only if the key does not already
+//	exist. Returns nats.ErrKeyExists if the key exists.
+func (t *TypedKeyValue[T]) Create(key string, value *T) (uint64, error) {
+	buf, err := encodeKVValue(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode key %q: %v", key, err)
+	}
+
+	rev, err := t.kv.Create(key, buf)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create key %q: %w", key, err)
+	}
+	return rev, nil
+}
+
+// Update
+//
+//	Writes the value for the key only if the latest revision of the
+//	key matches the passed revision. Returns nats.ErrKeyExists if the
+//	key has been modified since the revision.
+func (t *TypedKeyValue[T]) Update(key string, value *T, revision uint64) (uint64, error) {
+	buf, err := encodeKVValue(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode key %q: %v", key, err)
+	}
+
+	rev, err := t.kv.Update(key, buf, revision)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update key %q: %w", key, err)
+	}
+	return rev, nil
+}
+
+// Delete
+//
+//	Deletes the key from the bucket
+func (t *TypedKeyValue[T]) Delete(key string) error {
+	err := t.kv.Delete(key)
+	if err != nil {
+		return fmt.Errorf("failed to delete key %q: %v", key, err)
+	}
+	return nil
+}
+
+// Watch
+//
+//	Watches the keys matching the passed pattern (which may include
+//	wildcards) and sends each change to the returned channel. The
+//	current value of every matching key is sent first. Values that
+//	cannot be decoded are sent with Err set so a single bad value does
+//	not tear down the watch. The channel is closed once the context is
+//	cancelled.
+func (t *TypedKeyValue[T]) Watch(ctx context.Context, keys string) (<-chan KVUpdate[T], error) {
+	watcher, err := t.kv.Watch(keys, nats.Context(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch %q: %v", keys, err)
+	}
+
+	updates := make(chan KVUpdate[T])
+	go func() {
+		defer close(updates)
+		defer watcher.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case entry, ok := <-watcher.Updates():
+				if !ok {
+					return
+				}
+
+				// a nil entry marks the end of the initial values
+				if entry == nil {
+					continue
+				}
+
+				update := KVUpdate[T]{
+					Key:      entry.Key(),
+					Revision: entry.Revision(),
+					Deleted:  entry.Operation() != nats.KeyValuePut,
+				}
+
+				if !update.Deleted {
+					value, err := decodeKVValue[T](entry.Value())
+					if err != nil {
+						update.Err = fmt.Errorf("failed to decode key %q: %v", entry.Key(), err)
+					}
+					update.Value = value
+				}
+
+				select {
+				case updates <- update:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return updates, nil
+}
+
+// encodeKVValue
+//
+//	Gob encodes a value for storage in a key-value bucket
+func encodeKVValue[T any](value *T) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buf).Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeKVValue
+//
+//	Gob decodes a value loaded from a key-value bucket
+func decodeKVValue[T any](buf []byte) (*T, error) {
+	var value T
+	err := gob.NewDecoder(bytes.NewReader(buf)).Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// InitKeyValue
+//
+//	Creates or updates a key-value bucket. Buckets are provisioned
+//	the same way as streams: the bucket is created if it does not
+//	exist and its backing stream is reconfigured if the description,
+//	history, ttl, size limits or replicas have changed. The storage
+//	type of a stream cannot be changed so a storage mismatch is
+//	logged and the existing bucket is used.
+func (c *JetstreamClient) InitKeyValue(cfg nats.KeyValueConfig) (nats.KeyValue, error) {
+	// attempt to bind to an existing bucket
+	kv, err := c.KeyValue(cfg.Bucket)
+	if err != nil {
+		if !errors.Is(err, nats.ErrBucketNotFound) {
+			return nil, fmt.Errorf("could not retrieve key-value bucket: %v", err)
+		}
+
+		// create the bucket since it doesn't exist
+		kv, err = c.CreateKeyValue(&cfg)
+		if err != nil {
+			return nil, fmt.Errorf("could not create key-value bucket: %v", err)
+		}
+		return kv, nil
+	}
+
+	// retrieve the backing stream to check if we need to reconfigure
+	s, err := c.StreamInfo(fmt.Sprintf("KV_%s", cfg.Bucket))
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve key-value stream info: %v", err)
+	}
+
+	// apply the same defaults that are used when the bucket is created
+	streamCfg := s.Config
+	streamCfg.Description = cfg.Description
+	streamCfg.MaxAge = cfg.TTL
+	// jetstream stores at least one revision per key
+	streamCfg.MaxMsgsPerSubject = int64(cfg.History)
+	if streamCfg.MaxMsgsPerSubject < 1 {
+		streamCfg.MaxMsgsPerSubject = 1
+	}
+	streamCfg.MaxBytes = cfg.MaxBytes
+	if streamCfg.MaxBytes == 0 {
+		streamCfg.MaxBytes = -1
+	}
+	streamCfg.MaxMsgSize = cfg.MaxValueSize
+	if streamCfg.MaxMsgSize == 0 {
+		streamCfg.MaxMsgSize = -1
+	}
+	streamCfg.Replicas = cfg.Replicas
+	if streamCfg.Replicas == 0 {
+		streamCfg.Replicas = 1
+	}
+
+	if s.Config.Storage != cfg.Storage {
+		c.logger.Warnf("key-value bucket %q uses %s storage but %s storage was configured; the storage of an existing bucket cannot be changed",
+			cfg.Bucket, s.Config.Storage, cfg.Storage)
+	}
+
+	if !reflect.DeepEqual(streamCfg, s.Config) {
+		_, err = c.UpdateStream(&streamCfg)
+		if err != nil {
+			return nil, fmt.Errorf("could not reconfigure key-value bucket: %v", err)
+		}
+	}
+
+	return kv, nil
+}
+
+// InitObjectStore
+//
+//	Creates an object store if it does not already exist
+func (c *JetstreamClient) InitObjectStore(cfg nats.ObjectStoreConfig) (nats.ObjectStore, error) {
+	// attempt to bind to an existing object store
+	obs, err := c.ObjectStore(cfg.Bucket)
+	if err == nil {
+		return obs, nil
+	}
+	if !errors.Is(err, nats.ErrStreamNotFound) && !errors.Is(err, nats.ErrBucketNotFound) {
+		return nil, fmt.Errorf("could not retrieve object store: %v", err)
+	}
+
+	// create the object store since it doesn't exist
+	obs, err = c.CreateObjectStore(&cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not create object store: %v", err)
+	}
+
+	return obs, nil
+}
@@ -0,0 +1,147 @@
+package mq_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gage-technologies/gigo-lib/mq"
+	"github.com/gage-technologies/gigo-lib/mq/mqtest"
+	"github.com/nats-io/nats.go"
+)
+
+type testKVValue struct {
+	Name  string
+	Count int
+}
+
+func TestTypedKeyValue(t *testing.T) {
+	js := mqtest.NewJetstreamClient(t)
+
+	bucket, err := js.InitKeyValue(nats.KeyValueConfig{
+		Bucket:  "GigoTestKV",
+		History: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kv := mq.NewTypedKeyValue[testKVValue](bucket)
+
+	value, _, err := kv.Get("missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != nil {
+		t.Fatal("expected nil value for missing key")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	updates, err := kv.Watch(ctx, "test.>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rev, err := kv.Put("test.key", &testKVValue{Name: "test", Count: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, loadedRev, err := kv.Get("test.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value == nil || value.Name != "test" || value.Count != 1 || loadedRev != rev {
+		t.Fatalf("incorrect value loaded: %+v %d", value, loadedRev)
+	}
+
+	_, err = kv.Update("test.key", &testKVValue{Name: "test", Count: 2}, rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// updates against a stale revision must fail
+	_, err = kv.Update("test.key", &testKVValue{Name: "test", Count: 3}, rev)
+	if err == nil {
+		t.Fatal("expected stale update to fail")
+	}
+
+	err = kv.Delete("test.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// values that cannot be decoded are surfaced without ending the watch
+	_, err = bucket.Put("test.bad", []byte("not gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = kv.Put("test.key", &testKVValue{Name: "test", Count: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []int{1, 2, -1, 0, 4}
+	for _, count := range expected {
+		select {
+		case update := <-updates:
+			if count == 0 {
+				if update.Key != "test.bad" || update.Err == nil || update.Value != nil {
+					t.Fatalf("expected decode error update: %+v", update)
+				}
+				continue
+			}
+			if update.Err != nil {
+				t.Fatal(update.Err)
+			}
+			if count < 0 {
+				if !update.Deleted {
+					t.Fatalf("expected delete update: %+v", update)
+				}
+				continue
+			}
+			if update.Value == nil || update.Value.Count != count {
+				t.Fatalf("incorrect update: %+v", update)
+			}
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for update")
+		}
+	}
+}
+
+func TestJetstreamClient_ReconfigureKeyValue(t *testing.T) {
+	js := mqtest.NewJetstreamClient(t)
+
+	_, err := js.InitKeyValue(nats.KeyValueConfig{
+		Bucket:  "GigoTestReconfigureKV",
+		History: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every config that can be changed on the backing stream is reconciled
+	_, err = js.InitKeyValue(nats.KeyValueConfig{
+		Bucket:       "GigoTestReconfigureKV",
+		Description:  "reconfigured",
+		History:      5,
+		TTL:          time.Hour,
+		MaxValueSize: 1024,
+		MaxBytes:     1 << 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := js.StreamInfo("KV_GigoTestReconfigureKV")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Config.Description != "reconfigured" || info.Config.MaxMsgsPerSubject != 5 || info.Config.MaxAge != time.Hour ||
+		info.Config.MaxMsgSize != 1024 || info.Config.MaxBytes != 1<<20 {
+		t.Fatalf("key-value bucket was not reconfigured: %+v", info.Config)
+	}
+}
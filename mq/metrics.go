@@ -0,0 +1,381 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gage-technologies/gigo-lib/logging"
+	"github.com/gage-technologies/gigo-lib/mq/streams"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/conc"
+)
+
+const metricsNamespace = "gigo_jetstream"
+
+// consumerListSubject is the jetstream api subject that lists the consumers
+// of a stream one page at a time
+const consumerListSubject = "$JS.API.CONSUMER.LIST.%s"
+
+// StreamMetricsCollectorOptions
+//
+//	Options for a StreamMetricsCollector
+type StreamMetricsCollectorOptions struct {
+	Ctx    context.Context
+	Js     *JetstreamClient
+	Logger logging.Logger
+	// Registerer is the prometheus registerer that the metrics
+	// are added to; defaults to prometheus.DefaultRegisterer
+	Registerer prometheus.Registerer
+	// Streams is the set of streams that will be polled;
+	// defaults to streams.AllStreams
+	Streams []string
+	// Interval is the time between polls of the jetstream server
+	Interval time.Duration
+	// LagThreshold is the number of outstanding messages
+	// (pending + unacknowledged) for a consumer above which
+	// a warning will be logged; 0 disables the warnings
+	LagThreshold uint64
+}
+
+// StreamMetricsCollector
+//
+//	Periodically polls the jetstream server for the state of the
+//	configured streams and their consumers and exposes the results
+//	as prometheus metrics. Warnings are logged when a consumer's lag
+//	crosses the configured threshold and again when it recovers.
+type StreamMetricsCollector struct {
+	js           *JetstreamClient
+	logger       logging.Logger
+	streams      []string
+	interval     time.Duration
+	lagThreshold uint64
+
+	streamMessages    *prometheus.GaugeVec
+	streamBytes       *prometheus.GaugeVec
+	streamConsumers   *prometheus.GaugeVec
+	consumerPending   *prometheus.GaugeVec
+	consumerAckPend   *prometheus.GaugeVec
+	consumerRedeliver *prometheus.GaugeVec
+	consumerAckLag    *prometheus.GaugeVec
+	pollErrors        *prometheus.CounterVec
+
+	// consumers tracks the consumers observed on the last poll of
+	// each stream so that metrics for deleted consumers are removed
+	consumers map[string]map[string]bool
+	// lagging tracks the consumers that are currently over the lag threshold
+	lagging map[string]bool
+	// pollLock serializes polls so the state above is not raced
+	pollLock *sync.Mutex
+
+	lock    *sync.Mutex
+	started bool
+	wg      *conc.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewStreamMetricsCollector
+//
+//	Creates a new StreamMetricsCollector and registers its metrics
+//	with the configured prometheus registerer.
+func NewStreamMetricsCollector(opts StreamMetricsCollectorOptions) (*StreamMetricsCollector, error) {
+	if opts.Ctx == nil {
+		opts.Ctx = context.Background()
+	}
+	if opts.Registerer == nil {
+		opts.Registerer = prometheus.DefaultRegisterer
+	}
+	if len(opts.Streams) == 0 {
+		opts.Streams = streams.AllStreams
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second * 15
+	}
+
+	ctx, cancel := context.WithCancel(opts.Ctx)
+
+	c := &StreamMetricsCollector{
+		js:           opts.Js,
+		logger:       opts.Logger,
+		streams:      opts.Streams,
+		interval:     opts.Interval,
+		lagThreshold: opts.LagThreshold,
+		streamMessages: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "stream_messages",
+			Help:      "Number of messages stored in the stream.",
+		}, []string{"stream"}),
+		streamBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "stream_bytes",
+			Help:      "Number of bytes stored in the stream.",
+		}, []string{"stream"}),
+		streamConsumers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "stream_consumers",
+			Help:      "Number of consumers bound to the stream.",
+		}, []string{"stream"}),
+		consumerPending: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "consumer_pending_messages",
+			Help:      "Number of messages matching the consumer that have not been delivered.",
+		}, []string{"stream", "consumer"}),
+		consumerAckPend: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "consumer_ack_pending_messages",
+			Help:      "Number of messages delivered to the consumer that have not been acknowledged.",
+		}, []string{"stream", "consumer"}),
+		consumerRedeliver: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "consumer_redelivered_messages",
+			Help:      "Number of messages that have been redelivered to the consumer.",
+		}, []string{"stream", "consumer"}),
+		consumerAckLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "consumer_ack_floor_lag",
+			Help:      "Distance between the last stream sequence and the consumer's ack floor.",
+		}, []string{"stream", "consumer"}),
+		pollErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "poll_errors_total",
+			Help:      "Number of failed attempts to poll the state of a stream.",
+		}, []string{"stream"}),
+		consumers: make(map[string]map[string]bool),
+		lagging:   make(map[string]bool),
+		pollLock:  &sync.Mutex{},
+		lock:      &sync.Mutex{},
+		wg:        conc.NewWaitGroup(),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+
+	// register all the metrics
+	for _, collector := range []prometheus.Collector{
+		c.streamMessages,
+		c.streamBytes,
+		c.streamConsumers,
+		c.consumerPending,
+		c.consumerAckPend,
+		c.consumerRedeliver,
+		c.consumerAckLag,
+		c.pollErrors,
+	} {
+		err := opts.Registerer.Register(collector)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to register jetstream metric: %v", err)
+		}
+	}
+
+	return c, nil
+}
+
+// Start
+//
+//	Begins polling the jetstream server in the background.
+func (c *StreamMetricsCollector) Start() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// exit quietly if the collector has already been started
+	if c.started {
+		return
+	}
+	c.started = true
+
+	c.wg.Go(c.loop)
+}
+
+// Close
+//
+//	Stops the background polling of the jetstream server.
+func (c *StreamMetricsCollector) Close() {
+	c.cancel()
+	c.wg.Wait()
+}
+
+// loop
+//
+//	Polls the jetstream server on every interval until the collector is closed
+func (c *StreamMetricsCollector) loop() {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		err := c.Poll(c.ctx)
+		if err != nil && c.ctx.Err() == nil {
+			c.logger.Errorf("failed to poll jetstream metrics: %v", err)
+		}
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll
+//
+//	Polls the state of every configured stream and its consumers
+//	once and updates the metrics. Every stream is polled even if
+//	a prior stream fails; the errors are joined into the result.
+func (c *StreamMetricsCollector) Poll(ctx context.Context) error {
+	c.pollLock.Lock()
+	defer c.pollLock.Unlock()
+
+	var errs []error
+	for _, stream := range c.streams {
+		err := c.pollStream(ctx, stream)
+		if err != nil {
+			c.pollErrors.WithLabelValues(stream).Inc()
+			errs = append(errs, fmt.Errorf("stream %s: %v", stream, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pollStream
+//
+//	Updates the metrics for a single stream and its consumers. The
+//	metrics of a stream that no longer exists are removed; the metrics
+//	of the consumers are only pruned once every consumer was listed.
+func (c *StreamMetricsCollector) pollStream(ctx context.Context, stream string) error {
+	info, err := c.js.StreamInfo(stream, nats.Context(ctx))
+	if err != nil {
+		if errors.Is(err, nats.ErrStreamNotFound) {
+			c.dropStream(stream)
+		}
+		return fmt.Errorf("failed to retrieve stream info: %v", err)
+	}
+
+	c.streamMessages.WithLabelValues(stream).Set(float64(info.State.Msgs))
+	c.streamBytes.WithLabelValues(stream).Set(float64(info.State.Bytes))
+	c.streamConsumers.WithLabelValues(stream).Set(float64(info.State.Consumers))
+
+	consumers, err := c.listConsumers(ctx, stream)
+	if err != nil {
+		return fmt.Errorf("failed to list consumers: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, consumer := range consumers {
+		seen[consumer.Name] = true
+
+		// calculate how far the consumer's ack floor trails the end of the stream
+		var ackLag uint64
+		if info.State.LastSeq > consumer.AckFloor.Stream {
+			ackLag = info.State.LastSeq - consumer.AckFloor.Stream
+		}
+
+		c.consumerPending.WithLabelValues(stream, consumer.Name).Set(float64(consumer.NumPending))
+		c.consumerAckPend.WithLabelValues(stream, consumer.Name).Set(float64(consumer.NumAckPending))
+		c.consumerRedeliver.WithLabelValues(stream, consumer.Name).Set(float64(consumer.NumRedelivered))
+		c.consumerAckLag.WithLabelValues(stream, consumer.Name).Set(float64(ackLag))
+
+		c.checkLag(stream, consumer.Name, consumer.NumPending+uint64(consumer.NumAckPending))
+	}
+
+	// drop the metrics for any consumer that no longer exists
+	for consumer := range c.consumers[stream] {
+		if !seen[consumer] {
+			c.dropConsumer(stream, consumer)
+		}
+	}
+	c.consumers[stream] = seen
+
+	return nil
+}
+
+// listConsumers
+//
+//	Lists every consumer of the stream. The consumer listing of the
+//	jetstream context stops silently on the first failed page so the
+//	pages are requested directly and a failure is returned.
+func (c *StreamMetricsCollector) listConsumers(ctx context.Context, stream string) ([]*nats.ConsumerInfo, error) {
+	consumers := make([]*nats.ConsumerInfo, 0)
+	for {
+		req, err := json.Marshal(struct {
+			Offset int `json:"offset"`
+		}{Offset: len(consumers)})
+		if err != nil {
+			return nil, err
+		}
+
+		msg, err := c.js.conn.RequestWithContext(ctx, fmt.Sprintf(consumerListSubject, stream), req)
+		if err != nil {
+			return nil, err
+		}
+
+		var resp struct {
+			Total     int                  `json:"total"`
+			Consumers []*nats.ConsumerInfo `json:"consumers"`
+			Error     *nats.APIError       `json:"error"`
+		}
+		err = json.Unmarshal(msg.Data, &resp)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+
+		consumers = append(consumers, resp.Consumers...)
+		if len(resp.Consumers) == 0 || len(consumers) >= resp.Total {
+			return consumers, nil
+		}
+	}
+}
+
+// dropStream
+//
+//	Removes the metrics of a stream and all of its consumers
+func (c *StreamMetricsCollector) dropStream(stream string) {
+	c.streamMessages.DeleteLabelValues(stream)
+	c.streamBytes.DeleteLabelValues(stream)
+	c.streamConsumers.DeleteLabelValues(stream)
+	for consumer := range c.consumers[stream] {
+		c.dropConsumer(stream, consumer)
+	}
+	delete(c.consumers, stream)
+}
+
+// dropConsumer
+//
+//	Removes the metrics of a single consumer
+func (c *StreamMetricsCollector) dropConsumer(stream string, consumer string) {
+	c.consumerPending.DeleteLabelValues(stream, consumer)
+	c.consumerAckPend.DeleteLabelValues(stream, consumer)
+	c.consumerRedeliver.DeleteLabelValues(stream, consumer)
+	c.consumerAckLag.DeleteLabelValues(stream, consumer)
+	delete(c.lagging, stream+"/"+consumer)
+}
+
+// checkLag
+//
+//	Logs a warning when a consumer crosses the lag threshold
+//	and a notice when it drops back below the threshold
+func (c *StreamMetricsCollector) checkLag(stream string, consumer string, lag uint64) {
+	if c.lagThreshold == 0 {
+		return
+	}
+
+	key := stream + "/" + consumer
+	if lag >= c.lagThreshold {
+		if !c.lagging[key] {
+			c.lagging[key] = true
+			c.logger.Warnf("jetstream consumer %s on stream %s is lagging: %d outstanding messages (threshold %d)", consumer, stream, lag, c.lagThreshold)
+		}
+		return
+	}
+
+	if c.lagging[key] {
+		delete(c.lagging, key)
+		c.logger.Infof("jetstream consumer %s on stream %s has recovered: %d outstanding messages", consumer, stream, lag)
+	}
+}
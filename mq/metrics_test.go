@@ -0,0 +1,125 @@
+package mq_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gage-technologies/gigo-lib/logging"
+	"github.com/gage-technologies/gigo-lib/mq"
+	"github.com/gage-technologies/gigo-lib/mq/mqtest"
+	"github.com/gage-technologies/gigo-lib/mq/streams"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStreamMetricsCollector_Poll(t *testing.T) {
+	logger, err := logging.CreateBasicLogger(logging.NewDefaultBasicLoggerOptions("/tmp/gigo-core-mq-metrics-test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	js := mqtest.NewJetstreamClient(t)
+
+	// create a consumer that never acknowledges its messages
+	_, err = js.AddConsumer(streams.StreamWorkspace, &nats.ConsumerConfig{
+		Durable:       "metrics-test",
+		AckPolicy:     nats.AckExplicitPolicy,
+		FilterSubject: streams.SubjectWorkspaceCreate,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = js.Publish(streams.SubjectWorkspaceCreate, []byte("test"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	registry := prometheus.NewRegistry()
+	collector, err := mq.NewStreamMetricsCollector(mq.StreamMetricsCollectorOptions{
+		Js:           js,
+		Logger:       logger,
+		Registerer:   registry,
+		Streams:      []string{streams.StreamWorkspace, "missing"},
+		LagThreshold: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collector.Close()
+
+	// the missing stream should be reported without blocking the others
+	err = collector.Poll(context.TODO())
+	if err == nil {
+		t.Fatal("expected error for missing stream")
+	}
+
+	expected := `
+# HELP gigo_jetstream_consumer_pending_messages Number of messages matching the consumer that have not been delivered.
+# TYPE gigo_jetstream_consumer_pending_messages gauge
+gigo_jetstream_consumer_pending_messages{consumer="metrics-test",stream="Workspace"} 3
+# HELP gigo_jetstream_consumer_ack_floor_lag Distance between the last stream sequence and the consumer's ack floor.
+# TYPE gigo_jetstream_consumer_ack_floor_lag gauge
+gigo_jetstream_consumer_ack_floor_lag{consumer="metrics-test",stream="Workspace"} 3
+# HELP gigo_jetstream_stream_messages Number of messages stored in the stream.
+# TYPE gigo_jetstream_stream_messages gauge
+gigo_jetstream_stream_messages{stream="Workspace"} 3
+# HELP gigo_jetstream_poll_errors_total Number of failed attempts to poll the state of a stream.
+# TYPE gigo_jetstream_poll_errors_total counter
+gigo_jetstream_poll_errors_total{stream="missing"} 1
+`
+	err = testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"gigo_jetstream_consumer_pending_messages",
+		"gigo_jetstream_consumer_ack_floor_lag",
+		"gigo_jetstream_stream_messages",
+		"gigo_jetstream_poll_errors_total",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// deleted consumers should be removed from the metrics
+	err = js.DeleteConsumer(streams.StreamWorkspace, "metrics-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = collector.Poll(context.TODO())
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, family := range families {
+		if family.GetName() == "gigo_jetstream_consumer_pending_messages" && len(family.GetMetric()) > 0 {
+			t.Fatalf("expected consumer metrics to be removed: %d", len(family.GetMetric()))
+		}
+	}
+
+	// deleted streams should be removed from the metrics
+	err = js.DeleteStream(streams.StreamWorkspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = collector.Poll(context.TODO())
+	if err == nil {
+		t.Fatal("expected error for deleted stream")
+	}
+
+	families, err = registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, family := range families {
+		if family.GetName() == "gigo_jetstream_stream_messages" && len(family.GetMetric()) > 0 {
+			t.Fatalf("expected stream metrics to be removed: %d", len(family.GetMetric()))
+		}
+	}
+}
@@ -1,14 +1,53 @@
 package models
 
-import "encoding/gob"
+import (
+	"encoding/gob"
+
+	"github.com/gage-technologies/gigo-lib/db/models"
+)
 
 // we have to register the custom types with gob
 // so that they can be marshaled and unmarshaled
 func init() {
 	gob.Register(&AddStreakXPMsg{})
+	gob.Register(&XPLevelUpMsg{})
 }
 
 type AddStreakXPMsg struct {
 	ID      int64
 	OwnerID int64
 }
+
+// XPLevelUpMsg
+//
+//	Message published to the level up subject when an XP grant
+//	moves a user to a new level or tier
+type XPLevelUpMsg struct {
+	UserID   int64
+	ReasonID int64
+	Reason   string
+	OldXP    uint64
+	NewXP    uint64
+	OldTier  models.TierType
+	OldLevel models.LevelType
+	NewTier  models.TierType
+	NewLevel models.LevelType
+}
+
+// NewXPLevelUpMsg
+//
+//	Creates the level up message of an XP grant. Passed to the
+//	XPLedger as its LevelUpMsg.
+func NewXPLevelUpMsg(userID int64, result *models.XPGrantResult) interface{} {
+	return XPLevelUpMsg{
+		UserID:   userID,
+		ReasonID: result.Reason.ID,
+		Reason:   result.Reason.Reason,
+		OldXP:    result.OldXP,
+		NewXP:    result.NewXP,
+		OldTier:  result.OldTier,
+		OldLevel: result.OldLevel,
+		NewTier:  result.NewTier,
+		NewLevel: result.NewLevel,
+	}
+}
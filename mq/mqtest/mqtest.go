@@ -0,0 +1,69 @@
+// Package mqtest provides an in-process Jetstream server for tests.
+package mqtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gage-technologies/gigo-lib/config"
+	"github.com/gage-technologies/gigo-lib/logging"
+	"github.com/gage-technologies/gigo-lib/mq"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// RunJetstreamServer boots an embedded nats-server with Jetstream enabled
+// on a random local port, storing its data in a temporary directory. The
+// server is shutdown when the test completes.
+func RunJetstreamServer(t testing.TB) config.JetstreamConfig {
+	t.Helper()
+
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create nats server: %v", err)
+	}
+
+	go srv.Start()
+	if !srv.ReadyForConnections(10 * time.Second) {
+		srv.Shutdown()
+		t.Fatal("nats server was not ready for connections")
+	}
+
+	t.Cleanup(func() {
+		srv.Shutdown()
+		srv.WaitForShutdown()
+	})
+
+	return config.JetstreamConfig{
+		Host:        srv.ClientURL(),
+		MaxPubQueue: 256,
+	}
+}
+
+// NewJetstreamClient boots an embedded Jetstream server and returns a
+// connected client with all of the gigo streams initialized. The client
+// is closed when the test completes.
+func NewJetstreamClient(t testing.TB) *mq.JetstreamClient {
+	t.Helper()
+
+	logger, err := logging.CreateBasicLogger(
+		logging.NewDefaultBasicLoggerOptions("/tmp/mqtest-jetstream-client.log"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	js, err := mq.NewJetstreamClient(RunJetstreamServer(t), logger)
+	if err != nil {
+		t.Fatalf("failed to create jetstream client: %v", err)
+	}
+	t.Cleanup(js.Close)
+
+	return js
+}
@@ -0,0 +1,19 @@
+package mqtest_test
+
+import (
+	"testing"
+
+	"github.com/gage-technologies/gigo-lib/mq/mqtest"
+	"github.com/gage-technologies/gigo-lib/mq/streams"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewJetstreamClient(t *testing.T) {
+	js := mqtest.NewJetstreamClient(t)
+
+	for _, stream := range streams.AllStreams {
+		info, err := js.StreamInfo(stream)
+		require.NoError(t, err)
+		require.Equal(t, stream, info.Config.Name)
+	}
+}
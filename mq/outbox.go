@@ -0,0 +1,221 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/gage-technologies/gigo-lib/db/models"
+	"github.com/gage-technologies/gigo-lib/logging"
+	"github.com/nats-io/nats.go"
+)
+
+// DefaultOutboxMaxAttempts is the number of failed publishes after which
+// a message is parked when the options do not set a limit
+const DefaultOutboxMaxAttempts = 10
+
+// OutboxRelayOptions
+//
+//	Options for an OutboxRelay
+type OutboxRelayOptions struct {
+	DB     *ti.Database
+	Js     *JetstreamClient
+	Logger logging.Logger
+	// BatchSize is the maximum number of pending messages
+	// that will be relayed in a single execution
+	BatchSize int
+	// PollInterval is the minimum time between executions
+	// that check the outbox for pending messages
+	PollInterval time.Duration
+	// Retention is how long sent messages are kept in the
+	// outbox before they are purged
+	Retention time.Duration
+	// MaxAttempts is the number of failed publishes after which a
+	// message is parked so that it no longer blocks the messages
+	// behind it; defaults to DefaultOutboxMaxAttempts
+	MaxAttempts int
+}
+
+// OutboxRelay
+//
+//	Relays messages written to the outbox table to jetstream.
+//	The relay is designed to be executed from the leader routine
+//	of a cluster.Node so that only one relay is active in the
+//	cluster at any given time.
+//
+//	Delivery is at-least-once: a message is marked as sent only
+//	after jetstream has acknowledged the publish, and each publish
+//	carries a message id derived from the outbox id so that jetstream
+//	drops duplicates within the stream's duplicate window.
+//
+//	A message that fails to publish MaxAttempts times is parked: it
+//	stays in the outbox with its last error for operators to inspect
+//	but is no longer relayed.
+type OutboxRelay struct {
+	db           *ti.Database
+	js           *JetstreamClient
+	logger       logging.Logger
+	batchSize    int
+	pollInterval time.Duration
+	retention    time.Duration
+	maxAttempts  int
+	lastPoll     time.Time
+	lastPurge    time.Time
+}
+
+// NewOutboxRelay
+//
+//	Creates a new OutboxRelay
+func NewOutboxRelay(opts OutboxRelayOptions) *OutboxRelay {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = time.Hour * 24
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultOutboxMaxAttempts
+	}
+
+	return &OutboxRelay{
+		db:           opts.DB,
+		js:           opts.Js,
+		logger:       opts.Logger,
+		batchSize:    opts.BatchSize,
+		pollInterval: opts.PollInterval,
+		retention:    opts.Retention,
+		maxAttempts:  opts.MaxAttempts,
+	}
+}
+
+// LeaderRoutine
+//
+//	Executes a single relay cycle. The signature matches cluster.LeaderRoutine
+//	so the relay can be called directly from a node's leader routine. Cycles
+//	are skipped until the poll interval has elapsed since the last execution.
+func (r *OutboxRelay) LeaderRoutine(ctx context.Context) error {
+	// skip this cycle if we polled too recently
+	if time.Since(r.lastPoll) < r.pollInterval {
+		return nil
+	}
+	r.lastPoll = time.Now()
+
+	// relay pending messages until the outbox is drained
+	// or we fail to publish a message
+	for {
+		count, err := r.relayBatch(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to relay outbox batch: %v", err)
+		}
+		if count < r.batchSize {
+			break
+		}
+	}
+
+	// purge sent messages once per retention period
+	if time.Since(r.lastPurge) >= r.retention {
+		err := r.purge(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to purge outbox: %v", err)
+		}
+		r.lastPurge = time.Now()
+	}
+
+	return nil
+}
+
+// relayBatch
+//
+//	Publishes a single batch of pending outbox messages in insertion
+//	order and returns the number of messages that were relayed or
+//	parked. The batch is aborted on the first failed publish so that
+//	messages are never delivered out of order, unless the message has
+//	exhausted its attempts in which case it is parked and the batch
+//	continues with the messages behind it.
+func (r *OutboxRelay) relayBatch(ctx context.Context) (int, error) {
+	callerName := "OutboxRelay"
+
+	// query for the oldest pending messages from the primary so that
+	// messages marked as sent by the previous batch are never selected
+	res, err := r.db.QueryContext(ti.WithPrimaryReads(ctx), nil, &callerName,
+		"select * from outbox_messages where sent_at is null and attempts < ? order by _id limit ?", r.maxAttempts, r.batchSize,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query pending outbox messages: %v", err)
+	}
+
+	// load all messages before publishing so we don't hold the cursor open
+	messages := make([]*models.OutboxMessage, 0)
+	for res.Next() {
+		msg, err := models.OutboxMessageFromSQLNative(res)
+		if err != nil {
+			_ = res.Close()
+			return 0, fmt.Errorf("failed to load outbox message: %v", err)
+		}
+		messages = append(messages, msg)
+	}
+	err = res.Err()
+	_ = res.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to query pending outbox messages: %v", err)
+	}
+
+	for i, msg := range messages {
+		// exit quickly if the routine has been cancelled
+		if ctx.Err() != nil {
+			return i, nil
+		}
+
+		_, err = r.js.Publish(msg.Subject, msg.Payload, nats.MsgId(msg.MsgID()), nats.Context(ctx))
+		if err != nil {
+			// record the failure on the message so it is visible to operators
+			_, uErr := r.db.ExecContext(ctx, nil, &callerName,
+				"update outbox_messages set attempts = attempts + 1, last_error = ? where _id = ?",
+				err.Error(), msg.ID,
+			)
+			if uErr != nil {
+				r.logger.Errorf("failed to record outbox publish failure for %d: %v", msg.ID, uErr)
+				return i, fmt.Errorf("failed to publish outbox message %d to %q: %v", msg.ID, msg.Subject, err)
+			}
+
+			// park the message once it has exhausted its attempts so that
+			// it does not block the rest of the outbox
+			if msg.Attempts+1 >= r.maxAttempts {
+				r.logger.Errorf("parking outbox message %d to %q after %d failed publishes: %v", msg.ID, msg.Subject, msg.Attempts+1, err)
+				continue
+			}
+			return i, fmt.Errorf("failed to publish outbox message %d to %q: %v", msg.ID, msg.Subject, err)
+		}
+
+		// mark the message as sent - if this fails the message will be
+		// published again on the next cycle and de-duplicated by jetstream
+		_, err = r.db.ExecContext(ctx, nil, &callerName,
+			"update outbox_messages set sent_at = ?, attempts = attempts + 1, last_error = null where _id = ?",
+			time.Now(), msg.ID,
+		)
+		if err != nil {
+			return i, fmt.Errorf("failed to mark outbox message %d as sent: %v", msg.ID, err)
+		}
+	}
+
+	return len(messages), nil
+}
+
+// purge
+//
+//	Removes sent messages that are older than the retention period
+func (r *OutboxRelay) purge(ctx context.Context) error {
+	callerName := "OutboxRelayPurge"
+	_, err := r.db.ExecContext(ctx, nil, &callerName,
+		"delete from outbox_messages where sent_at is not null and sent_at < ?",
+		time.Now().Add(-r.retention),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete sent outbox messages: %v", err)
+	}
+	return nil
+}
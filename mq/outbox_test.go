@@ -0,0 +1,113 @@
+package mq_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/gage-technologies/gigo-lib/db/models"
+	"github.com/gage-technologies/gigo-lib/logging"
+	"github.com/gage-technologies/gigo-lib/mq"
+	"github.com/gage-technologies/gigo-lib/mq/mqtest"
+	"github.com/gage-technologies/gigo-lib/mq/streams"
+)
+
+func TestOutboxRelay(t *testing.T) {
+	logger, err := logging.CreateBasicLogger(logging.NewDefaultBasicLoggerOptions("/tmp/gigo-core-mq-outbox-test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := ti.CreateDatabase("gigo-dev-tidb", "4000", "mysql", "gigo-dev", "gigo-dev", "gigo_dev_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = db.DB.Exec("delete from outbox_messages where _id between 6944001 and 6944003")
+	defer db.DB.Exec("delete from outbox_messages where _id between 6944001 and 6944003")
+
+	js := mqtest.NewJetstreamClient(t)
+	subject := fmt.Sprintf(streams.SubjectChatHistoryDynamic, 6944)
+
+	// the second message is published to a subject without a stream so it
+	// can never be delivered and must not block the message behind it
+	messages := []*models.OutboxMessage{
+		models.CreateOutboxMessage(6944001, subject, []byte("first")),
+		models.CreateOutboxMessage(6944002, "OUTBOX_TEST.undeliverable", []byte("poison")),
+		models.CreateOutboxMessage(6944003, subject, []byte("third")),
+	}
+	for _, msg := range messages {
+		for _, statement := range msg.ToSQLNative() {
+			_, err = db.DB.Exec(statement.Statement, statement.Values...)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	relay := mq.NewOutboxRelay(mq.OutboxRelayOptions{
+		DB:           db,
+		Js:           js,
+		Logger:       logger,
+		PollInterval: time.Nanosecond,
+		MaxAttempts:  2,
+	})
+
+	// the first failure aborts the cycle to preserve the order of the messages
+	err = relay.LeaderRoutine(context.TODO())
+	if err == nil {
+		t.Fatal("expected the undeliverable message to fail the cycle")
+	}
+
+	last, err := js.GetLastMsg(streams.StreamChatHistory, subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(last.Data) != "first" {
+		t.Fatalf("incorrect message relayed: %s", last.Data)
+	}
+
+	// the second failure parks the message and relays the rest of the outbox
+	time.Sleep(time.Millisecond)
+	err = relay.LeaderRoutine(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last, err = js.GetLastMsg(streams.StreamChatHistory, subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(last.Data) != "third" || last.Header.Get("Nats-Msg-Id") != messages[2].MsgID() {
+		t.Fatalf("incorrect message relayed: %s", last.Data)
+	}
+
+	// parked messages keep their error and are no longer relayed
+	time.Sleep(time.Millisecond)
+	err = relay.LeaderRoutine(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var attempts int
+	var lastError *string
+	var sentAt *time.Time
+	err = db.DB.QueryRow("select attempts, last_error, sent_at from outbox_messages where _id = ?", 6944002).Scan(&attempts, &lastError, &sentAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 || lastError == nil || sentAt != nil {
+		t.Fatalf("incorrect parked message: %d %v %v", attempts, lastError, sentAt)
+	}
+
+	var pending int
+	err = db.DB.QueryRow("select count(*) from outbox_messages where _id in (6944001, 6944003) and sent_at is null").Scan(&pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending != 0 {
+		t.Fatalf("%d messages were not marked as sent", pending)
+	}
+}
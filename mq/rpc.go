@@ -0,0 +1,419 @@
+package mq
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gage-technologies/gigo-lib/logging"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// this file contains a small request/reply rpc framework that runs
+// over core nats. requests and replies are gob encoded the same way
+// that the jetstream messages in mq/models are.
+
+const (
+	// RPCSubjectPrefix is the prefix used for all rpc subjects. rpc subjects
+	// must never overlap with the subjects of a jetstream stream or the
+	// stream would capture the requests.
+	RPCSubjectPrefix = "RPC"
+
+	// DefaultRPCTimeout is the timeout used for calls whose context
+	// does not already carry a deadline
+	DefaultRPCTimeout = time.Second * 10
+
+	rpcHeaderDeadline     = "Gigo-Rpc-Deadline"
+	rpcHeaderErrorCode    = "Gigo-Rpc-Error-Code"
+	rpcHeaderErrorMessage = "Gigo-Rpc-Error-Message"
+)
+
+// rpcPropagator propagates trace context and baggage across rpc calls
+var rpcPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+// RPCErrorCode
+//
+//	Classification of an error returned by an rpc call
+type RPCErrorCode int
+
+const (
+	RPCErrorInternal RPCErrorCode = iota
+	RPCErrorBadRequest
+	RPCErrorNotFound
+	RPCErrorUnauthorized
+	RPCErrorTimeout
+	RPCErrorUnavailable
+)
+
+func (c RPCErrorCode) String() string {
+	switch c {
+	case RPCErrorInternal:
+		return "Internal"
+	case RPCErrorBadRequest:
+		return "BadRequest"
+	case RPCErrorNotFound:
+		return "NotFound"
+	case RPCErrorUnauthorized:
+		return "Unauthorized"
+	case RPCErrorTimeout:
+		return "Timeout"
+	case RPCErrorUnavailable:
+		return "Unavailable"
+	}
+	return "Unknown"
+}
+
+// RPCError
+//
+//	Structured error that is transferred from an rpc handler
+//	to the caller. Handlers can return an RPCError to control
+//	the code received by the caller; any other error is returned
+//	to the caller as RPCErrorInternal.
+type RPCError struct {
+	Code    RPCErrorCode
+	Message string
+}
+
+// NewRPCError
+//
+//	Creates a new RPCError
+func NewRPCError(code RPCErrorCode, format string, args ...interface{}) *RPCError {
+	return &RPCError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error (%s): %s", e.Code, e.Message)
+}
+
+// RPCErrorCodeOf
+//
+//	Returns the rpc error code of the passed error. Errors that are
+//	not an RPCError are classified as RPCErrorInternal.
+func RPCErrorCodeOf(err error) RPCErrorCode {
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code
+	}
+	return RPCErrorInternal
+}
+
+// natsHeaderCarrier
+//
+//	Adapts a nats.Header to the propagation.TextMapCarrier interface
+type natsHeaderCarrier nats.Header
+
+func (c natsHeaderCarrier) Get(key string) string {
+	return nats.Header(c).Get(key)
+}
+
+func (c natsHeaderCarrier) Set(key string, value string) {
+	nats.Header(c).Set(key, value)
+}
+
+func (c natsHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// RPCHandler
+//
+//	Function that handles a single typed rpc request. The context
+//	carries the caller's deadline and trace context.
+type RPCHandler[Req any, Resp any] func(ctx context.Context, req *Req) (*Resp, error)
+
+// RPCMethod
+//
+//	Typed descriptor for a single rpc method. Methods are declared once
+//	as package level variables and shared by the caller and the handler
+//	so both sides agree on the subject and types:
+//
+//	 var GetWorkspace = mq.NewRPCMethod[GetWorkspaceReq, GetWorkspaceResp]("Workspace.Get")
+//
+//	 // server
+//	 err := GetWorkspace.Handle(server, func(ctx context.Context, req *GetWorkspaceReq) (*GetWorkspaceResp, error) { ... })
+//
+//	 // client
+//	 resp, err := GetWorkspace.Call(ctx, js, &GetWorkspaceReq{ID: id})
+type RPCMethod[Req any, Resp any] struct {
+	Subject string
+}
+
+// NewRPCMethod
+//
+//	Creates a new RPCMethod for the passed name. The name is prefixed
+//	with RPCSubjectPrefix to form the subject of the method.
+func NewRPCMethod[Req any, Resp any](name string) RPCMethod[Req, Resp] {
+	return RPCMethod[Req, Resp]{
+		Subject: fmt.Sprintf("%s.%s", RPCSubjectPrefix, name),
+	}
+}
+
+// Call
+//
+//	Sends a request to the method and waits for the reply. If the context
+//	does not carry a deadline DefaultRPCTimeout is applied. Failures are
+//	always returned as an *RPCError.
+func (m RPCMethod[Req, Resp]) Call(ctx context.Context, js *JetstreamClient, req *Req) (*Resp, error) {
+	// apply the default timeout if the caller didn't set one
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultRPCTimeout)
+		defer cancel()
+	}
+
+	// encode the request
+	buf := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buf).Encode(req)
+	if err != nil {
+		return nil, NewRPCError(RPCErrorBadRequest, "failed to encode request: %v", err)
+	}
+
+	// create request message propagating the deadline and trace context
+	msg := nats.NewMsg(m.Subject)
+	msg.Data = buf.Bytes()
+	deadline, _ := ctx.Deadline()
+	msg.Header.Set(rpcHeaderDeadline, strconv.FormatInt(deadline.UnixNano(), 10))
+	rpcPropagator.Inject(ctx, natsHeaderCarrier(msg.Header))
+
+	// send request and wait for the reply
+	reply, err := js.conn.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		if errors.Is(err, nats.ErrNoResponders) {
+			return nil, NewRPCError(RPCErrorUnavailable, "no handlers available for %s", m.Subject)
+		}
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout) {
+			return nil, NewRPCError(RPCErrorTimeout, "request to %s timed out", m.Subject)
+		}
+		return nil, NewRPCError(RPCErrorUnavailable, "request to %s failed: %v", m.Subject, err)
+	}
+
+	// handle error replies
+	if code := reply.Header.Get(rpcHeaderErrorCode); code != "" {
+		c, err := strconv.Atoi(code)
+		if err != nil {
+			return nil, NewRPCError(RPCErrorInternal, "invalid error code in reply: %q", code)
+		}
+		return nil, &RPCError{
+			Code:    RPCErrorCode(c),
+			Message: reply.Header.Get(rpcHeaderErrorMessage),
+		}
+	}
+
+	// decode the response
+	var resp Resp
+	err = gob.NewDecoder(bytes.NewReader(reply.Data)).Decode(&resp)
+	if err != nil {
+		return nil, NewRPCError(RPCErrorInternal, "failed to decode response: %v", err)
+	}
+
+	return &resp, nil
+}
+
+// Handle
+//
+//	Registers the handler for the method with the passed server
+func (m RPCMethod[Req, Resp]) Handle(server *RPCServer, handler RPCHandler[Req, Resp]) error {
+	return server.register(m.Subject, func(ctx context.Context, data []byte) ([]byte, error) {
+		// decode the request
+		var req Req
+		err := gob.NewDecoder(bytes.NewReader(data)).Decode(&req)
+		if err != nil {
+			return nil, NewRPCError(RPCErrorBadRequest, "failed to decode request: %v", err)
+		}
+
+		// execute the handler
+		resp, err := handler(ctx, &req)
+		if err != nil {
+			return nil, err
+		}
+
+		// encode the zero value for nil responses since gob cannot encode nil pointers
+		if resp == nil {
+			resp = new(Resp)
+		}
+
+		// encode the response
+		buf := bytes.NewBuffer(nil)
+		err = gob.NewEncoder(buf).Encode(resp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode response: %v", err)
+		}
+		return buf.Bytes(), nil
+	})
+}
+
+// rawRPCHandler
+//
+//	Untyped handler used internally by the server
+type rawRPCHandler func(ctx context.Context, data []byte) ([]byte, error)
+
+// RPCServerOptions
+//
+//	Options for an RPCServer
+type RPCServerOptions struct {
+	Ctx    context.Context
+	Js     *JetstreamClient
+	Logger logging.Logger
+	// QueueGroup is the nats queue group that handlers subscribe
+	// with. Requests are load balanced across all servers that
+	// share a queue group.
+	QueueGroup string
+}
+
+// RPCServer
+//
+//	Serves rpc methods registered via RPCMethod.Handle
+type RPCServer struct {
+	ctx           context.Context
+	cancel        context.CancelFunc
+	js            *JetstreamClient
+	logger        logging.Logger
+	queueGroup    string
+	subscriptions []*nats.Subscription
+	// closed is set under the lock by Close so that no handler is added
+	// to the wait group once Close waits on it
+	closed bool
+	wg     sync.WaitGroup
+	lock   sync.Mutex
+}
+
+// NewRPCServer
+//
+//	Creates a new RPCServer
+func NewRPCServer(opts RPCServerOptions) *RPCServer {
+	if opts.Ctx == nil {
+		opts.Ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(opts.Ctx)
+	return &RPCServer{
+		ctx:        ctx,
+		cancel:     cancel,
+		js:         opts.Js,
+		logger:     opts.Logger,
+		queueGroup: opts.QueueGroup,
+	}
+}
+
+// register
+//
+//	Subscribes the handler to the subject within the server's queue group
+func (s *RPCServer) register(subject string, handler rawRPCHandler) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("failed to subscribe to %s: rpc server is closed", subject)
+	}
+
+	sub, err := s.js.conn.QueueSubscribe(subject, s.queueGroup, func(msg *nats.Msg) {
+		// requests that are delivered after the server closed are dropped
+		// and the caller times out
+		s.lock.Lock()
+		if s.closed {
+			s.lock.Unlock()
+			return
+		}
+		s.wg.Add(1)
+		s.lock.Unlock()
+
+		defer s.wg.Done()
+		s.handleMsg(subject, handler, msg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %v", subject, err)
+	}
+
+	s.subscriptions = append(s.subscriptions, sub)
+	return nil
+}
+
+// handleMsg
+//
+//	Executes the handler for a single request and sends the reply
+func (s *RPCServer) handleMsg(subject string, handler rawRPCHandler, msg *nats.Msg) {
+	// derive the handler context from the caller's deadline and trace context
+	ctx := s.ctx
+	if msg.Header != nil {
+		ctx = rpcPropagator.Extract(ctx, natsHeaderCarrier(msg.Header))
+		if d := msg.Header.Get(rpcHeaderDeadline); d != "" {
+			nanos, err := strconv.ParseInt(d, 10, 64)
+			if err == nil {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithDeadline(ctx, time.Unix(0, nanos))
+				defer cancel()
+			}
+		}
+	}
+
+	// execute the handler recovering any panic as an internal error
+	var data []byte
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				s.logger.Errorf("rpc handler for %s panicked: %v\n%s", subject, r, string(debug.Stack()))
+				err = NewRPCError(RPCErrorInternal, "handler panicked")
+			}
+		}()
+		data, err = handler(ctx, msg.Data)
+	}()
+
+	// there is no point in replying if the caller has given up
+	if ctx.Err() != nil {
+		s.logger.Warnf("rpc handler for %s exceeded caller deadline", subject)
+		return
+	}
+
+	reply := nats.NewMsg(msg.Reply)
+	if err != nil {
+		var rpcErr *RPCError
+		if !errors.As(err, &rpcErr) {
+			s.logger.Errorf("rpc handler for %s failed: %v", subject, err)
+			rpcErr = NewRPCError(RPCErrorInternal, "%v", err)
+		}
+		reply.Header.Set(rpcHeaderErrorCode, strconv.Itoa(int(rpcErr.Code)))
+		// headers cannot contain line breaks so we flatten the message
+		reply.Header.Set(rpcHeaderErrorMessage, strings.NewReplacer("\r", " ", "\n", " ").Replace(rpcErr.Message))
+	} else {
+		reply.Data = data
+	}
+
+	err = msg.RespondMsg(reply)
+	if err != nil {
+		s.logger.Errorf("failed to send rpc reply for %s: %v", subject, err)
+	}
+}
+
+// Close
+//
+//	Unsubscribes all handlers, cancels the context of in-flight
+//	requests and waits for the handlers to exit
+func (s *RPCServer) Close() {
+	s.lock.Lock()
+	s.closed = true
+	for _, sub := range s.subscriptions {
+		_ = sub.Unsubscribe()
+	}
+	s.subscriptions = nil
+	s.lock.Unlock()
+
+	s.cancel()
+	s.wg.Wait()
+}
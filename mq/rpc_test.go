@@ -0,0 +1,136 @@
+package mq_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gage-technologies/gigo-lib/logging"
+	"github.com/gage-technologies/gigo-lib/mq"
+	"github.com/gage-technologies/gigo-lib/mq/mqtest"
+)
+
+type testRPCReq struct {
+	Value int64
+}
+
+type testRPCResp struct {
+	Value int64
+}
+
+var testRPCDouble = mq.NewRPCMethod[testRPCReq, testRPCResp]("Test.Double")
+
+func TestRPCErrorCodeOf(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", mq.NewRPCError(mq.RPCErrorNotFound, "missing %d", 42))
+	if code := mq.RPCErrorCodeOf(err); code != mq.RPCErrorNotFound {
+		t.Fatalf("incorrect error code: %s", code)
+	}
+
+	if code := mq.RPCErrorCodeOf(errors.New("test")); code != mq.RPCErrorInternal {
+		t.Fatalf("incorrect error code: %s", code)
+	}
+
+	if testRPCDouble.Subject != "RPC.Test.Double" {
+		t.Fatalf("incorrect subject: %s", testRPCDouble.Subject)
+	}
+}
+
+func TestRPCMethod_Call(t *testing.T) {
+	logger, err := logging.CreateBasicLogger(logging.NewDefaultBasicLoggerOptions("/tmp/gigo-core-rpc-test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	js := mqtest.NewJetstreamClient(t)
+
+	// calls without a handler should be reported as unavailable
+	_, err = testRPCDouble.Call(context.TODO(), js, &testRPCReq{Value: 2})
+	if mq.RPCErrorCodeOf(err) != mq.RPCErrorUnavailable {
+		t.Fatalf("expected unavailable error: %v", err)
+	}
+
+	server := mq.NewRPCServer(mq.RPCServerOptions{
+		Js:         js,
+		Logger:     logger,
+		QueueGroup: "test",
+	})
+	defer server.Close()
+
+	err = testRPCDouble.Handle(server, func(ctx context.Context, req *testRPCReq) (*testRPCResp, error) {
+		if req.Value < 0 {
+			return nil, mq.NewRPCError(mq.RPCErrorBadRequest, "negative value")
+		}
+		if _, ok := ctx.Deadline(); !ok {
+			return nil, errors.New("missing deadline")
+		}
+		return &testRPCResp{Value: req.Value * 2}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	resp, err := testRPCDouble.Call(ctx, js, &testRPCReq{Value: 21})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Value != 42 {
+		t.Fatalf("incorrect response: %d", resp.Value)
+	}
+
+	_, err = testRPCDouble.Call(ctx, js, &testRPCReq{Value: -1})
+	if mq.RPCErrorCodeOf(err) != mq.RPCErrorBadRequest {
+		t.Fatalf("expected bad request error: %v", err)
+	}
+}
+
+func TestRPCServer_Close(t *testing.T) {
+	logger, err := logging.CreateBasicLogger(logging.NewDefaultBasicLoggerOptions("/tmp/gigo-core-rpc-test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	js := mqtest.NewJetstreamClient(t)
+
+	server := mq.NewRPCServer(mq.RPCServerOptions{
+		Js:         js,
+		Logger:     logger,
+		QueueGroup: "test",
+	})
+
+	err = testRPCDouble.Handle(server, func(ctx context.Context, req *testRPCReq) (*testRPCResp, error) {
+		time.Sleep(time.Millisecond)
+		return &testRPCResp{Value: req.Value * 2}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// close the server while requests are still arriving
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+			defer cancel()
+			_, _ = testRPCDouble.Call(ctx, js, &testRPCReq{Value: int64(i)})
+		}(i)
+	}
+	time.Sleep(time.Millisecond * 5)
+	server.Close()
+	wg.Wait()
+
+	err = testRPCDouble.Handle(server, func(ctx context.Context, req *testRPCReq) (*testRPCResp, error) {
+		return &testRPCResp{}, nil
+	})
+	if err == nil {
+		t.Fatal("expected handler registration on a closed server to fail")
+	}
+}
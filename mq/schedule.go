@@ -0,0 +1,420 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gage-technologies/gigo-lib/logging"
+	"github.com/gage-technologies/gigo-lib/mq/streams"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// headerScheduleSubject holds the subject that a scheduled message is delivered to
+	headerScheduleSubject = "Gigo-Schedule-Subject"
+	// headerScheduleDeliverAt holds the time that a scheduled message is due
+	headerScheduleDeliverAt = "Gigo-Schedule-Deliver-At"
+)
+
+// DefaultScheduledMaxAttempts is the number of failed deliveries after which
+// a scheduled message is dropped when the dispatcher options do not set one
+const DefaultScheduledMaxAttempts = 5
+
+// validateScheduleID
+//
+//	Ensures that a schedule id can be used as a single subject token
+func validateScheduleID(id string) error {
+	if id == "" || strings.ContainsAny(id, ".*> \t\r\n") {
+		return fmt.Errorf("invalid schedule id %q: must be a single subject token", id)
+	}
+	return nil
+}
+
+// validateScheduleSubject
+//
+//	Ensures that a destination subject is a concrete subject that a
+//	message can be published to
+func validateScheduleSubject(subject string) error {
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return fmt.Errorf("invalid schedule subject %q", subject)
+	}
+	for _, token := range strings.Split(subject, ".") {
+		if token == "" || token == "*" || token == ">" {
+			return fmt.Errorf("invalid schedule subject %q: must not contain empty or wildcard tokens", subject)
+		}
+	}
+	return nil
+}
+
+// PublishAt
+//
+//	Schedules a message for delivery to the passed subject at the passed
+//	time. The message is stored durably in the scheduled stream until it
+//	is dispatched by a ScheduledDispatcher. Scheduling a message with an
+//	id that is already pending replaces the pending message.
+func (c *JetstreamClient) PublishAt(id string, subject string, data []byte, deliverAt time.Time, opts ...nats.PubOpt) (*nats.PubAck, error) {
+	err := validateScheduleID(id)
+	if err != nil {
+		return nil, err
+	}
+	err = validateScheduleSubject(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := nats.NewMsg(fmt.Sprintf(streams.SubjectScheduledDynamic, id))
+	msg.Data = data
+	msg.Header.Set(headerScheduleSubject, subject)
+	msg.Header.Set(headerScheduleDeliverAt, deliverAt.UTC().Format(time.RFC3339Nano))
+
+	ack, err := c.PublishMsg(msg, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish scheduled message: %v", err)
+	}
+
+	return ack, nil
+}
+
+// CancelScheduled
+//
+//	Cancels a pending scheduled message. Cancelling a message that
+//	does not exist or has already been delivered is a no-op.
+func (c *JetstreamClient) CancelScheduled(id string) error {
+	err := validateScheduleID(id)
+	if err != nil {
+		return err
+	}
+
+	err = c.PurgeStream(streams.StreamScheduled, &nats.StreamPurgeRequest{
+		Subject: fmt.Sprintf(streams.SubjectScheduledDynamic, id),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cancel scheduled message: %v", err)
+	}
+
+	return nil
+}
+
+// ScheduledDispatcherOptions
+//
+//	Options for a ScheduledDispatcher
+type ScheduledDispatcherOptions struct {
+	Js     *JetstreamClient
+	Logger logging.Logger
+	// MaxAttempts is the number of failed deliveries after which a
+	// message is dropped; defaults to DefaultScheduledMaxAttempts
+	MaxAttempts int
+}
+
+// scheduledEntry
+//
+//	In-memory index entry for a pending scheduled message
+type scheduledEntry struct {
+	id        string
+	seq       uint64
+	deliverAt time.Time
+	// attempts is the number of failed deliveries
+	attempts int
+}
+
+// ScheduledDispatcher
+//
+//	Delivers scheduled messages to their destination subject once they
+//	are due. The dispatcher is designed to be executed from the leader
+//	routine of a cluster.Node so that only one dispatcher delivers
+//	messages at any given time.
+//
+//	Pending messages are indexed in memory from an ordered consumer on
+//	the scheduled stream so the index is rebuilt from the stream after a
+//	restart. Each message is verified against the stream before delivery
+//	so cancelled and replaced messages are never delivered. Delivery is
+//	at-least-once and de-duplicated by jetstream using a message id
+//	derived from the schedule id and stream sequence.
+//
+//	A message that fails to be delivered is retried on the following
+//	executions without blocking the messages that are due after it and
+//	is dropped from the stream once it reaches the maximum attempts.
+type ScheduledDispatcher struct {
+	js          *JetstreamClient
+	logger      logging.Logger
+	maxAttempts int
+	sub         *nats.Subscription
+	entries     map[string]scheduledEntry
+	lock        *sync.Mutex
+}
+
+// NewScheduledDispatcher
+//
+//	Creates a new ScheduledDispatcher. The index of pending messages
+//	is built lazily on the first execution of the leader routine.
+func NewScheduledDispatcher(opts ScheduledDispatcherOptions) *ScheduledDispatcher {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultScheduledMaxAttempts
+	}
+
+	return &ScheduledDispatcher{
+		js:          opts.Js,
+		logger:      opts.Logger,
+		maxAttempts: opts.MaxAttempts,
+		entries:     make(map[string]scheduledEntry),
+		lock:        &sync.Mutex{},
+	}
+}
+
+// Close
+//
+//	Stops indexing the scheduled stream
+func (d *ScheduledDispatcher) Close() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.sub == nil {
+		return nil
+	}
+
+	err := d.sub.Unsubscribe()
+	d.sub = nil
+	if err != nil {
+		return fmt.Errorf("failed to unsubscribe from scheduled stream: %v", err)
+	}
+
+	return nil
+}
+
+// LeaderRoutine
+//
+//	Delivers every scheduled message that is due. Messages that fail to be
+//	delivered are logged and skipped so they do not block the messages
+//	after them. The signature matches cluster.LeaderRoutine so the
+//	dispatcher can be called directly from a node's leader routine.
+func (d *ScheduledDispatcher) LeaderRoutine(ctx context.Context) error {
+	err := d.subscribe()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range d.due(time.Now()) {
+		// exit quickly if the routine has been cancelled
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		err = d.dispatch(ctx, entry)
+		if err != nil {
+			// failures caused by the routine being cancelled are not the message's fault
+			if ctx.Err() != nil {
+				return nil
+			}
+			d.failed(entry, err)
+		}
+	}
+
+	return nil
+}
+
+// failed
+//
+//	Records a failed delivery of an entry and drops the message from the
+//	stream once it reaches the maximum attempts
+func (d *ScheduledDispatcher) failed(entry scheduledEntry, dispatchErr error) {
+	d.lock.Lock()
+	existing, ok := d.entries[entry.id]
+	if !ok || existing.seq != entry.seq {
+		d.lock.Unlock()
+		return
+	}
+	existing.attempts++
+	d.entries[entry.id] = existing
+	d.lock.Unlock()
+
+	if existing.attempts < d.maxAttempts {
+		d.logger.Warnf("failed to dispatch scheduled message %q (attempt %d of %d): %v", entry.id, existing.attempts, d.maxAttempts, dispatchErr)
+		return
+	}
+
+	d.logger.Errorf("dropping scheduled message %q after %d failed attempts: %v", entry.id, existing.attempts, dispatchErr)
+	err := d.js.DeleteMsg(streams.StreamScheduled, entry.seq)
+	if err != nil && !errors.Is(err, nats.ErrMsgNotFound) {
+		d.logger.Errorf("failed to drop scheduled message %q: %v", entry.id, err)
+		return
+	}
+	d.forget(entry)
+}
+
+// subscribe
+//
+//	Creates the ordered consumer that indexes the scheduled stream
+//	if it has not already been created
+func (d *ScheduledDispatcher) subscribe() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.sub != nil {
+		return nil
+	}
+
+	sub, err := d.js.Subscribe(streams.SubjectScheduled, d.index, nats.OrderedConsumer(), nats.DeliverLastPerSubject())
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to scheduled stream: %v", err)
+	}
+	d.sub = sub
+
+	return nil
+}
+
+// index
+//
+//	Adds a message from the scheduled stream to the in-memory index
+func (d *ScheduledDispatcher) index(msg *nats.Msg) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		d.logger.Errorf("failed to retrieve scheduled message metadata: %v", err)
+		return
+	}
+
+	entry, err := parseScheduledEntry(msg.Subject, msg.Header, meta.Sequence.Stream)
+	if err != nil {
+		d.logger.Errorf("failed to index scheduled message: %v", err)
+		return
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	// ignore messages that are older than the indexed message
+	if existing, ok := d.entries[entry.id]; ok && existing.seq > entry.seq {
+		return
+	}
+	d.entries[entry.id] = entry
+}
+
+// due
+//
+//	Returns the indexed entries that are due in delivery order
+func (d *ScheduledDispatcher) due(now time.Time) []scheduledEntry {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	entries := make([]scheduledEntry, 0)
+	for _, entry := range d.entries {
+		if !entry.deliverAt.After(now) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].deliverAt.Equal(entries[j].deliverAt) {
+			return entries[i].seq < entries[j].seq
+		}
+		return entries[i].deliverAt.Before(entries[j].deliverAt)
+	})
+
+	return entries
+}
+
+// dispatch
+//
+//	Delivers a single scheduled message after verifying that it
+//	has not been cancelled or replaced and removes it from the stream
+func (d *ScheduledDispatcher) dispatch(ctx context.Context, entry scheduledEntry) error {
+	raw, err := d.js.GetLastMsg(streams.StreamScheduled, fmt.Sprintf(streams.SubjectScheduledDynamic, entry.id))
+	if err != nil {
+		// drop the entry if the message has been cancelled
+		if errors.Is(err, nats.ErrMsgNotFound) {
+			d.forget(entry)
+			return nil
+		}
+		return fmt.Errorf("failed to retrieve scheduled message: %v", err)
+	}
+
+	// the message was replaced so re-index the newer message
+	// and leave it for the next execution
+	if raw.Sequence != entry.seq {
+		latest, err := parseScheduledEntry(raw.Subject, raw.Header, raw.Sequence)
+		if err != nil {
+			// the replacement can never be delivered so drop it
+			// along with the entry it replaced
+			d.logger.Errorf("dropping invalid replacement of scheduled message %q: %v", entry.id, err)
+			err = d.js.DeleteMsg(streams.StreamScheduled, raw.Sequence)
+			if err != nil && !errors.Is(err, nats.ErrMsgNotFound) {
+				return fmt.Errorf("failed to remove invalid replacement: %v", err)
+			}
+			d.forget(entry)
+			return nil
+		}
+		d.lock.Lock()
+		if existing, ok := d.entries[entry.id]; !ok || existing.seq < latest.seq {
+			d.entries[entry.id] = latest
+		}
+		d.lock.Unlock()
+		return nil
+	}
+
+	// copy the message to its destination dropping the schedule headers
+	msg := nats.NewMsg(raw.Header.Get(headerScheduleSubject))
+	msg.Data = raw.Data
+	for key, values := range raw.Header {
+		if key == headerScheduleSubject || key == headerScheduleDeliverAt {
+			continue
+		}
+		msg.Header[key] = values
+	}
+
+	_, err = d.js.PublishMsg(msg,
+		nats.MsgId(fmt.Sprintf("scheduled-%s-%d", entry.id, entry.seq)),
+		nats.Context(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to publish to %q: %v", msg.Subject, err)
+	}
+
+	// remove the exact message that was delivered so that a replacement
+	// published in the meantime is preserved
+	err = d.js.DeleteMsg(streams.StreamScheduled, entry.seq)
+	if err != nil && !errors.Is(err, nats.ErrMsgNotFound) {
+		return fmt.Errorf("failed to remove delivered message: %v", err)
+	}
+
+	d.forget(entry)
+
+	return nil
+}
+
+// forget
+//
+//	Removes an entry from the index if it has not been replaced
+func (d *ScheduledDispatcher) forget(entry scheduledEntry) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if existing, ok := d.entries[entry.id]; ok && existing.seq == entry.seq {
+		delete(d.entries, entry.id)
+	}
+}
+
+// parseScheduledEntry
+//
+//	Creates an index entry from a message stored in the scheduled stream
+func parseScheduledEntry(subject string, header nats.Header, seq uint64) (scheduledEntry, error) {
+	id := strings.TrimPrefix(subject, strings.TrimSuffix(streams.SubjectScheduled, ">"))
+
+	if header.Get(headerScheduleSubject) == "" {
+		return scheduledEntry{}, fmt.Errorf("scheduled message %q is missing a destination subject", id)
+	}
+
+	deliverAt, err := time.Parse(time.RFC3339Nano, header.Get(headerScheduleDeliverAt))
+	if err != nil {
+		return scheduledEntry{}, fmt.Errorf("scheduled message %q has an invalid delivery time: %v", id, err)
+	}
+
+	return scheduledEntry{
+		id:        id,
+		seq:       seq,
+		deliverAt: deliverAt,
+	}, nil
+}
@@ -0,0 +1,165 @@
+package mq_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gage-technologies/gigo-lib/logging"
+	"github.com/gage-technologies/gigo-lib/mq"
+	"github.com/gage-technologies/gigo-lib/mq/mqtest"
+	"github.com/gage-technologies/gigo-lib/mq/streams"
+	"github.com/nats-io/nats.go"
+)
+
+func TestScheduledDispatcher_LeaderRoutine(t *testing.T) {
+	logger, err := logging.CreateBasicLogger(logging.NewDefaultBasicLoggerOptions("/tmp/gigo-core-mq-schedule-test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	js := mqtest.NewJetstreamClient(t)
+
+	if _, err = js.PublishAt("invalid.id", streams.SubjectDayRollover, nil, time.Now()); err == nil {
+		t.Fatal("expected error for invalid schedule id")
+	}
+
+	sub, err := js.SubscribeSync(streams.SubjectDayRollover)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	// schedule a message, replace it and schedule a second message that is cancelled
+	_, err = js.PublishAt("rollover-1", streams.SubjectDayRollover, []byte("stale"), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = js.PublishAt("rollover-1", streams.SubjectDayRollover, []byte("fresh"), time.Now().Add(time.Millisecond*200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = js.PublishAt("rollover-2", streams.SubjectDayRollover, []byte("cancelled"), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = js.CancelScheduled("rollover-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := mq.NewScheduledDispatcher(mq.ScheduledDispatcherOptions{
+		Js:     js,
+		Logger: logger,
+	})
+	defer dispatcher.Close()
+
+	// nothing should be delivered before the message is due
+	err = dispatcher.LeaderRoutine(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sub.NextMsg(time.Millisecond * 50); err != nats.ErrTimeout {
+		t.Fatalf("expected no message before delivery time: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second * 5)
+	var msg *nats.Msg
+	for msg == nil && time.Now().Before(deadline) {
+		err = dispatcher.LeaderRoutine(context.TODO())
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, _ = sub.NextMsg(time.Millisecond * 50)
+	}
+
+	if msg == nil {
+		t.Fatal("scheduled message was not delivered")
+	}
+	if string(msg.Data) != "fresh" {
+		t.Fatalf("incorrect message delivered: %s", msg.Data)
+	}
+
+	// the delivered message should be removed from the scheduled stream
+	info, err := js.StreamInfo(streams.StreamScheduled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 0 {
+		t.Fatalf("expected empty scheduled stream: %d", info.State.Msgs)
+	}
+
+	// no further messages should be delivered
+	err = dispatcher.LeaderRoutine(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sub.NextMsg(time.Millisecond * 100); err != nats.ErrTimeout {
+		t.Fatalf("expected no further messages: %v", err)
+	}
+}
+
+func TestScheduledDispatcher_FailedDelivery(t *testing.T) {
+	logger, err := logging.CreateBasicLogger(logging.NewDefaultBasicLoggerOptions("/tmp/gigo-core-mq-schedule-failed-test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	js := mqtest.NewJetstreamClient(t)
+
+	for _, subject := range []string{"", "DAY.*", "DAY.>", "DAY..ROLLOVER", "DAY ROLLOVER"} {
+		if _, err = js.PublishAt("invalid-subject", subject, nil, time.Now()); err == nil {
+			t.Fatalf("expected error for invalid subject %q", subject)
+		}
+	}
+
+	sub, err := js.SubscribeSync(streams.SubjectDayRollover)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	// the first message is due earlier but no stream captures its subject
+	_, err = js.PublishAt("uncaptured", "UNCAPTURED.subject", []byte("lost"), time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = js.PublishAt("rollover", streams.SubjectDayRollover, []byte("delivered"), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := mq.NewScheduledDispatcher(mq.ScheduledDispatcherOptions{
+		Js:          js,
+		Logger:      logger,
+		MaxAttempts: 2,
+	})
+	defer dispatcher.Close()
+
+	// the failing message does not block the message after it
+	deadline := time.Now().Add(time.Second * 5)
+	var msg *nats.Msg
+	for msg == nil && time.Now().Before(deadline) {
+		err = dispatcher.LeaderRoutine(context.TODO())
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, _ = sub.NextMsg(time.Millisecond * 50)
+	}
+	if msg == nil || string(msg.Data) != "delivered" {
+		t.Fatalf("scheduled message was not delivered: %v", msg)
+	}
+
+	// the failing message is dropped once it reaches the maximum attempts
+	err = dispatcher.LeaderRoutine(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := js.StreamInfo(streams.StreamScheduled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 0 {
+		t.Fatalf("expected empty scheduled stream: %d", info.State.Msgs)
+	}
+}
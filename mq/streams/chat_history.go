@@ -0,0 +1,33 @@
+package streams
+
+import (
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// this file contains the jetstream configuration for
+// the replayable history of chat messages
+
+const (
+	StreamChatHistory string = "ChatHistory"
+
+	// each chat is stored on its own subject so that the retention
+	// limits are applied per chat
+	SubjectChatHistory        = "CHAT_HISTORY.>"
+	SubjectChatHistoryDynamic = "CHAT_HISTORY.%d"
+
+	RetentionPolicyChatHistory = nats.LimitsPolicy
+
+	DuplicateFilterWindowChatHistory = time.Minute
+
+	// the number of messages retained for each chat
+	MaxMsgsPerSubjectChatHistory = 1000
+
+	// the maximum age of a retained message
+	MaxAgeChatHistory = time.Hour * 24 * 7
+)
+
+var StreamSubjectsChatHistory = []string{
+	SubjectChatHistory,
+}
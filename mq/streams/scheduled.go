@@ -0,0 +1,28 @@
+package streams
+
+import (
+	"github.com/nats-io/nats.go"
+)
+
+// this file contains the jetstream configuration for
+// messages that are scheduled for delivery at a future time
+
+const (
+	StreamScheduled string = "Scheduled"
+
+	// each scheduled message is stored on its own subject using the
+	// schedule id so that a message can be replaced or cancelled by id
+	SubjectScheduled        = "SCHEDULED.>"
+	SubjectScheduledDynamic = "SCHEDULED.%s"
+
+	RetentionPolicyScheduled = nats.LimitsPolicy
+
+	DuplicateFilterWindowScheduled = 0
+
+	// only the latest message for each schedule id is retained
+	MaxMsgsPerSubjectScheduled = 1
+)
+
+var StreamSubjectsScheduled = []string{
+	SubjectScheduled,
+}
@@ -12,6 +12,7 @@ const (
 	SubjectStreakExpiration = "STREAK.ExpirationRemoval"
 	SubjectDayRollover      = "STREAK.DayRollover"
 	SubjectPremiumFreeze    = "STREAK.PremiumFreeze"
+	SubjectStreakLevelUp    = "STREAK.LevelUp"
 
 	RetentionPolicyStreak = nats.WorkQueuePolicy
 
@@ -23,4 +24,5 @@ var StreamSubjectsStreak = []string{
 	SubjectStreakExpiration,
 	SubjectDayRollover,
 	SubjectPremiumFreeze,
+	SubjectStreakLevelUp,
 }
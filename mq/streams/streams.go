@@ -0,0 +1,17 @@
+package streams
+
+// AllStreams lists every stream that is initialized for
+// the Gigo Core system so that tooling can iterate over them
+var AllStreams = []string{
+	StreamWorkspace,
+	StreamMisc,
+	StreamStreakXP,
+	StreamWorkspaceStatus,
+	StreamBroadcastEvent,
+	StreamNemesis,
+	StreamTailscale,
+	StreamWsConnCache,
+	StreamChat,
+	StreamChatHistory,
+	StreamScheduled,
+}
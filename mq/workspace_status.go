@@ -0,0 +1,56 @@
+package mq
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/gage-technologies/gigo-lib/db/models"
+	mqmodels "github.com/gage-technologies/gigo-lib/mq/models"
+	"github.com/gage-technologies/gigo-lib/mq/streams"
+	"github.com/nats-io/nats.go"
+)
+
+// WorkspaceStatusPublisher
+//
+//	Implementation of models.WorkspaceStatusPublisher that publishes
+//	a WorkspaceStatusUpdateMsg to the workspace status stream.
+type WorkspaceStatusPublisher struct {
+	js       *JetstreamClient
+	hostname string
+	https    bool
+}
+
+// NewWorkspaceStatusPublisher
+//
+//	Creates a new WorkspaceStatusPublisher. The hostname and https flag
+//	are used to format the workspace ports for the frontend.
+func NewWorkspaceStatusPublisher(js *JetstreamClient, hostname string, https bool) *WorkspaceStatusPublisher {
+	return &WorkspaceStatusPublisher{
+		js:       js,
+		hostname: hostname,
+		https:    https,
+	}
+}
+
+// PublishWorkspaceStatus
+//
+//	Publishes the current status of the workspace to the
+//	workspace status subject of the workspace.
+func (p *WorkspaceStatusPublisher) PublishWorkspaceStatus(ctx context.Context, workspace *models.Workspace) error {
+	buf := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buf).Encode(mqmodels.WorkspaceStatusUpdateMsg{
+		Workspace: workspace.ToFrontend(p.hostname, p.https),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode workspace status: %v", err)
+	}
+
+	_, err = p.js.Publish(fmt.Sprintf(streams.SubjectWorkspaceStatusUpdateDynamic, workspace.ID), buf.Bytes(), nats.Context(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to publish workspace status: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package mq_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gage-technologies/gigo-lib/db/models"
+	"github.com/gage-technologies/gigo-lib/mq"
+	mqmodels "github.com/gage-technologies/gigo-lib/mq/models"
+	"github.com/gage-technologies/gigo-lib/mq/mqtest"
+	"github.com/gage-technologies/gigo-lib/mq/streams"
+)
+
+func TestWorkspaceStatusPublisher_PublishWorkspaceStatus(t *testing.T) {
+	js := mqtest.NewJetstreamClient(t)
+
+	sub, err := js.SubscribeSync(fmt.Sprintf(streams.SubjectWorkspaceStatusUpdateDynamic, 42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	publisher := mq.NewWorkspaceStatusPublisher(js, "gigo.dev", true)
+	err = publisher.PublishWorkspaceStatus(context.TODO(), &models.Workspace{
+		ID:    42,
+		State: models.WorkspaceActive,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := sub.NextMsg(time.Second * 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var update mqmodels.WorkspaceStatusUpdateMsg
+	err = gob.NewDecoder(bytes.NewReader(msg.Data)).Decode(&update)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if update.Workspace.ID != "42" || update.Workspace.State != models.WorkspaceActive {
+		t.Fatalf("incorrect workspace status: %+v", update.Workspace)
+	}
+}
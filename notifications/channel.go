@@ -0,0 +1,43 @@
+package notifications
+
+import (
+	"context"
+
+	"github.com/gage-technologies/gigo-lib/db/models"
+)
+
+// Recipient
+//
+//	User that a message is delivered to
+type Recipient struct {
+	UserID   int64
+	UserName string
+	Email    string
+}
+
+// Message
+//
+//	Rendered message delivered by a channel. Notifications holds the
+//	notifications the message was rendered from.
+type Message struct {
+	Recipient     Recipient
+	Subject       string
+	Text          string
+	HTML          string
+	Notifications []*models.Notification
+}
+
+// Channel
+//
+//	Delivers messages to users outside of the application
+type Channel interface {
+	// Name
+	//
+	//	Returns the name of the channel used in errors and logs
+	Name() string
+
+	// Send
+	//
+	//	Delivers the message to its recipient
+	Send(ctx context.Context, msg *Message) error
+}
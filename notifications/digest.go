@@ -0,0 +1,136 @@
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/gage-technologies/gigo-lib/db/models"
+)
+
+// digestTitles are the section titles of each notification type
+var digestTitles = map[models.NotificationType]string{
+	models.FriendRequest:  "Friend Requests",
+	models.NemesisRequest: "Nemesis Requests",
+	models.NemesisAlert:   "Nemesis Alerts",
+	models.StreakInfo:     "Streaks",
+}
+
+// DigestItem
+//
+//	Notification rendered in a digest
+type DigestItem struct {
+	Message   string
+	CreatedAt time.Time
+	// Collapsed is the number of notifications that were collapsed into this one
+	Collapsed int
+}
+
+// DigestSection
+//
+//	Notifications of a single type rendered in a digest
+type DigestSection struct {
+	Type  models.NotificationType
+	Title string
+	Items []DigestItem
+}
+
+// DigestData
+//
+//	Data passed to the digest templates
+type DigestData struct {
+	Recipient Recipient
+	Date      time.Time
+	Total     int
+	Sections  []DigestSection
+}
+
+var digestTextTemplate = texttemplate.Must(texttemplate.New("digest").Parse(
+	`Hi {{.Recipient.UserName}},
+
+You have {{.Total}} unread notification{{if ne .Total 1}}s{{end}}.
+{{range .Sections}}
+{{.Title}}
+{{range .Items}}  - {{.Message}}{{if .Collapsed}} (+{{.Collapsed}} more){{end}}
+{{end}}{{end}}`))
+
+var digestHTMLTemplate = htmltemplate.Must(htmltemplate.New("digest").Parse(
+	`<html><body>
+<p>Hi {{.Recipient.UserName}},</p>
+<p>You have {{.Total}} unread notification{{if ne .Total 1}}s{{end}}.</p>
+{{range .Sections}}<h3>{{.Title}}</h3>
+<ul>
+{{range .Items}}<li>{{.Message}}{{if .Collapsed}} (+{{.Collapsed}} more){{end}}</li>
+{{end}}</ul>
+{{end}}</body></html>`))
+
+// RenderDigest
+//
+//	Renders the notifications into a digest message. Notifications are
+//	grouped by type in the order of the notification types and sorted from
+//	newest to oldest within each group. Returns nil if there are no
+//	notifications.
+func RenderDigest(recipient Recipient, notifications []*models.Notification, now time.Time) (*Message, error) {
+	if len(notifications) == 0 {
+		return nil, nil
+	}
+
+	grouped := make(map[models.NotificationType][]*models.Notification)
+	for _, notification := range notifications {
+		grouped[notification.NotificationType] = append(grouped[notification.NotificationType], notification)
+	}
+
+	data := DigestData{
+		Recipient: recipient,
+		Date:      now,
+		Total:     len(notifications),
+		Sections:  make([]DigestSection, 0, len(grouped)),
+	}
+	for _, notificationType := range models.NotificationTypes {
+		group := grouped[notificationType]
+		if len(group) == 0 {
+			continue
+		}
+
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].CreatedAt.After(group[j].CreatedAt)
+		})
+
+		section := DigestSection{
+			Type:  notificationType,
+			Title: digestTitles[notificationType],
+			Items: make([]DigestItem, 0, len(group)),
+		}
+		for _, notification := range group {
+			section.Items = append(section.Items, DigestItem{
+				Message:   notification.Message,
+				CreatedAt: notification.CreatedAt,
+				Collapsed: notification.CollapsedCount,
+			})
+		}
+		data.Sections = append(data.Sections, section)
+	}
+
+	text := bytes.NewBuffer(nil)
+	err := digestTextTemplate.Execute(text, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render digest text: %v", err)
+	}
+
+	html := bytes.NewBuffer(nil)
+	err = digestHTMLTemplate.Execute(html, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render digest html: %v", err)
+	}
+
+	return &Message{
+		Recipient:     recipient,
+		Subject:       fmt.Sprintf("Your GIGO digest for %s", now.Format("January 2, 2006")),
+		Text:          text.String(),
+		HTML:          html.String(),
+		Notifications: notifications,
+	}, nil
+}
@@ -0,0 +1,52 @@
+package notifications
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gage-technologies/gigo-lib/db/models"
+)
+
+func TestRenderDigest(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	recipient := Recipient{UserID: 69, UserName: "test", Email: "test@gigo.dev"}
+
+	msg, err := RenderDigest(recipient, nil, now)
+	if err != nil || msg != nil {
+		t.Fatal("\nRender Digest Failed\n    Error: rendered an empty digest ", err)
+	}
+
+	notifications := []*models.Notification{
+		{ID: 1, UserID: 69, Message: "streak <3 days>", NotificationType: models.StreakInfo, CreatedAt: now.Add(-time.Hour)},
+		{ID: 2, UserID: 69, Message: "older request", NotificationType: models.FriendRequest, CreatedAt: now.Add(-time.Hour * 2)},
+		{ID: 3, UserID: 69, Message: "newer request", NotificationType: models.FriendRequest, CreatedAt: now.Add(-time.Minute)},
+		{ID: 4, UserID: 69, Message: "your nemesis captured a tower", NotificationType: models.NemesisAlert, CreatedAt: now, CollapsedCount: 4},
+	}
+
+	msg, err = RenderDigest(recipient, notifications, now)
+	if err != nil {
+		t.Fatal("\nRender Digest Failed\n    Error: ", err)
+	}
+
+	if msg.Recipient != recipient || len(msg.Notifications) != 4 || !strings.Contains(msg.Subject, "June 1, 2023") {
+		t.Fatalf("\nRender Digest Failed\n    Error: wrong message %+v", msg)
+	}
+
+	// sections follow the order of the notification types and items are newest first
+	order := []string{"You have 4 unread notifications", "Friend Requests", "newer request", "older request", "Nemesis Alerts", "(+4 more)", "Streaks"}
+	last := -1
+	for _, fragment := range order {
+		index := strings.Index(msg.Text, fragment)
+		if index <= last {
+			t.Fatalf("\nRender Digest Failed\n    Error: %q is out of order in\n%s", fragment, msg.Text)
+		}
+		last = index
+	}
+
+	if !strings.Contains(msg.HTML, "streak &lt;3 days&gt;") {
+		t.Fatalf("\nRender Digest Failed\n    Error: html was not escaped\n%s", msg.HTML)
+	}
+
+	t.Log("\nRender Digest Succeeded")
+}
@@ -0,0 +1,454 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/gage-technologies/gigo-lib/db/models"
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultCollapseWindow is the time in which repeated notifications
+	// are collapsed when the options do not set a window
+	DefaultCollapseWindow = time.Hour
+	// DefaultEmailRateLimit is the number of emails of a single type a
+	// user is sent per rate window when the options do not set a limit
+	DefaultEmailRateLimit = 5
+	// DefaultEmailRateWindow is the window of the email rate limit when
+	// the options do not set a window
+	DefaultEmailRateWindow = time.Hour
+	// DefaultDigestInterval is the time between the digests of a user
+	// when the options do not set an interval
+	DefaultDigestInterval = time.Hour * 24
+	// DefaultDigestLimit is the maximum number of notifications included
+	// in a digest when the options do not set a limit
+	DefaultDigestLimit = 50
+)
+
+// DefaultCollapsibleTypes are the notification types that are collapsed
+// when the options do not set any
+var DefaultCollapsibleTypes = []models.NotificationType{models.NemesisAlert}
+
+// DispatcherOptions
+//
+//	Options for a Dispatcher
+type DispatcherOptions struct {
+	DB *ti.Database
+	SF *snowflake.Node
+	// RDB optionally rate limits emails; emails are not rate limited
+	// without a redis client
+	RDB redis.UniversalClient
+	// Email delivers notifications and digests to users that enabled
+	// email; emails are not sent without a channel
+	Email Channel
+
+	// CollapsibleTypes are collapsed into the latest unacknowledged
+	// notification of the same type and interacting user created within
+	// the collapse window; defaults to DefaultCollapsibleTypes
+	CollapsibleTypes []models.NotificationType
+	// CollapseWindow defaults to DefaultCollapseWindow
+	CollapseWindow time.Duration
+	// EmailRateLimit and EmailRateWindow limit the emails of each type a
+	// user is sent; default to DefaultEmailRateLimit and DefaultEmailRateWindow
+	EmailRateLimit  int
+	EmailRateWindow time.Duration
+	// DigestInterval defaults to DefaultDigestInterval
+	DigestInterval time.Duration
+	// DigestLimit caps the notifications included in a digest; defaults
+	// to DefaultDigestLimit
+	DigestLimit int
+}
+
+// Dispatcher
+//
+//	Delivers notifications according to each user's preferences.
+//	Notifications of muted types are dropped, in app notifications are
+//	stored and collapsed, and notifications of types the user wants to be
+//	emailed about are sent through the email channel. Emails over the
+//	rate limit are skipped; the notification is still included in the
+//	user's next digest.
+type Dispatcher struct {
+	db               *ti.Database
+	sf               *snowflake.Node
+	rdb              redis.UniversalClient
+	email            Channel
+	collapsibleTypes map[models.NotificationType]bool
+	collapseWindow   time.Duration
+	emailRateLimit   int
+	emailRateWindow  time.Duration
+	digestInterval   time.Duration
+	digestLimit      int
+}
+
+// NewDispatcher
+//
+//	Creates a new Dispatcher
+func NewDispatcher(opts DispatcherOptions) (*Dispatcher, error) {
+	if opts.DB == nil {
+		return nil, fmt.Errorf("notification dispatcher requires a database")
+	}
+	if opts.SF == nil {
+		return nil, fmt.Errorf("notification dispatcher requires a snowflake node")
+	}
+	if opts.CollapsibleTypes == nil {
+		opts.CollapsibleTypes = DefaultCollapsibleTypes
+	}
+	if opts.CollapseWindow <= 0 {
+		opts.CollapseWindow = DefaultCollapseWindow
+	}
+	if opts.EmailRateLimit <= 0 {
+		opts.EmailRateLimit = DefaultEmailRateLimit
+	}
+	if opts.EmailRateWindow <= 0 {
+		opts.EmailRateWindow = DefaultEmailRateWindow
+	}
+	if opts.DigestInterval <= 0 {
+		opts.DigestInterval = DefaultDigestInterval
+	}
+	if opts.DigestLimit <= 0 {
+		opts.DigestLimit = DefaultDigestLimit
+	}
+
+	collapsible := make(map[models.NotificationType]bool, len(opts.CollapsibleTypes))
+	for _, notificationType := range opts.CollapsibleTypes {
+		collapsible[notificationType] = true
+	}
+
+	return &Dispatcher{
+		db:               opts.DB,
+		sf:               opts.SF,
+		rdb:              opts.RDB,
+		email:            opts.Email,
+		collapsibleTypes: collapsible,
+		collapseWindow:   opts.CollapseWindow,
+		emailRateLimit:   opts.EmailRateLimit,
+		emailRateWindow:  opts.EmailRateWindow,
+		digestInterval:   opts.DigestInterval,
+		digestLimit:      opts.DigestLimit,
+	}, nil
+}
+
+// Notify
+//
+//	Delivers a notification to a user. Returns the stored notification,
+//	which is an existing notification if the notification was collapsed,
+//	or nil if the user does not receive notifications of the type in app.
+//	The stored notification is also returned when the email fails so that
+//	callers do not store it again by retrying.
+func (d *Dispatcher) Notify(ctx context.Context, span *trace.Span, callerName *string, userID int64, notificationType models.NotificationType,
+	message string, interactingUserID *int64) (*models.Notification, error) {
+	preferences, err := models.GetNotificationPreferences(ctx, d.db, span, callerName, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	preference := preferences[notificationType]
+	if preference == nil || !preference.Delivers() {
+		return nil, nil
+	}
+
+	now := time.Now()
+	var stored *models.Notification
+	collapsed := false
+	if preference.InApp {
+		stored, collapsed, err = d.store(ctx, span, callerName, userID, notificationType, message, interactingUserID, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// collapsed notifications were already delivered by email with the
+	// notification they were collapsed into
+	if !preference.Email || collapsed || d.email == nil {
+		return stored, nil
+	}
+
+	allowed, err := d.allowEmail(ctx, userID, notificationType)
+	if err != nil {
+		return stored, err
+	}
+	if !allowed {
+		return stored, nil
+	}
+
+	recipient, err := d.recipient(ctx, span, callerName, userID)
+	if err != nil {
+		return stored, err
+	}
+
+	notification := stored
+	if notification == nil {
+		notification, _ = models.CreateNotification(0, userID, message, notificationType, now, false, interactingUserID)
+	}
+
+	err = d.email.Send(ctx, &Message{
+		Recipient:     *recipient,
+		Subject:       message,
+		Text:          message,
+		Notifications: []*models.Notification{notification},
+	})
+	if err != nil {
+		return stored, fmt.Errorf("failed to deliver notification through %s: %v", d.email.Name(), err)
+	}
+
+	return stored, nil
+}
+
+// store
+//
+//	Stores an in app notification. Notifications of collapsible types
+//	replace the latest matching unacknowledged notification created within
+//	the collapse window. Returns true if the notification was collapsed.
+func (d *Dispatcher) store(ctx context.Context, span *trace.Span, callerName *string, userID int64, notificationType models.NotificationType,
+	message string, interactingUserID *int64, now time.Time) (*models.Notification, bool, error) {
+	var stored *models.Notification
+	collapsed := false
+	err := d.db.RunInTx(ctx, span, callerName, nil, func(ctx context.Context, tx *ti.Tx) error {
+		stored, collapsed = nil, false
+
+		if d.collapsibleTypes[notificationType] {
+			// lock the user so that concurrent notifications are collapsed
+			// in turn; locking the notification alone does not block inserts
+			res, err := tx.QueryContext(ctx, callerName, "select _id from users where _id = ? for update", userID)
+			if err != nil {
+				return fmt.Errorf("failed to lock notification user: %w", err)
+			}
+			_ = res.Close()
+
+			query := "select * from notification where user_id = ? and acknowledged = false and notification_type = ? and created_at >= ?"
+			args := []interface{}{userID, notificationType, now.Add(-d.collapseWindow)}
+			if interactingUserID != nil {
+				query += " and interacting_user_id = ?"
+				args = append(args, *interactingUserID)
+			} else {
+				query += " and interacting_user_id is null"
+			}
+			query += " order by created_at desc limit 1 for update"
+
+			res, err = tx.QueryContext(ctx, callerName, query, args...)
+			if err != nil {
+				return fmt.Errorf("failed to query collapsible notification: %w", err)
+			}
+
+			var existing *models.Notification
+			if res.Next() {
+				existing, err = models.NotificationFromSQLNative(res)
+			}
+			if err == nil {
+				err = res.Err()
+			}
+			_ = res.Close()
+			if err != nil {
+				return fmt.Errorf("failed to load collapsible notification: %w", err)
+			}
+
+			if existing != nil {
+				_, err = tx.ExecContext(ctx, callerName,
+					"update notification set message = ?, created_at = ?, collapsed_count = collapsed_count + 1 where _id = ?",
+					message, now, existing.ID,
+				)
+				if err != nil {
+					return fmt.Errorf("failed to collapse notification: %w", err)
+				}
+
+				existing.Message = message
+				existing.CreatedAt = now
+				existing.CollapsedCount++
+				stored, collapsed = existing, true
+				return nil
+			}
+		}
+
+		notification, err := models.CreateNotification(d.sf.Generate().Int64(), userID, message, notificationType, now, false, interactingUserID)
+		if err != nil {
+			return fmt.Errorf("failed to create notification: %w", err)
+		}
+
+		statement := notification.ToSQLNative()
+		_, err = tx.ExecContext(ctx, callerName, statement.Statement, statement.Values...)
+		if err != nil {
+			return fmt.Errorf("failed to insert notification: %w", err)
+		}
+
+		stored = notification
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return stored, collapsed, nil
+}
+
+// allowEmail
+//
+//	Counts an email of the type towards the user's rate limit and returns
+//	false if the limit was exceeded
+func (d *Dispatcher) allowEmail(ctx context.Context, userID int64, notificationType models.NotificationType) (bool, error) {
+	if d.rdb == nil {
+		return true, nil
+	}
+
+	key := rateLimitKey(userID, notificationType)
+	count, err := d.rdb.Incr(ctx, key).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to increment notification rate limit: %v", err)
+	}
+	if count == 1 {
+		// the first email of the window starts the window
+		err = d.rdb.Expire(ctx, key, d.emailRateWindow).Err()
+		if err != nil {
+			return false, fmt.Errorf("failed to expire notification rate limit: %v", err)
+		}
+	}
+
+	return count <= int64(d.emailRateLimit), nil
+}
+
+func rateLimitKey(userID int64, notificationType models.NotificationType) string {
+	return fmt.Sprintf("gigo-notification-rate-%d-%d", userID, notificationType)
+}
+
+// recipient
+//
+//	Loads the recipient of a user's messages
+func (d *Dispatcher) recipient(ctx context.Context, span *trace.Span, callerName *string, userID int64) (*Recipient, error) {
+	recipient := &Recipient{UserID: userID}
+	err := d.db.QueryRowContext(ctx, span, callerName,
+		"select user_name, email from users where _id = ?", userID,
+	).Scan(&recipient.UserName, &recipient.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load notification recipient %d: %v", userID, err)
+	}
+	return recipient, nil
+}
+
+// SendDigests
+//
+//	Sends a digest of the unacknowledged notifications created since their
+//	last digest to each user that enabled email for a notification type and
+//	has not received a digest within the digest interval. Notifications of
+//	muted types are left out of the digest. At most limit digests are sent.
+//	Returns the number of digests that were sent.
+func (d *Dispatcher) SendDigests(ctx context.Context, span *trace.Span, callerName *string, now time.Time, limit int) (int, error) {
+	if d.email == nil {
+		return 0, nil
+	}
+
+	res, err := d.db.QueryContext(ctx, span, callerName,
+		"select u._id, u.user_name, u.email from users u "+
+			"where exists (select 1 from notification_preferences p where p.user_id = u._id and p.email = true and p.muted = false) "+
+			"and exists (select 1 from notification n where n.user_id = u._id and n.acknowledged = false "+
+			"and not exists (select 1 from notification_digests g where g.user_id = u._id and g.last_sent_at >= n.created_at)) "+
+			"and not exists (select 1 from notification_digests g where g.user_id = u._id and g.last_sent_at > ?) "+
+			"order by u._id limit ?",
+		now.Add(-d.digestInterval), limit,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query digest recipients: %v", err)
+	}
+
+	recipients := make([]*Recipient, 0)
+	for res.Next() {
+		recipient := new(Recipient)
+		err = res.Scan(&recipient.UserID, &recipient.UserName, &recipient.Email)
+		if err != nil {
+			_ = res.Close()
+			return 0, fmt.Errorf("failed to scan digest recipient: %v", err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	err = res.Err()
+	_ = res.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to query digest recipients: %v", err)
+	}
+
+	sent := 0
+	for _, recipient := range recipients {
+		msg, err := d.Digest(ctx, span, callerName, recipient, now)
+		if err != nil {
+			return sent, err
+		}
+
+		if msg != nil {
+			err = d.email.Send(ctx, msg)
+			if err != nil {
+				return sent, fmt.Errorf("failed to send digest through %s: %v", d.email.Name(), err)
+			}
+			sent++
+		}
+
+		// record the digest even if every notification was muted so the
+		// user is not queried again until the next interval
+		_, err = d.db.ExecContext(ctx, span, callerName,
+			"insert into notification_digests(user_id, last_sent_at) values (?, ?) on duplicate key update last_sent_at = values(last_sent_at)",
+			recipient.UserID, now,
+		)
+		if err != nil {
+			return sent, fmt.Errorf("failed to record digest: %v", err)
+		}
+	}
+
+	return sent, nil
+}
+
+// Digest
+//
+//	Renders the digest of the recipient's unacknowledged notifications
+//	created since the recipient's last digest, newest first and capped at
+//	the digest limit. Returns nil if there are no notifications to include.
+func (d *Dispatcher) Digest(ctx context.Context, span *trace.Span, callerName *string, recipient *Recipient, now time.Time) (*Message, error) {
+	preferences, err := models.GetNotificationPreferences(ctx, d.db, span, callerName, recipient.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// notifications that were already included in a digest are not sent again
+	var lastSent time.Time
+	err = d.db.QueryRowContext(ctx, span, callerName,
+		"select last_sent_at from notification_digests where user_id = ?", recipient.UserID,
+	).Scan(&lastSent)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to query last digest: %v", err)
+	}
+
+	query := "select * from notification where user_id = ? and acknowledged = false and created_at > ?"
+	args := []interface{}{recipient.UserID, lastSent}
+	for _, notificationType := range models.NotificationTypes {
+		if preference := preferences[notificationType]; preference != nil && preference.Muted {
+			query += " and notification_type != ?"
+			args = append(args, notificationType)
+		}
+	}
+	query += " order by created_at desc limit ?"
+	args = append(args, d.digestLimit)
+
+	res, err := d.db.QueryContext(ctx, span, callerName, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query digest notifications: %v", err)
+	}
+	defer res.Close()
+
+	notifications := make([]*models.Notification, 0)
+	for res.Next() {
+		notification, err := models.NotificationFromSQLNative(res)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan digest notification: %v", err)
+		}
+		notifications = append(notifications, notification)
+	}
+
+	if err := res.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query digest notifications: %v", err)
+	}
+
+	return RenderDigest(*recipient, notifications, now)
+}
@@ -0,0 +1,248 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	ti "github.com/gage-technologies/gigo-lib/db"
+	"github.com/gage-technologies/gigo-lib/db/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// testChannel
+//
+//	Channel that records the messages it is asked to send
+type testChannel struct {
+	messages []*Message
+	err      error
+	lock     sync.Mutex
+}
+
+func (c *testChannel) Name() string {
+	return "test"
+}
+
+func (c *testChannel) Send(_ context.Context, msg *Message) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.messages = append(c.messages, msg)
+	return nil
+}
+
+func (c *testChannel) sent() []*Message {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]*Message(nil), c.messages...)
+}
+
+func createDispatcherTestUser(t *testing.T, db *ti.Database, userID int64) {
+	user, err := models.CreateUser(userID, "test", "testpass", "testemail@email.com",
+		"phone", models.UserStatusBasic, "test", []int64{1, 2}, []int64{1, 2, 3},
+		"first", "last", 23, "", models.DefaultUserStart, "America/Chicago",
+		models.AvatarSettings{}, 0)
+	if err != nil {
+		t.Fatal("\nDispatcher Failed\n    Error: ", err)
+	}
+
+	statements, err := user.ToSQLNative()
+	if err != nil {
+		t.Fatal("\nDispatcher Failed\n    Error: ", err)
+	}
+	for _, statement := range statements {
+		_, err = db.DB.Exec(statement.Statement, statement.Values...)
+		if err != nil {
+			t.Fatal("\nDispatcher Failed\n    Error: ", err)
+		}
+	}
+}
+
+func TestDispatcher_Notify(t *testing.T) {
+	db, err := ti.CreateDatabase("gigo-dev-tidb", "4000", "mysql", "gigo-dev", "gigo-dev", "gigo_dev_test")
+	if err != nil {
+		t.Fatal("\nDispatcher Failed\n    Error: ", err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: "gigo-dev-redis:6379", Password: "gigo-dev", DB: 7})
+
+	userID := int64(6943001)
+	defer db.DB.Exec("delete from users where _id = ?", userID)
+	defer db.DB.Exec("delete from notification where user_id = ?", userID)
+	defer db.DB.Exec("delete from notification_preferences where user_id = ?", userID)
+	for _, notificationType := range models.NotificationTypes {
+		defer rdb.Del(context.TODO(), rateLimitKey(userID, notificationType))
+	}
+
+	createDispatcherTestUser(t, db, userID)
+
+	sf, err := snowflake.NewNode(1)
+	if err != nil {
+		t.Fatal("\nDispatcher Failed\n    Error: ", err)
+	}
+
+	channel := &testChannel{}
+	dispatcher, err := NewDispatcher(DispatcherOptions{DB: db, SF: sf, RDB: rdb, Email: channel, EmailRateLimit: 1})
+	if err != nil {
+		t.Fatal("\nDispatcher Failed\n    Error: ", err)
+	}
+
+	// friend requests are muted, nemesis requests are only emailed, nemesis
+	// alerts are shown in app and emailed and streaks use the defaults
+	preferences := []*models.NotificationPreference{
+		models.CreateNotificationPreference(userID, models.FriendRequest, true, true, true),
+		models.CreateNotificationPreference(userID, models.NemesisRequest, false, true, false),
+		models.CreateNotificationPreference(userID, models.NemesisAlert, true, true, false),
+	}
+	for _, preference := range preferences {
+		err = models.SetNotificationPreference(context.TODO(), db, nil, nil, preference)
+		if err != nil {
+			t.Fatal("\nDispatcher Failed\n    Error: ", err)
+		}
+	}
+	for _, notificationType := range models.NotificationTypes {
+		rdb.Del(context.TODO(), rateLimitKey(userID, notificationType))
+	}
+
+	callerName := "TestDispatcher_Notify"
+
+	muted, err := dispatcher.Notify(context.TODO(), nil, &callerName, userID, models.FriendRequest, "friend request", nil)
+	if err != nil || muted != nil || len(channel.sent()) != 0 {
+		t.Fatalf("\nDispatcher Failed\n    Error: muted notification was delivered %+v %v", muted, err)
+	}
+
+	inApp, err := dispatcher.Notify(context.TODO(), nil, &callerName, userID, models.StreakInfo, "streak", nil)
+	if err != nil || inApp == nil || len(channel.sent()) != 0 {
+		t.Fatalf("\nDispatcher Failed\n    Error: in app notification was not stored or was emailed %+v %v", inApp, err)
+	}
+
+	emailed, err := dispatcher.Notify(context.TODO(), nil, &callerName, userID, models.NemesisRequest, "nemesis request", nil)
+	if err != nil || emailed != nil || len(channel.sent()) != 1 {
+		t.Fatalf("\nDispatcher Failed\n    Error: email notification was stored or was not emailed %+v %v", emailed, err)
+	}
+
+	// the email rate limit of the type has been reached
+	emailed, err = dispatcher.Notify(context.TODO(), nil, &callerName, userID, models.NemesisRequest, "nemesis request", nil)
+	if err != nil || emailed != nil || len(channel.sent()) != 1 {
+		t.Fatalf("\nDispatcher Failed\n    Error: email rate limit was not applied %d %v", len(channel.sent()), err)
+	}
+
+	// repeated alerts from the same nemesis are collapsed and only emailed once
+	nemesisID := int64(6943002)
+	first, err := dispatcher.Notify(context.TODO(), nil, &callerName, userID, models.NemesisAlert, "tower captured", &nemesisID)
+	if err != nil || first == nil {
+		t.Fatal("\nDispatcher Failed\n    Error: ", err)
+	}
+	second, err := dispatcher.Notify(context.TODO(), nil, &callerName, userID, models.NemesisAlert, "another tower captured", &nemesisID)
+	if err != nil || second == nil {
+		t.Fatal("\nDispatcher Failed\n    Error: ", err)
+	}
+	if second.ID != first.ID || second.CollapsedCount != 1 || second.Message != "another tower captured" || len(channel.sent()) != 2 {
+		t.Fatalf("\nDispatcher Failed\n    Error: nemesis alert was not collapsed %+v %d", second, len(channel.sent()))
+	}
+
+	var count, collapsedCount int
+	err = db.DB.QueryRow("select count(*), sum(collapsed_count) from notification where user_id = ? and notification_type = ?",
+		userID, models.NemesisAlert).Scan(&count, &collapsedCount)
+	if err != nil || count != 1 || collapsedCount != 1 {
+		t.Fatalf("\nDispatcher Failed\n    Error: wrong stored nemesis alerts %d %d %v", count, collapsedCount, err)
+	}
+
+	// a failed email still returns the stored notification so it is not stored again
+	channel.err = errors.New("smtp unavailable")
+	unlimited, err := NewDispatcher(DispatcherOptions{DB: db, SF: sf, Email: channel})
+	if err != nil {
+		t.Fatal("\nDispatcher Failed\n    Error: ", err)
+	}
+	otherID := int64(6943003)
+	failed, err := unlimited.Notify(context.TODO(), nil, &callerName, userID, models.NemesisAlert, "tower captured", &otherID)
+	if err == nil || failed == nil || failed.ID == first.ID {
+		t.Fatalf("\nDispatcher Failed\n    Error: failed email did not return the stored notification %+v %v", failed, err)
+	}
+
+	t.Log("\nDispatcher Succeeded")
+}
+
+func TestDispatcher_SendDigests(t *testing.T) {
+	db, err := ti.CreateDatabase("gigo-dev-tidb", "4000", "mysql", "gigo-dev", "gigo-dev", "gigo_dev_test")
+	if err != nil {
+		t.Fatal("\nDispatcher Failed\n    Error: ", err)
+	}
+
+	userID := int64(6943011)
+	defer db.DB.Exec("delete from users where _id = ?", userID)
+	defer db.DB.Exec("delete from notification where user_id = ?", userID)
+	defer db.DB.Exec("delete from notification_preferences where user_id = ?", userID)
+	defer db.DB.Exec("delete from notification_digests where user_id = ?", userID)
+
+	createDispatcherTestUser(t, db, userID)
+
+	sf, err := snowflake.NewNode(1)
+	if err != nil {
+		t.Fatal("\nDispatcher Failed\n    Error: ", err)
+	}
+
+	channel := &testChannel{}
+	dispatcher, err := NewDispatcher(DispatcherOptions{DB: db, SF: sf, Email: channel, DigestInterval: time.Hour, DigestLimit: 2})
+	if err != nil {
+		t.Fatal("\nDispatcher Failed\n    Error: ", err)
+	}
+
+	err = models.SetNotificationPreference(context.TODO(), db, nil, nil, models.CreateNotificationPreference(userID, models.StreakInfo, true, true, false))
+	if err != nil {
+		t.Fatal("\nDispatcher Failed\n    Error: ", err)
+	}
+
+	insert := func(message string, createdAt time.Time) {
+		notification, err := models.CreateNotification(sf.Generate().Int64(), userID, message, models.StreakInfo, createdAt, false, nil)
+		if err != nil {
+			t.Fatal("\nDispatcher Failed\n    Error: ", err)
+		}
+		statement := notification.ToSQLNative()
+		_, err = db.DB.Exec(statement.Statement, statement.Values...)
+		if err != nil {
+			t.Fatal("\nDispatcher Failed\n    Error: ", err)
+		}
+	}
+
+	now := time.Now().Truncate(time.Second)
+	insert("oldest", now.Add(-time.Minute*3))
+	insert("older", now.Add(-time.Minute*2))
+	insert("newest", now.Add(-time.Minute))
+
+	callerName := "TestDispatcher_SendDigests"
+
+	// the digest is capped at the newest notifications
+	sent, err := dispatcher.SendDigests(context.TODO(), nil, &callerName, now, 100)
+	if err != nil || sent != 1 {
+		t.Fatalf("\nDispatcher Failed\n    Error: wrong number of digests %d %v", sent, err)
+	}
+	digest := channel.sent()[0]
+	if len(digest.Notifications) != 2 || digest.Notifications[0].Message != "newest" || digest.Notifications[1].Message != "older" {
+		t.Fatalf("\nDispatcher Failed\n    Error: wrong digest %+v", digest.Notifications)
+	}
+
+	// notifications that were already in a digest are not sent again
+	later := now.Add(time.Hour * 2)
+	sent, err = dispatcher.SendDigests(context.TODO(), nil, &callerName, later, 100)
+	if err != nil || sent != 0 {
+		t.Fatalf("\nDispatcher Failed\n    Error: digest was sent again %d %v", sent, err)
+	}
+
+	insert("new", later.Add(time.Minute))
+	sent, err = dispatcher.SendDigests(context.TODO(), nil, &callerName, later.Add(time.Hour*2), 100)
+	if err != nil || sent != 1 {
+		t.Fatalf("\nDispatcher Failed\n    Error: wrong number of digests %d %v", sent, err)
+	}
+	digest = channel.sent()[1]
+	if len(digest.Notifications) != 1 || digest.Notifications[0].Message != "new" {
+		t.Fatalf("\nDispatcher Failed\n    Error: wrong digest %+v", digest.Notifications)
+	}
+
+	t.Log("\nDispatcher Succeeded")
+}
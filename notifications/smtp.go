@@ -0,0 +1,143 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/gage-technologies/gigo-lib/config"
+)
+
+// SMTPChannel
+//
+//	Delivers messages by email through an SMTP server. Messages are sent
+//	as multipart/alternative with a plain text and an html part.
+type SMTPChannel struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from mail.Address
+}
+
+// NewSMTPChannel
+//
+//	Creates a new SMTPChannel from the SMTP config. Authentication is only
+//	used when a username is configured.
+func NewSMTPChannel(cfg config.SMTPConfig) (*SMTPChannel, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("smtp channel requires a host")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse smtp from address: %v", err)
+	}
+	if cfg.FromName != "" {
+		from.Name = cfg.FromName
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	return &SMTPChannel{
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		host: cfg.Host,
+		auth: auth,
+		from: *from,
+	}, nil
+}
+
+func (c *SMTPChannel) Name() string {
+	return "smtp"
+}
+
+// Send
+//
+//	Sends the message to the recipient's email address. The context is
+//	only checked before sending since net/smtp does not support
+//	cancellation.
+func (c *SMTPChannel) Send(ctx context.Context, msg *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if msg.Recipient.Email == "" {
+		return fmt.Errorf("recipient %d does not have an email address", msg.Recipient.UserID)
+	}
+
+	to := mail.Address{Name: msg.Recipient.UserName, Address: msg.Recipient.Email}
+	body, err := c.render(to, msg)
+	if err != nil {
+		return err
+	}
+
+	err = smtp.SendMail(c.addr, c.auth, c.from.Address, []string{to.Address}, body)
+	if err != nil {
+		return fmt.Errorf("failed to send email to %d: %v", msg.Recipient.UserID, err)
+	}
+
+	return nil
+}
+
+// render
+//
+//	Renders the message into an RFC 5322 email
+func (c *SMTPChannel) render(to mail.Address, msg *Message) ([]byte, error) {
+	boundaryBytes := make([]byte, 12)
+	_, err := rand.Read(boundaryBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate mime boundary: %v", err)
+	}
+	boundary := "gigo-" + hex.EncodeToString(boundaryBytes)
+
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "From: %s\r\n", c.from.String())
+	fmt.Fprintf(buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(boundaryBytes), c.host)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	}
+	for _, part := range parts {
+		if part.body == "" {
+			continue
+		}
+		fmt.Fprintf(buf, "--%s\r\n", boundary)
+		fmt.Fprintf(buf, "Content-Type: %s; charset=utf-8\r\n", part.contentType)
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		writer := quotedprintable.NewWriter(buf)
+		_, err = writer.Write([]byte(part.body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode email body: %v", err)
+		}
+		err = writer.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode email body: %v", err)
+		}
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,190 @@
+package notifications
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gage-technologies/gigo-lib/config"
+)
+
+// testSMTPServer
+//
+//	Minimal SMTP server that records the messages it receives
+type testSMTPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []testSMTPMessage
+}
+
+type testSMTPMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+func newTestSMTPServer(t *testing.T) *testSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("\nSMTP Server Failed\n    Error: ", err)
+	}
+
+	server := &testSMTPServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.handle(conn)
+		}
+	}()
+
+	return server
+}
+
+func (s *testSMTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *testSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = fmt.Fprintf(conn, "%s\r\n", line)
+	}
+
+	reply("220 localhost ESMTP test")
+	msg := testSMTPMessage{}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			msg.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			msg.to = append(msg.to, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data := strings.Builder{}
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(dataLine, "."))
+			}
+			msg.data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			msg = testSMTPMessage{}
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPChannel_Send(t *testing.T) {
+	server := newTestSMTPServer(t)
+	defer server.listener.Close()
+
+	channel, err := NewSMTPChannel(config.SMTPConfig{
+		Host:     "127.0.0.1",
+		Port:     server.port(),
+		From:     "noreply@gigo.dev",
+		FromName: "GIGO",
+	})
+	if err != nil {
+		t.Fatal("\nSMTP Send Failed\n    Error: ", err)
+	}
+
+	err = channel.Send(context.TODO(), &Message{
+		Recipient: Recipient{UserID: 69, UserName: "test", Email: "test@gigo.dev"},
+		Subject:   "Your digest",
+		Text:      "plain body",
+		HTML:      "<p>html body</p>",
+	})
+	if err != nil {
+		t.Fatal("\nSMTP Send Failed\n    Error: ", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.messages) != 1 {
+		t.Fatalf("\nSMTP Send Failed\n    Error: expected 1 message, got %d", len(server.messages))
+	}
+
+	received := server.messages[0]
+	if received.from != "noreply@gigo.dev" || len(received.to) != 1 || received.to[0] != "test@gigo.dev" {
+		t.Fatalf("\nSMTP Send Failed\n    Error: wrong envelope %+v", received)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(received.data))
+	if err != nil {
+		t.Fatal("\nSMTP Send Failed\n    Error: ", err)
+	}
+	if parsed.Header.Get("Subject") != "Your digest" {
+		t.Fatalf("\nSMTP Send Failed\n    Error: wrong subject %q", parsed.Header.Get("Subject"))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("\nSMTP Send Failed\n    Error: wrong content type %q %v", mediaType, err)
+	}
+
+	bodies := make(map[string]string)
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("\nSMTP Send Failed\n    Error: ", err)
+		}
+		buf, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal("\nSMTP Send Failed\n    Error: ", err)
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		bodies[contentType] = string(buf)
+	}
+
+	if bodies["text/plain"] != "plain body" || bodies["text/html"] != "<p>html body</p>" {
+		t.Fatalf("\nSMTP Send Failed\n    Error: wrong bodies %+v", bodies)
+	}
+
+	// recipients without an address are rejected before connecting
+	err = channel.Send(context.TODO(), &Message{Recipient: Recipient{UserID: 420}})
+	if err == nil {
+		t.Fatal("\nSMTP Send Failed\n    Error: sent to a recipient without an address")
+	}
+
+	t.Log("\nSMTP Send Succeeded")
+}
@@ -0,0 +1,354 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/gage-technologies/gigo-lib/config"
+	"github.com/nats-io/nats.go"
+)
+
+// JetstreamObjectStorage
+//
+//	Implementation of the Storage interface backed by a Jetstream object
+//	store. Intended for small deployments that already run Jetstream and
+//	do not want to operate a separate object storage system.
+//
+//	Object stores have a flat namespace so directories are emulated using
+//	'/' separated object names in the same way as S3 compliant systems.
+type JetstreamObjectStorage struct {
+	Storage
+	store  nats.ObjectStore
+	config config.StorageJetstreamConfig
+}
+
+// CreateJetstreamObjectStorage
+//
+//	Creates a new JetstreamObjectStorage including creating the configured
+//	object store bucket if it doesn't already exist.
+func CreateJetstreamObjectStorage(js nats.ObjectStoreManager, config config.StorageJetstreamConfig) (*JetstreamObjectStorage, error) {
+	// attempt to bind to an existing object store
+	store, err := js.ObjectStore(config.Bucket)
+	if err != nil {
+		if !errors.Is(err, nats.ErrStreamNotFound) && !errors.Is(err, nats.ErrBucketNotFound) {
+			return nil, fmt.Errorf("failed to check if object store exists: %v", err)
+		}
+
+		// create object store since it doesn't exist
+		store, err = js.CreateObjectStore(&nats.ObjectStoreConfig{
+			Bucket:   config.Bucket,
+			MaxBytes: config.MaxBytes,
+			Replicas: config.Replicas,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create object store: %v", err)
+		}
+	}
+
+	return &JetstreamObjectStorage{
+		store:  store,
+		config: config,
+	}, nil
+}
+
+// GetFile
+//
+//			Returns a file from the configured bucket.
+//	     Returns nil if the file does not exist.
+//
+//			Args:
+//		       - path (string): The path of the file to retrieve.
+//
+//		 Returns:
+//		       - (io.ReadCloser): The contents of the file.
+func (s *JetstreamObjectStorage) GetFile(path string) (io.ReadCloser, error) {
+	obj, err := s.store.Get(path)
+	if err != nil {
+		if errors.Is(err, nats.ErrObjectNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get object: %v", err)
+	}
+	return obj, nil
+}
+
+// CreateFile
+//
+//	Creates a new file in the configured bucket.
+//
+//	Args:
+//	   - path (string): The path of the file to create.
+//	   - contents ([]byte): The contents of the file.
+func (s *JetstreamObjectStorage) CreateFile(path string, contents []byte) error {
+	_, err := s.store.PutBytes(path, contents)
+	if err != nil {
+		return fmt.Errorf("failed to put object: %v", err)
+	}
+	return nil
+}
+
+// CreateFileStreamed
+//
+//	  Creates a new file in the configured bucket reading from an io.ReadCloser.
+//
+//	Args:
+//	      - path (string): The path of the file to create.
+//		  - length (int64): The size in bytes of the contents.
+//	      - contents (io.ReadCloser): The contents of the file.
+func (s *JetstreamObjectStorage) CreateFileStreamed(path string, length int64, contents io.ReadCloser) error {
+	defer contents.Close()
+	_, err := s.store.Put(&nats.ObjectMeta{Name: path}, io.LimitReader(contents, length))
+	if err != nil {
+		return fmt.Errorf("failed to put object: %v", err)
+	}
+	return nil
+}
+
+// DeleteFile
+//
+//	    Deletes a file from the configured bucket.
+//
+//	Args:
+//	       - path (string): The path of the file to delete.
+func (s *JetstreamObjectStorage) DeleteFile(path string) error {
+	err := s.store.Delete(path)
+	if err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+		return fmt.Errorf("failed to delete object: %v", err)
+	}
+	return nil
+}
+
+// MoveFile
+//
+//	    Moves a file within the configured bucket.
+//
+//	Args:
+//	       - src (string): The path of the file to move.
+//	       - dst (string): The new path of the file.
+func (s *JetstreamObjectStorage) MoveFile(src, dst string) error {
+	// copy the file to the new destination
+	err := s.CopyFile(src, dst)
+	if err != nil {
+		return fmt.Errorf("failed to copy object: %v", err)
+	}
+
+	// delete the source file
+	err = s.store.Delete(src)
+	if err != nil {
+		return fmt.Errorf("failed to delete source object: %v", err)
+	}
+
+	return nil
+}
+
+// CopyFile
+//
+//	    Copies a file within the configured bucket.
+//
+//	Args:
+//	       - src (string): The path of the file to copy.
+//	       - dst (string): The new path of the file.
+func (s *JetstreamObjectStorage) CopyFile(src, dst string) error {
+	// open the source object
+	obj, err := s.store.Get(src)
+	if err != nil {
+		return fmt.Errorf("failed to get source object: %v", err)
+	}
+	defer obj.Close()
+
+	// stream the source object into the destination
+	_, err = s.store.Put(&nats.ObjectMeta{Name: dst}, obj)
+	if err != nil {
+		return fmt.Errorf("failed to put destination object: %v", err)
+	}
+
+	return nil
+}
+
+// MergeFiles
+//
+//	    Merges multiple files within the configured bucket.
+//
+//	Args:
+//	       - dst (string): The path of the merged file in the configured bucket.
+//	       - paths ([]string): The paths of the files to merge in order of merge.
+//	       - smallFiles (bool): This parameter is a no-op in this implementation and only used
+//	                            for compatibility with the Storage interface
+func (s *JetstreamObjectStorage) MergeFiles(dst string, paths []string, smallFiles bool) error {
+	// open all source objects
+	readers := make([]io.Reader, 0, len(paths))
+	for _, path := range paths {
+		obj, err := s.store.Get(path)
+		if err != nil {
+			closeReaders(readers)
+			return fmt.Errorf("failed to get object %s: %v", path, err)
+		}
+		readers = append(readers, obj)
+	}
+	defer closeReaders(readers)
+
+	// stream the concatenated objects into the destination
+	_, err := s.store.Put(&nats.ObjectMeta{Name: dst}, io.MultiReader(readers...))
+	if err != nil {
+		return fmt.Errorf("failed to put merged object: %v", err)
+	}
+
+	return nil
+}
+
+// closeReaders
+//
+//	Helper function to close every reader that implements io.Closer
+func closeReaders(readers []io.Reader) {
+	for _, r := range readers {
+		if c, ok := r.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}
+}
+
+// Exists
+//
+//	   Checks whether the path exists in the configured bucket
+//	   and returns what type of path it is (file, directory, symlink, etc.).
+//
+//	Args:
+//	    - path (string): The path of the file to check.
+//
+//	Returns:
+//	    - (bool): Whether the path exists or not.
+//	    - (string): Path type
+func (s *JetstreamObjectStorage) Exists(path string) (bool, string, error) {
+	_, err := s.store.GetInfo(path)
+	if err == nil {
+		return true, "file", nil
+	}
+	if !errors.Is(err, nats.ErrObjectNotFound) {
+		return false, "", fmt.Errorf("failed to stat object: %v", err)
+	}
+
+	// check if the path is the prefix of any object
+	names, err := s.listNames()
+	if err != nil {
+		return false, "", err
+	}
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			return true, "dir", nil
+		}
+	}
+
+	return false, "", nil
+}
+
+// CreateDir
+//
+//	    Creates a new directory in the configured bucket. Object stores
+//	    do not have directories so this is a no-op.
+//
+//	Args:
+//	       - path (string): The path of the directory to create.
+func (s *JetstreamObjectStorage) CreateDir(path string) error {
+	return nil
+}
+
+// ListDir
+//
+//		       Lists the contents of a directory in the configured bucket.
+//
+//		   Args:
+//		        - path (string): The path of the directory to list.
+//				- recursive (bool): Whether to list the directory recursively.
+//		   Returns:
+//	         - []string: The list of files in the directory.
+func (s *JetstreamObjectStorage) ListDir(path string, recursive bool) ([]string, error) {
+	names, err := s.listNames()
+	if err != nil {
+		return nil, err
+	}
+
+	// conditionally append final slash to path if it was not passed
+	if path != "" && !strings.HasSuffix(path, "/") {
+		path = path + "/"
+	}
+
+	contents := make([]string, 0)
+	seenDirs := make(map[string]bool)
+	for _, name := range names {
+		if !strings.HasPrefix(name, path) {
+			continue
+		}
+
+		// include every object under the prefix for recursive listings
+		rel := strings.TrimPrefix(name, path)
+		if recursive || !strings.Contains(rel, "/") {
+			contents = append(contents, name)
+			continue
+		}
+
+		// collapse nested objects into their top level directory
+		dir := path + rel[:strings.Index(rel, "/")+1]
+		if !seenDirs[dir] {
+			seenDirs[dir] = true
+			contents = append(contents, dir)
+		}
+	}
+
+	return contents, nil
+}
+
+// DeleteDir
+//
+//	    Deletes a directory in the configured bucket.
+//
+//	Args:
+//	       - path (string): The path of the directory to delete.
+//		   - recursive (bool): Whether to delete all subdirectories within the passed directory
+func (s *JetstreamObjectStorage) DeleteDir(path string, recursive bool) error {
+	contents, err := s.ListDir(path, recursive)
+	if err != nil {
+		return fmt.Errorf("failed to list directory: %v", err)
+	}
+
+	for _, name := range contents {
+		// skip nested directories for non-recursive deletes
+		if strings.HasSuffix(name, "/") {
+			continue
+		}
+
+		err = s.store.Delete(name)
+		if err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+			return fmt.Errorf("failed to delete object %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// listNames
+//
+//	Returns the sorted names of all objects in the store
+func (s *JetstreamObjectStorage) listNames() ([]string, error) {
+	infos, err := s.store.List()
+	if err != nil {
+		if errors.Is(err, nats.ErrNoObjectsFound) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to list objects: %v", err)
+	}
+
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if info.Deleted {
+			continue
+		}
+		names = append(names, info.Name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
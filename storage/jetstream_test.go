@@ -0,0 +1,162 @@
+package storage_test
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/gage-technologies/gigo-lib/config"
+	"github.com/gage-technologies/gigo-lib/mq/mqtest"
+	"github.com/gage-technologies/gigo-lib/storage"
+	"github.com/nats-io/nats.go"
+)
+
+func createTestJetstreamObjectStorage(t *testing.T) (*storage.JetstreamObjectStorage, func()) {
+	conn, err := nats.Connect(mqtest.RunJetstreamServer(t).Host)
+	if err != nil {
+		t.Fatalf("\nCreateJetstreamObjectStorage failed\n    Error: %v", err)
+	}
+
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		t.Fatalf("\nCreateJetstreamObjectStorage failed\n    Error: %v", err)
+	}
+
+	s, err := storage.CreateJetstreamObjectStorage(js, config.StorageJetstreamConfig{
+		Bucket: "gigo-test",
+	})
+	if err != nil {
+		conn.Close()
+		t.Fatalf("\nCreateJetstreamObjectStorage failed\n    Error: %v", err)
+	}
+
+	return s, func() {
+		_ = js.DeleteObjectStore("gigo-test")
+		conn.Close()
+	}
+}
+
+func TestJetstreamObjectStorage_CreateFile(t *testing.T) {
+	s, cleanup := createTestJetstreamObjectStorage(t)
+	defer cleanup()
+
+	err := s.CreateFile("create-test", []byte("create-test-file"))
+	if err != nil {
+		t.Fatalf("\nJetstreamObjectStorage_CreateFile failed\n    Error: %v", err)
+	}
+
+	file, err := s.GetFile("create-test")
+	if err != nil {
+		t.Fatalf("\nJetstreamObjectStorage_CreateFile failed\n    Error: %v", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("\nJetstreamObjectStorage_CreateFile failed\n    Error: %v", err)
+	}
+
+	if string(data) != "create-test-file" {
+		t.Fatalf("\nJetstreamObjectStorage_CreateFile failed\n    Error: incorrect contents %q", string(data))
+	}
+
+	missing, err := s.GetFile("missing")
+	if err != nil {
+		t.Fatalf("\nJetstreamObjectStorage_CreateFile failed\n    Error: %v", err)
+	}
+	if missing != nil {
+		t.Fatalf("\nJetstreamObjectStorage_CreateFile failed\n    Error: missing file should be nil")
+	}
+
+	t.Log("\nJetstreamObjectStorage_CreateFile succeeded")
+}
+
+func TestJetstreamObjectStorage_MergeFiles(t *testing.T) {
+	s, cleanup := createTestJetstreamObjectStorage(t)
+	defer cleanup()
+
+	for _, part := range []string{"1", "2", "3"} {
+		err := s.CreateFileStreamed("merge/part-"+part, 1, io.NopCloser(bytes.NewBufferString(part)))
+		if err != nil {
+			t.Fatalf("\nJetstreamObjectStorage_MergeFiles failed\n    Error: %v", err)
+		}
+	}
+
+	err := s.MergeFiles("merged", []string{"merge/part-1", "merge/part-2", "merge/part-3"}, false)
+	if err != nil {
+		t.Fatalf("\nJetstreamObjectStorage_MergeFiles failed\n    Error: %v", err)
+	}
+
+	file, err := s.GetFile("merged")
+	if err != nil {
+		t.Fatalf("\nJetstreamObjectStorage_MergeFiles failed\n    Error: %v", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("\nJetstreamObjectStorage_MergeFiles failed\n    Error: %v", err)
+	}
+
+	if string(data) != "123" {
+		t.Fatalf("\nJetstreamObjectStorage_MergeFiles failed\n    Error: incorrect contents %q", string(data))
+	}
+
+	t.Log("\nJetstreamObjectStorage_MergeFiles succeeded")
+}
+
+func TestJetstreamObjectStorage_ListDir(t *testing.T) {
+	s, cleanup := createTestJetstreamObjectStorage(t)
+	defer cleanup()
+
+	for _, path := range []string{"dir/a", "dir/b", "dir/sub/c", "other"} {
+		err := s.CreateFile(path, []byte(path))
+		if err != nil {
+			t.Fatalf("\nJetstreamObjectStorage_ListDir failed\n    Error: %v", err)
+		}
+	}
+
+	contents, err := s.ListDir("dir", false)
+	if err != nil {
+		t.Fatalf("\nJetstreamObjectStorage_ListDir failed\n    Error: %v", err)
+	}
+
+	if !reflect.DeepEqual(contents, []string{"dir/a", "dir/b", "dir/sub/"}) {
+		t.Fatalf("\nJetstreamObjectStorage_ListDir failed\n    Error: incorrect contents %v", contents)
+	}
+
+	contents, err = s.ListDir("dir", true)
+	if err != nil {
+		t.Fatalf("\nJetstreamObjectStorage_ListDir failed\n    Error: %v", err)
+	}
+
+	if !reflect.DeepEqual(contents, []string{"dir/a", "dir/b", "dir/sub/c"}) {
+		t.Fatalf("\nJetstreamObjectStorage_ListDir failed\n    Error: incorrect contents %v", contents)
+	}
+
+	exists, pathType, err := s.Exists("dir/sub")
+	if err != nil {
+		t.Fatalf("\nJetstreamObjectStorage_ListDir failed\n    Error: %v", err)
+	}
+	if !exists || pathType != "dir" {
+		t.Fatalf("\nJetstreamObjectStorage_ListDir failed\n    Error: incorrect exists result %v %s", exists, pathType)
+	}
+
+	err = s.DeleteDir("dir", false)
+	if err != nil {
+		t.Fatalf("\nJetstreamObjectStorage_ListDir failed\n    Error: %v", err)
+	}
+
+	contents, err = s.ListDir("dir", true)
+	if err != nil {
+		t.Fatalf("\nJetstreamObjectStorage_ListDir failed\n    Error: %v", err)
+	}
+
+	if !reflect.DeepEqual(contents, []string{"dir/sub/c"}) {
+		t.Fatalf("\nJetstreamObjectStorage_ListDir failed\n    Error: incorrect contents after delete %v", contents)
+	}
+
+	t.Log("\nJetstreamObjectStorage_ListDir succeeded")
+}